@@ -0,0 +1,67 @@
+package rhmap
+
+import "slices"
+
+// Depth of the frequency sketch of WithAdmission, and its counters per
+// element of the bound
+const (
+	admissionDepth = 4
+	admissionWidth = 4
+)
+
+// Makes a map bounded by WithMaxEntries admit a new key into a full map only
+// if it has been asked for more often than the element that would be
+// evicted for it, as TinyLFU does, so that keys seen once can't flush out
+// the keys a skewed workload keeps coming back to. Frequencies are
+// estimated from every Get and every insert attempt by a Count-Min sketch
+// of 16 bytes per element of the bound, halved every ten times the bound
+// so that they follow recent traffic. Turned-away keys count as rejected.
+// It only applies under an eviction policy that evicts, and turns Get into
+// a write as EvictLRU does.
+func WithAdmission() Option {
+	return func(o *options) {
+		o.admission = true
+	}
+}
+
+// TinyLFU frequency filter of a bounded map
+type admission[K comparable] struct {
+	sketch CountMinSketch[K]
+	// Accesses recorded since the sketch was last halved, and how many it
+	// takes to halve it
+	samples uint64
+	period  uint64
+}
+
+func newAdmission[K comparable](maxEntries uint64) *admission[K] {
+	width := max(1024, admissionWidth*maxEntries)
+	return &admission[K]{
+		sketch: CountMinSketch[K]{
+			counters: make([]uint32, width*admissionDepth),
+			width:    width,
+			depth:    admissionDepth,
+		},
+		period: 10 * maxEntries,
+	}
+}
+
+// Counts an access to the key of hash
+func (a *admission[K]) record(hash uint64) {
+	a.sketch.addHash(hash, 1)
+	a.samples++
+	if a.samples >= a.period {
+		a.sketch.Halve()
+		a.samples = 0
+	}
+}
+
+// Reports whether a key of hash should replace the victim of victimHash
+func (a *admission[K]) admits(hash, victimHash uint64) bool {
+	return a.sketch.estimateHash(hash) > a.sketch.estimateHash(victimHash)
+}
+
+func (a *admission[K]) clone() *admission[K] {
+	c := *a
+	c.sketch.counters = slices.Clone(a.sketch.counters)
+	return &c
+}
@@ -0,0 +1,57 @@
+package rhmap
+
+import "testing"
+
+func TestWithAdmission(t *testing.T) {
+	m := must(New[int, int](WithMaxEntries(100), WithEvictionPolicy(EvictLRU), WithAdmission()))
+	for round := 0; round < 5; round++ {
+		for k := 0; k < 100; k++ {
+			m.Set(k, k)
+			m.Get(k)
+		}
+	}
+
+	// A scan of keys seen once shouldn't flush out the hot ones. A scanned
+	// key colliding with a hot one in every row of the sketch may still get
+	// in.
+	for k := 1000; k < 2000; k++ {
+		m.Set(k, k)
+	}
+	kept := 0
+	for k := 0; k < 100; k++ {
+		if _, ok := m.Get(k); ok {
+			kept++
+		}
+	}
+	if s := m.Stats(); kept < 95 || s.Rejected+s.Evictions != 1000 {
+		t.Errorf("Expected most hot keys kept and the scan rejected, Got %d kept, %d rejected and %d evictions", kept, s.Rejected, s.Evictions)
+	}
+
+	// A key asked for often enough earns its way in
+	for i := 0; i < 20; i++ {
+		m.Get(5000)
+	}
+	m.Set(5000, 0)
+	if _, ok := m.Get(5000); !ok || m.Len() != 100 {
+		t.Errorf("A frequently requested key should be admitted.")
+	}
+
+	c := m.Clone()
+	for i := 0; i < 20; i++ {
+		c.Get(6000)
+	}
+	m.Set(6000, 0)
+	if _, ok := m.Get(6000); ok {
+		t.Errorf("A clone should count frequencies on its own.")
+	}
+}
+
+func TestWithAdmissionUnbounded(t *testing.T) {
+	m := must(New[int, int](WithAdmission()))
+	for k := 0; k < 1000; k++ {
+		m.Set(k, k)
+	}
+	if m.Len() != 1000 || m.admission != nil {
+		t.Errorf("Admission should only apply to bounded maps.")
+	}
+}
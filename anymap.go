@@ -0,0 +1,18 @@
+package rhmap
+
+// Map of heterogeneous values, for config and registry use where each key
+// holds a value of its own type. Read values back with GetAs. For keys whose
+// type isn't known at compile time, see DynamicMap.
+type AnyMap[K comparable] = Map[K, any]
+
+// Returns the value under key as a T. It reports false if the key is
+// missing or its value is not a T.
+func GetAs[T any, K comparable](m *AnyMap[K], key K) (T, bool) {
+	val, ok := m.Get(key)
+	if !ok {
+		var zeroVal T
+		return zeroVal, false
+	}
+	t, ok := val.(T)
+	return t, ok
+}
@@ -0,0 +1,30 @@
+package rhmap
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestGetAs(t *testing.T) {
+	var m *AnyMap[string] = must(New[string, any]())
+	m.Set("port", 8080)
+	m.Set("timeout", 5*time.Second)
+	m.Set("out", io.Discard)
+
+	if port, ok := GetAs[int](m, "port"); !ok || port != 8080 {
+		t.Errorf("port should be the int 8080. Got %d, %t", port, ok)
+	}
+	if timeout, ok := GetAs[time.Duration](m, "timeout"); !ok || timeout != 5*time.Second {
+		t.Errorf("timeout should be 5s. Got %v, %t", timeout, ok)
+	}
+	if _, ok := GetAs[io.Writer](m, "out"); !ok {
+		t.Errorf("GetAs should assert to interface types.")
+	}
+	if port, ok := GetAs[string](m, "port"); ok || port != "" {
+		t.Errorf("A value of another type should not be returned. Got %q, %t", port, ok)
+	}
+	if _, ok := GetAs[int](m, "missing"); ok {
+		t.Errorf("A missing key should not be found.")
+	}
+}
@@ -0,0 +1,80 @@
+package rhmap
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// Allocates the map's tables from memory mapped outside the Go heap, so
+// that a table of many gigabytes neither counts toward the heap goal nor
+// costs the garbage collector anything to track. It only takes effect when
+// neither K nor V holds pointers, since the collector must see every
+// pointer; other maps stay on the heap, as do all maps on platforms without
+// anonymous memory mappings.
+//
+// The collector never frees an arena table, so every map using one must be
+// released with Free, and each clone and snapshot along with it: a table
+// they share is unmapped when the last of them is freed. Wrappers pass the
+// option to their tables, but as only a Map can be freed, it suits them
+// only for tables that live as long as the process.
+func WithArena() Option {
+	return func(o *options) {
+		o.arena = true
+	}
+}
+
+// Memory mapped for an arena table and the number of maps and snapshot
+// iterators sharing it
+type arenaBlock struct {
+	data []byte
+	refs atomic.Int32
+}
+
+// Adds a reference to the block, if any
+func (b *arenaBlock) retain() {
+	if b != nil {
+		b.refs.Add(1)
+	}
+}
+
+// Drops a reference to the block, if any, unmapping it once no map shares
+// it
+func (b *arenaBlock) release() {
+	if b != nil && b.refs.Add(-1) == 0 {
+		unmapArena(b.data)
+	}
+}
+
+// Replaces the map's table with a zeroed one of n slots, mapped from an
+// arena if the map uses one and memory can be mapped. The caller releases
+// the old table's block.
+func (m *Map[K, V]) allocElements(n uint64) {
+	m.block = nil
+	if m.arena && n > 0 {
+		if data, err := mapArena(int(n * uint64(unsafe.Sizeof(element[K, V]{})))); err == nil {
+			m.block = &arenaBlock{data: data}
+			m.block.refs.Store(1)
+			m.elements = unsafe.Slice((*element[K, V])(unsafe.Pointer(&data[0])), n)
+			return
+		}
+	}
+	m.elements = make([]element[K, V], n)
+}
+
+// Unmaps the tables of a WithArena map now, leaving it empty. The map must
+// not be used afterward, nor any iterator over it; clones and snapshots
+// that share its table keep it mapped until they are freed in turn. Free
+// does nothing to maps on the heap.
+func (m *Map[K, V]) Free() {
+	if !m.arena {
+		return
+	}
+	m.block.release()
+	if m.draining != nil {
+		m.draining.block.release()
+	}
+	m.block, m.elements, m.ctrl, m.draining = nil, nil, nil, nil
+	m.numElements, m.totalPsl, m.maxPsl, m.maxFreq = 0, 0, 0, 0
+	m.layout++
+	m.version++
+}
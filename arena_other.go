@@ -0,0 +1,13 @@
+//go:build !unix
+
+package rhmap
+
+import "errors"
+
+func mapArena(size int) ([]byte, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func unmapArena(data []byte) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,110 @@
+package rhmap
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestArenaOnlyForPointerFreeTypes(t *testing.T) {
+	if m := must(New[int, [4]int64](WithArena())); !m.Config().Arena || m.block == nil {
+		t.Errorf("A map of pointer-free types should be mapped from an arena.")
+	} else {
+		m.Free()
+	}
+	if m := must(New[string, int](WithArena())); m.Config().Arena || m.block != nil {
+		t.Errorf("A map with string keys should stay on the heap.")
+	}
+	if m := must(New[int, int]()); m.Config().Arena {
+		t.Errorf("Maps should stay on the heap without WithArena.")
+	}
+}
+
+func TestArenaKeepsTableOffHeap(t *testing.T) {
+	const n = 1 << 20
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	m := must(New[int64, int64](WithArena(), WithSize(n)))
+	runtime.ReadMemStats(&after)
+	defer m.Free()
+
+	table := EstimateMemory[int64, int64](n)
+	if grown := after.HeapAlloc - before.HeapAlloc; grown > table/8 {
+		t.Errorf("A %d-byte arena table should stay off the heap. Got the heap grown by %d bytes", table, grown)
+	}
+}
+
+func TestArenaOperations(t *testing.T) {
+	for _, opts := range [][]Option{{WithArena()}, {WithArena(), WithIncrementalRehash(4), WithShrink(.2)}} {
+		m := must(New[int, int](opts...))
+		for i := 0; i < 5000; i++ {
+			m.Set(i, i)
+		}
+		for i := 0; i < 5000; i += 2 {
+			m.Delete(i)
+		}
+		if m.Len() != 2500 {
+			t.Errorf("Expected 2500 elements, Got %d", m.Len())
+		}
+		for i := 1; i < 5000; i += 2 {
+			if v, ok := m.Get(i); !ok || v != i {
+				t.Fatalf("Expected %d true, Got %d %v", i, v, ok)
+			}
+		}
+		m.ShrinkToFit()
+		m.Clear()
+		m.Set(1, 1)
+		if v, _ := m.Get(1); v != 1 || m.Len() != 1 {
+			t.Errorf("A cleared arena map should take new elements.")
+		}
+		m.Free()
+	}
+}
+
+func TestArenaSharing(t *testing.T) {
+	m := must(New[int, int](WithArena(), WithIncrementalRehash(1)))
+	for i := 0; i < 100; i++ {
+		m.Set(i, i)
+	}
+
+	clone := m.Clone()
+	snapshot := m.Snapshot()
+	iter := m.SnapshotIter()
+	shared := m.block
+	for i := 0; i < 100; i++ {
+		m.Set(i, -i)
+	}
+	m.Free()
+
+	for i := 0; i < 100; i++ {
+		if v, _ := clone.Get(i); v != i {
+			t.Fatalf("A clone should keep its table after the map is freed. Expected %d, Got %d", i, v)
+		}
+		if v, _ := snapshot.Get(i); v != i {
+			t.Fatalf("A snapshot should keep its table after the map is freed. Expected %d, Got %d", i, v)
+		}
+	}
+	clone.Set(0, 1)
+	clone.Free()
+	if v, _ := snapshot.Get(0); v != 0 {
+		t.Errorf("A snapshot should keep its table after a clone is freed. Expected 0, Got %d", v)
+	}
+	snapshot.Free()
+
+	count := 0
+	for k, v := range iter {
+		if k != v {
+			t.Fatalf("A snapshot iterator should see the map as it was. Expected %d, Got %d", k, v)
+		}
+		count++
+	}
+	if count != 100 {
+		t.Errorf("Expected 100 elements, Got %d", count)
+	}
+	for range iter {
+		t.Fatalf("A spent arena snapshot iterator should yield nothing.")
+	}
+	if refs := shared.refs.Load(); refs != 0 {
+		t.Errorf("The shared table should be unmapped once every holder is done. Got %d references left", refs)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build unix
+
+package rhmap
+
+import "syscall"
+
+// Maps size bytes of zeroed anonymous memory outside the Go heap
+func mapArena(size int) ([]byte, error) {
+	return syscall.Mmap(-1, 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+}
+
+func unmapArena(data []byte) error {
+	return syscall.Munmap(data)
+}
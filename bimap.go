@@ -0,0 +1,95 @@
+package rhmap
+
+import "iter"
+
+// Robin hood hashmap kept in lockstep with its inverse, so that elements can
+// be looked up by key or by value. Keys and values are both unique: setting
+// a pair replaces any pair holding the same key or the same value. Each
+// operation hashes a key and a value at most once, and updates both tables
+// before returning.
+type BiMap[K, V comparable] struct {
+	forward *Map[K, V]
+	reverse *Map[V, K]
+	onEvict func(K, V)
+}
+
+// Creates a bidirectional map whose tables are both configured by opts;
+// WithZeroDeletes has no effect. WithOnEvict is called with pairs that are
+// deleted or displaced by a Set of their value under another key. It returns
+// an error if K or V can't be encoded, as New does.
+func NewBiMap[K, V comparable](opts ...Option) (*BiMap[K, V], error) {
+	forward, err := New[K, V](opts...)
+	if err != nil {
+		return nil, err
+	}
+	reverse, err := New[V, K](opts...)
+	if err != nil {
+		return nil, err
+	}
+	forward.zeroDeletes, reverse.zeroDeletes = false, false
+	forward.onEvict, reverse.onEvict = nil, nil
+	return &BiMap[K, V]{forward: forward, reverse: reverse, onEvict: evictHook[K, V](resolveOptions(opts))}, nil
+}
+
+// Pairs key with value, unpairing key from its old value and value from its
+// old key
+func (b *BiMap[K, V]) Set(key K, value V) {
+	keyHash, valueHash := b.forward.hashKey(key), b.reverse.hashKey(value)
+	oldValue, hadKey, _ := b.forward.getWithHash(key, keyHash)
+	if hadKey && oldValue == value {
+		return
+	}
+	oldKey, hadValue, _ := b.reverse.getWithHash(value, valueHash)
+
+	if hadKey {
+		b.reverse.deleteWithHash(oldValue, b.reverse.hashKey(oldValue))
+	}
+	if hadValue {
+		b.forward.deleteWithHash(oldKey, b.forward.hashKey(oldKey))
+	}
+	b.forward.setWithHash(key, value, keyHash)
+	b.reverse.setWithHash(value, key, valueHash)
+	if hadValue && b.onEvict != nil {
+		b.onEvict(oldKey, value)
+	}
+}
+
+// Returns the value paired with key
+func (b *BiMap[K, V]) GetByKey(key K) (V, bool) {
+	return b.forward.Get(key)
+}
+
+// Returns the key paired with value
+func (b *BiMap[K, V]) GetByValue(value V) (K, bool) {
+	return b.reverse.Get(value)
+}
+
+// Deletes key and the value paired with it
+func (b *BiMap[K, V]) DeleteByKey(key K) {
+	if value, ok := b.forward.GetAndDelete(key); ok {
+		b.reverse.GetAndDelete(value)
+		if b.onEvict != nil {
+			b.onEvict(key, value)
+		}
+	}
+}
+
+// Deletes value and the key paired with it
+func (b *BiMap[K, V]) DeleteByValue(value V) {
+	if key, ok := b.reverse.GetAndDelete(value); ok {
+		b.forward.GetAndDelete(key)
+		if b.onEvict != nil {
+			b.onEvict(key, value)
+		}
+	}
+}
+
+func (b *BiMap[K, V]) Len() uint64 {
+	return b.forward.Len()
+}
+
+// Iterates over every pair. The map must not be modified while the
+// iteration is in progress.
+func (b *BiMap[K, V]) All() iter.Seq2[K, V] {
+	return b.forward.All()
+}
@@ -0,0 +1,56 @@
+package rhmap
+
+import "testing"
+
+func TestBiMap(t *testing.T) {
+	var evicted []Entry[string, int]
+	b := must(NewBiMap[string, int](WithOnEvict(func(k string, v int) { evicted = append(evicted, Entry[string, int]{k, v}) })))
+	b.Set("one", 1)
+	b.Set("two", 2)
+	b.Set("three", 3)
+
+	if val, ok := b.GetByKey("two"); !ok || val != 2 {
+		t.Errorf("GetByKey should find the paired value. Got %d, %t", val, ok)
+	}
+	if key, ok := b.GetByValue(3); !ok || key != "three" {
+		t.Errorf("GetByValue should find the paired key. Got %q, %t", key, ok)
+	}
+
+	// Rebinding a key frees its old value
+	b.Set("one", 10)
+	if _, ok := b.GetByValue(1); ok {
+		t.Errorf("A key's old value should be unpaired when the key is set again.")
+	}
+	// Binding a value to another key displaces its old key
+	b.Set("deux", 2)
+	if _, ok := b.GetByKey("two"); ok || b.Len() != 3 {
+		t.Errorf("A value's old key should be unpaired when the value is set again. Found %d pairs", b.Len())
+	}
+	if key, _ := b.GetByValue(2); key != "deux" {
+		t.Errorf("Value 2 should be paired with deux. Got %q", key)
+	}
+
+	b.DeleteByKey("three")
+	b.DeleteByValue(10)
+	b.DeleteByValue(99)
+	if b.Len() != 1 || b.forward.Len() != b.reverse.Len() {
+		t.Errorf("Deletes should remove pairs from both directions. Got %d and %d", b.forward.Len(), b.reverse.Len())
+	}
+	if _, ok := b.GetByValue(3); ok {
+		t.Errorf("Deleting by key should remove the value too.")
+	}
+	want := []Entry[string, int]{{"two", 2}, {"three", 3}, {"one", 10}}
+	if len(evicted) != len(want) {
+		t.Fatalf("Displaced and deleted pairs should be evicted. Got %v", evicted)
+	}
+	for i := range want {
+		if evicted[i] != want[i] {
+			t.Errorf("Eviction %d should be %v. Got %v", i, want[i], evicted[i])
+		}
+	}
+	for k, v := range b.All() {
+		if k != "deux" || v != 2 {
+			t.Errorf("Only deux should remain. Got %q, %d", k, v)
+		}
+	}
+}
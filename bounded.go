@@ -0,0 +1,152 @@
+package rhmap
+
+import "math/rand/v2"
+
+// What a map bounded by WithMaxEntries does with a new key once full
+type EvictionPolicy uint8
+
+const (
+	// Drop the new key, leaving the map as it was. This is the default.
+	RejectNew EvictionPolicy = iota
+	// Evict an element chosen at random
+	EvictRandom
+	// Evict the least recently used element, as read by Get or written
+	EvictLRU
+	// Evict the least frequently used element, counting its insert and every
+	// read and write since, and the least recently used of those tied
+	EvictLFU
+	// Evict the element inserted first, however often it was used since
+	EvictFIFO
+)
+
+// Bounds the map to n elements, so that it can serve as a cache within a
+// memory budget. Once the map is full, a new key is handled by the
+// eviction policy, RejectNew unless WithEvictionPolicy chooses another.
+// Evicted elements go to the WithOnEvict callback. Wrappers pass the bound
+// to each of their tables, except ConcurrentMap, which splits it evenly
+// among its shards.
+func WithMaxEntries(n uint64) Option {
+	return func(o *options) {
+		o.maxEntries = n
+	}
+}
+
+// Chooses what a map bounded by WithMaxEntries does with a new key once
+// full. EvictLRU, EvictLFU and EvictFIFO track their order in a second
+// table, which costs a second hash per insert and delete. EvictLRU and
+// EvictLFU also pay it per Get and write, and turn Get into a write: such
+// maps must not be read concurrently, though a ConcurrentMap takes its
+// write locks for them. WithEvictor plugs in other policies.
+func WithEvictionPolicy(p EvictionPolicy) Option {
+	return func(o *options) {
+		o.eviction = p
+	}
+}
+
+// Sets key to value and reports whether it was stored, which under
+// RejectNew fails for a new key while the map is full
+func (m *Map[K, V]) TrySet(key K, value V) bool {
+	rejected := m.rejected
+	m.Set(key, value)
+	return m.rejected == rejected
+}
+
+// Returns up to n elements of a bounded map, those its eviction policy would
+// evict last first, for warming a replacement cache or finding out why a
+// key was evicted. It returns nil unless the policy ranks its elements, as
+// EvictLRU, EvictLFU and EvictFIFO do. Neither it nor Coldest counts as a
+// use of the elements it returns.
+func (m *Map[K, V]) Hottest(n int) []Entry[K, V] {
+	return m.ranked(n, true)
+}
+
+// Returns up to n elements of a bounded map, those its eviction policy would
+// evict first first, or nil as Hottest does
+func (m *Map[K, V]) Coldest(n int) []Entry[K, V] {
+	return m.ranked(n, false)
+}
+
+func (m *Map[K, V]) ranked(n int, hottest bool) []Entry[K, V] {
+	r, ok := m.evictor.(rankingEvictor[K])
+	if !ok || n <= 0 {
+		return nil
+	}
+	keys := r.ranked(n, hottest)
+	entries := make([]Entry[K, V], 0, len(keys))
+	for _, key := range keys {
+		if val, ok, _ := m.getWithHash(key, m.hashKey(key)); ok {
+			entries = append(entries, Entry[K, V]{key, val})
+		}
+	}
+	return entries
+}
+
+// Makes room for a new key of hash in a bounded map, evicting an element if
+// the map is full and the policy, and admission filter if any, allow, and
+// reports whether the key may be inserted
+func (m *Map[K, V]) makeRoom(hash uint64) bool {
+	if m.admission != nil {
+		m.admission.record(hash)
+	}
+	if m.numElements < m.maxEntries {
+		return true
+	}
+	if m.evictor == nil && m.eviction != EvictRandom {
+		m.rejected++
+		return false
+	}
+	victim, victimHash, ok := m.nextVictim()
+	if !ok {
+		return false
+	}
+	if m.admission != nil && !m.admission.admits(hash, victimHash) {
+		m.rejected++
+		return false
+	}
+	m.evict(victim, victimHash)
+	return m.numElements < m.maxEntries
+}
+
+// Returns the key and hash of the first element at or after a random slot
+// of a non-empty table
+func (m *Map[K, V]) randomElement() (K, uint64) {
+	for _, elems := range m.tables() {
+		size := uint64(len(elems))
+		start := rand.Uint64N(size)
+		for j := uint64(0); j < size; j++ {
+			if e := &elems[(start+j)%size]; e.set {
+				return e.key, e.hash
+			}
+		}
+	}
+	var zeroKey K
+	return zeroKey, 0
+}
+
+// Tells the map's Evictor, if any, of a key just inserted
+func (m *Map[K, V]) inserted(key K) {
+	if m.evictor != nil {
+		m.evictor.OnInsert(key)
+	}
+}
+
+// Tells the map's Evictor, if any, of a key just read or overwritten
+func (m *Map[K, V]) accessed(key K) {
+	if m.evictor != nil {
+		m.evictor.OnAccess(key)
+	}
+}
+
+// Creates the list of an EvictLRU or EvictFIFO map of at most capacity
+// elements. Unlike NewLRU it reserves nothing up front, and it never evicts
+// on its own since the map removes keys before exceeding its bound.
+func newRecency[K comparable](enc keyEncoder[K], capacity uint64) *LRU[K, struct{}] {
+	table := newMap[K, uint32](enc)
+	table.zeroDeletes = false
+	c := &LRU[K, struct{}]{
+		table:    table,
+		capacity: int(min(capacity, 1<<31)),
+	}
+	c.entries.NewList()
+	return c
+}
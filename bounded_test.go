@@ -0,0 +1,277 @@
+package rhmap
+
+import "testing"
+
+func TestMaxEntriesRejectNew(t *testing.T) {
+	m := must(New[int, int](WithMaxEntries(3)))
+	for i := 0; i < 3; i++ {
+		if !m.TrySet(i, i) {
+			t.Errorf("TrySet should store keys while the map has room. Failed on %d", i)
+		}
+	}
+	if m.TrySet(3, 3) {
+		t.Errorf("TrySet should reject a new key once the map is full.")
+	}
+	if !m.TrySet(0, 10) {
+		t.Errorf("TrySet should update a present key of a full map.")
+	}
+	if v, ok := m.Get(0); !ok || v != 10 || m.Len() != 3 {
+		t.Errorf("Expected 0 -> 10 with 3 elements, Got %d, %t with %d", v, ok, m.Len())
+	}
+	if s := m.Stats(); s.Rejected != 1 || s.Evictions != 0 {
+		t.Errorf("Expected 1 rejection and no eviction, Got %d and %d", s.Rejected, s.Evictions)
+	}
+	if cfg := m.Config(); cfg.Eviction != "reject" || cfg.MaxElements != 3 {
+		t.Errorf("Config should report the bound and policy. Got %q with %d", cfg.Eviction, cfg.MaxElements)
+	}
+}
+
+func TestMaxEntriesEvictRandom(t *testing.T) {
+	var evicted int
+	m := must(New[int, int](WithMaxEntries(100), WithEvictionPolicy(EvictRandom),
+		WithOnEvict(func(int, int) { evicted++ })))
+	for i := 0; i < 1000; i++ {
+		m.Set(i, i)
+		if m.Len() > 100 {
+			t.Fatalf("The map should never exceed its bound. Got %d elements", m.Len())
+		}
+	}
+	if _, ok := m.Get(999); !ok {
+		t.Errorf("The key just set should be present.")
+	}
+	if evicted != 900 || m.Stats().Evictions != 900 {
+		t.Errorf("Expected 900 evictions, Got %d reported and %d counted", evicted, m.Stats().Evictions)
+	}
+}
+
+func TestMaxEntriesEvictLRU(t *testing.T) {
+	var evicted []string
+	m := must(New[string, int](WithMaxEntries(3), WithEvictionPolicy(EvictLRU),
+		WithOnEvict(func(k string, _ int) { evicted = append(evicted, k) })))
+	m.Set("a", 1)
+	m.Set("b", 2)
+	m.Set("c", 3)
+	m.Get("a")
+	m.Set("d", 4)
+	if _, ok := m.Get("b"); ok || len(evicted) != 1 || evicted[0] != "b" {
+		t.Errorf("The least recently used key should be evicted. Got %v", evicted)
+	}
+	m.Set("c", 30)
+	m.Delete("a")
+	m.Set("e", 5)
+	m.Set("f", 6)
+	if _, ok := m.Get("d"); ok || m.Len() != 3 {
+		t.Errorf("Updates should count as uses, deleted keys should not be evicted. Got %v", evicted)
+	}
+
+	c := m.Clone()
+	c.Set("g", 7)
+	if _, ok := m.Get("g"); ok || m.Len() != 3 || c.Len() != 3 {
+		t.Errorf("A clone should track recency on its own.")
+	}
+	m.Clear()
+	for _, k := range []string{"x", "y", "z", "w"} {
+		m.Set(k, 0)
+	}
+	if _, ok := m.Get("x"); ok || m.Len() != 3 {
+		t.Errorf("A cleared map should evict from its new keys only.")
+	}
+}
+
+func TestMaxEntriesEvictLFU(t *testing.T) {
+	var evicted []string
+	m := must(New[string, int](WithMaxEntries(3), WithEvictionPolicy(EvictLFU),
+		WithOnEvict(func(k string, _ int) { evicted = append(evicted, k) })))
+	m.Set("a", 1)
+	m.Set("b", 2)
+	m.Set("c", 3)
+	m.Get("a")
+	m.Get("a")
+	m.Get("c")
+	m.Set("d", 4)
+	if _, ok := m.Get("b"); ok || len(evicted) != 1 || evicted[0] != "b" {
+		t.Errorf("The least frequently used key should be evicted. Got %v", evicted)
+	}
+	m.Set("e", 5)
+	if _, ok := m.Get("d"); ok || len(evicted) != 2 {
+		t.Errorf("Among keys used as often, the least recently used should be evicted. Got %v", evicted)
+	}
+	m.Delete("a")
+	m.Set("f", 6)
+	if m.Len() != 3 || m.Stats().Evictions != 2 {
+		t.Errorf("A deleted key should make room without an eviction. Got %d with %d elements", m.Stats().Evictions, m.Len())
+	}
+	if cfg := m.Config(); cfg.Eviction != "lfu" {
+		t.Errorf("Config should report the policy. Got %q", cfg.Eviction)
+	}
+	if err := m.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMaxEntriesEvictFIFO(t *testing.T) {
+	m := must(New[int, int](WithMaxEntries(3), WithEvictionPolicy(EvictFIFO)))
+	for i := 0; i < 3; i++ {
+		m.Set(i, i)
+	}
+	m.Get(0)
+	m.Set(0, 10)
+	m.Set(3, 3)
+	if _, ok := m.Get(0); ok {
+		t.Errorf("The first key inserted should be evicted however often it was used.")
+	}
+	m.Clear()
+	for i := 10; i < 14; i++ {
+		m.Set(i, i)
+	}
+	if _, ok := m.Get(10); ok || m.Len() != 3 {
+		t.Errorf("A cleared map should evict from its new keys only.")
+	}
+}
+
+// Evictor preferring the largest key, as a policy outside the built-in ones
+type maxKeyEvictor struct {
+	keys map[int]bool
+}
+
+func (e *maxKeyEvictor) OnInsert(key int) { e.keys[key] = true }
+func (e *maxKeyEvictor) OnAccess(int)     {}
+func (e *maxKeyEvictor) OnRemove(key int) { delete(e.keys, key) }
+
+func (e *maxKeyEvictor) NextVictim() (int, bool) {
+	victim, ok := 0, false
+	for k := range e.keys {
+		if !ok || k > victim {
+			victim, ok = k, true
+		}
+	}
+	return victim, ok
+}
+
+func (e *maxKeyEvictor) Clone() Evictor[int] {
+	c := &maxKeyEvictor{keys: make(map[int]bool)}
+	for k := range e.keys {
+		c.keys[k] = true
+	}
+	return c
+}
+
+func TestWithEvictor(t *testing.T) {
+	newEvictor := func() Evictor[int] { return &maxKeyEvictor{keys: make(map[int]bool)} }
+	m := must(NewConcurrent[int, int](1, WithMaxEntries(3), WithEvictor(newEvictor)))
+	for _, k := range []int{5, 1, 9, 3} {
+		m.Set(k, k)
+	}
+	if _, ok := m.Get(9); ok || m.Len() != 3 {
+		t.Errorf("The evictor should choose the victim. Found %d elements", m.Len())
+	}
+	if cfg := m.Config(); cfg.Eviction != "custom" {
+		t.Errorf("Config should report a custom policy. Got %q", cfg.Eviction)
+	}
+
+	other := must(New[int, int](WithMaxEntries(1), WithEvictor(newEvictor)))
+	c := other.Clone()
+	other.Set(1, 1)
+	c.Set(2, 2)
+	c.Set(0, 0)
+	if _, ok := c.Get(0); !ok || other.Len() != 1 {
+		t.Errorf("A clone should evict with a copy of the evictor.")
+	}
+}
+
+// Evictor preferring the entry furthest from its home slot, and the largest
+// key of those tied, so that it picks the same victim whatever order it
+// ranges over its keys in
+type pslEvictor struct {
+	maxKeyEvictor
+	view func(int) (EntryView, bool)
+}
+
+func (e *pslEvictor) Inspect(view func(int) (EntryView, bool)) { e.view = view }
+
+func (e *pslEvictor) Clone() Evictor[int] {
+	return &pslEvictor{maxKeyEvictor: *e.maxKeyEvictor.Clone().(*maxKeyEvictor)}
+}
+
+func (e *pslEvictor) NextVictim() (int, bool) {
+	victim, worst, ok := 0, uint(0), false
+	for k := range e.keys {
+		if v, _ := e.view(k); !ok || v.PSL > worst || (v.PSL == worst && k > victim) {
+			victim, worst, ok = k, v.PSL, true
+		}
+	}
+	return victim, ok
+}
+
+func TestInspectingEvictor(t *testing.T) {
+	var e *pslEvictor
+	m := must(New[int, int](WithMaxEntries(12), WithLoadFactor(1), WithSize(16), WithEvictor(func() Evictor[int] {
+		e = &pslEvictor{maxKeyEvictor: maxKeyEvictor{keys: make(map[int]bool)}}
+		return e
+	})))
+	for i := 0; i < 12; i++ {
+		m.Set(i, i)
+	}
+	for k := range e.keys {
+		v, ok := e.view(k)
+		_, _, i := m.probe(k, m.hashKey(k))
+		if !ok || v.PSL != m.elements[i].psl || v.MaxPSL != m.MaxPSL() {
+			t.Errorf("The view of %d should report its PSL %d of max %d. Got %+v", k, m.elements[i].psl, m.MaxPSL(), v)
+		}
+	}
+	if _, ok := e.view(-1); ok {
+		t.Errorf("Absent keys should have no view.")
+	}
+
+	victim, _ := e.NextVictim()
+	if v, _ := e.view(victim); v.PSL != m.MaxPSL() {
+		t.Errorf("Expected a victim at the max PSL %d. Got PSL %d", m.MaxPSL(), v.PSL)
+	}
+	m.Set(12, 12)
+	if _, ok := m.Get(victim); ok || m.Len() != 12 {
+		t.Errorf("The evictor's victim should be evicted.")
+	}
+
+	c := m.Clone()
+	c.Delete(12)
+	if _, ok := e.view(12); !ok {
+		t.Errorf("The original's view should stay bound to the original.")
+	}
+	if ce := c.evictor.(*pslEvictor); ce.view == nil {
+		t.Errorf("A clone's evictor should be given the clone's view.")
+	} else if _, ok := ce.view(12); ok {
+		t.Errorf("A clone's view should show the clone's entries.")
+	}
+}
+
+func TestHottestColdest(t *testing.T) {
+	m := must(New[string, int](WithMaxEntries(10), WithEvictionPolicy(EvictLFU)))
+	for i, k := range []string{"a", "b", "c", "d"} {
+		m.Set(k, i)
+		for j := 0; j < i; j++ {
+			m.Get(k)
+		}
+	}
+	hot := m.Hottest(2)
+	if len(hot) != 2 || hot[0] != (Entry[string, int]{"d", 3}) || hot[1] != (Entry[string, int]{"c", 2}) {
+		t.Errorf("Expected the most frequently used first, Got %v", hot)
+	}
+	cold := m.Coldest(10)
+	if len(cold) != 4 || cold[0].Key != "a" || cold[3].Key != "d" {
+		t.Errorf("Expected the least frequently used first, Got %v", cold)
+	}
+
+	m = must(New[string, int](WithMaxEntries(10), WithEvictionPolicy(EvictLRU)))
+	m.Set("a", 1)
+	m.Set("b", 2)
+	m.Get("a")
+	if cold := m.Coldest(1); len(cold) != 1 || cold[0].Key != "b" || m.Coldest(1)[0].Key != "b" {
+		t.Errorf("Expected the least recently used first without counting a use, Got %v", cold)
+	}
+
+	m = must(New[string, int](WithMaxEntries(10), WithEvictionPolicy(EvictRandom)))
+	m.Set("a", 1)
+	if m.Hottest(1) != nil {
+		t.Errorf("A policy without a ranking should rank nothing.")
+	}
+}
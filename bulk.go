@@ -0,0 +1,128 @@
+package rhmap
+
+// Creates a map holding the elements of src, sized up front so that loading
+// them never rehashes. Options apply as for New; a WithSize option overrides
+// the computed size. It returns an error if K can't be encoded, as New does.
+func FromMap[K comparable, V any](src map[K]V, opts ...Option) (*Map[K, V], error) {
+	sized := append([]Option{WithSize(EstimateCapacityFor(uint64(len(src)), 0))}, opts...)
+	m, err := New[K, V](sized...)
+	if err != nil {
+		return nil, err
+	}
+	m.Reserve(uint64(len(src)))
+	for k, v := range src {
+		m.Set(k, v)
+	}
+	return m, nil
+}
+
+// Slots per region when SetMany orders a large batch by home slot
+const bulkRegionSize = 16
+
+// Sets keys[i] to values[i] for every i. The table is grown at most once and
+// the keys are hashed as one batch. Batches of at least the bulk threshold
+// (see WithBulkThreshold) are then bucketed by home slot and inserted in
+// table order, so the inserts sweep the table once instead of touching it at
+// random. Where a key repeats, the last value wins either way. Slices of
+// different lengths are misuse, and nothing is set.
+func (m *Map[K, V]) SetMany(keys []K, values []V) {
+	if len(keys) != len(values) {
+		m.misuse(ErrMismatchedLengths)
+		return
+	}
+	if m.rejectsWrites() {
+		return
+	}
+	m.Reserve(uint64(len(keys)))
+	hashes := m.HashMany(keys)
+	if len(keys) < m.bulkThreshold {
+		for i, hash := range hashes {
+			m.setWithHash(keys[i], values[i], hash)
+		}
+	} else {
+		for _, i := range m.homeOrder(hashes) {
+			m.setWithHash(keys[i], values[i], hashes[i])
+		}
+	}
+	m.checkFlooding()
+}
+
+// Looks up every key in keys, returning the value under keys[i] and whether
+// it is present at index i of the results. See GetManyInto.
+func (m *Map[K, V]) GetMany(keys []K) ([]V, []bool) {
+	values, found := make([]V, len(keys)), make([]bool, len(keys))
+	m.GetManyInto(keys, values, found)
+	return values, found
+}
+
+// Looks up every key in keys into values and found, which must be as long
+// as keys, so that a caller looking up batch after batch allocates nothing
+// but the hashes. The keys are hashed as one batch before any is probed, so
+// the probes' loads overlap rather than waiting on each hash in turn, and
+// batches of at least the bulk threshold are probed in table order, as
+// SetMany inserts them. Slices of different lengths are misuse, and nothing
+// is looked up.
+func (m *Map[K, V]) GetManyInto(keys []K, values []V, found []bool) {
+	if len(values) != len(keys) || len(found) != len(keys) {
+		m.misuse(ErrMismatchedLengths)
+		return
+	}
+	hashes := m.HashMany(keys)
+	if len(keys) < m.bulkThreshold {
+		for i, hash := range hashes {
+			values[i], found[i], _ = m.getWithHash(keys[i], hash)
+		}
+	} else {
+		for _, i := range m.homeOrder(hashes) {
+			values[i], found[i], _ = m.getWithHash(keys[i], hashes[i])
+		}
+	}
+
+	if m.metrics != nil {
+		m.metrics.Count(MetricGets, uint64(len(keys)))
+	}
+	if m.misses != nil || m.evictor != nil {
+		for i, ok := range found {
+			if !ok && m.misses != nil {
+				m.misses.add(keys[i], hashes[i])
+			}
+			if ok && m.evictor != nil {
+				m.accessed(keys[i])
+			}
+		}
+	}
+}
+
+// Returns the indexes of hashes stably sorted by the table region of their
+// home slots, with a counting sort over the regions
+func (m *Map[K, V]) homeOrder(hashes []uint64) []uint32 {
+	starts := make([]uint32, m.size/bulkRegionSize+2)
+	regions := make([]uint32, len(hashes))
+	for i, hash := range hashes {
+		regions[i] = uint32(m.indexAtPsl(hash, 0) / bulkRegionSize)
+		starts[regions[i]+1]++
+	}
+	for r := 1; r < len(starts); r++ {
+		starts[r] += starts[r-1]
+	}
+
+	order := make([]uint32, len(hashes))
+	for i, r := range regions {
+		order[starts[r]] = uint32(i)
+		starts[r]++
+	}
+	return order
+}
+
+// Sets every element of other in m, overwriting values under keys present in
+// both. The table is grown at most once. other is not modified.
+func (m *Map[K, V]) Merge(other *Map[K, V]) {
+	if other == m || m.rejectsWrites() {
+		return
+	}
+	m.Reserve(other.numElements)
+	for k, v := range other.All() {
+		m.setWithHash(k, v, m.hashKey(k))
+	}
+	m.checkFlooding()
+}
@@ -0,0 +1,164 @@
+package rhmap
+
+import "testing"
+
+func TestFromMap(t *testing.T) {
+	src := make(map[int]int)
+	for i := 0; i < 1000; i++ {
+		src[i] = i * 2
+	}
+	m := must(FromMap(src))
+	if m.Len() != 1000 {
+		t.Errorf("Map should contain 1000 elements. Found %d", m.Len())
+	}
+	if m.size != EstimateCapacityFor(1000, 0) {
+		t.Errorf("Map should be sized for its elements. Expected %d slots, Got %d", EstimateCapacityFor(1000, 0), m.size)
+	}
+	for k, v := range src {
+		if val, ok := m.Get(k); !ok || val != v {
+			t.Errorf("Key %d should map to %d. Got %d, %t", k, v, val, ok)
+		}
+	}
+
+	if m := must(FromMap(src, WithSize(4096))); m.size != 4096 {
+		t.Errorf("WithSize should override the computed size. Expected 4096, Got %d", m.size)
+	}
+}
+
+func TestSetMany(t *testing.T) {
+	m := must(New[int, int]())
+	m.Set(0, -1)
+	keys := make([]int, 500)
+	values := make([]int, 500)
+	for i := range keys {
+		keys[i], values[i] = i, i+1
+	}
+	m.SetMany(keys, values)
+
+	if m.Len() != 500 {
+		t.Errorf("Map should contain 500 elements. Found %d", m.Len())
+	}
+	for i := range keys {
+		if val, ok := m.Get(i); !ok || val != i+1 {
+			t.Errorf("Key %d should map to %d. Got %d, %t", i, i+1, val, ok)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Mismatched slices should panic.")
+		}
+	}()
+	m.SetMany([]int{1, 2}, []int{1})
+}
+
+func TestMerge(t *testing.T) {
+	m := must(New[string, int]())
+	other := must(New[string, int](WithHasher(XXHasher{})))
+	m.Set("a", 1)
+	m.Set("b", 2)
+	other.Set("b", 20)
+	other.Set("c", 30)
+
+	m.Merge(other)
+	want := map[string]int{"a": 1, "b": 20, "c": 30}
+	if m.Len() != uint64(len(want)) {
+		t.Errorf("Expected %d elements, Got %d", len(want), m.Len())
+	}
+	for k, v := range want {
+		if val, ok := m.Get(k); !ok || val != v {
+			t.Errorf("Key %s should map to %d. Got %d, %t", k, v, val, ok)
+		}
+	}
+	if other.Len() != 2 {
+		t.Errorf("Merge should leave other unchanged. Found %d elements", other.Len())
+	}
+
+	m.Merge(m)
+	if m.Len() != 3 {
+		t.Errorf("Merging a map into itself should change nothing. Found %d elements", m.Len())
+	}
+}
+
+func TestSetManyInTableOrder(t *testing.T) {
+	keys := make([]int, 20000)
+	values := make([]int, len(keys))
+	for i := range keys {
+		keys[i], values[i] = i%15000, i
+	}
+
+	m := must(New[int, int](WithBulkThreshold(100)))
+	m.SetMany(keys, values)
+	plain := must(New[int, int](WithBulkThreshold(0)))
+	plain.SetMany(keys, values)
+
+	if m.Len() != 15000 {
+		t.Errorf("Map should contain 15000 distinct keys. Found %d", m.Len())
+	}
+	if !m.Equal(plain, func(a, b int) bool { return a == b }) {
+		t.Errorf("Inserting in table order should give the same map as inserting in batch order.")
+	}
+	// Keys below 5000 appear twice, and the later value must win
+	if val, ok := m.Get(10); !ok || val != 15010 {
+		t.Errorf("Key 10 should keep the last value set. Got %d, %t", val, ok)
+	}
+
+	order := m.homeOrder(m.HashMany(keys))
+	for i := 1; i < len(order); i++ {
+		a, b := m.indexAtPsl(m.hashKey(keys[order[i-1]]), 0), m.indexAtPsl(m.hashKey(keys[order[i]]), 0)
+		if a/bulkRegionSize > b/bulkRegionSize {
+			t.Fatalf("Keys should be ordered by home region. Slot %d came before %d", a, b)
+		}
+	}
+}
+
+func BenchmarkSetMany(b *testing.B) {
+	keys := make([]int, 1<<21)
+	for i := range keys {
+		keys[i] = i
+	}
+	for _, bench := range []struct {
+		name      string
+		threshold int
+	}{
+		{"batchOrder", 0},
+		{"tableOrder", 1},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				m := must(New[int, int](WithBulkThreshold(bench.threshold)))
+				m.SetMany(keys, keys)
+			}
+		})
+	}
+}
+
+func TestGetMany(t *testing.T) {
+	for _, threshold := range []int{1 << 30, 1} {
+		m := must(New[int, int](WithBulkThreshold(threshold)))
+		for i := 0; i < 1000; i += 2 {
+			m.Set(i, i*3)
+		}
+		keys := make([]int, 300)
+		for i := range keys {
+			keys[i] = 999 - i*3
+		}
+		values, found := m.GetMany(keys)
+		for i, key := range keys {
+			val, ok := m.Get(key)
+			if values[i] != val || found[i] != ok {
+				t.Errorf("Key %d should be found as Get finds it. Expected %d, %t, Got %d, %t", key, val, ok, values[i], found[i])
+			}
+		}
+	}
+
+	m := must(New[int, int]())
+	m.Set(1, 1)
+	values, found := make([]int, 2), make([]bool, 2)
+	if allocs := testing.AllocsPerRun(100, func() { m.GetManyInto([]int{1, 2}, values, found) }); allocs > 1 {
+		t.Errorf("GetManyInto should allocate only the hashes. Got %f allocations", allocs)
+	}
+	if !found[0] || found[1] {
+		t.Errorf("Expected 1 found and 2 missing, Got %v", found)
+	}
+}
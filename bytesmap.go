@@ -0,0 +1,79 @@
+package rhmap
+
+import (
+	"iter"
+	"unsafe"
+)
+
+// Robin hood hashmap keyed by byte slices, the usual key type of parsers,
+// caches and network code, which Map can't take since []byte isn't
+// comparable. Keys are hashed as they are and compared in place, so Get,
+// Delete and Sets of existing keys don't allocate. Set copies new keys
+// unless the map was created with WithBorrowedKeys.
+type BytesMap[V any] struct {
+	table  *Map[string, V]
+	borrow bool
+}
+
+// Creates a byte-keyed map configured by opts. WithZeroDeletes and
+// WithIncrementalRehash have no effect.
+func NewBytesMap[V any](opts ...Option) (*BytesMap[V], error) {
+	table, err := New[string, V](opts...)
+	if err != nil {
+		return nil, err
+	}
+	// Lookups compare stored keys against the caller's bytes, which must
+	// never be stored in their place, as migrating an element would
+	table.zeroDeletes, table.rehashStep = false, 0
+	return &BytesMap[V]{table: table, borrow: resolveOptions(opts).borrowKeys}, nil
+}
+
+// Stores value under key. A new key is copied unless keys are borrowed, in
+// which case the caller must not modify key while it is in the map.
+func (b *BytesMap[V]) Set(key []byte, value V) {
+	hash := b.hash(key)
+	if _, ok, i := b.table.getForUpdate(view(key), hash); ok {
+		b.table.unshare()
+		b.table.elements[i].value = value
+		return
+	}
+
+	stored := string(key)
+	if b.borrow {
+		stored = view(key)
+	}
+	b.table.insertAbsent(stored, value, hash)
+}
+
+func (b *BytesMap[V]) Get(key []byte) (V, bool) {
+	val, ok, _ := b.table.getWithHash(view(key), b.hash(key))
+	return val, ok
+}
+
+func (b *BytesMap[V]) Delete(key []byte) {
+	if b.table.deleteWithHash(view(key), b.hash(key)) {
+		b.table.maybeShrink()
+	}
+}
+
+func (b *BytesMap[V]) Len() uint64 {
+	return b.table.Len()
+}
+
+// Returns an iterator over every key/value pair in the map, in table order,
+// with keys as strings. The map must not be modified while the iteration is
+// in progress.
+func (b *BytesMap[V]) All() iter.Seq2[string, V] {
+	return b.table.All()
+}
+
+// Hashes key exactly as the table hashes the equal string
+func (b *BytesMap[V]) hash(key []byte) uint64 {
+	return hashBytes(b.table.hasher, b.table.k0, b.table.k1, key)
+}
+
+// Returns a string sharing key's bytes, for comparisons that don't outlive
+// the call
+func view(key []byte) string {
+	return unsafe.String(unsafe.SliceData(key), len(key))
+}
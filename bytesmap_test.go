@@ -0,0 +1,60 @@
+package rhmap
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestBytesMap(t *testing.T) {
+	m := must(NewBytesMap[int](WithIncrementalRehash(1)))
+	buf := []byte("key")
+	m.Set(buf, 1)
+	// The key was copied, so reusing the buffer doesn't disturb it
+	copy(buf, "abc")
+	m.Set(buf, 2)
+	if val, ok := m.Get([]byte("key")); !ok || val != 1 {
+		t.Errorf("Key should keep its own copy of the bytes. Got %d, %t", val, ok)
+	}
+	if val, ok := m.table.Get("abc"); !ok || val != 2 {
+		t.Errorf("Byte keys should hash as the equal string. Got %d, %t", val, ok)
+	}
+
+	for i := 0; i < 1000; i++ {
+		m.Set(strconv.AppendInt(nil, int64(i), 10), i)
+	}
+	m.Set(nil, -1)
+	m.Delete([]byte("key"))
+	m.Delete([]byte("missing"))
+	if m.Len() != 1002 {
+		t.Errorf("Map should contain 1002 elements. Found %d", m.Len())
+	}
+	if val, ok := m.Get([]byte{}); !ok || val != -1 {
+		t.Errorf("The empty key should map to -1. Got %d, %t", val, ok)
+	}
+	if m.table.draining != nil {
+		t.Errorf("Byte-keyed maps should rehash in one step.")
+	}
+
+	key, missing := []byte("500"), []byte("missing")
+	allocs := testing.AllocsPerRun(100, func() {
+		m.Get(key)
+		m.Set(key, 5)
+		m.Delete(missing)
+	})
+	if allocs != 0 {
+		t.Errorf("Lookups and updates should not allocate. Got %f allocations", allocs)
+	}
+}
+
+func TestBytesMapBorrowedKeys(t *testing.T) {
+	m := must(NewBytesMap[int](WithBorrowedKeys()))
+	buf := []byte("key")
+	allocs := testing.AllocsPerRun(1, func() { m.Set(buf, 1) })
+	if allocs != 0 {
+		t.Errorf("Inserting a borrowed key should not allocate. Got %f allocations", allocs)
+	}
+	copy(buf, "abc")
+	if _, ok := m.Get([]byte("abc")); ok {
+		t.Errorf("A borrowed key changed in place can't be found, as documented.")
+	}
+}
@@ -0,0 +1,71 @@
+package rhmap
+
+import (
+	"math"
+	"unsafe"
+)
+
+// Returns the smallest power-of-two table size holding n elements without
+// crossing loadFactor, to pass to WithSize so that n inserts never rehash. A
+// loadFactor outside (0, 1], such as 0, means the default of 0.9 that every
+// map grows at.
+func EstimateCapacityFor(n uint64, loadFactor float32) uint64 {
+	if !(loadFactor > 0 && loadFactor <= 1) {
+		loadFactor = defaultLoadFactor
+	}
+	capacity := roundSize(max(defaultSize, uint64(math.Ceil(float64(n)/float64(loadFactor)))))
+	// Sets grow once the load reaches the load factor, so n elements need
+	// strictly more than n/loadFactor slots
+	for float32(float64(n)/float64(capacity)) >= loadFactor {
+		capacity *= 2
+	}
+	return capacity
+}
+
+// Returns the bytes a Map[K, V] with capacity slots occupies: the map itself
+// plus its table. Memory that keys and values point to, such as string
+// contents, is not included.
+func EstimateMemory[K comparable, V any](capacity uint64) uint64 {
+	return uint64(unsafe.Sizeof(Map[K, V]{})) + capacity*uint64(unsafe.Sizeof(element[K, V]{}))
+}
+
+// Returns the bytes of a Map[K, V] holding n elements in the table size
+// EstimateCapacityFor picks for loadFactor, for capacity planning before
+// any map exists
+func EstimateSize[K comparable, V any](n uint64, loadFactor float32) uint64 {
+	return EstimateMemory[K, V](EstimateCapacityFor(n, loadFactor))
+}
+
+// Returns the bytes the map occupies: the map itself, its table and control
+// bytes, the table an incremental rehash is draining, and the bookkeeping
+// of WithKeyspaceStats, WithMissStats and the built-in eviction policies.
+// Like EstimateMemory it leaves out memory that keys and values point to,
+// and a table shared with a snapshot is counted in full by both.
+func (m *Map[K, V]) MemoryFootprint() uint64 {
+	bytes := uint64(unsafe.Sizeof(*m)) +
+		uint64(cap(m.elements))*uint64(unsafe.Sizeof(element[K, V]{})) + uint64(cap(m.ctrl))
+	if m.draining != nil {
+		bytes += m.draining.MemoryFootprint()
+	}
+	if m.keyspace != nil {
+		bytes += uint64(unsafe.Sizeof(*m.keyspace))
+	}
+	if m.latency != nil {
+		bytes += uint64(unsafe.Sizeof(*m.latency))
+	}
+	if m.hits != nil {
+		bytes += uint64(unsafe.Sizeof(*m.hits))
+	}
+	if m.admission != nil {
+		bytes += uint64(unsafe.Sizeof(*m.admission)) + uint64(cap(m.admission.sketch.counters))*uint64(unsafe.Sizeof(uint32(0)))
+	}
+	if m.misses != nil {
+		bytes += uint64(unsafe.Sizeof(*m.misses)) +
+			uint64(cap(m.misses.sketch.counters))*uint64(unsafe.Sizeof(uint32(0))) +
+			uint64(cap(m.misses.candidates))*uint64(unsafe.Sizeof(missCandidate[K]{}))
+	}
+	if e, ok := m.evictor.(interface{ MemoryFootprint() uint64 }); ok {
+		bytes += e.MemoryFootprint()
+	}
+	return bytes
+}
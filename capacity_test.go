@@ -0,0 +1,59 @@
+package rhmap
+
+import "testing"
+
+func TestEstimateCapacityFor(t *testing.T) {
+	for _, n := range []uint64{0, 1, 7, 100, 900, 12345} {
+		capacity := EstimateCapacityFor(n, 0)
+		m := must(New[int, int](WithSize(capacity)))
+		for i := 0; i < int(n); i++ {
+			m.Set(i, i)
+		}
+		if m.size != capacity {
+			t.Errorf("%d inserts into %d slots should not rehash. Grew to %d", n, capacity, m.size)
+		}
+		if capacity > defaultSize && float32(float64(n)/float64(capacity/2)) < defaultLoadFactor {
+			t.Errorf("%d slots is more than %d elements need.", capacity, n)
+		}
+	}
+
+	if got := EstimateCapacityFor(50, .5); got != 128 {
+		t.Errorf("50 elements at load .5 need 128 slots. Got %d", got)
+	}
+	if got := EstimateCapacityFor(64, .5); got != 256 {
+		t.Errorf("64 elements at load .5 reach the load factor in 128 slots. Expected 256, Got %d", got)
+	}
+}
+
+func TestEstimateMemory(t *testing.T) {
+	small := EstimateMemory[int64, int64](0)
+	big := EstimateMemory[int64, int64](1000)
+	// Each element holds two int64s, a hash, a PSL and a set flag
+	if per := (big - small) / 1000; per != 40 {
+		t.Errorf("An int64 to int64 element should take 40 bytes. Got %d", per)
+	}
+}
+
+func TestEstimateSize(t *testing.T) {
+	if got, want := EstimateSize[int64, int64](1000, 0), EstimateMemory[int64, int64](2048); got != want {
+		t.Errorf("1000 elements should fit 2048 slots. Expected %d, Got %d", want, got)
+	}
+}
+
+func TestMemoryFootprint(t *testing.T) {
+	m := must(New[int64, int64]())
+	for i := int64(0); i < 1000; i++ {
+		m.Set(i, i)
+	}
+	if got, want := m.MemoryFootprint(), EstimateSize[int64, int64](1000, 0); got < want {
+		t.Errorf("A map of 1000 elements should take at least the estimate. Expected >= %d, Got %d", want, got)
+	}
+
+	bounded := must(New[int64, int64](WithMaxEntries(1000), WithEvictionPolicy(EvictLRU)))
+	for i := int64(0); i < 1000; i++ {
+		bounded.Set(i, i)
+	}
+	if bounded.MemoryFootprint() <= m.MemoryFootprint() {
+		t.Errorf("Tracking recency should add to the footprint.")
+	}
+}
@@ -0,0 +1,129 @@
+package rhmap
+
+import "iter"
+
+// Map a ChildMap reads through to: a Map or another ChildMap
+type childParent[K comparable, V any] interface {
+	Get(key K) (V, bool)
+	Set(key K, value V)
+	Delete(key K)
+	Len() uint64
+	All() iter.Seq2[K, V]
+}
+
+// Overlay over a parent map that reads through to the parent but keeps its
+// own writes, deletes included, until Flatten applies them to the parent.
+// It suits speculative computation and per-request overrides of a shared
+// base map: the parent is never copied, and a child that is dropped leaves
+// it untouched. Reads see the parent's current contents wherever the child
+// hasn't written.
+type ChildMap[K comparable, V any] struct {
+	parent childParent[K, V]
+	// Keys the child set, and keys it deleted, which hash alike so that a
+	// key is hashed once for both
+	own    *Map[K, V]
+	hidden *Map[K, struct{}]
+}
+
+// Creates a child map over m
+func (m *Map[K, V]) NewChild() *ChildMap[K, V] {
+	return newChild[K, V](m, m.enc)
+}
+
+// Creates a child map over c, so that overrides can be scoped in layers
+func (c *ChildMap[K, V]) NewChild() *ChildMap[K, V] {
+	return newChild[K, V](c, c.own.enc)
+}
+
+func newChild[K comparable, V any](parent childParent[K, V], enc keyEncoder[K]) *ChildMap[K, V] {
+	own := newMap[K, V](enc)
+	own.zeroDeletes = false
+	own.autoReseed = false
+	return &ChildMap[K, V]{
+		parent: parent,
+		own:    own,
+		hidden: newMap[K, struct{}](enc, WithSeedsFrom(own)),
+	}
+}
+
+// Returns the child's value under key, or the parent's if the child hasn't
+// written key
+func (c *ChildMap[K, V]) Get(key K) (V, bool) {
+	hash := c.own.hashKey(key)
+	if val, ok, _ := c.own.getWithHash(key, hash); ok {
+		return val, true
+	}
+	if _, ok, _ := c.hidden.getWithHash(key, hash); ok {
+		var zeroVal V
+		return zeroVal, false
+	}
+	return c.parent.Get(key)
+}
+
+// Sets key in the child only
+func (c *ChildMap[K, V]) Set(key K, value V) {
+	hash := c.own.hashKey(key)
+	c.hidden.deleteWithHash(key, hash)
+	c.own.setWithHash(key, value, hash)
+}
+
+// Hides key from the child's reads without deleting it from the parent
+func (c *ChildMap[K, V]) Delete(key K) {
+	hash := c.own.hashKey(key)
+	c.own.deleteWithHash(key, hash)
+	c.hidden.setWithHash(key, struct{}{}, hash)
+}
+
+// Returns the number of elements the child sees. It looks up every key the
+// child has written in the parent.
+func (c *ChildMap[K, V]) Len() uint64 {
+	n := c.parent.Len()
+	for k := range c.own.All() {
+		if _, ok := c.parent.Get(k); !ok {
+			n++
+		}
+	}
+	for k := range c.hidden.All() {
+		if _, ok := c.parent.Get(k); ok {
+			n--
+		}
+	}
+	return n
+}
+
+// Returns an iterator over the elements the child sees: the parent's that
+// the child hasn't written, then the child's own
+func (c *ChildMap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for k, v := range c.parent.All() {
+			hash := c.own.hashKey(k)
+			if _, ok, _ := c.own.getWithHash(k, hash); ok {
+				continue
+			}
+			if _, ok, _ := c.hidden.getWithHash(k, hash); ok {
+				continue
+			}
+			if !yield(k, v) {
+				return
+			}
+		}
+		for k, v := range c.own.All() {
+			if !yield(k, v) {
+				return
+			}
+		}
+	}
+}
+
+// Applies the child's writes and deletes to its parent and empties the
+// child, which keeps reading through to the parent
+func (c *ChildMap[K, V]) Flatten() {
+	for k := range c.hidden.All() {
+		c.parent.Delete(k)
+	}
+	for k, v := range c.own.All() {
+		c.parent.Set(k, v)
+	}
+	c.own.Clear()
+	c.hidden.Clear()
+}
@@ -0,0 +1,66 @@
+package rhmap
+
+import "testing"
+
+func TestChildMap(t *testing.T) {
+	base := must(New[string, int]())
+	base.Set("a", 1)
+	base.Set("b", 2)
+
+	child := base.NewChild()
+	child.Set("a", 10)
+	child.Set("c", 30)
+	child.Delete("b")
+	child.Delete("missing")
+
+	if val, ok := child.Get("a"); !ok || val != 10 {
+		t.Errorf("The child's write should shadow the parent. Got %d, %t", val, ok)
+	}
+	if _, ok := child.Get("b"); ok {
+		t.Errorf("A key deleted in the child should read as absent.")
+	}
+	if val, ok := base.Get("b"); !ok || val != 2 {
+		t.Errorf("The parent should keep its elements. Got %d, %t", val, ok)
+	}
+	base.Set("d", 4)
+	if val, ok := child.Get("d"); !ok || val != 4 {
+		t.Errorf("The child should read through to the parent's current contents. Got %d, %t", val, ok)
+	}
+
+	seen := make(map[string]int)
+	for k, v := range child.All() {
+		seen[k] = v
+	}
+	if child.Len() != 3 || len(seen) != 3 || seen["a"] != 10 || seen["c"] != 30 || seen["d"] != 4 {
+		t.Errorf("The child should see a, c and d. Got %v with Len %d", seen, child.Len())
+	}
+
+	// Scoped layers
+	grandchild := child.NewChild()
+	grandchild.Set("b", 20)
+	grandchild.Delete("c")
+	if val, ok := grandchild.Get("b"); !ok || val != 20 || grandchild.Len() != 3 {
+		t.Errorf("A grandchild should layer over the child. Got %d, %t with Len %d", val, ok, grandchild.Len())
+	}
+	grandchild.Flatten()
+	if val, ok := child.Get("b"); !ok || val != 20 {
+		t.Errorf("Flattening should apply writes to the parent. Got %d, %t", val, ok)
+	}
+	if _, ok := child.Get("c"); ok {
+		t.Errorf("Flattening should apply deletes to the parent.")
+	}
+
+	child.Flatten()
+	want := map[string]int{"a": 10, "b": 20, "d": 4}
+	if base.Len() != uint64(len(want)) {
+		t.Errorf("Expected %d elements in the base, Got %d", len(want), base.Len())
+	}
+	for k, v := range want {
+		if val, ok := base.Get(k); !ok || val != v {
+			t.Errorf("The base should hold %s=%d after flattening. Got %d, %t", k, v, val, ok)
+		}
+	}
+	if child.own.Len() != 0 || child.hidden.Len() != 0 || child.Len() != base.Len() {
+		t.Errorf("A flattened child should be empty and read through. Found %d writes", child.own.Len())
+	}
+}
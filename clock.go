@@ -0,0 +1,35 @@
+package rhmap
+
+import "time"
+
+// Clock is the source of time for expiring and rate-limiting maps. Tests can
+// inject a fake clock with WithClock to advance time without sleeping.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a single-shot timer created by a Clock, like time.Timer
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// Clock backed by the time package, used when no clock is injected
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
@@ -0,0 +1,80 @@
+package rhmap
+
+// Returns an independent copy of the map with the same seeds, hasher and
+// options. The copy shares the table until either map is next mutated,
+// which copies it first, so cloning before a speculative batch of changes
+// costs nothing unless the batch runs. The copy is not registered, even if
+// the map is.
+func (m *Map[K, V]) Clone() *Map[K, V] {
+	c := *m
+	c.readOnly = false
+	c.registration = nil
+	c.auditCursor = 0
+	c.iterators = 0
+	c.err = nil
+	if m.keyspace != nil {
+		ks := *m.keyspace
+		c.keyspace = &ks
+	}
+	if m.misses != nil {
+		c.misses = m.misses.clone()
+	}
+	if m.latency != nil {
+		c.latency = m.latency.clone()
+	}
+	if m.hits != nil {
+		c.hits = m.hits.clone()
+	}
+	if m.admission != nil {
+		c.admission = m.admission.clone()
+	}
+	if m.evictor != nil {
+		c.evictor = m.evictor.Clone()
+		c.bindEvictor()
+	}
+	if m.deleted != nil {
+		c.deleted = m.deleted.clone()
+	}
+	m.shared = true
+	c.shared = true
+	m.block.retain()
+	if m.draining != nil {
+		m.draining.block.retain()
+		d := *m.draining
+		m.draining.shared = true
+		d.shared = true
+		c.draining = &d
+	}
+	return &c
+}
+
+// Returns a read-only view of the map as of this call. Like a clone, it
+// shares the table until the map's next write copies it, so handing out a
+// consistent view per request costs no copying while the map is read. The
+// view may be read from any number of goroutines, even while the map keeps
+// mutating. Writing to it is misuse, which panics under the default
+// FailurePolicy. Clone it for a writable copy.
+func (m *Map[K, V]) Snapshot() *Map[K, V] {
+	s := m.Clone()
+	s.readOnly = true
+	// Reads of a snapshot may be concurrent, so they can't be reported to an
+	// Evictor or admission filter
+	s.evictor = nil
+	s.admission = nil
+	return s
+}
+
+// Reports whether both maps hold the same keys with values eq considers
+// equal
+func (m *Map[K, V]) Equal(other *Map[K, V], eq func(V, V) bool) bool {
+	if m.numElements != other.numElements {
+		return false
+	}
+	for k, v := range m.All() {
+		ov, ok := other.Get(k)
+		if !ok || !eq(v, ov) {
+			return false
+		}
+	}
+	return true
+}
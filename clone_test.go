@@ -0,0 +1,108 @@
+package rhmap
+
+import "testing"
+
+func intsEqual(a, b int) bool { return a == b }
+
+func TestClone(t *testing.T) {
+	m := must(New[int, int]())
+	for i := 0; i < 100; i++ {
+		m.Set(i, i)
+	}
+
+	c := m.Clone()
+	if !m.Equal(c, intsEqual) {
+		t.Errorf("A clone should equal its source.")
+	}
+
+	for i := 0; i < 1000; i++ {
+		c.Set(i, -i)
+	}
+	c.Delete(0)
+	m.Set(5, 50)
+
+	for i := 0; i < 100; i++ {
+		want := i
+		if i == 5 {
+			want = 50
+		}
+		if val, ok := m.Get(i); !ok || val != want {
+			t.Errorf("Changes to the clone should not reach the source. Key %d: Got %d, %t", i, val, ok)
+		}
+	}
+	if val, ok := c.Get(5); !ok || val != -5 {
+		t.Errorf("Changes to the source should not reach the clone. Got %d, %t", val, ok)
+	}
+	if c.Len() != 999 || m.Len() != 100 {
+		t.Errorf("Clone and source should keep separate counts. Got %d and %d", c.Len(), m.Len())
+	}
+	if c.hashKey(12345) != m.hashKey(12345) {
+		t.Errorf("A clone should hash keys as its source does.")
+	}
+}
+
+func TestEqual(t *testing.T) {
+	a := must(New[string, int]())
+	b := must(New[string, int]())
+	a.Set("x", 1)
+	b.Set("x", 1)
+	if !a.Equal(b, intsEqual) {
+		t.Errorf("Maps with the same elements should be equal regardless of seeds.")
+	}
+
+	b.Set("x", 2)
+	if a.Equal(b, intsEqual) {
+		t.Errorf("Maps with different values should not be equal.")
+	}
+	b.Set("x", 1)
+	b.Set("y", 1)
+	if a.Equal(b, intsEqual) || b.Equal(a, intsEqual) {
+		t.Errorf("Maps of different lengths should not be equal.")
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	m := must(New[int, int](WithIncrementalRehash(1)))
+	n := 0
+	for ; m.draining == nil; n++ {
+		m.Set(n, n)
+	}
+	s := m.Snapshot()
+	for i := 0; i < n; i++ {
+		m.Set(i, -i)
+	}
+	m.Set(n, n)
+	m.Delete(0)
+
+	for i := 0; i < n; i++ {
+		if val, ok := s.Get(i); !ok || val != i {
+			t.Errorf("The snapshot should keep key %d as it was. Got %d, %t", i, val, ok)
+		}
+	}
+	if s.Len() != uint64(n) {
+		t.Errorf("The snapshot should keep its length. Expected %d, Got %d", n, s.Len())
+	}
+
+	for name, write := range map[string]func(){
+		"Set":       func() { s.Set(-1, 1) },
+		"Delete":    func() { s.Delete(1) },
+		"Clear":     func() { s.Clear() },
+		"GrowTo":    func() { s.GrowTo(1 << 12) },
+		"SetHasher": func() { s.SetHasher(XXHasher{}) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s on a snapshot should panic.", name)
+				}
+			}()
+			write()
+		}()
+	}
+
+	c := s.Clone()
+	c.Set(-1, 1)
+	if val, ok := c.Get(-1); !ok || val != 1 {
+		t.Errorf("A clone of a snapshot should be writable. Got %d, %t", val, ok)
+	}
+}
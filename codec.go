@@ -0,0 +1,148 @@
+package rhmap
+
+import (
+	"iter"
+	"slices"
+)
+
+// KeyCodec makes a key type usable by a CodecMap without it being
+// comparable. Encode appends the bytes the key is hashed by to dst and
+// returns the extended slice; keys that Equal reports as equal must encode
+// identically. Keys that encode identically but aren't Equal are allowed,
+// and are told apart by Equal.
+type KeyCodec[K any] interface {
+	Encode(dst []byte, key K) []byte
+	Equal(a, b K) bool
+}
+
+// Key/value pair in a CodecMap
+type codecEntry[K, V any] struct {
+	key   K
+	value V
+}
+
+// Entries of a map whose keys aren't comparable, grouped by a comparable
+// digest of the key, such as its encoding or a caller-supplied hash. Keys
+// with equal digests are told apart by equal.
+type bucketTable[D comparable, K, V any] struct {
+	// Entries grouped by digest, almost always one per digest
+	table       *Map[D, []codecEntry[K, V]]
+	equal       func(a, b K) bool
+	numElements uint64
+}
+
+func newBucketTable[D comparable, K, V any](equal func(a, b K) bool, opts []Option) (*bucketTable[D, K, V], error) {
+	table, err := New[D, []codecEntry[K, V]](opts...)
+	if err != nil {
+		return nil, err
+	}
+	table.zeroDeletes = false
+	return &bucketTable[D, K, V]{table: table, equal: equal}, nil
+}
+
+func (b *bucketTable[D, K, V]) set(digest D, key K, value V) {
+	hash := b.table.hashKey(digest)
+	entries, _, _ := b.table.getWithHash(digest, hash)
+	if i := b.index(entries, key); i >= 0 {
+		entries[i].value = value
+		return
+	}
+	b.table.setWithHash(digest, append(entries, codecEntry[K, V]{key, value}), hash)
+	b.numElements++
+}
+
+func (b *bucketTable[D, K, V]) get(digest D, key K) (V, bool) {
+	entries, _ := b.table.Get(digest)
+	if i := b.index(entries, key); i >= 0 {
+		return entries[i].value, true
+	}
+	var zeroVal V
+	return zeroVal, false
+}
+
+func (b *bucketTable[D, K, V]) delete(digest D, key K) {
+	hash := b.table.hashKey(digest)
+	entries, _, _ := b.table.getWithHash(digest, hash)
+	i := b.index(entries, key)
+	if i < 0 {
+		return
+	}
+	if len(entries) == 1 {
+		b.table.deleteWithHash(digest, hash)
+	} else {
+		b.table.setWithHash(digest, slices.Delete(entries, i, i+1), hash)
+	}
+	b.numElements--
+}
+
+func (b *bucketTable[D, K, V]) all() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for _, entries := range b.table.All() {
+			for _, e := range entries {
+				if !yield(e.key, e.value) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Returns the position of key among entries sharing its digest, or -1
+func (b *bucketTable[D, K, V]) index(entries []codecEntry[K, V], key K) int {
+	return slices.IndexFunc(entries, func(e codecEntry[K, V]) bool { return b.equal(e.key, key) })
+}
+
+// Robin hood hashmap for key types that aren't comparable, such as
+// *big.Int compared by value or slices, identified through a KeyCodec.
+// Comparable keys should use Map, which needs no codec and doesn't allocate
+// on lookup.
+type CodecMap[K, V any] struct {
+	buckets *bucketTable[string, K, V]
+	codec   KeyCodec[K]
+}
+
+// Creates a map whose keys are hashed and compared through codec. Options
+// configure the underlying map; WithZeroDeletes has no effect.
+func NewWithCodec[K, V any](codec KeyCodec[K], opts ...Option) (*CodecMap[K, V], error) {
+	buckets, err := newBucketTable[string, K, V](codec.Equal, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &CodecMap[K, V]{buckets: buckets, codec: codec}, nil
+}
+
+func (c *CodecMap[K, V]) Set(key K, value V) {
+	c.buckets.set(c.encode(key), key, value)
+}
+
+func (c *CodecMap[K, V]) Get(key K) (V, bool) {
+	return c.buckets.get(c.encode(key), key)
+}
+
+func (c *CodecMap[K, V]) Delete(key K) {
+	c.buckets.delete(c.encode(key), key)
+}
+
+func (c *CodecMap[K, V]) Len() uint64 {
+	return c.buckets.numElements
+}
+
+// Returns an iterator over every key/value pair in the map. The map must not
+// be modified while the iteration is in progress.
+func (c *CodecMap[K, V]) All() iter.Seq2[K, V] {
+	return c.buckets.all()
+}
+
+// Returns the statistics of the underlying table, whose elements are the
+// groups of keys sharing an encoding. Len counts keys rather than groups.
+func (c *CodecMap[K, V]) Stats() Stats {
+	s := c.buckets.table.Stats()
+	s.Len = c.buckets.numElements
+	return s
+}
+
+// Returns the encoding key is hashed by
+func (c *CodecMap[K, V]) encode(key K) string {
+	var scratch [keyScratchSize]byte
+	return string(c.codec.Encode(scratch[:0], key))
+}
@@ -0,0 +1,91 @@
+package rhmap
+
+import (
+	"math/big"
+	"slices"
+	"testing"
+)
+
+type bigIntCodec struct{}
+
+func (bigIntCodec) Encode(dst []byte, key *big.Int) []byte {
+	dst = append(dst, byte(key.Sign()+1))
+	return key.Append(dst, 16)
+}
+
+func (bigIntCodec) Equal(a, b *big.Int) bool {
+	return a.Cmp(b) == 0
+}
+
+// Encodes every slice by its length alone, so that keys collide
+type lenCodec struct{}
+
+func (lenCodec) Encode(dst []byte, key []int) []byte {
+	return append(dst, byte(len(key)))
+}
+
+func (lenCodec) Equal(a, b []int) bool {
+	return slices.Equal(a, b)
+}
+
+func TestCodecMapBigInt(t *testing.T) {
+	m := must(NewWithCodec[*big.Int, int](bigIntCodec{}))
+	for i := int64(-500); i < 500; i++ {
+		m.Set(big.NewInt(i), int(i))
+	}
+	m.Set(big.NewInt(7), 70)
+
+	if m.Len() != 1000 {
+		t.Errorf("Map should contain 1000 elements. Found %d", m.Len())
+	}
+	for i := int64(-500); i < 500; i++ {
+		want := int(i)
+		if i == 7 {
+			want = 70
+		}
+		// A distinct *big.Int of equal value must find the element
+		if val, ok := m.Get(new(big.Int).SetInt64(i)); !ok || val != want {
+			t.Errorf("Key %d should map to %d. Got %d, %t", i, want, val, ok)
+		}
+	}
+
+	for i := int64(-500); i < 500; i += 2 {
+		m.Delete(big.NewInt(i))
+	}
+	m.Delete(big.NewInt(1000))
+	if m.Len() != 500 {
+		t.Errorf("Map should contain 500 elements after deleting evens. Found %d", m.Len())
+	}
+	n := 0
+	for k, v := range m.All() {
+		if k.Int64()%2 == 0 || (v != int(k.Int64()) && v != 70) {
+			t.Errorf("Key %v should have been deleted or maps to the wrong value %d", k, v)
+		}
+		n++
+	}
+	if n != 500 {
+		t.Errorf("All should yield 500 elements. Got %d", n)
+	}
+}
+
+func TestCodecMapSharedEncodings(t *testing.T) {
+	m := must(NewWithCodec[[]int, string](lenCodec{}))
+	m.Set([]int{1, 2}, "a")
+	m.Set([]int{3, 4}, "b")
+	m.Set([]int{1, 2}, "c")
+
+	if m.Len() != 2 {
+		t.Errorf("Keys sharing an encoding should still be distinct. Found %d elements", m.Len())
+	}
+	if val, ok := m.Get([]int{1, 2}); !ok || val != "c" {
+		t.Errorf("[1 2] should map to c. Got %s, %t", val, ok)
+	}
+
+	m.Delete([]int{1, 2})
+	if _, ok := m.Get([]int{1, 2}); ok {
+		t.Errorf("[1 2] should have been deleted.")
+	}
+	if val, ok := m.Get([]int{3, 4}); !ok || val != "b" {
+		t.Errorf("Deleting [1 2] should keep [3 4], which shares its encoding. Got %s, %t", val, ok)
+	}
+}
@@ -0,0 +1,228 @@
+package rhmap
+
+import (
+	"cmp"
+	"iter"
+	"math"
+)
+
+// Longest probe sequence a CompactMap slot records. PSLs are stored plus one
+// in a byte, so that 0 marks an empty slot.
+const maxCompactPsl = math.MaxUint8 - 1
+
+// Robin hood hashmap for tables of hundreds of millions of small elements,
+// where Map's per-slot bookkeeping of a 64-bit hash, a word-sized PSL and a
+// set flag outweighs the elements themselves. A CompactMap keeps keys,
+// values and metadata in separate arrays, with the metadata packed into a
+// 32-bit fingerprint of each key's hash and an 8-bit PSL: five bytes a slot,
+// and no padding between keys and values. In exchange, rehashes hash every
+// key again, since only part of each hash is kept, and an insert that would
+// probe past 254 slots resizes the table instead, so a hasher that gives
+// hundreds of keys one hash would grow it without bound. Options configure
+// the size, hasher, seeds and load factor; others have no effect.
+type CompactMap[K comparable, V any] struct {
+	hasher       Hasher
+	enc          keyEncoder[K]
+	k0           uint64
+	k1           uint64
+	keys         []K
+	values       []V
+	fingerprints []uint32
+	// PSL of each slot plus one, or 0 if the slot is empty
+	psls        []uint8
+	size        uint64
+	numElements uint64
+	loadFactor  float32
+	// Whether the map may draw new seeds to break up long probes, and the
+	// table size it last did at
+	autoReseed   bool
+	cryptoSeeds  bool
+	reseededSize uint64
+}
+
+// Creates a compact map configured by opts. It returns an error if K can't
+// be encoded, as New does.
+func NewCompactMap[K comparable, V any](opts ...Option) (*CompactMap[K, V], error) {
+	enc, err := newKeyEncoder[K]()
+	if err != nil {
+		return nil, err
+	}
+	o := resolveOptions(opts)
+	size := defaultSize
+	if o.size > 0 {
+		size = roundSize(o.size)
+	}
+	hasher, k0, k1 := o.hashing()
+
+	m := &CompactMap[K, V]{
+		hasher:      hasher,
+		enc:         enc,
+		k0:          k0,
+		k1:          k1,
+		loadFactor:  cmp.Or(o.loadFactor, defaultLoadFactor),
+		autoReseed:  !o.seeded,
+		cryptoSeeds: o.cryptoSeeds,
+	}
+	m.alloc(size)
+	return m, nil
+}
+
+func (m *CompactMap[K, V]) Set(key K, value V) {
+	hash := m.hash(key)
+	if i, ok := m.find(key, hash); ok {
+		m.values[i] = value
+		return
+	}
+	if float32(float64(m.numElements)/float64(m.size)) >= m.loadFactor {
+		m.resize(m.size * 2)
+	}
+	m.insert(key, value, hash)
+}
+
+func (m *CompactMap[K, V]) Get(key K) (V, bool) {
+	if i, ok := m.find(key, m.hash(key)); ok {
+		return m.values[i], true
+	}
+	var zeroVal V
+	return zeroVal, false
+}
+
+// Deletes key, shifting the elements after it back a slot as Map does
+func (m *CompactMap[K, V]) Delete(key K) {
+	i, ok := m.find(key, m.hash(key))
+	if !ok {
+		return
+	}
+	for {
+		j := (i + 1) & (m.size - 1)
+		if m.psls[j] <= 1 {
+			break
+		}
+		m.keys[i], m.values[i] = m.keys[j], m.values[j]
+		m.fingerprints[i], m.psls[i] = m.fingerprints[j], m.psls[j]-1
+		i = j
+	}
+	var zeroKey K
+	var zeroVal V
+	m.keys[i], m.values[i], m.fingerprints[i], m.psls[i] = zeroKey, zeroVal, 0, 0
+	m.numElements--
+}
+
+func (m *CompactMap[K, V]) Len() uint64 {
+	return m.numElements
+}
+
+// Deletes every element, keeping the table's size
+func (m *CompactMap[K, V]) Clear() {
+	clear(m.keys)
+	clear(m.values)
+	clear(m.fingerprints)
+	clear(m.psls)
+	m.numElements = 0
+}
+
+// Returns an iterator over every key/value pair in the map, in table order.
+// The map must not be modified while the iteration is in progress.
+func (m *CompactMap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for i, psl := range m.psls {
+			if psl != 0 && !yield(m.keys[i], m.values[i]) {
+				return
+			}
+		}
+	}
+}
+
+func (m *CompactMap[K, V]) hash(key K) uint64 {
+	return m.enc.hash(m.hasher, m.k0, m.k1, key)
+}
+
+// The low bits of a hash pick its home slot, so the fingerprint is taken
+// from the high bits, which the home slot says nothing about
+func fingerprint(hash uint64) uint32 {
+	return uint32(hash >> 32)
+}
+
+// Returns the slot holding key, walking from its home slot until an empty
+// slot or one closer to its home than key would be
+func (m *CompactMap[K, V]) find(key K, hash uint64) (uint64, bool) {
+	fp := fingerprint(hash)
+	i := hash & (m.size - 1)
+	for psl := 1; int(m.psls[i]) >= psl; psl++ {
+		if m.fingerprints[i] == fp && m.equal(m.keys[i], key) {
+			return i, true
+		}
+		i = (i + 1) & (m.size - 1)
+	}
+	return 0, false
+}
+
+func (m *CompactMap[K, V]) equal(a, b K) bool {
+	if m.enc.equalStruct != nil {
+		return m.enc.equalStruct(a, b)
+	}
+	return a == b
+}
+
+// Inserts a key known to be absent. The element carried along the probe
+// swaps places with any richer one it passes; should the one carried need a
+// longer probe than a slot records, the table makes room and the insert of
+// that element starts over.
+func (m *CompactMap[K, V]) insert(key K, value V, hash uint64) {
+	fp := fingerprint(hash)
+	i := hash & (m.size - 1)
+	for psl := 1; ; psl++ {
+		if psl > maxCompactPsl+1 {
+			m.makeRoom()
+			m.insert(key, value, m.hash(key))
+			return
+		}
+		if m.psls[i] == 0 {
+			m.keys[i], m.values[i], m.fingerprints[i], m.psls[i] = key, value, fp, uint8(psl)
+			m.numElements++
+			return
+		}
+		if int(m.psls[i]) < psl {
+			key, m.keys[i] = m.keys[i], key
+			value, m.values[i] = m.values[i], value
+			fp, m.fingerprints[i] = m.fingerprints[i], fp
+			psl, m.psls[i] = int(m.psls[i]), uint8(psl)
+		}
+		i = (i + 1) & (m.size - 1)
+	}
+}
+
+// Shortens probes after one ran past what a slot records. Long probes in a
+// table under half the load factor are more likely down to the seeds than
+// to load, so such a table draws new seeds, once per size, if its seeds are
+// random; otherwise the table doubles.
+func (m *CompactMap[K, V]) makeRoom() {
+	if m.autoReseed && m.reseededSize != m.size && float32(float64(m.numElements)/float64(m.size)) < m.loadFactor/2 {
+		m.reseededSize = m.size
+		m.k0, m.k1 = randomSeeds(m.cryptoSeeds)
+		m.resize(m.size)
+		return
+	}
+	m.resize(m.size * 2)
+}
+
+// Moves every element into a new table of size slots, hashing each key
+// again
+func (m *CompactMap[K, V]) resize(size uint64) {
+	keys, values, psls := m.keys, m.values, m.psls
+	m.alloc(size)
+	m.numElements = 0
+	for i, psl := range psls {
+		if psl != 0 {
+			m.insert(keys[i], values[i], m.hash(keys[i]))
+		}
+	}
+}
+
+func (m *CompactMap[K, V]) alloc(size uint64) {
+	m.size = size
+	m.keys = make([]K, size)
+	m.values = make([]V, size)
+	m.fingerprints = make([]uint32, size)
+	m.psls = make([]uint8, size)
+}
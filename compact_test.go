@@ -0,0 +1,95 @@
+package rhmap
+
+import (
+	"encoding/binary"
+	"testing"
+	"unsafe"
+)
+
+// Checks that every slot of m sits psl slots past its home and that its
+// fingerprint matches its key
+func validateCompact[K comparable, V any](t *testing.T, m *CompactMap[K, V]) {
+	t.Helper()
+	var count uint64
+	for i, psl := range m.psls {
+		if psl == 0 {
+			continue
+		}
+		count++
+		hash := m.hash(m.keys[i])
+		if home := hash & (m.size - 1); (home+uint64(psl)-1)&(m.size-1) != uint64(i) {
+			t.Fatalf("Slot %d holds an element whose PSL %d doesn't lead to it", i, psl-1)
+		}
+		if m.fingerprints[i] != fingerprint(hash) {
+			t.Fatalf("Slot %d has a stale fingerprint", i)
+		}
+	}
+	if count != m.numElements {
+		t.Fatalf("Expected %d elements, Counted %d", m.numElements, count)
+	}
+}
+
+func TestCompactMap(t *testing.T) {
+	m := must(NewCompactMap[string, int32]())
+	for i := 0; i < 5000; i++ {
+		m.Set(string(rune('a'+i%26))+string(rune(i)), int32(i))
+	}
+	for i := 0; i < 5000; i += 3 {
+		m.Delete(string(rune('a'+i%26)) + string(rune(i)))
+	}
+	m.Delete("missing")
+	m.Set("a", -1)
+	m.Set("a", -2)
+	validateCompact(t, m)
+	if m.Len() != 3334 {
+		t.Errorf("Map should contain 3334 elements. Found %d", m.Len())
+	}
+	for i := 0; i < 5000; i++ {
+		v, ok := m.Get(string(rune('a'+i%26)) + string(rune(i)))
+		if ok != (i%3 != 0) || (ok && v != int32(i)) {
+			t.Fatalf("Get of key %d returned %d, %t", i, v, ok)
+		}
+	}
+	if v, _ := m.Get("a"); v != -2 {
+		t.Errorf("Expected -2, Got %d", v)
+	}
+
+	var n int
+	for range m.All() {
+		n++
+	}
+	m.Clear()
+	if n != 3334 || m.Len() != 0 {
+		t.Errorf("All should yield every element and Clear remove them. Got %d, %d", n, m.Len())
+	}
+}
+
+func TestCompactMapLongProbes(t *testing.T) {
+	// Multiples of 1<<16 share home slot 0 until the table outgrows 1<<16
+	// slots, whatever the seeds
+	identity := HasherFunc(func(_, _ uint64, p []byte) uint64 { return binary.LittleEndian.Uint64(p) })
+	m := must(NewCompactMap[uint64, bool](WithHasher(identity), WithSize(1024)))
+	for i := uint64(0); i < 300; i++ {
+		m.Set(i<<16, true)
+	}
+	validateCompact(t, m)
+	if m.reseededSize == 0 {
+		t.Errorf("A table under half full should reseed before growing.")
+	}
+	if m.size < 1<<17 {
+		t.Errorf("Probes past 254 slots should grow the table. Got %d slots", m.size)
+	}
+	for i := uint64(0); i < 300; i++ {
+		if _, ok := m.Get(i << 16); !ok {
+			t.Fatalf("Key %d went missing", i<<16)
+		}
+	}
+}
+
+func TestCompactMapSlotSize(t *testing.T) {
+	var key, value uint32
+	compact := unsafe.Sizeof(key) + unsafe.Sizeof(value) + unsafe.Sizeof(uint32(0)) + unsafe.Sizeof(uint8(0))
+	if full := unsafe.Sizeof(element[uint32, uint32]{}); compact*2 >= full {
+		t.Errorf("Compact slots should take under half the bytes of Map's. Got %d and %d", compact, full)
+	}
+}
@@ -0,0 +1,129 @@
+package rhmap
+
+import "bytes"
+
+// Compressor compresses values for a CompressedMap. Both methods append
+// their output to dst and return the extended slice, so implementations can
+// wrap snappy, zstd or any other codec without this package depending on it.
+type Compressor interface {
+	Compress(dst, src []byte) []byte
+	Decompress(dst, src []byte) ([]byte, error)
+}
+
+// Byte counts of the values held by a CompressedMap
+type CompressionStats struct {
+	// Total length of the values as set
+	RawBytes uint64
+	// Total length of the values as stored, after compression
+	StoredBytes uint64
+	// Number of values stored compressed
+	Compressed uint64
+}
+
+// Returns raw bytes per stored byte, or 1 for an empty map
+func (s CompressionStats) Ratio() float64 {
+	if s.StoredBytes == 0 {
+		return 1
+	}
+	return float64(s.RawBytes) / float64(s.StoredBytes)
+}
+
+// Value as held in a CompressedMap's table
+type storedValue struct {
+	data       []byte
+	rawLen     int
+	compressed bool
+}
+
+// Robin hood hashmap of string or byte slice values that compresses values
+// at least threshold bytes long, trading CPU on Set and Get for memory in
+// maps holding large blobs. Values that don't shrink are kept as they are.
+type CompressedMap[K comparable, V ~string | ~[]byte] struct {
+	table      *Map[K, storedValue]
+	compressor Compressor
+	threshold  int
+	stats      CompressionStats
+}
+
+// Creates a compressed map that compresses values of at least threshold
+// bytes with c. Options apply to the underlying map; WithZeroDeletes has no
+// effect. It returns an error if K can't be encoded, as New does.
+func NewCompressed[K comparable, V ~string | ~[]byte](c Compressor, threshold int, opts ...Option) (*CompressedMap[K, V], error) {
+	table, err := New[K, storedValue](opts...)
+	if err != nil {
+		return nil, err
+	}
+	table.zeroDeletes = false
+	return &CompressedMap[K, V]{table: table, compressor: c, threshold: threshold}, nil
+}
+
+// Stores value under key. Byte slice values are copied, so the caller may
+// reuse value afterwards.
+func (c *CompressedMap[K, V]) Set(key K, value V) {
+	stored := storedValue{rawLen: len(value)}
+	if len(value) >= c.threshold {
+		if data := c.compressor.Compress(nil, []byte(value)); len(data) < len(value) {
+			stored.data, stored.compressed = data, true
+		}
+	}
+	if !stored.compressed {
+		stored.data = append([]byte(nil), value...)
+	}
+
+	hash := c.table.hashKey(key)
+	if old, ok, _ := c.table.getWithHash(key, hash); ok {
+		c.forget(old)
+	}
+	c.table.setWithHash(key, stored, hash)
+
+	c.stats.RawBytes += uint64(stored.rawLen)
+	c.stats.StoredBytes += uint64(len(stored.data))
+	if stored.compressed {
+		c.stats.Compressed++
+	}
+}
+
+// Returns a copy of the value stored under key, decompressing it if needed.
+// The error is the compressor's, should it fail to decompress its own output.
+func (c *CompressedMap[K, V]) Get(key K) (V, bool, error) {
+	var zeroVal V
+	stored, ok := c.table.Get(key)
+	if !ok {
+		return zeroVal, false, nil
+	}
+	if !stored.compressed {
+		return V(bytes.Clone(stored.data)), true, nil
+	}
+
+	data, err := c.compressor.Decompress(make([]byte, 0, stored.rawLen), stored.data)
+	if err != nil {
+		return zeroVal, false, err
+	}
+	return V(data), true, nil
+}
+
+func (c *CompressedMap[K, V]) Delete(key K) {
+	hash := c.table.hashKey(key)
+	if old, ok, _ := c.table.getWithHash(key, hash); ok {
+		c.forget(old)
+		c.table.deleteWithHash(key, hash)
+	}
+}
+
+func (c *CompressedMap[K, V]) Len() uint64 {
+	return c.table.Len()
+}
+
+// Returns the byte counts of the values currently in the map
+func (c *CompressedMap[K, V]) Stats() CompressionStats {
+	return c.stats
+}
+
+// Removes a value that is being replaced or deleted from the stats
+func (c *CompressedMap[K, V]) forget(old storedValue) {
+	c.stats.RawBytes -= uint64(old.rawLen)
+	c.stats.StoredBytes -= uint64(len(old.data))
+	if old.compressed {
+		c.stats.Compressed--
+	}
+}
@@ -0,0 +1,84 @@
+package rhmap
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"strings"
+	"testing"
+)
+
+type flateCompressor struct{}
+
+func (flateCompressor) Compress(dst, src []byte) []byte {
+	buf := bytes.NewBuffer(dst)
+	w, _ := flate.NewWriter(buf, flate.BestSpeed)
+	w.Write(src)
+	w.Close()
+	return buf.Bytes()
+}
+
+func (flateCompressor) Decompress(dst, src []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+	_, err := io.Copy(buf, flate.NewReader(bytes.NewReader(src)))
+	return buf.Bytes(), err
+}
+
+func TestCompressedMap(t *testing.T) {
+	m := must(NewCompressed[int, string](flateCompressor{}, 64))
+
+	large := strings.Repeat("abcd", 1000)
+	m.Set(1, large)
+	m.Set(2, "short")
+	// Random-looking data that flate can't shrink is stored as it is
+	noisy := make([]byte, 100)
+	for i := range noisy {
+		noisy[i] = byte(i * 7919 >> 3)
+	}
+	m.Set(3, string(noisy))
+
+	for key, want := range map[int]string{1: large, 2: "short", 3: string(noisy)} {
+		if val, ok, err := m.Get(key); err != nil || !ok || val != want {
+			t.Errorf("Key %d should round-trip its value. Got %d bytes, %t, %v", key, len(val), ok, err)
+		}
+	}
+
+	stats := m.Stats()
+	if stats.Compressed != 1 {
+		t.Errorf("Only the large value should be compressed. Got %d compressed", stats.Compressed)
+	}
+	if stats.RawBytes != uint64(len(large)+len("short")+len(noisy)) {
+		t.Errorf("RawBytes should total the values as set. Got %d", stats.RawBytes)
+	}
+	if stats.Ratio() < 5 {
+		t.Errorf("A repetitive value should compress well. Got ratio %.2f", stats.Ratio())
+	}
+
+	m.Set(1, "replaced")
+	m.Delete(3)
+	m.Delete(4)
+	stats = m.Stats()
+	if stats.Compressed != 0 || stats.RawBytes != uint64(len("replaced")+len("short")) || stats.StoredBytes != stats.RawBytes {
+		t.Errorf("Stats should drop replaced and deleted values. Got %+v", stats)
+	}
+	if m.Len() != 2 {
+		t.Errorf("Map should contain 2 elements. Found %d", m.Len())
+	}
+}
+
+func TestCompressedMapByteValues(t *testing.T) {
+	m := must(NewCompressed[string, []byte](flateCompressor{}, 0))
+
+	value := []byte("value value value value")
+	m.Set("key", value)
+	value[0] = 'X'
+
+	got, ok, err := m.Get("key")
+	if err != nil || !ok || string(got) != "value value value value" {
+		t.Errorf("Set should copy byte slice values. Got %q, %t, %v", got, ok, err)
+	}
+	got[0] = 'Y'
+	if again, _, _ := m.Get("key"); again[0] != 'v' {
+		t.Errorf("Get should return a copy. Got %q", again)
+	}
+}
@@ -0,0 +1,307 @@
+package rhmap
+
+import (
+	"math/bits"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// Robin hood table guarded by its own lock
+type shard[K comparable, V any] struct {
+	mu    sync.RWMutex
+	table *Map[K, V]
+}
+
+// Robin hood hashmap safe for concurrent use. Keys are spread across
+// independent shards by hash, each behind its own RWMutex, so goroutines
+// working on different shards never contend and readers of the same shard
+// proceed in parallel.
+type ConcurrentMap[K comparable, V any] struct {
+	shards    []shard[K, V]
+	shardBits uint
+	// Registry entry of the whole map under WithName, or nil
+	registration *registration
+	// Where the shards' gauges are reported in sum, or nil, and the Sets and
+	// Deletes since the map was created, which pace the reports
+	metrics   MetricsSink
+	metricOps atomic.Uint64
+}
+
+// Creates a concurrent map with numShards shards, rounded up to a power of
+// two, or four per CPU if numShards is 0. Options apply to every shard,
+// except that WithSize and WithMaxEntries give the capacity and bound of
+// the whole map, which are split evenly among the shards, and the map is
+// registered and reports its gauges as one. A bounded map may turn keys
+// away before it is full if they spread unevenly. It returns an error if K
+// can't be encoded, as New does.
+func NewConcurrent[K comparable, V any](numShards int, opts ...Option) (*ConcurrentMap[K, V], error) {
+	if numShards <= 0 {
+		numShards = 4 * runtime.GOMAXPROCS(0)
+	}
+	shardBits := uint(bits.Len(uint(numShards - 1)))
+	numShards = 1 << shardBits
+
+	opts = slices.Clip(opts)
+	o := resolveOptions(opts)
+	if o.size > 0 {
+		opts = append(opts, WithSize(max(defaultSize, o.size>>shardBits)))
+	}
+	if o.maxEntries > 0 {
+		opts = append(opts, WithMaxEntries(max(1, (o.maxEntries+uint64(numShards)-1)>>shardBits)))
+	}
+	c := &ConcurrentMap[K, V]{
+		shards:    make([]shard[K, V], numShards),
+		shardBits: shardBits,
+	}
+	if o.metrics != nil {
+		c.metrics = o.metricsSink()
+		opts = append(opts, WithMetrics(countingSink{c.metrics}))
+	}
+	opts = append(opts, WithName(""))
+	first, err := New[K, V](opts...)
+	if err != nil {
+		return nil, err
+	}
+	c.shards[0].table = first
+	// Every shard must hash identically for keys to be routed by one hash, so
+	// none may reseed itself
+	first.autoReseed = false
+	opts = append(opts, WithSeedsFrom(first))
+	for i := 1; i < numShards; i++ {
+		c.shards[i].table = newMap[K, V](first.enc, opts...)
+		// Misses are counted across shards, so that the most missed keys are
+		// those of the whole map
+		c.shards[i].table.misses = first.misses
+	}
+	if o.name != "" {
+		shards := c.shards
+		c.registration = &registration{name: o.name, labels: o.labels, collect: func() Stats {
+			return concurrentStats(shards)
+		}}
+		addRegistration(c.registration)
+		runtime.AddCleanup(c, unregister, c.registration)
+	}
+
+	return c, nil
+}
+
+func (c *ConcurrentMap[K, V]) Set(key K, value V) {
+	hash := c.shards[0].table.hashKey(key)
+	s := c.shardFor(hash)
+	s.mu.Lock()
+	s.table.setWithHash(key, value, hash)
+	s.mu.Unlock()
+	c.countOp()
+}
+
+// Returns the value under key, recording misses, metrics and the access as
+// Map.Get does. Under an eviction policy that tracks accesses, such as
+// EvictLRU, Get takes the shard's write lock rather than its read lock.
+func (c *ConcurrentMap[K, V]) Get(key K) (V, bool) {
+	hash := c.shards[0].table.hashKey(key)
+	s := c.shardFor(hash)
+	if s.table.evictor != nil || s.table.admission != nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.table.getRecorded(key, hash)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.table.getRecorded(key, hash)
+}
+
+// Returns a copy of the value under key made by clone, as Map.GetClone
+// does. clone runs under the shard's read lock, so the copy is consistent
+// with concurrent writers.
+func (c *ConcurrentMap[K, V]) GetClone(key K, clone func(V) V) (V, bool) {
+	hash := c.shards[0].table.hashKey(key)
+	s := c.shardFor(hash)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	val, ok, _ := s.table.getWithHash(key, hash)
+	if !ok {
+		return val, false
+	}
+	return clone(val), true
+}
+
+func (c *ConcurrentMap[K, V]) Delete(key K) {
+	hash := c.shards[0].table.hashKey(key)
+	s := c.shardFor(hash)
+	s.mu.Lock()
+	if s.table.numElements > 0 {
+		s.table.removeWithHash(key, hash)
+	}
+	s.mu.Unlock()
+	c.countOp()
+}
+
+// Returns the number of elements across all shards. Under concurrent
+// mutation the count is only a point-in-time estimate.
+func (c *ConcurrentMap[K, V]) Len() uint64 {
+	var n uint64
+	for i := range c.shards {
+		c.shards[i].mu.RLock()
+		n += c.shards[i].table.numElements
+		c.shards[i].mu.RUnlock()
+	}
+	return n
+}
+
+// Waits until the eviction callbacks of every element evicted so far under
+// WithAsyncEviction have returned, in every shard
+func (c *ConcurrentMap[K, V]) WaitEvictions() {
+	for i := range c.shards {
+		c.shards[i].table.WaitEvictions()
+	}
+}
+
+// Calls fn for every element, stopping early if fn returns false. Each shard
+// is iterated from a snapshot taken when Range reaches it, without holding
+// its lock, so fn may modify the map. Elements set or deleted concurrently
+// in shards not yet reached may or may not be seen.
+func (c *ConcurrentMap[K, V]) Range(fn func(K, V) bool) {
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		snapshot := s.table.SnapshotIter()
+		s.mu.Unlock()
+
+		for k, v := range snapshot {
+			if !fn(k, v) {
+				return
+			}
+		}
+	}
+}
+
+// Calls fn for every element as Range does, with values copied by clone.
+// Unlike Range, which yields values from a snapshot that may share their
+// references with the map, clone runs under the shard's lock, so the
+// copies are consistent with concurrent writers.
+func (c *ConcurrentMap[K, V]) RangeCloned(clone func(V) V, fn func(K, V) bool) {
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.RLock()
+		elements := make([]Entry[K, V], 0, s.table.numElements)
+		for k, v := range s.table.All() {
+			elements = append(elements, Entry[K, V]{k, clone(v)})
+		}
+		s.mu.RUnlock()
+
+		for _, e := range elements {
+			if !fn(e.Key, e.Value) {
+				return
+			}
+		}
+	}
+}
+
+// Shard owning a hash. The hash is remixed by a Fibonacci multiply before
+// taking its leading bits, so routing doesn't correlate with the bits the
+// shard's table uses to pick a slot.
+func (c *ConcurrentMap[K, V]) shardFor(hash uint64) *shard[K, V] {
+	return &c.shards[c.shardIndex(hash)]
+}
+
+func (c *ConcurrentMap[K, V]) shardIndex(hash uint64) uint64 {
+	return (hash * 0x9e3779b97f4a7c15) >> (64 - c.shardBits)
+}
+
+// Returns the value under key and true if present, or sets key to value and
+// returns value and false, as one atomic step
+func (c *ConcurrentMap[K, V]) loadOrStore(key K, value V) (V, bool) {
+	hash := c.shards[0].table.hashKey(key)
+	s := c.shardFor(hash)
+	s.mu.Lock()
+	if val, ok, _ := s.table.getWithHash(key, hash); ok {
+		s.mu.Unlock()
+		return val, true
+	}
+	s.table.setWithHash(key, value, hash)
+	s.mu.Unlock()
+	c.countOp()
+	return value, false
+}
+
+// Deletes every element for which fn returns true, one shard at a time, and
+// returns how many were deleted. fn runs under the shard's lock and must not
+// call back into the map.
+func (c *ConcurrentMap[K, V]) deleteWhere(fn func(K, V) bool) int {
+	deleted := 0
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		var keys []K
+		for k, v := range s.table.All() {
+			if fn(k, v) {
+				keys = append(keys, k)
+			}
+		}
+		deleted += s.table.DeleteAll(keys)
+		s.mu.Unlock()
+	}
+	return deleted
+}
+
+// Counts a write, reporting the gauges of the whole map every
+// metricsGaugeInterval writes
+func (c *ConcurrentMap[K, V]) countOp() {
+	if c.metrics != nil && c.metricOps.Add(1)%metricsGaugeInterval == 0 {
+		s := concurrentStats(c.shards)
+		c.metrics.Gauge(MetricLen, float64(s.Len))
+		c.metrics.Gauge(MetricCapacity, float64(s.Capacity))
+		c.metrics.Gauge(MetricLoadFactor, s.Load)
+		c.metrics.Gauge(MetricMaxPsl, float64(s.MaxPsl))
+	}
+}
+
+// Sums the running statistics of shards, as Registry lists them, taking
+// each shard's read lock in turn
+func concurrentStats[K comparable, V any](shards []shard[K, V]) Stats {
+	var s Stats
+	var totalPsl uint64
+	for i := range shards {
+		sh := &shards[i]
+		sh.mu.RLock()
+		m := sh.table
+		s.Len += m.numElements
+		s.Capacity += m.size
+		s.MaxPsl = max(s.MaxPsl, m.MaxPSL())
+		s.Resizes += m.resizes
+		totalPsl += m.totalPsl
+		if m.draining != nil {
+			totalPsl += m.draining.totalPsl
+		}
+		if m.latency != nil {
+			s.Latency = mergeLatency(s.Latency, m.latency.histograms())
+		}
+		if m.hits != nil {
+			hits, misses := m.hits.counts()
+			s.RecentHits += hits
+			s.RecentMisses += misses
+		}
+		sh.mu.RUnlock()
+	}
+	if s.Capacity > 0 {
+		s.Load = float64(s.Len) / float64(s.Capacity)
+	}
+	if s.Len > 0 {
+		s.MeanPsl = float64(totalPsl) / float64(s.Len)
+	}
+	s.HitRatio = ratioOf(s.RecentHits, s.RecentMisses)
+	if t := shards[0].table.misses; t != nil {
+		s.Misses, s.TopMisses = t.top()
+	}
+	return s
+}
+
+// MetricsSink passing on everything but gauges, which shards of a
+// ConcurrentMap would each report for themselves
+type countingSink struct {
+	MetricsSink
+}
+
+func (countingSink) Gauge(string, float64) {}
@@ -0,0 +1,168 @@
+package rhmap
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestConcurrentMap(t *testing.T) {
+	m := must(NewConcurrent[int, int](5))
+	if len(m.shards) != 8 {
+		t.Errorf("Shard count should round up to a power of two. Expected 8, Got %d", len(m.shards))
+	}
+
+	for i := 0; i < 10000; i++ {
+		m.Set(i, i)
+	}
+	m.Set(5, 50)
+	if m.Len() != 10000 {
+		t.Errorf("Map should contain 10000 elements. Found %d", m.Len())
+	}
+	for i := 0; i < 10000; i++ {
+		want := i
+		if i == 5 {
+			want = 50
+		}
+		if val, ok := m.Get(i); !ok || val != want {
+			t.Errorf("Key %d should map to %d. Got %d, %t", i, want, val, ok)
+		}
+	}
+
+	for i := range m.shards {
+		if n := m.shards[i].table.Len(); n < 10000/8/2 {
+			t.Errorf("Keys should spread evenly across shards. Shard %d holds %d", i, n)
+		}
+	}
+
+	for i := 0; i < 10000; i += 2 {
+		m.Delete(i)
+	}
+	m.Delete(-1)
+	if m.Len() != 5000 {
+		t.Errorf("Map should contain 5000 elements after deleting evens. Found %d", m.Len())
+	}
+	for i := 0; i < 10000; i++ {
+		if _, ok := m.Get(i); ok != (i%2 == 1) {
+			t.Errorf("Key %d should be present: %t", i, i%2 == 1)
+		}
+	}
+}
+
+func TestConcurrentMapInstrumented(t *testing.T) {
+	sink := newRecordingSink()
+	m := must(NewConcurrent[int, int](4, WithName("concurrent-test"), WithMetrics(sink), WithMissStats(4),
+		WithMaxEntries(1000), WithEvictionPolicy(EvictLRU)))
+	for i := 0; i < 2*metricsGaugeInterval; i++ {
+		m.Set(i, i)
+	}
+	m.Get(-1)
+	m.Get(-1)
+
+	var infos []MapInfo
+	for _, info := range Registry() {
+		if info.Name == "concurrent-test" {
+			infos = append(infos, info)
+		}
+	}
+	if len(infos) != 1 {
+		t.Fatalf("The map should be registered once. Found %d entries", len(infos))
+	}
+	if infos[0].Len != m.Len() || infos[0].Misses != 2 || infos[0].Capacity < infos[0].Len {
+		t.Errorf("The registry should sum the shards. Got %+v", infos[0].Stats)
+	}
+	if sink.gauges[MetricLen] != float64(m.Len()) || sink.counters[MetricGets] != 2 {
+		t.Errorf("Gauges should cover the whole map and Gets be counted. Got %v, %v", sink.gauges, sink.counters)
+	}
+	if cfg := m.Config(); cfg.Name != "concurrent-test" || cfg.MaxElements != 1000 {
+		t.Errorf("Config should describe the whole map. Got %+v", cfg)
+	}
+
+	// Each shard holds its share of the bound, and Get keeps keys recent
+	for i := 0; i < 2000; i++ {
+		m.Set(i, i)
+		m.Get(0)
+	}
+	if n := m.Len(); n > 1000 {
+		t.Errorf("The map should hold at most 1000 elements. Found %d", n)
+	}
+	if _, ok := m.Get(0); !ok {
+		t.Errorf("A key read after every write should not be evicted.")
+	}
+}
+
+func TestConcurrentMapRange(t *testing.T) {
+	m := must(NewConcurrent[int, int](4))
+	for i := 0; i < 1000; i++ {
+		m.Set(i, i)
+	}
+
+	// fn mutates the map, which would deadlock if Range held a shard lock
+	seen := make(map[int]bool)
+	m.Range(func(k, v int) bool {
+		if k != v {
+			t.Errorf("Key %d should map to itself. Got %d", k, v)
+		}
+		seen[k] = true
+		m.Delete(k)
+		return true
+	})
+	if len(seen) != 1000 {
+		t.Errorf("Range should visit all 1000 elements. Visited %d", len(seen))
+	}
+	if m.Len() != 0 {
+		t.Errorf("Every element should have been deleted during Range. Found %d", m.Len())
+	}
+
+	m.Set(1, 1)
+	m.Set(2, 2)
+	calls := 0
+	m.Range(func(k, v int) bool {
+		calls++
+		return false
+	})
+	if calls != 1 {
+		t.Errorf("Range should stop once fn returns false. Expected 1 call, Got %d", calls)
+	}
+}
+
+func TestConcurrentMapParallel(t *testing.T) {
+	m := must(NewConcurrent[int, int](0))
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := g * 1000; i < (g+1)*1000; i++ {
+				m.Set(i, i)
+				if val, ok := m.Get(i); !ok || val != i {
+					t.Errorf("Key %d should map to %d. Got %d, %t", i, i, val, ok)
+				}
+				if i%2 == 0 {
+					m.Delete(i)
+				}
+			}
+			m.Range(func(k, v int) bool { return true })
+		}()
+	}
+	wg.Wait()
+
+	if m.Len() != 4000 {
+		t.Errorf("Map should contain 4000 elements. Found %d", m.Len())
+	}
+}
+
+func BenchmarkConcurrentMapGet(b *testing.B) {
+	m := must(NewConcurrent[int, int](0))
+	for i := 0; i < 1024; i++ {
+		m.Set(i, i)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			m.Get(i % 1024)
+			i++
+		}
+	})
+}
@@ -0,0 +1,139 @@
+package rhmap
+
+import "time"
+
+// Effective configuration of a map or map variant as reported by its Config
+// method. It encodes with encoding/json or gob, so services can log exactly
+// how their maps are set up.
+type Config struct {
+	// Slots allocated, summed across shards or segments
+	Capacity uint64
+	// Load at which a table grows
+	LoadFactor float32
+	// Load below which deletes shrink a table, or 0 if tables never shrink
+	ShrinkLoad float32
+	// "double" for tables that rebuild at a larger size, "incremental" for
+	// tables that grow but migrate over later writes, or "split" for a
+	// SegmentedMap, which splits single segments
+	Growth string
+	// Factor a table grows by, or 0 if a WithGrowthPolicy sizes it
+	GrowthFactor float64
+	// "siphash", "xxhash", "fnv1a", "int", "maphash" or "custom"
+	Hasher      string
+	FastRange   bool
+	ZeroDeletes bool
+	// Whether tables are mapped outside the Go heap under WithArena
+	Arena bool
+	// Name given with WithName, if any
+	Name string
+	// Shards of a ConcurrentMap or segments of a SegmentedMap, 1 otherwise
+	Shards int
+	// "none", "lru" or "ttl", or for a map bounded by WithMaxEntries
+	// "reject", "random", "lru", "lfu", "fifo" or "custom" for an Evictor
+	Eviction string
+	// Element limit of an LRU or bounded map, 0 if unbounded
+	MaxElements uint64
+	// Default time to live of an ExpiringMap, 0 if elements never expire
+	TTL time.Duration
+}
+
+func (m *Map[K, V]) Config() Config {
+	c := Config{
+		Capacity:    m.size,
+		LoadFactor:  m.loadFactor,
+		ShrinkLoad:  m.shrinkLoad,
+		Growth:      "double",
+		Hasher:      hasherName(m.hasher),
+		FastRange:   m.fastRange,
+		ZeroDeletes: m.zeroDeletes,
+		Arena:       m.arena,
+		Shards:      1,
+		Eviction:    "none",
+	}
+	if m.rehashStep > 0 {
+		c.Growth = "incremental"
+	}
+	switch {
+	case m.growthPolicy != nil:
+	case m.growthFactor > 1:
+		c.GrowthFactor = m.growthFactor
+	default:
+		c.GrowthFactor = 2
+	}
+	if m.maxEntries > 0 {
+		c.Eviction = [...]string{RejectNew: "reject", EvictRandom: "random", EvictLRU: "lru", EvictLFU: "lfu", EvictFIFO: "fifo"}[m.eviction]
+		switch m.evictor.(type) {
+		case nil, *listEvictor[K], *lfuEvictor[K]:
+		default:
+			c.Eviction = "custom"
+		}
+		c.MaxElements = m.maxEntries
+	}
+	if m.registration != nil {
+		c.Name = m.registration.name
+	}
+	return c
+}
+
+func (c *ConcurrentMap[K, V]) Config() Config {
+	var capacity uint64
+	for i := range c.shards {
+		c.shards[i].mu.RLock()
+		capacity += c.shards[i].table.size
+		c.shards[i].mu.RUnlock()
+	}
+
+	c.shards[0].mu.RLock()
+	cfg := c.shards[0].table.Config()
+	c.shards[0].mu.RUnlock()
+	cfg.Capacity = capacity
+	cfg.Shards = len(c.shards)
+	cfg.MaxElements *= uint64(len(c.shards))
+	if c.registration != nil {
+		cfg.Name = c.registration.name
+	}
+	return cfg
+}
+
+func (s *SegmentedMap[K, V]) Config() Config {
+	var capacity uint64
+	for i, seg := range s.dir {
+		if i == 0 || seg != s.dir[i-1] {
+			capacity += seg.table.size
+		}
+	}
+
+	cfg := s.dir[0].table.Config()
+	cfg.Capacity = capacity
+	cfg.Growth = "split"
+	cfg.Shards = s.Segments()
+	return cfg
+}
+
+func (c *LRU[K, V]) Config() Config {
+	cfg := c.table.Config()
+	cfg.Eviction = "lru"
+	cfg.MaxElements = uint64(c.capacity)
+	return cfg
+}
+
+func (e *ExpiringMap[K, V]) Config() Config {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	cfg := e.table.Config()
+	cfg.Eviction = "ttl"
+	cfg.TTL = e.ttl
+	return cfg
+}
+
+// Names h for a Config, including hashers that can't be encoded
+func hasherName(h Hasher) string {
+	if name := builtinHasherName(h); name != "" {
+		return name
+	}
+	if _, ok := h.(*MapHasher); ok {
+		return "maphash"
+	}
+	return "custom"
+}
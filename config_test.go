@@ -0,0 +1,62 @@
+package rhmap
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestMapConfig(t *testing.T) {
+	m := must(New[int, int](WithSize(100), WithHasher(XXHasher{}), WithFastRange(), WithShrink(.1), WithName("config-test")))
+	want := Config{
+		Capacity:     128,
+		LoadFactor:   defaultLoadFactor,
+		ShrinkLoad:   .1,
+		Growth:       "double",
+		GrowthFactor: 2,
+		Hasher:       "xxhash",
+		FastRange:    true,
+		Name:         "config-test",
+		Shards:       1,
+		Eviction:     "none",
+	}
+	if got := m.Config(); got != want {
+		t.Errorf("Expected %+v, Got %+v", want, got)
+	}
+
+	data := must(json.Marshal(m.Config()))
+	var decoded Config
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded != want {
+		t.Errorf("Config should round-trip through JSON. Got %+v, %v", decoded, err)
+	}
+
+	custom := must(New[int, int](WithHasher(HasherFunc(func(k0, k1 uint64, p []byte) uint64 { return 0 }))))
+	if got := custom.Config().Hasher; got != "custom" {
+		t.Errorf("Custom hashers should be reported as custom. Got %q", got)
+	}
+}
+
+func TestVariantConfig(t *testing.T) {
+	c := must(NewConcurrent[int, int](4, WithSize(64)))
+	if cfg := c.Config(); cfg.Shards != 4 || cfg.Capacity != 64 || cfg.Hasher != "siphash" {
+		t.Errorf("Expected 4 shards of 16 slots hashed with siphash. Got %+v", cfg)
+	}
+
+	s := must(NewSegmented[int, int](64))
+	for i := 0; i < 200; i++ {
+		s.Set(i, i)
+	}
+	if cfg := s.Config(); cfg.Growth != "split" || cfg.Shards != s.Segments() || cfg.Capacity != uint64(64*s.Segments()) {
+		t.Errorf("Segmented config should describe its %d segments of 64 slots. Got %+v", s.Segments(), cfg)
+	}
+
+	l := must(NewLRU[int, int](10, nil))
+	if cfg := l.Config(); cfg.Eviction != "lru" || cfg.MaxElements != 10 {
+		t.Errorf("LRU config should report LRU eviction of 10 elements. Got %+v", cfg)
+	}
+
+	e := must(NewExpiring[int, int](time.Minute))
+	if cfg := e.Config(); cfg.Eviction != "ttl" || cfg.TTL != time.Minute {
+		t.Errorf("Expiring config should report a one-minute TTL. Got %+v", cfg)
+	}
+}
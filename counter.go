@@ -0,0 +1,118 @@
+package rhmap
+
+import (
+	"cmp"
+	"container/heap"
+	"iter"
+	"slices"
+)
+
+// Robin hood hashmap of counts, for frequency counting. Add hashes and
+// probes a key once, where Get followed by Set would do both twice. Absent
+// keys count 0. Under WithZeroDeletes, keys whose count returns to 0 are
+// deleted.
+type Counter[K comparable] struct {
+	table *Map[K, int64]
+}
+
+// Creates a counter configured by opts. It returns an error if K can't be
+// encoded, as New does.
+func NewCounter[K comparable](opts ...Option) (*Counter[K], error) {
+	table, err := New[K, int64](opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Counter[K]{table: table}, nil
+}
+
+// Adds delta to key's count and returns the new count
+func (c *Counter[K]) Add(key K, delta int64) int64 {
+	count, _ := c.table.Compute(key, func(old int64, _ bool) (int64, bool) {
+		return old + delta, true
+	})
+	return count
+}
+
+func (c *Counter[K]) Inc(key K) int64 {
+	return c.Add(key, 1)
+}
+
+func (c *Counter[K]) Dec(key K) int64 {
+	return c.Add(key, -1)
+}
+
+// Returns key's count, 0 if it was never counted
+func (c *Counter[K]) Get(key K) int64 {
+	count, _ := c.table.Get(key)
+	return count
+}
+
+func (c *Counter[K]) Delete(key K) {
+	c.table.Delete(key)
+}
+
+// Returns the number of keys counted
+func (c *Counter[K]) Len() uint64 {
+	return c.table.Len()
+}
+
+// Iterates over every key and its count, in no particular order
+func (c *Counter[K]) All() iter.Seq2[K, int64] {
+	return c.table.All()
+}
+
+// Returns the n keys with the highest counts, highest first. Ties are broken
+// arbitrarily. It keeps a heap of n entries rather than sorting every key.
+func (c *Counter[K]) Top(n int) []Entry[K, int64] {
+	if n <= 0 {
+		return nil
+	}
+	// Min-heap of the n highest counts so far
+	var top countHeap[K]
+	for key, count := range c.table.All() {
+		if len(top) < n {
+			heap.Push(&top, Entry[K, int64]{key, count})
+		} else if count > top[0].Value {
+			top[0] = Entry[K, int64]{key, count}
+			heap.Fix(&top, 0)
+		}
+	}
+	slices.SortFunc(top, func(a, b Entry[K, int64]) int {
+		return cmp.Compare(b.Value, a.Value)
+	})
+	return top
+}
+
+// Iterates over every key and its count, highest count first. The entries
+// are copied and sorted when iteration starts, so the counter may be
+// modified during iteration.
+func (c *Counter[K]) AllByCount() iter.Seq2[K, int64] {
+	return func(yield func(K, int64) bool) {
+		entries := make([]Entry[K, int64], 0, c.table.Len())
+		for key, count := range c.table.All() {
+			entries = append(entries, Entry[K, int64]{key, count})
+		}
+		slices.SortFunc(entries, func(a, b Entry[K, int64]) int {
+			return cmp.Compare(b.Value, a.Value)
+		})
+		for _, e := range entries {
+			if !yield(e.Key, e.Value) {
+				return
+			}
+		}
+	}
+}
+
+type countHeap[K comparable] []Entry[K, int64]
+
+func (h countHeap[K]) Len() int           { return len(h) }
+func (h countHeap[K]) Less(i, j int) bool { return h[i].Value < h[j].Value }
+func (h countHeap[K]) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *countHeap[K]) Push(x any)        { *h = append(*h, x.(Entry[K, int64])) }
+
+func (h *countHeap[K]) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
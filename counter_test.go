@@ -0,0 +1,55 @@
+package rhmap
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCounter(t *testing.T) {
+	c := must(NewCounter[string]())
+	words := []string{"a", "b", "a", "c", "a", "b", "d"}
+	for _, w := range words {
+		c.Inc(w)
+	}
+	if got := c.Add("c", 10); got != 11 {
+		t.Errorf("Add should return the new count. Expected 11, Got %d", got)
+	}
+	if got := c.Dec("d"); got != 0 || c.Len() != 4 {
+		t.Errorf("A count at 0 should be kept by default. Got %d with %d keys", got, c.Len())
+	}
+	if c.Get("a") != 3 || c.Get("missing") != 0 {
+		t.Errorf("Get should return counts, 0 for absent keys. Got %d, %d", c.Get("a"), c.Get("missing"))
+	}
+
+	top := c.Top(2)
+	if !slices.Equal(top, []Entry[string, int64]{{"c", 11}, {"a", 3}}) {
+		t.Errorf("Top should return the highest counts first. Got %v", top)
+	}
+	if len(c.Top(10)) != 4 || c.Top(0) != nil {
+		t.Errorf("Top should return at most every key, and nothing for n = 0.")
+	}
+
+	var order []string
+	for key := range c.AllByCount() {
+		order = append(order, key)
+		c.Delete(key)
+	}
+	if !slices.Equal(order, []string{"c", "a", "b", "d"}) {
+		t.Errorf("AllByCount should visit the highest counts first. Got %v", order)
+	}
+
+	z := must(NewCounter[int](WithZeroDeletes()))
+	z.Inc(1)
+	z.Dec(1)
+	if z.Len() != 0 {
+		t.Errorf("Under WithZeroDeletes, a count returning to 0 should be deleted.")
+	}
+}
+
+func TestCounterAddDoesNotAllocate(t *testing.T) {
+	c := must(NewCounter[int]())
+	c.Inc(1)
+	if allocs := testing.AllocsPerRun(100, func() { c.Add(1, 2) }); allocs != 0 {
+		t.Errorf("Incrementing a present key should not allocate. Got %f allocations", allocs)
+	}
+}
@@ -0,0 +1,98 @@
+package rhmap
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Sighting of a key in a Deduper's window
+type sighting[K comparable] struct {
+	key  K
+	seen int64
+}
+
+// Detects repeated keys within a sliding window bounded by time, by count or
+// both, as used to drop duplicate events in stream processors. Keys are
+// queued in the order they were first seen, so the oldest sightings are
+// always at the front and leave the window in O(1) without any sweeping.
+// A Deduper is safe for concurrent use.
+type Deduper[K comparable] struct {
+	mu      sync.Mutex
+	table   *Map[K, struct{}]
+	queue   []sighting[K]
+	head    int
+	window  time.Duration
+	maxKeys int
+	clock   Clock
+}
+
+// Creates a deduper remembering keys for window after they are first seen
+// and at most maxKeys keys at a time. A zero window or maxKeys leaves that
+// bound off, but at least one must be set. Options configure the underlying
+// map, and WithClock sets the clock the window is measured by.
+func NewDeduper[K comparable](window time.Duration, maxKeys int, opts ...Option) (*Deduper[K], error) {
+	if window < 0 || maxKeys < 0 || (window == 0 && maxKeys == 0) {
+		return nil, errors.New("rhmap: deduper needs a positive window or key limit")
+	}
+	table, err := New[K, struct{}](opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	clock := resolveOptions(opts).clock
+	if clock == nil {
+		clock = realClock{}
+	}
+	return &Deduper[K]{table: table, window: window, maxKeys: maxKeys, clock: clock}, nil
+}
+
+// Reports whether key was already seen within the window. If not, key is
+// recorded as seen now.
+func (d *Deduper[K]) Seen(key K) bool {
+	now := d.clock.Now().UnixNano()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.expire(now)
+
+	hash := d.table.hashKey(key)
+	if _, ok, _ := d.table.getWithHash(key, hash); ok {
+		return true
+	}
+	d.table.setWithHash(key, struct{}{}, hash)
+	d.queue = append(d.queue, sighting[K]{key, now})
+	if d.maxKeys > 0 && len(d.queue)-d.head > d.maxKeys {
+		d.pop()
+	}
+	return false
+}
+
+// Returns the number of keys in the window
+func (d *Deduper[K]) Len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.expire(d.clock.Now().UnixNano())
+	return len(d.queue) - d.head
+}
+
+// Forgets the sightings that have left the time window
+func (d *Deduper[K]) expire(now int64) {
+	if d.window == 0 {
+		return
+	}
+	for d.head < len(d.queue) && now-d.queue[d.head].seen >= int64(d.window) {
+		d.pop()
+	}
+}
+
+// Forgets the oldest sighting, compacting the queue once half of it is spent
+func (d *Deduper[K]) pop() {
+	d.table.Delete(d.queue[d.head].key)
+	d.queue[d.head] = sighting[K]{}
+	d.head++
+	if d.head > len(d.queue)/2 {
+		d.queue = d.queue[:copy(d.queue, d.queue[d.head:])]
+		d.head = 0
+	}
+}
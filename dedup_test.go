@@ -0,0 +1,55 @@
+package rhmap
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeduperTimeWindow(t *testing.T) {
+	clock := newFakeClock()
+	d := must(NewDeduper[string](time.Minute, 0, WithClock(clock)))
+
+	if d.Seen("a") {
+		t.Errorf("A new key should not have been seen.")
+	}
+	clock.Advance(30 * time.Second)
+	if !d.Seen("a") {
+		t.Errorf("a should be a duplicate within the window.")
+	}
+	d.Seen("b")
+
+	// The window runs from the first sighting, so a is forgotten now
+	clock.Advance(30 * time.Second)
+	if d.Seen("a") {
+		t.Errorf("a should have left the window.")
+	}
+	if !d.Seen("b") {
+		t.Errorf("b should still be in the window.")
+	}
+	if d.Len() != 2 {
+		t.Errorf("Window should hold 2 keys. Found %d", d.Len())
+	}
+}
+
+func TestDeduperCountWindow(t *testing.T) {
+	d := must(NewDeduper[int](0, 100))
+
+	for i := 0; i < 1000; i++ {
+		if d.Seen(i) {
+			t.Errorf("Key %d should be new.", i)
+		}
+	}
+	if d.Len() != 100 {
+		t.Errorf("Window should hold the last 100 keys. Found %d", d.Len())
+	}
+	if !d.Seen(950) || d.Seen(850) {
+		t.Errorf("Only the last 100 keys should be remembered.")
+	}
+	if len(d.queue) > 200 {
+		t.Errorf("The queue should be compacted. Found %d sightings", len(d.queue))
+	}
+
+	if _, err := NewDeduper[int](0, 0); err == nil {
+		t.Errorf("A deduper without any bound should be rejected.")
+	}
+}
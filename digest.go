@@ -0,0 +1,166 @@
+package rhmap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"iter"
+	"math/bits"
+	"reflect"
+	"unsafe"
+
+	"github.com/dchest/siphash"
+)
+
+// Fixed SipHash keys for digests, which must agree between replicas whatever
+// seeds and hasher each map uses
+const (
+	digestK0 uint64 = 0x736f6d6570736575
+	digestK1 uint64 = 0x646f72616e646f6d
+)
+
+// Returns an order-independent hash of the map's elements: the sum of a
+// hash of each key and value under fixed keys. Replicas holding the same
+// elements have the same digest regardless of their seeds, hashers or
+// insertion order, so comparing digests tells whether a full comparison is
+// needed. Pointer and channel keys digest by address and only agree within
+// a process; other values are encoded by their contents, with gob for
+// composite types. Gob encodes built-in maps in iteration order, so values
+// holding them don't digest reliably.
+func (m *Map[K, V]) Digest() uint64 {
+	return m.DigestTree(0)[0]
+}
+
+// Digests of a map's elements grouped into 2^depth buckets by key and
+// summed up a binary tree: node 0 is the root, equal to the map's Digest,
+// node i has children 2i+1 and 2i+2, and the last 2^depth nodes are the
+// buckets. Replicas exchange trees and descend only into nodes that differ
+// to find the buckets that diverge.
+type DigestTree []uint64
+
+// Returns the map's digest tree with 2^depth buckets
+func (m *Map[K, V]) DigestTree(depth uint) DigestTree {
+	tree := make(DigestTree, 2<<depth-1)
+	leaves := tree[1<<depth-1:]
+	appendValue := valueAppender[V]()
+	var buf []byte
+	for _, elems := range m.tables() {
+		for i := range elems {
+			if elems[i].set {
+				var d uint64
+				d, buf = m.entryDigest(elems[i].key, elems[i].value, appendValue, buf)
+				leaves[m.digestBucket(elems[i].key, depth)] += d
+			}
+		}
+	}
+	for i := 1<<depth - 2; i >= 0; i-- {
+		tree[i] = tree[2*i+1] + tree[2*i+2]
+	}
+	return tree
+}
+
+// Returns the number of bucket levels below the root
+func (t DigestTree) Depth() uint {
+	return uint(bits.Len(uint(len(t)))) - 1
+}
+
+// Returns the buckets whose digests differ between t and other, which must
+// have the same depth, visiting only subtrees whose digests differ
+func (t DigestTree) Diverging(other DigestTree) []uint64 {
+	if len(t) != len(other) {
+		panic(fmt.Sprintf("rhmap: comparing digest trees of %d and %d nodes", len(t), len(other)))
+	}
+	var buckets []uint64
+	firstLeaf := len(t) / 2
+	var visit func(i int)
+	visit = func(i int) {
+		if t[i] == other[i] {
+			return
+		}
+		if i >= firstLeaf {
+			buckets = append(buckets, uint64(i-firstLeaf))
+			return
+		}
+		visit(2*i + 1)
+		visit(2*i + 2)
+	}
+	visit(0)
+	return buckets
+}
+
+// Iterates over the elements that fall in bucket of a digest tree of the
+// given depth, so that replicas can compare just the buckets that diverge
+func (m *Map[K, V]) DigestBucket(depth uint, bucket uint64) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for _, elems := range m.tables() {
+			for i := range elems {
+				if elems[i].set && m.digestBucket(elems[i].key, depth) == bucket {
+					if !yield(elems[i].key, elems[i].value) {
+						return
+					}
+				}
+			}
+		}
+	}
+}
+
+// Returns the bucket key falls in at depth, taken from the top bits of its
+// fixed-key hash so that it agrees between replicas
+func (m *Map[K, V]) digestBucket(key K, depth uint) uint64 {
+	if depth == 0 {
+		return 0
+	}
+	return m.enc.hash(SipHasher{}, digestK0, digestK1, key) >> (64 - depth)
+}
+
+// Hashes an element under the fixed digest keys, returning buf for reuse
+func (m *Map[K, V]) entryDigest(key K, value V, appendValue func([]byte, V) []byte, buf []byte) (uint64, []byte) {
+	var pair [16]byte
+	binary.LittleEndian.PutUint64(pair[:8], m.enc.hash(SipHasher{}, digestK0, digestK1, key))
+	buf = appendValue(buf[:0], value)
+	binary.LittleEndian.PutUint64(pair[8:], siphash.Hash(digestK0, digestK1, buf))
+	return siphash.Hash(digestK0, digestK1, pair[:]), buf
+}
+
+// Returns a function appending a portable encoding of values of type V:
+// numbers and bools little-endian, strings as their bytes, nil interfaces as
+// nothing and everything else through gob. It panics on values gob can't
+// encode.
+func valueAppender[V any]() func([]byte, V) []byte {
+	t := reflect.TypeFor[V]()
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		size := t.Size()
+		return func(buf []byte, v V) []byte {
+			p := unsafe.Pointer(&v)
+			switch size {
+			case 1:
+				return append(buf, *(*uint8)(p))
+			case 2:
+				return binary.LittleEndian.AppendUint16(buf, *(*uint16)(p))
+			case 4:
+				return binary.LittleEndian.AppendUint32(buf, *(*uint32)(p))
+			case 8:
+				return binary.LittleEndian.AppendUint64(buf, *(*uint64)(p))
+			}
+			words := (*[2]uint64)(p)
+			buf = binary.LittleEndian.AppendUint64(buf, words[0])
+			return binary.LittleEndian.AppendUint64(buf, words[1])
+		}
+	case reflect.String:
+		return func(buf []byte, v V) []byte {
+			return append(buf, *(*string)(unsafe.Pointer(&v))...)
+		}
+	}
+	return func(buf []byte, v V) []byte {
+		if t.Kind() == reflect.Interface && any(v) == nil {
+			return buf
+		}
+		b, err := gobEncode(v)
+		if err != nil {
+			panic(fmt.Sprintf("rhmap: can't digest value of type %v: %v", t, err))
+		}
+		return append(buf, b...)
+	}
+}
@@ -0,0 +1,71 @@
+package rhmap
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDigest(t *testing.T) {
+	a := must(New[int, string]())
+	b := must(New[int, string](WithHasher(XXHasher{}), WithIncrementalRehash(4)))
+	for i := 0; i < 1000; i++ {
+		a.Set(i, "v")
+		b.Set(999-i, "v")
+	}
+	if a.Digest() != b.Digest() {
+		t.Errorf("Maps with the same elements should have the same digest, whatever their hashers and insertion order.")
+	}
+	if empty := must(New[int, string]()); empty.Digest() != 0 {
+		t.Errorf("An empty map should digest to 0. Got %d", empty.Digest())
+	}
+
+	b.Set(500, "changed")
+	if a.Digest() == b.Digest() {
+		t.Errorf("Changing a value should change the digest.")
+	}
+	b.Set(500, "v")
+	b.Set(1000, "")
+	if a.Digest() == b.Digest() {
+		t.Errorf("Adding an element should change the digest.")
+	}
+
+	ta, tb := a.DigestTree(6), b.DigestTree(6)
+	if len(ta) != 127 || ta.Depth() != 6 || ta[0] != a.Digest() {
+		t.Errorf("A tree of depth 6 should have 127 nodes rooted at the digest. Got %d nodes of depth %d", len(ta), ta.Depth())
+	}
+	diverging := ta.Diverging(tb)
+	if len(diverging) != 1 {
+		t.Fatalf("Exactly one bucket should diverge. Got %v", diverging)
+	}
+	var keys []int
+	for k := range b.DigestBucket(6, diverging[0]) {
+		keys = append(keys, k)
+	}
+	if !slices.Contains(keys, 1000) {
+		t.Errorf("The diverging bucket should hold the added key. Got %v", keys)
+	}
+	if got := ta.Diverging(ta); len(got) != 0 {
+		t.Errorf("A tree should not diverge from itself. Got %v", got)
+	}
+}
+
+func TestDigestValueKinds(t *testing.T) {
+	x := must(New[string, any]())
+	y := must(New[string, any]())
+	for _, m := range []*Map[string, any]{x, y} {
+		m.Set("nil", nil)
+		m.Set("point", point{1, 2})
+		m.Set("slice", []int{1, 2, 3})
+	}
+	if x.Digest() != y.Digest() {
+		t.Errorf("Composite values should digest by their contents.")
+	}
+
+	c := must(New[int, complex128]())
+	d := must(New[int, complex128]())
+	c.Set(1, complex(1, 2))
+	d.Set(1, complex(1, 3))
+	if c.Digest() == d.Digest() {
+		t.Errorf("Both halves of a complex value should be digested.")
+	}
+}
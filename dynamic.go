@@ -0,0 +1,60 @@
+package rhmap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+)
+
+// Map keyed and valued by any, for callers that build maps from reflection,
+// such as decoders of schemas unknown at compile time, and can't instantiate
+// Map[K, V]. Keys are hashed by their encodings under a KeyCodec, so keys ==
+// can't compare, such as slices and maps, are accepted, and the table
+// probes, rehashes and reports Stats as Map's does.
+type DynamicMap = CodecMap[any, any]
+
+// Creates a dynamic map whose keys are hashed and compared through codec, or
+// through GobKeyCodec if codec is nil. Options configure the underlying map
+// as for NewWithCodec.
+func NewDynamicMap(codec KeyCodec[any], opts ...Option) (*DynamicMap, error) {
+	if codec == nil {
+		codec = GobKeyCodec{}
+	}
+	return NewWithCodec[any, any](codec, opts...)
+}
+
+// KeyCodec for keys of any type, encoding a key's dynamic type followed by
+// its value, strings as they are and anything else with gob, so that keys
+// of different types never collide. Keys are equal if reflect.DeepEqual
+// says so. Gob encodes maps in no particular order, so maps of more than
+// one element only make usable keys under a codec of the caller's. Like a
+// built-in map given an unhashable key, it panics on keys gob can't encode,
+// such as funcs.
+type GobKeyCodec struct{}
+
+func (GobKeyCodec) Encode(dst []byte, key any) []byte {
+	if key == nil {
+		return append(dst, 0)
+	}
+	t := reflect.TypeOf(key)
+	name := t.String()
+	if t.PkgPath() != "" {
+		name = t.PkgPath() + "." + t.Name()
+	}
+	// Lengths are offset by one so that no type name encodes as nil does
+	dst = binary.AppendUvarint(dst, uint64(len(name))+1)
+	dst = append(dst, name...)
+
+	if s, ok := key.(string); ok {
+		return append(dst, s...)
+	}
+	enc, err := gobEncode(key)
+	if err != nil {
+		panic(fmt.Sprintf("rhmap: can't encode key of type %T: %v", key, err))
+	}
+	return append(dst, enc...)
+}
+
+func (GobKeyCodec) Equal(a, b any) bool {
+	return reflect.DeepEqual(a, b)
+}
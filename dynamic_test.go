@@ -0,0 +1,74 @@
+package rhmap
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type dynamicPoint struct {
+	X, Y int
+}
+
+func TestDynamicMap(t *testing.T) {
+	d := must(NewDynamicMap(nil, WithIncrementalRehash(1)))
+	keys := []any{"a", 1, int64(1), 1.0, []int{1, 2}, dynamicPoint{1, 2}, nil, true, map[string]int{"x": 1}}
+	for i, key := range keys {
+		d.Set(key, i)
+	}
+	if d.Len() != uint64(len(keys)) {
+		t.Errorf("Keys of different types should not collide. Expected %d elements, Found %d", len(keys), d.Len())
+	}
+	for i, key := range keys {
+		if v, ok := d.Get(key); !ok || v != i {
+			t.Errorf("Get(%#v) should return %d. Got %v, %t", key, i, v, ok)
+		}
+	}
+	if v, ok := d.Get([]int{1, 2}); !ok || v != 4 {
+		t.Errorf("Equal slices should find the same element. Got %v, %t", v, ok)
+	}
+
+	for k, v := range d.All() {
+		if !reflect.DeepEqual(k, keys[v.(int)]) {
+			t.Errorf("All should yield keys as they were set. Got %#v for %d", k, v)
+		}
+	}
+	d.Delete(int64(1))
+	if _, ok := d.Get(1); !ok || d.Len() != uint64(len(keys)-1) {
+		t.Errorf("Deleting int64(1) should leave int(1) alone.")
+	}
+
+	for i := 0; i < 1000; i++ {
+		d.Set(dynamicPoint{i, -i}, i)
+	}
+	if s := d.Stats(); s.Len != d.Len() || s.Resizes == 0 {
+		t.Errorf("Stats should describe the underlying table. Got %+v", s)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("A key gob can't encode should panic.")
+		}
+	}()
+	d.Set(func() {}, 0)
+}
+
+// Hashes and compares string keys case-insensitively
+type foldingCodec struct{}
+
+func (foldingCodec) Encode(dst []byte, key any) []byte {
+	return append(dst, strings.ToLower(key.(string))...)
+}
+
+func (foldingCodec) Equal(a, b any) bool {
+	return strings.EqualFold(a.(string), b.(string))
+}
+
+func TestDynamicMapCodec(t *testing.T) {
+	d := must(NewDynamicMap(foldingCodec{}))
+	d.Set("Content-Type", "text/plain")
+	d.Set("content-type", "text/html")
+	if v, ok := d.Get("CONTENT-TYPE"); !ok || v != "text/html" || d.Len() != 1 {
+		t.Errorf("Keys the codec finds equal should be one key. Got %v, %t, %d elements", v, ok, d.Len())
+	}
+}
@@ -0,0 +1,281 @@
+package rhmap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"math"
+	"reflect"
+	"unsafe"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/dchest/siphash"
+)
+
+// Size of the stack buffer keys are encoded into before hashing. Longer
+// encodings spill to the heap.
+const keyScratchSize = 64
+
+// How a key type is turned into bytes for hashing
+type keyKind uint8
+
+const (
+	// Encoded with gob, for structs and arrays
+	kindGob keyKind = iota
+	// Encoded by the dynamic value's type, with nil encoding to nothing
+	kindInterface
+	// Fixed-size integers, bools and pointers, stored little-endian
+	kindFixed1
+	kindFixed2
+	kindFixed4
+	kindFixed8
+	kindFloat32
+	kindFloat64
+	kindComplex64
+	kindComplex128
+	kindString
+	// Structs with fields tagged `rhmap:"-"`, encoded and compared field by
+	// field through a valueCodec
+	kindStruct
+	// Types implementing BytesHashable, encoded by their HashBytes method
+	kindHashBytes
+	// Types implementing Hashable, which hash themselves without an encoding
+	kindHash64
+)
+
+// Key types implementing Hashable hash themselves: the map calls Hash64 with
+// its seeds instead of encoding the key and hashing it with its Hasher. Keys
+// that compare equal must hash equally, and for maps facing untrusted keys
+// the hash should be keyed by both seeds, as SipHash is. The method is called
+// through the interface, so a key larger than a pointer is copied to the
+// heap for each call.
+type Hashable interface {
+	Hash64(k0, k1 uint64) uint64
+}
+
+// Key types implementing BytesHashable provide their own canonical encoding,
+// which the map hashes with its Hasher in place of the encoding it would
+// pick by reflection, such as gob for structs. Keys that compare equal must
+// return equal bytes. As with Hashable, the call may copy the key to the
+// heap.
+type BytesHashable interface {
+	HashBytes() []byte
+}
+
+var (
+	hashableType      = reflect.TypeFor[Hashable]()
+	bytesHashableType = reflect.TypeFor[BytesHashable]()
+)
+
+// Encodes keys of type K into bytes for hashing. The encoding is chosen once
+// from K's kind, and every kind except the gob fallback appends to a caller
+// buffer without allocating. Keys that compare equal always encode equally.
+type keyEncoder[K comparable] struct {
+	kind keyKind
+	// Encoding of kindStruct and kindHashBytes keys, equality of kindStruct
+	// keys, and the hash of kindHash64 keys. They are called through func
+	// values, and the caller's buffer is never passed to them, so that
+	// reflecting on a key or calling its methods doesn't move keys or
+	// buffers of every kind to the heap.
+	encodeStruct func(K) []byte
+	equalStruct  func(a, b K) bool
+	hash64       func(key K, k0, k1 uint64) uint64
+}
+
+// Picks the encoder for K, or returns an error if K falls back to gob and gob
+// can't encode it, such as a struct with no exported fields
+func newKeyEncoder[K comparable]() (keyEncoder[K], error) {
+	var zero K
+	t := reflect.TypeOf(&zero).Elem()
+
+	// An interface key's methods belong to its dynamic value, which may be
+	// nil, so only concrete types hash themselves
+	if t.Kind() != reflect.Interface {
+		switch {
+		case t.Implements(hashableType):
+			return keyEncoder[K]{
+				kind: kindHash64,
+				hash64: func(key K, k0, k1 uint64) uint64 {
+					return any(key).(Hashable).Hash64(k0, k1)
+				},
+			}, nil
+		case t.Implements(bytesHashableType):
+			return keyEncoder[K]{
+				kind: kindHashBytes,
+				encodeStruct: func(key K) []byte {
+					return any(key).(BytesHashable).HashBytes()
+				},
+			}, nil
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Pointer, reflect.UnsafePointer, reflect.Chan:
+		// Pointers and channels compare by address, and Go's collector never
+		// moves heap objects, so the address is a stable encoding.
+		switch t.Size() {
+		case 1:
+			return keyEncoder[K]{kind: kindFixed1}, nil
+		case 2:
+			return keyEncoder[K]{kind: kindFixed2}, nil
+		case 4:
+			return keyEncoder[K]{kind: kindFixed4}, nil
+		case 8:
+			return keyEncoder[K]{kind: kindFixed8}, nil
+		}
+	case reflect.Float32:
+		return keyEncoder[K]{kind: kindFloat32}, nil
+	case reflect.Float64:
+		return keyEncoder[K]{kind: kindFloat64}, nil
+	case reflect.Complex64:
+		return keyEncoder[K]{kind: kindComplex64}, nil
+	case reflect.Complex128:
+		return keyEncoder[K]{kind: kindComplex128}, nil
+	case reflect.String:
+		return keyEncoder[K]{kind: kindString}, nil
+	case reflect.Interface:
+		return keyEncoder[K]{kind: kindInterface}, nil
+	}
+
+	if hasIgnoredFields(t) {
+		codec, err := newValueCodec(t)
+		if err != nil {
+			return keyEncoder[K]{}, fmt.Errorf("rhmap: can't encode keys of type %v: %w", t, err)
+		}
+		return keyEncoder[K]{
+			kind: kindStruct,
+			encodeStruct: func(key K) []byte {
+				return codec.append(nil, reflect.ValueOf(&key).Elem())
+			},
+			equalStruct: func(a, b K) bool {
+				return codec.equal(reflect.ValueOf(&a).Elem(), reflect.ValueOf(&b).Elem())
+			},
+		}, nil
+	}
+
+	if _, err := gobEncode(zero); err != nil {
+		return keyEncoder[K]{}, fmt.Errorf("rhmap: can't encode keys of type %v: %w", t, err)
+	}
+	return keyEncoder[K]{kind: kindGob}, nil
+}
+
+// Appends the encoding of key to buf. Like a built-in map with an unhashable
+// key, it panics if an interface key or field holds a value gob can't encode.
+func (e keyEncoder[K]) append(buf []byte, key K) []byte {
+	p := unsafe.Pointer(&key)
+
+	switch e.kind {
+	case kindFixed1:
+		return append(buf, *(*uint8)(p))
+	case kindFixed2:
+		return binary.LittleEndian.AppendUint16(buf, *(*uint16)(p))
+	case kindFixed4:
+		return binary.LittleEndian.AppendUint32(buf, *(*uint32)(p))
+	case kindFixed8:
+		return binary.LittleEndian.AppendUint64(buf, *(*uint64)(p))
+	case kindFloat32:
+		return binary.LittleEndian.AppendUint32(buf, float32Bits(*(*float32)(p)))
+	case kindFloat64:
+		return binary.LittleEndian.AppendUint64(buf, float64Bits(*(*float64)(p)))
+	case kindComplex64:
+		c := *(*complex64)(p)
+		buf = binary.LittleEndian.AppendUint32(buf, float32Bits(real(c)))
+		return binary.LittleEndian.AppendUint32(buf, float32Bits(imag(c)))
+	case kindComplex128:
+		c := *(*complex128)(p)
+		buf = binary.LittleEndian.AppendUint64(buf, float64Bits(real(c)))
+		return binary.LittleEndian.AppendUint64(buf, float64Bits(imag(c)))
+	case kindString:
+		return append(buf, *(*string)(p)...)
+	case kindInterface:
+		if any(key) == nil {
+			return buf
+		}
+	case kindStruct, kindHashBytes:
+		return append(buf, e.encodeStruct(key)...)
+	}
+
+	enc, err := gobEncode(any(key))
+	if err != nil {
+		panic(fmt.Sprintf("rhmap: can't encode key of type %T: %v", any(key), err))
+	}
+	return append(buf, enc...)
+}
+
+// Returns the bytes of a string key in place, so that hashing a long string
+// neither copies it nor spills a buffer to the heap. Reports false for keys
+// of other kinds, which must be encoded with append.
+func (e keyEncoder[K]) view(key K) ([]byte, bool) {
+	if e.kind != kindString {
+		return nil, false
+	}
+	s := *(*string)(unsafe.Pointer(&key))
+	return unsafe.Slice(unsafe.StringData(s), len(s)), true
+}
+
+// Hashes key with h and the seeds, in place for strings and through a stack
+// buffer otherwise, unless the key hashes itself
+func (e keyEncoder[K]) hash(h Hasher, k0, k1 uint64, key K) uint64 {
+	if b, ok := e.view(key); ok {
+		return hashBytes(h, k0, k1, b)
+	}
+	if e.hash64 != nil {
+		return e.hash64(key, k0, k1)
+	}
+	var scratch [keyScratchSize]byte
+	return hashBytes(h, k0, k1, e.append(scratch[:0], key))
+}
+
+// Bits of f with negative zero folded into positive zero, since they compare
+// equal as keys
+func float32Bits(f float32) uint32 {
+	if f == 0 {
+		return 0
+	}
+	return math.Float32bits(f)
+}
+
+func float64Bits(f float64) uint64 {
+	if f == 0 {
+		return 0
+	}
+	return math.Float64bits(f)
+}
+
+func gobEncode(key any) ([]byte, error) {
+	if err := injectFault(faultEncodeKey); err != nil {
+		return nil, err
+	}
+	var buffer bytes.Buffer
+	enc := gob.NewEncoder(&buffer)
+	if err := enc.Encode(key); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// Hashes an encoded key. The built-in hashers are called directly so that
+// p, which usually points into a caller's stack buffer, doesn't escape;
+// custom hashers get a copy.
+func hashBytes(h Hasher, k0, k1 uint64, p []byte) uint64 {
+	switch h := h.(type) {
+	case SipHasher:
+		return siphash.Hash(k0, k1, p)
+	case XXHasher:
+		var d xxhash.Digest
+		d.ResetWithSeed(k0)
+		d.Write(p)
+		return d.Sum64()
+	case FNV1aHasher:
+		return h.Hash(k0, k1, p)
+	case IntHasher:
+		return h.Hash(k0, k1, p)
+	case *MapHasher:
+		return h.Hash(k0, k1, p)
+	default:
+		return h.Hash(k0, k1, bytes.Clone(p))
+	}
+}
@@ -0,0 +1,301 @@
+package rhmap
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type celsius float64
+
+type point struct {
+	X, Y int
+}
+
+func TestKeyEncoderEqualKeys(t *testing.T) {
+	negZero := math.Copysign(0, -1)
+
+	f := must(newKeyEncoder[float64]())
+	if !bytes.Equal(f.append(nil, 0), f.append(nil, negZero)) {
+		t.Errorf("0 and -0 compare equal and should encode equally.")
+	}
+	c := must(newKeyEncoder[celsius]())
+	if !bytes.Equal(c.append(nil, 0), c.append(nil, celsius(negZero))) {
+		t.Errorf("Named float types should fold -0 into 0.")
+	}
+	z := must(newKeyEncoder[complex128]())
+	if !bytes.Equal(z.append(nil, complex(0, 1)), z.append(nil, complex(negZero, 1))) {
+		t.Errorf("Complex keys should fold -0 in each part into 0.")
+	}
+
+	i := must(newKeyEncoder[int16]())
+	if bytes.Equal(i.append(nil, 1), i.append(nil, 256)) {
+		t.Errorf("Distinct int16 keys should encode differently.")
+	}
+	if got := len(i.append(nil, 1)); got != 2 {
+		t.Errorf("int16 keys should encode to 2 bytes. Got %d", got)
+	}
+
+	s := must(newKeyEncoder[string]())
+	if got := s.append([]byte("x"), "abc"); string(got) != "xabc" {
+		t.Errorf("String keys should append their bytes. Expected xabc, Got %q", got)
+	}
+}
+
+func testKeyKind[K comparable](t *testing.T, name string, keys []K) {
+	m := must(New[K, int]())
+	for i, key := range keys {
+		m.Set(key, i)
+	}
+	for i, key := range keys {
+		if val, ok := m.Get(key); !ok || val != i {
+			t.Errorf("%s: key %v should map to %d. Got %d, %t", name, key, i, val, ok)
+		}
+	}
+	if m.Len() != uint64(len(keys)) {
+		t.Errorf("%s: Expected %d elements, Got %d", name, len(keys), m.Len())
+	}
+}
+
+func TestKeyKinds(t *testing.T) {
+	ptrs := make([]*int, 100)
+	chans := make([]chan int, 100)
+	for i := range ptrs {
+		ptrs[i] = new(int)
+		chans[i] = make(chan int)
+	}
+	var ints []int
+	var int8s []int8
+	var floats []float32
+	var complexes []complex64
+	var strs []string
+	var points []point
+	for i := 0; i < 100; i++ {
+		ints = append(ints, i-50)
+		int8s = append(int8s, int8(i))
+		floats = append(floats, float32(i)/3)
+		complexes = append(complexes, complex(float32(i), -float32(i)))
+		strs = append(strs, strconv.Itoa(i))
+		points = append(points, point{i, -i})
+	}
+
+	testKeyKind(t, "int", ints)
+	testKeyKind(t, "int8", int8s)
+	testKeyKind(t, "bool", []bool{false, true})
+	testKeyKind(t, "float32", floats)
+	testKeyKind(t, "complex64", complexes)
+	testKeyKind(t, "string", strs)
+	testKeyKind(t, "pointer", ptrs)
+	testKeyKind(t, "chan", chans)
+	testKeyKind(t, "struct", points)
+
+	m := must(New[float64, int]())
+	m.Set(0, 1)
+	if val, ok := m.Get(math.Copysign(0, -1)); !ok || val != 1 {
+		t.Errorf("-0 should find the value stored under 0. Got %d, %t", val, ok)
+	}
+}
+
+func TestNewRejectsUnencodableKeys(t *testing.T) {
+	type hidden struct{ x int }
+
+	if m, err := New[hidden, int](); err == nil || m != nil {
+		t.Errorf("A key type with no exported fields should be rejected. Got %v, %v", m, err)
+	}
+	if _, err := NewSegmented[hidden, int](0); err == nil {
+		t.Errorf("NewSegmented should reject the same key types as New.")
+	}
+	if _, err := NewConcurrent[hidden, int](0); err == nil {
+		t.Errorf("NewConcurrent should reject the same key types as New.")
+	}
+}
+
+func TestInterfaceKeys(t *testing.T) {
+	m := must(New[any, int]())
+	keys := []any{nil, 1, int64(1), "1", point{1, 2}, 1.5}
+	for i, key := range keys {
+		m.Set(key, i)
+	}
+	for i, key := range keys {
+		if val, ok := m.Get(key); !ok || val != i {
+			t.Errorf("Key %#v should map to %d. Got %d, %t", key, i, val, ok)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("A dynamic key gob can't encode should panic like an unhashable key.")
+		}
+	}()
+	m.Set(struct{ x int }{1}, 0)
+}
+
+type taggedKey struct {
+	ID    int
+	Name  string
+	Seen  int64 `rhmap:"-"`
+	Inner struct {
+		Zone  string
+		Cache string `rhmap:"-"`
+	}
+}
+
+func TestTaggedStructKeys(t *testing.T) {
+	m := must(New[taggedKey, int]())
+	a := taggedKey{ID: 1, Name: "a", Seen: 100}
+	a.Inner.Zone = "eu"
+	b := a
+	b.Seen = 200
+	b.Inner.Cache = "cached"
+
+	m.Set(a, 1)
+	m.Set(b, 2)
+	if m.Len() != 1 {
+		t.Errorf("Keys differing only in ignored fields should be one key. Found %d elements", m.Len())
+	}
+	if val, ok := m.Get(b); !ok || val != 2 {
+		t.Errorf("Key should map to 2 whatever its ignored fields hold. Got %d, %t", val, ok)
+	}
+
+	c := a
+	c.Inner.Zone = "us"
+	if _, ok := m.Get(c); ok {
+		t.Errorf("Keys differing in a nested field that isn't ignored should be distinct.")
+	}
+
+	// Strings are length-prefixed, so fields can't run together
+	m.Set(taggedKey{Name: "ab"}, 3)
+	if _, ok := m.Get(taggedKey{Name: "a"}); ok {
+		t.Errorf("Distinct keys should not be confused.")
+	}
+
+	m.Delete(taggedKey{ID: 1, Name: "a", Seen: -1, Inner: a.Inner})
+	if _, ok := m.Get(a); ok || m.Len() != 1 {
+		t.Errorf("Delete should match keys by their identifying fields. Found %d elements", m.Len())
+	}
+
+	// Lookups in a table being drained compare the same way
+	inc := must(New[taggedKey, int](WithIncrementalRehash(1)))
+	n := 0
+	for ; inc.draining == nil; n++ {
+		inc.Set(taggedKey{ID: n, Seen: int64(n)}, n)
+	}
+	for i := 0; i < n; i++ {
+		if val, ok := inc.Get(taggedKey{ID: i, Seen: -1}); !ok || val != i {
+			t.Errorf("Key %d should be found mid-migration. Got %d, %t", i, val, ok)
+		}
+	}
+
+	type onlyIgnored struct {
+		Stamp int `rhmap:"-"`
+	}
+	if _, err := New[onlyIgnored, int](); err == nil {
+		t.Errorf("A key type whose fields are all ignored should be rejected.")
+	}
+}
+
+func TestHashKeyMatchesHasher(t *testing.T) {
+	long := strings.Repeat("k", 4*keyScratchSize)
+	for name, h := range builtinHashers() {
+		m := must(New[string, int](WithHasher(h)))
+		for _, key := range []string{"key", long} {
+			if got, want := m.hashKey(key), h.Hash(m.k0, m.k1, []byte(key)); got != want {
+				t.Errorf("%s: hashing a key directly should match the hasher. Expected %d, Got %d", name, want, got)
+			}
+		}
+		if got, want := m.HashMany([]string{long})[0], m.hashKey(long); got != want {
+			t.Errorf("%s: HashMany should hash like hashKey. Expected %d, Got %d", name, want, got)
+		}
+	}
+}
+
+func TestLongStringKeysDontAllocate(t *testing.T) {
+	long := strings.Repeat("k", 4*keyScratchSize)
+	m := must(New[string, int]())
+	m.Set(long, 1)
+	if allocs := testing.AllocsPerRun(100, func() { m.Get(long) }); allocs != 0 {
+		t.Errorf("String keys should be hashed in place, however long. Got %f allocations", allocs)
+	}
+}
+
+func BenchmarkSet(b *testing.B) {
+	b.Run("int", func(b *testing.B) {
+		m := must(New[int, int]())
+		for i := 0; i < b.N; i++ {
+			m.Set(i, i)
+		}
+	})
+	b.Run("string", func(b *testing.B) {
+		keys := make([]string, 1024)
+		for i := range keys {
+			keys[i] = strconv.Itoa(i)
+		}
+		b.ResetTimer()
+		m := must(New[string, int]())
+		for i := 0; i < b.N; i++ {
+			m.Set(keys[i%len(keys)], i)
+		}
+	})
+}
+
+func BenchmarkGet(b *testing.B) {
+	m := must(New[int, int]())
+	for i := 0; i < 1024; i++ {
+		m.Set(i, i)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.Get(i % 1024)
+	}
+}
+
+// Key hashing itself with only its ID, so that keys differing in Name
+// collide and must still be told apart by ==
+type selfHashed struct {
+	ID   int
+	Name string
+}
+
+func (k selfHashed) Hash64(k0, k1 uint64) uint64 {
+	return mixInt(k0, uint64(k.ID))
+}
+
+// Key whose canonical encoding folds case
+type caseless struct {
+	s string
+}
+
+func (k caseless) HashBytes() []byte {
+	return []byte(strings.ToLower(k.s))
+}
+
+func TestHashableKeys(t *testing.T) {
+	m := must(New[selfHashed, int]())
+	if m.enc.kind != kindHash64 {
+		t.Fatalf("A key implementing Hashable should hash itself.")
+	}
+	m.Set(selfHashed{1, "a"}, 1)
+	m.Set(selfHashed{1, "b"}, 2)
+	if v, ok := m.Get(selfHashed{1, "a"}); !ok || v != 1 || m.Len() != 2 {
+		t.Errorf("Keys with equal hashes should still be distinct. Got %d, %t with %d elements", v, ok, m.Len())
+	}
+	key := selfHashed{7, "x"}
+	if got, want := m.hashKey(key), key.Hash64(m.k0, m.k1); got != want {
+		t.Errorf("hashKey should call Hash64. Expected %d, Got %d", want, got)
+	}
+	if got := m.HashMany([]selfHashed{key})[0]; got != m.hashKey(key) {
+		t.Errorf("HashMany should hash like hashKey. Expected %d, Got %d", m.hashKey(key), got)
+	}
+
+	// Unexported fields alone would make gob reject the type
+	c := must(New[caseless, int]())
+	c.Set(caseless{"Key"}, 1)
+	if got, want := c.hashKey(caseless{"Key"}), c.hashKey(caseless{"KEY"}); got != want {
+		t.Errorf("Keys should be hashed by their HashBytes encoding. Got %d and %d", got, want)
+	}
+	if v, ok := c.Get(caseless{"Key"}); !ok || v != 1 {
+		t.Errorf("Expected 1, Got %d, %t", v, ok)
+	}
+}
@@ -0,0 +1,135 @@
+package rhmap
+
+// Handle on one key of a Map, for loops that read and write the same key in
+// turn. It keeps the key's hash and the slot the key was found in, so that
+// its operations skip hashing, and skip probing too while no other write
+// has moved elements. Writes through the map or another handle, which may
+// move elements, make it probe again on its next use, and a rebuild, which
+// may change how keys hash, makes it hash the key again. Its methods update
+// the handle, so keep it in a variable rather than calling them on Entry's
+// result.
+type EntryHandle[K comparable, V any] struct {
+	m    *Map[K, V]
+	key  K
+	hash uint64
+	// Slot of the key in the current table if found, or whether it waits in
+	// the table an incremental rehash is draining, as of the map's version
+	// and layout when it was looked for
+	index   uint64
+	found   bool
+	waiting bool
+	version uint64
+	layout  uint64
+}
+
+// Returns a handle on key, hashing and looking it up once
+func (m *Map[K, V]) Entry(key K) EntryHandle[K, V] {
+	h := EntryHandle[K, V]{m: m, key: key, hash: m.hashKey(key)}
+	h.find()
+	return h
+}
+
+// Returns the handle's key
+func (h *EntryHandle[K, V]) Key() K {
+	return h.key
+}
+
+// Returns the value under the key, as Map.Get does
+func (h *EntryHandle[K, V]) Get() (V, bool) {
+	m := h.m
+	val, ok := h.lookup()
+	if !ok && m.misses != nil {
+		m.misses.add(h.key, h.hash)
+	}
+	if m.metrics != nil {
+		m.metrics.Count(MetricGets, 1)
+	}
+	if ok && m.evictor != nil {
+		m.accessed(h.key)
+	}
+	return val, ok
+}
+
+// Sets the key to value, in place if the handle knows its slot
+func (h *EntryHandle[K, V]) Set(value V) {
+	m := h.m
+	if m.rejectsWrites() {
+		return
+	}
+	if m.zeroDeletes && isZero(value) {
+		h.Delete()
+		return
+	}
+	h.locate()
+	switch {
+	case h.waiting:
+		// Updating it through its slot means moving it out of the old table
+		_, _, h.index = m.getForUpdate(h.key, h.hash)
+	case !h.found:
+		m.insertAbsent(h.key, value, h.hash)
+		return
+	}
+	m.unshare()
+	m.elements[h.index].value = value
+	m.accessed(h.key)
+	h.found, h.waiting = true, false
+	h.version, h.layout = m.version, m.layout
+}
+
+func (h *EntryHandle[K, V]) Delete() {
+	m := h.m
+	if m.numElements == 0 || m.rejectsWrites() {
+		return
+	}
+	h.locate()
+	if (h.found || h.waiting) && m.softRemove(h.key, h.hash) {
+		m.maybeShrink()
+	}
+}
+
+// Returns the value under the key, first setting it to value if the key is
+// absent
+func (h *EntryHandle[K, V]) OrInsert(value V) V {
+	if val, ok := h.lookup(); ok {
+		return val
+	}
+	h.Set(value)
+	return value
+}
+
+// Returns the value under the key without Get's bookkeeping
+func (h *EntryHandle[K, V]) lookup() (V, bool) {
+	h.locate()
+	switch {
+	case h.found:
+		return h.m.elements[h.index].value, true
+	case h.waiting:
+		val, _, _ := h.m.draining.probe(h.key, h.hash)
+		return val, true
+	}
+	var zeroVal V
+	return zeroVal, false
+}
+
+// Looks the key up again if a write may have moved it since it was last
+// found, hashing it again first if the table was rebuilt or migrated
+func (h *EntryHandle[K, V]) locate() {
+	m := h.m
+	if h.version == m.version && h.layout == m.layout {
+		return
+	}
+	if h.layout != m.layout {
+		h.hash = m.hashKey(h.key)
+	}
+	h.find()
+}
+
+func (h *EntryHandle[K, V]) find() {
+	m := h.m
+	_, h.found, h.index = m.probe(h.key, h.hash)
+	h.waiting = false
+	if !h.found && m.draining != nil {
+		_, h.waiting, _ = m.draining.probe(h.key, h.hash)
+	}
+	h.version, h.layout = m.version, m.layout
+}
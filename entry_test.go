@@ -0,0 +1,114 @@
+package rhmap
+
+import "testing"
+
+func TestEntry(t *testing.T) {
+	m := must(New[string, int]())
+	e := m.Entry("hits")
+	if _, ok := e.Get(); ok {
+		t.Errorf("A handle on an absent key should find nothing.")
+	}
+	if v := e.OrInsert(1); v != 1 {
+		t.Errorf("Expected 1, Got %d", v)
+	}
+	if v := e.OrInsert(5); v != 1 {
+		t.Errorf("OrInsert should keep a present value. Expected 1, Got %d", v)
+	}
+	v, _ := e.Get()
+	e.Set(v + 1)
+	if v, ok := m.Get("hits"); !ok || v != 2 {
+		t.Errorf("Expected 2, Got %d, %t", v, ok)
+	}
+
+	// Inserts shift the key along its cluster and grow the table
+	for i := 0; i < 1000; i++ {
+		m.Set(string(rune(i)), i)
+		v, ok := e.Get()
+		if !ok || v != 2+i {
+			t.Fatalf("Expected %d, Got %d, %t", 2+i, v, ok)
+		}
+		e.Set(v + 1)
+	}
+	m.SetHasher(XXHasher{})
+	if v, ok := e.Get(); !ok || v != 1002 {
+		t.Errorf("A handle should find its key after the map rehashes with another hasher. Got %d, %t", v, ok)
+	}
+
+	e.Delete()
+	if _, ok := m.Get("hits"); ok || m.Len() != 1000 {
+		t.Errorf("Delete should remove the key.")
+	}
+	e.Delete()
+	if m.Len() != 1000 {
+		t.Errorf("Deleting twice should remove nothing more. Got %d elements", m.Len())
+	}
+	if err := m.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEntryIncremental(t *testing.T) {
+	m := must(New[int, int](WithIncrementalRehash(1)))
+	for i := 0; i < 9; i++ {
+		m.Set(i, i)
+	}
+	// Which keys the first migration step moved depends on the seeds
+	key := -1
+	for i := 0; i < 9 && key < 0; i++ {
+		if _, ok, _ := m.draining.probe(i, m.hashKey(i)); ok {
+			key = i
+		}
+	}
+	if key < 0 {
+		t.Fatalf("Expected keys to wait in the draining table.")
+	}
+	e := m.Entry(key)
+	if !e.waiting {
+		t.Errorf("Expected %d to wait in the draining table.", key)
+	}
+	if v, ok := e.Get(); !ok || v != key {
+		t.Errorf("Expected %d, Got %d, %t", key, v, ok)
+	}
+	e.Set(-1)
+	if v, ok := m.Get(key); !ok || v != -1 || e.waiting {
+		t.Errorf("Setting a waiting key should move it to the current table. Got %d, %t", v, ok)
+	}
+	if err := m.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEntryAllocs(t *testing.T) {
+	m := must(New[string, int]())
+	m.Set("key", 0)
+	allocs := testing.AllocsPerRun(100, func() {
+		e := m.Entry("key")
+		v, _ := e.Get()
+		e.Set(v + 1)
+	})
+	if allocs != 0 {
+		t.Errorf("Updating through a handle should not allocate. Got %f allocations", allocs)
+	}
+}
+
+// Compares a read-modify-write of one key through Get and Set with one
+// through a handle
+func BenchmarkEntry(b *testing.B) {
+	m := must(New[string, int]())
+	for i := 0; i < 1<<16; i++ {
+		m.Set("key-"+string(rune(i)), i)
+	}
+	b.Run("get-set", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			v, _ := m.Get("key-x")
+			m.Set("key-x", v+1)
+		}
+	})
+	b.Run("entry", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			e := m.Entry("key-x")
+			v, _ := e.Get()
+			e.Set(v + 1)
+		}
+	})
+}
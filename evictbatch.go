@@ -0,0 +1,130 @@
+package rhmap
+
+import "sync"
+
+// Makes a full map bounded by WithMaxEntries evict n elements at once, the
+// policy's next n victims, rather than one per new key, so that the n-1 new
+// keys after it are inserted without evicting. Evicting in batches trades a
+// map that is up to n-1 elements short of its bound for fewer trips
+// through the eviction policy and callback.
+func WithEvictionBatch(n int) Option {
+	return func(o *options) {
+		o.evictBatch = n
+	}
+}
+
+// Makes a map bounded by WithMaxEntries pass the elements it evicts for
+// capacity to the WithOnEvict callback from a worker goroutine rather than
+// from the Set that evicted them, so that a slow callback doesn't hold up
+// writers, nor the lock of a ConcurrentMap shard. Batches are passed on in
+// the order they were evicted, and the worker exits once it runs out of
+// them. Elements removed any other way still go to the callback at once.
+// WaitEvictions waits for the worker to catch up.
+func WithAsyncEviction() Option {
+	return func(o *options) {
+		o.asyncEvict = true
+	}
+}
+
+// Returns the next element the policy would evict from a full map, or false
+// if it has none
+func (m *Map[K, V]) nextVictim() (K, uint64, bool) {
+	switch {
+	case m.evictor != nil:
+		key, ok := m.evictor.NextVictim()
+		if !ok {
+			return key, 0, false
+		}
+		return key, m.hashKey(key), true
+	case m.eviction == EvictRandom && m.numElements > 0:
+		key, hash := m.randomElement()
+		return key, hash, true
+	}
+	var zeroKey K
+	return zeroKey, 0, false
+}
+
+// Evicts victim and, under WithEvictionBatch, the policy's next victims up
+// to the batch size, passing them to the eviction callback or its worker
+func (m *Map[K, V]) evict(victim K, hash uint64) {
+	if m.evictBatch <= 1 && m.evictWorker == nil {
+		m.removeWithHash(victim, hash)
+		m.evictions++
+		return
+	}
+	var batch []Entry[K, V]
+	for n := 0; ; {
+		val, ok := m.takeWithHash(victim, hash)
+		if !ok {
+			break
+		}
+		m.evictions++
+		if m.onEvict != nil {
+			batch = append(batch, Entry[K, V]{victim, val})
+		}
+		if n++; n >= m.evictBatch {
+			break
+		}
+		if victim, hash, ok = m.nextVictim(); !ok {
+			break
+		}
+	}
+	if m.evictWorker != nil {
+		m.evictWorker.send(batch)
+	} else {
+		m.notifyEvicted(batch)
+	}
+}
+
+// Waits until the eviction callbacks of every element evicted so far under
+// WithAsyncEviction have returned
+func (m *Map[K, V]) WaitEvictions() {
+	if m.evictWorker != nil {
+		m.evictWorker.wg.Wait()
+	}
+}
+
+// Passes batches of evicted elements to an eviction callback from a
+// goroutine started whenever batches are pending and none is running
+type evictWorker[K comparable, V any] struct {
+	mu      sync.Mutex
+	pending [][]Entry[K, V]
+	running bool
+	// Counts the batches whose callbacks haven't returned
+	wg sync.WaitGroup
+	fn func(K, V)
+}
+
+func (w *evictWorker[K, V]) send(batch []Entry[K, V]) {
+	if len(batch) == 0 {
+		return
+	}
+	w.wg.Add(1)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending = append(w.pending, batch)
+	if !w.running {
+		w.running = true
+		go w.run()
+	}
+}
+
+func (w *evictWorker[K, V]) run() {
+	for {
+		w.mu.Lock()
+		if len(w.pending) == 0 {
+			w.running = false
+			w.mu.Unlock()
+			return
+		}
+		batch := w.pending[0]
+		w.pending[0] = nil
+		w.pending = w.pending[1:]
+		w.mu.Unlock()
+
+		for _, e := range batch {
+			w.fn(e.Key, e.Value)
+		}
+		w.wg.Done()
+	}
+}
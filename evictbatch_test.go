@@ -0,0 +1,77 @@
+package rhmap
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestWithEvictionBatch(t *testing.T) {
+	var evicted []int
+	m := must(New[int, int](WithMaxEntries(100), WithEvictionPolicy(EvictFIFO), WithEvictionBatch(16),
+		WithOnEvict(func(k, _ int) { evicted = append(evicted, k) })))
+	for k := 0; k < 101; k++ {
+		m.Set(k, k)
+	}
+	if m.Len() != 85 || len(evicted) != 16 || m.Stats().Evictions != 16 {
+		t.Errorf("Expected a batch of 16 evicted, Got %d evicted and %d left", len(evicted), m.Len())
+	}
+	for i, k := range evicted {
+		if k != i {
+			t.Errorf("Expected the batch in eviction order, Got %v", evicted)
+			break
+		}
+	}
+
+	// The next 15 new keys fit without evicting
+	for k := 101; k < 116; k++ {
+		m.Set(k, k)
+	}
+	if m.Len() != 100 || len(evicted) != 16 {
+		t.Errorf("Keys after a batch shouldn't evict until the map is full again. Got %d evicted", len(evicted))
+	}
+	if err := m.Validate(); err != nil {
+		t.Errorf("Expected a valid table, Got %v", err)
+	}
+}
+
+func TestWithAsyncEviction(t *testing.T) {
+	var mu sync.Mutex
+	var evicted []int
+	c := must(NewConcurrent[int, int](4, WithMaxEntries(40), WithEvictionPolicy(EvictRandom), WithAsyncEviction(),
+		WithOnEvict(func(k, _ int) {
+			mu.Lock()
+			evicted = append(evicted, k)
+			mu.Unlock()
+		})))
+	for k := 0; k < 1000; k++ {
+		c.Set(k, k)
+	}
+	c.WaitEvictions()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if uint64(len(evicted))+c.Len() != 1000 {
+		t.Errorf("Every evicted element should reach the callback. Got %d evicted and %d left", len(evicted), c.Len())
+	}
+	seen := make(map[int]bool)
+	for _, k := range evicted {
+		if seen[k] {
+			t.Errorf("Key %d was passed to the callback twice.", k)
+		}
+		seen[k] = true
+	}
+}
+
+func TestAsyncEvictionCallsBack(t *testing.T) {
+	// Callbacks run outside the shard's lock, so they may use the map
+	var c *ConcurrentMap[int, int]
+	c = must(NewConcurrent[int, int](2, WithMaxEntries(10), WithEvictionPolicy(EvictLRU), WithAsyncEviction(),
+		WithOnEvict(func(k, _ int) { c.Get(k) })))
+	for k := 0; k < 100; k++ {
+		c.Set(k, k)
+	}
+	c.WaitEvictions()
+	if c.Len() != 10 {
+		t.Errorf("Expected 10 elements, Got %d", c.Len())
+	}
+}
@@ -0,0 +1,279 @@
+package rhmap
+
+import (
+	"cmp"
+	"container/heap"
+	"slices"
+	"unsafe"
+
+	"github.com/micoo227/robin-hood-hashing/internal/slab"
+)
+
+// Eviction policy of a map bounded by WithMaxEntries, for policies the
+// EvictionPolicy constants don't cover. The map tells it of every key it
+// inserts, reads or overwrites, and removes, and asks it for a victim once
+// full. It is called under the map's own synchronization, so it needn't be
+// safe for concurrent use, but a map with an Evictor turns Get into a
+// write, as EvictLRU does.
+type Evictor[K comparable] interface {
+	// Called with every key added to the map
+	OnInsert(key K)
+	// Called with every key read by Get or given a new value
+	OnAccess(key K)
+	// Called with every key deleted, evicted or cleared
+	OnRemove(key K)
+	// Returns the key to evict from a full map, which must be one the map
+	// holds, or false to turn the new key away instead
+	NextVictim() (K, bool)
+	// Returns an independent copy, for a clone of the map
+	Clone() Evictor[K]
+}
+
+// Read-only view of an entry of a bounded map, for Evictors choosing
+// victims by what only the map knows, such as preferring entries far from
+// their home slot to keep probes short. Timestamps and tags aren't kept by
+// the map; an Evictor sees every insert and access and can keep its own.
+type EntryView struct {
+	// Slots the entry sits past its home slot
+	PSL uint
+	// Longest PSL in the map, to judge PSL against
+	MaxPSL uint
+}
+
+// Evictor that inspects entries through EntryViews. The map calls Inspect
+// once, when it creates or clones the Evictor, with a function returning
+// the view of a key it holds. The function may only be called from the
+// Evictor's other methods, while the map is calling them.
+type InspectingEvictor[K comparable] interface {
+	Evictor[K]
+	Inspect(view func(key K) (EntryView, bool))
+}
+
+// Bounds the map with a policy made by newEvictor, called once per table:
+// once per map, and once per shard of a ConcurrentMap. It overrides
+// WithEvictionPolicy and only applies together with WithMaxEntries. K must
+// be the map's key type, or the option is ignored.
+func WithEvictor[K comparable](newEvictor func() Evictor[K]) Option {
+	return func(o *options) {
+		o.evictor = newEvictor
+	}
+}
+
+// Returns the Evictor of a map of at most capacity elements configured by
+// o, or nil if the map evicts at random or not at all
+func newEvictor[K comparable](enc keyEncoder[K], o options) Evictor[K] {
+	if o.maxEntries == 0 {
+		return nil
+	}
+	if fn, ok := o.evictor.(func() Evictor[K]); ok {
+		return fn()
+	}
+	switch o.eviction {
+	case EvictLRU:
+		return &listEvictor[K]{list: newRecency(enc, o.maxEntries)}
+	case EvictFIFO:
+		return &listEvictor[K]{list: newRecency(enc, o.maxEntries), fifo: true}
+	case EvictLFU:
+		index := newMap[K, uint32](enc)
+		index.zeroDeletes = false
+		return &lfuEvictor[K]{index: index}
+	}
+	return nil
+}
+
+// Evictor that can rank the keys it tracks, for Hottest and Coldest
+type rankingEvictor[K comparable] interface {
+	// Returns up to n keys, those it would evict last first if hottest, and
+	// those it would evict first first otherwise
+	ranked(n int, hottest bool) []K
+}
+
+// Hands the map's Evictor, if it inspects entries, the map's view of them
+func (m *Map[K, V]) bindEvictor() {
+	if e, ok := m.evictor.(InspectingEvictor[K]); ok {
+		e.Inspect(m.entryView)
+	}
+}
+
+// Returns the view of key, looking in the table being drained too
+func (m *Map[K, V]) entryView(key K) (EntryView, bool) {
+	hash := m.hashKey(key)
+	for t := m; t != nil; t = t.draining {
+		if _, ok, i := t.probe(key, hash); ok {
+			return EntryView{PSL: t.elements[i].psl, MaxPSL: m.MaxPSL()}, true
+		}
+	}
+	return EntryView{}, false
+}
+
+// Evictor keeping keys in an LRU list, moved up on access unless it
+// evicts in insertion order
+type listEvictor[K comparable] struct {
+	list *LRU[K, struct{}]
+	fifo bool
+}
+
+func (e *listEvictor[K]) OnInsert(key K) {
+	e.list.Add(key, struct{}{})
+}
+
+func (e *listEvictor[K]) OnAccess(key K) {
+	if !e.fifo {
+		e.list.Add(key, struct{}{})
+	}
+}
+
+func (e *listEvictor[K]) OnRemove(key K) {
+	e.list.Remove(key)
+}
+
+func (e *listEvictor[K]) NextVictim() (K, bool) {
+	if e.list.Len() == 0 {
+		var zeroKey K
+		return zeroKey, false
+	}
+	return e.list.entries.At(e.list.entries.Prev(0)).key, true
+}
+
+func (e *listEvictor[K]) ranked(n int, hottest bool) []K {
+	step := e.list.entries.Prev
+	if hottest {
+		step = e.list.entries.Next
+	}
+	var keys []K
+	for i := step(0); i != 0 && len(keys) < n; i = step(i) {
+		keys = append(keys, e.list.entries.At(i).key)
+	}
+	return keys
+}
+
+func (e *listEvictor[K]) Clone() Evictor[K] {
+	return &listEvictor[K]{list: e.list.clone(), fifo: e.fifo}
+}
+
+func (e *listEvictor[K]) MemoryFootprint() uint64 {
+	return e.list.table.MemoryFootprint() + e.list.entries.Footprint()
+}
+
+// Key counted by an lfuEvictor, with the access that last touched it, so
+// that ties are broken by recency, and its position in the heap
+type lfuKey[K comparable] struct {
+	key   K
+	count uint64
+	tick  uint64
+	pos   int
+}
+
+// Evictor keeping keys in a min-heap by access count, evicting the least
+// frequently used key and, among those, the least recently used. Keys live
+// in a slab, which index maps them to, and the heap orders their slots.
+type lfuEvictor[K comparable] struct {
+	index *Map[K, uint32]
+	keys  slab.Slab[lfuKey[K]]
+	heap  []uint32
+	tick  uint64
+}
+
+func (e *lfuEvictor[K]) OnInsert(key K) {
+	e.tick++
+	i := e.keys.Alloc()
+	*e.keys.At(i) = lfuKey[K]{key: key, count: 1, tick: e.tick}
+	e.index.Set(key, i)
+	heap.Push(e, i)
+}
+
+func (e *lfuEvictor[K]) OnAccess(key K) {
+	if i, ok := e.index.Get(key); ok {
+		e.tick++
+		k := e.keys.At(i)
+		k.count++
+		k.tick = e.tick
+		heap.Fix(e, k.pos)
+	}
+}
+
+func (e *lfuEvictor[K]) OnRemove(key K) {
+	if i, ok := e.index.GetAndDelete(key); ok {
+		heap.Remove(e, e.keys.At(i).pos)
+		e.keys.Free(i)
+	}
+}
+
+func (e *lfuEvictor[K]) NextVictim() (K, bool) {
+	if len(e.heap) == 0 {
+		var zeroKey K
+		return zeroKey, false
+	}
+	return e.keys.At(e.heap[0]).key, true
+}
+
+func (e *lfuEvictor[K]) ranked(n int, hottest bool) []K {
+	order := slices.Clone(e.heap)
+	slices.SortFunc(order, func(a, b uint32) int {
+		ka, kb := e.keys.At(a), e.keys.At(b)
+		return cmp.Or(cmp.Compare(ka.count, kb.count), cmp.Compare(ka.tick, kb.tick))
+	})
+	if hottest {
+		slices.Reverse(order)
+	}
+	keys := make([]K, 0, min(n, len(order)))
+	for _, i := range order[:min(n, len(order))] {
+		keys = append(keys, e.keys.At(i).key)
+	}
+	return keys
+}
+
+func (e *lfuEvictor[K]) uses(key K) uint64 {
+	if i, ok := e.index.Get(key); ok {
+		return e.keys.At(i).count
+	}
+	return 0
+}
+
+func (e *lfuEvictor[K]) setUses(key K, n uint64) {
+	if i, ok := e.index.Get(key); ok {
+		k := e.keys.At(i)
+		k.count = n
+		heap.Fix(e, k.pos)
+	}
+}
+
+func (e *lfuEvictor[K]) Clone() Evictor[K] {
+	return &lfuEvictor[K]{index: e.index.Clone(), keys: e.keys.Clone(), heap: slices.Clone(e.heap), tick: e.tick}
+}
+
+func (e *lfuEvictor[K]) MemoryFootprint() uint64 {
+	return e.index.MemoryFootprint() + e.keys.Footprint() + uint64(cap(e.heap))*uint64(unsafe.Sizeof(uint32(0)))
+}
+
+// heap.Interface over the slots of the counted keys
+
+func (e *lfuEvictor[K]) Len() int {
+	return len(e.heap)
+}
+
+func (e *lfuEvictor[K]) Less(i, j int) bool {
+	a, b := e.keys.At(e.heap[i]), e.keys.At(e.heap[j])
+	if a.count != b.count {
+		return a.count < b.count
+	}
+	return a.tick < b.tick
+}
+
+func (e *lfuEvictor[K]) Swap(i, j int) {
+	e.heap[i], e.heap[j] = e.heap[j], e.heap[i]
+	e.keys.At(e.heap[i]).pos = i
+	e.keys.At(e.heap[j]).pos = j
+}
+
+func (e *lfuEvictor[K]) Push(x any) {
+	i := x.(uint32)
+	e.keys.At(i).pos = len(e.heap)
+	e.heap = append(e.heap, i)
+}
+
+func (e *lfuEvictor[K]) Pop() any {
+	i := e.heap[len(e.heap)-1]
+	e.heap = e.heap[:len(e.heap)-1]
+	return i
+}
@@ -0,0 +1,232 @@
+package rhmap
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Value in an ExpiringMap with the Unix nanosecond time it expires at and
+// the TTL it was set with
+type expiringValue[V any] struct {
+	value   V
+	expires int64
+	ttl     int64
+}
+
+// Robin hood hashmap whose elements expire a fixed time after they are set,
+// or after a per-element TTL. Expired elements read as absent right away and
+// are reclaimed lazily: Set purges them before it would grow the table, and
+// Sweep or a background sweeper purges them on demand. An ExpiringMap is
+// safe for concurrent use, so that the sweeper can run alongside callers.
+type ExpiringMap[K comparable, V any] struct {
+	mu       sync.RWMutex
+	table    *Map[K, expiringValue[V]]
+	ttl      time.Duration
+	sliding  bool
+	paused   atomic.Bool
+	clock    Clock
+	onExpire func(K, V)
+	onEvict  func(K, V)
+}
+
+// Creates a map whose elements expire ttl after they are set. Options
+// configure the underlying map, and WithClock sets the clock expiry is
+// measured by, and WithSlidingExpiration makes Get refresh elements;
+// WithZeroDeletes has no effect. It returns an error if ttl is not positive
+// or K can't be encoded.
+func NewExpiring[K comparable, V any](ttl time.Duration, opts ...Option) (*ExpiringMap[K, V], error) {
+	if ttl <= 0 {
+		return nil, errors.New("rhmap: TTL must be positive")
+	}
+	table, err := New[K, expiringValue[V]](opts...)
+	if err != nil {
+		return nil, err
+	}
+	table.zeroDeletes = false
+
+	o := resolveOptions(opts)
+	clock := o.clock
+	if clock == nil {
+		clock = realClock{}
+	}
+	return &ExpiringMap[K, V]{table: table, ttl: ttl, sliding: o.sliding, clock: clock, onEvict: evictHook[K, V](o)}, nil
+}
+
+// Sets key to value, expiring after the map's TTL
+func (e *ExpiringMap[K, V]) Set(key K, value V) {
+	e.SetWithTTL(key, value, e.ttl)
+}
+
+// Sets key to value, expiring after ttl instead of the map's TTL
+func (e *ExpiringMap[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
+	now := e.clock.Now().UnixNano()
+
+	e.mu.Lock()
+
+	var expired []Entry[K, V]
+	t := e.table
+	if float32(float64(t.numElements)/float64(t.size)) >= t.loadFactor && !e.paused.Load() {
+		// Reclaim expired elements first, which may avoid growing at all
+		expired = e.purge(now)
+	}
+	t.Set(key, expiringValue[V]{value: value, expires: now + int64(ttl), ttl: int64(ttl)})
+	e.mu.Unlock()
+
+	e.notifyExpired(expired)
+}
+
+// Sets fn to be called with every element reclaimed after expiring, outside
+// the map's lock. Set it before the map is shared or the sweeper started.
+func (e *ExpiringMap[K, V]) OnExpire(fn func(K, V)) {
+	e.onExpire = fn
+}
+
+// Restarts key's TTL, the one it was last set with, from now and reports
+// whether it was present and unexpired
+func (e *ExpiringMap[K, V]) Touch(key K) bool {
+	_, ok := e.refresh(key, e.clock.Now().UnixNano())
+	return ok
+}
+
+// Returns the value under key, unless it is missing or has expired. Under
+// WithSlidingExpiration it also restarts the element's TTL, as Touch does.
+func (e *ExpiringMap[K, V]) Get(key K) (V, bool) {
+	now := e.clock.Now().UnixNano()
+	if e.sliding {
+		return e.refresh(key, now)
+	}
+
+	e.mu.RLock()
+	ev, ok := e.table.Get(key)
+	e.mu.RUnlock()
+
+	if !ok || ev.expires <= now {
+		var zeroVal V
+		return zeroVal, false
+	}
+	return ev.value, true
+}
+
+func (e *ExpiringMap[K, V]) Delete(key K) {
+	e.mu.Lock()
+	hash := e.table.hashKey(key)
+	ev, ok, _ := e.table.getWithHash(key, hash)
+	if ok {
+		e.table.deleteWithHash(key, hash)
+		e.table.maybeShrink()
+	}
+	e.mu.Unlock()
+
+	if ok && e.onEvict != nil {
+		e.onEvict(key, ev.value)
+	}
+}
+
+// Returns the number of elements, including expired ones not yet reclaimed
+func (e *ExpiringMap[K, V]) Len() uint64 {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.table.Len()
+}
+
+// Reclaims every expired element and returns how many there were
+func (e *ExpiringMap[K, V]) Sweep() int {
+	now := e.clock.Now().UnixNano()
+
+	e.mu.Lock()
+	expired := e.purge(now)
+	e.mu.Unlock()
+
+	e.notifyExpired(expired)
+	return len(expired)
+}
+
+// Stops the sweeper and Set from reclaiming expired elements until
+// ResumeEviction, so that latency-sensitive phases such as startup don't pay
+// for maintenance. Expired elements still read as absent, and the table
+// grows past them instead. Explicit calls to Sweep still reclaim.
+func (e *ExpiringMap[K, V]) PauseEviction() {
+	e.paused.Store(true)
+}
+
+// Lets the sweeper and Set reclaim expired elements again
+func (e *ExpiringMap[K, V]) ResumeEviction() {
+	e.paused.Store(false)
+}
+
+// Starts a goroutine that calls Sweep every interval, skipping ticks while
+// eviction is paused, until the returned function is called
+func (e *ExpiringMap[K, V]) StartSweeper(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		for {
+			timer := e.clock.NewTimer(interval)
+			select {
+			case <-timer.C():
+				if !e.paused.Load() {
+					e.Sweep()
+				}
+			case <-done:
+				timer.Stop()
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-stopped
+		})
+	}
+}
+
+// Restarts key's TTL from now and returns its value, if key is present and
+// unexpired
+func (e *ExpiringMap[K, V]) refresh(key K, now int64) (V, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	hash := e.table.hashKey(key)
+	ev, ok, i := e.table.getForUpdate(key, hash)
+	if !ok || ev.expires <= now {
+		var zeroVal V
+		return zeroVal, false
+	}
+	e.table.unshare()
+	e.table.elements[i].value.expires = now + ev.ttl
+	return ev.value, true
+}
+
+// Deletes and returns the elements expired as of now. The caller holds the
+// write lock.
+func (e *ExpiringMap[K, V]) purge(now int64) []Entry[K, V] {
+	var expired []Entry[K, V]
+	var keys []K
+	for k, ev := range e.table.All() {
+		if ev.expires <= now {
+			expired = append(expired, Entry[K, V]{k, ev.value})
+			keys = append(keys, k)
+		}
+	}
+	e.table.DeleteAll(keys)
+	return expired
+}
+
+// Calls onExpire and the eviction callback for reclaimed elements, outside
+// the lock so that they may use the map
+func (e *ExpiringMap[K, V]) notifyExpired(expired []Entry[K, V]) {
+	for _, entry := range expired {
+		if e.onExpire != nil {
+			e.onExpire(entry.Key, entry.Value)
+		}
+		if e.onEvict != nil {
+			e.onEvict(entry.Key, entry.Value)
+		}
+	}
+}
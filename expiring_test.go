@@ -0,0 +1,152 @@
+package rhmap
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpiringMap(t *testing.T) {
+	clock := newFakeClock()
+	e := must(NewExpiring[string, int](time.Minute, WithClock(clock)))
+
+	e.Set("a", 1)
+	e.SetWithTTL("b", 2, time.Hour)
+	clock.Advance(30 * time.Second)
+	if val, ok := e.Get("a"); !ok || val != 1 {
+		t.Errorf("a should not expire before its TTL. Got %d, %t", val, ok)
+	}
+
+	clock.Advance(30 * time.Second)
+	if _, ok := e.Get("a"); ok {
+		t.Errorf("a should read as absent once its TTL has passed.")
+	}
+	if val, ok := e.Get("b"); !ok || val != 2 {
+		t.Errorf("b should keep its own longer TTL. Got %d, %t", val, ok)
+	}
+	if e.Len() != 2 {
+		t.Errorf("Expired elements should stay until reclaimed. Found %d", e.Len())
+	}
+
+	if n := e.Sweep(); n != 1 || e.Len() != 1 {
+		t.Errorf("Sweep should reclaim only a. Reclaimed %d, %d left", n, e.Len())
+	}
+
+	e.Set("b", 3)
+	clock.Advance(time.Minute)
+	if _, ok := e.Get("b"); ok {
+		t.Errorf("Setting b again should reset its expiry to the map's TTL.")
+	}
+	e.Delete("b")
+	if e.Len() != 0 {
+		t.Errorf("Map should be empty. Found %d", e.Len())
+	}
+}
+
+func TestExpiringMapReclaimsBeforeGrowing(t *testing.T) {
+	clock := newFakeClock()
+	e := must(NewExpiring[int, int](time.Second, WithClock(clock)))
+
+	for i := 0; i < 10000; i++ {
+		if i%100 == 0 {
+			clock.Advance(time.Second)
+		}
+		e.Set(i, i)
+	}
+	if e.table.size > 256 {
+		t.Errorf("At most 100 live elements should never need %d slots.", e.table.size)
+	}
+	if val, ok := e.Get(9999); !ok || val != 9999 {
+		t.Errorf("The latest element should be live. Got %d, %t", val, ok)
+	}
+}
+
+func TestExpiringMapSweeper(t *testing.T) {
+	clock := newFakeClock()
+	e := must(NewExpiring[int, int](time.Second, WithClock(clock)))
+	for i := 0; i < 10; i++ {
+		e.Set(i, i)
+	}
+
+	stop := e.StartSweeper(time.Minute)
+	defer stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for e.Len() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Sweeper should have reclaimed every element. Found %d", e.Len())
+		}
+		clock.Advance(time.Minute)
+		time.Sleep(time.Millisecond)
+	}
+
+	stop()
+	stop()
+}
+
+func TestExpiringMapSliding(t *testing.T) {
+	clock := newFakeClock()
+	e := must(NewExpiring[string, int](time.Minute, WithClock(clock), WithSlidingExpiration()))
+
+	e.Set("a", 1)
+	e.SetWithTTL("b", 2, time.Hour)
+	for range 3 {
+		clock.Advance(45 * time.Second)
+		if val, ok := e.Get("a"); !ok || val != 1 {
+			t.Errorf("Reading a within its TTL should keep it alive. Got %d, %t", val, ok)
+		}
+	}
+	clock.Advance(time.Minute)
+	if _, ok := e.Get("a"); ok {
+		t.Errorf("a should expire a full TTL after it was last read.")
+	}
+	if e.Touch("a") {
+		t.Errorf("Touching an expired element should fail.")
+	}
+
+	clock.Advance(50 * time.Minute)
+	if !e.Touch("b") {
+		t.Errorf("Touching a live element should succeed.")
+	}
+	clock.Advance(59 * time.Minute)
+	if val, ok := e.Get("b"); !ok || val != 2 {
+		t.Errorf("Touch should restart b's own TTL. Got %d, %t", val, ok)
+	}
+}
+
+func TestExpiringMapPauseEviction(t *testing.T) {
+	clock := newFakeClock()
+	e := must(NewExpiring[int, int](time.Second, WithClock(clock)))
+	e.PauseEviction()
+	for i := 0; i < 1000; i++ {
+		if i%100 == 0 {
+			clock.Advance(time.Second)
+		}
+		e.Set(i, i)
+	}
+	if e.Len() != 1000 {
+		t.Errorf("Set should reclaim nothing while eviction is paused. Found %d elements", e.Len())
+	}
+	if _, ok := e.Get(0); ok {
+		t.Errorf("Expired elements should read as absent while eviction is paused.")
+	}
+
+	stop := e.StartSweeper(time.Minute)
+	defer stop()
+	for range 10 {
+		clock.Advance(time.Minute)
+		time.Sleep(time.Millisecond)
+	}
+	if e.Len() != 1000 {
+		t.Errorf("The sweeper should skip its ticks while eviction is paused. Found %d elements", e.Len())
+	}
+
+	e.ResumeEviction()
+	deadline := time.Now().Add(5 * time.Second)
+	for e.Len() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("The sweeper should reclaim once eviction resumes. Found %d elements", e.Len())
+		}
+		clock.Advance(time.Minute)
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,223 @@
+package rhmap
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"unsafe"
+
+	"github.com/micoo227/robin-hood-hashing/internal/rawtype"
+)
+
+// Appends every key to keys and every value to values in one pass over the
+// table, so that the returned slices are parallel columns: the element at
+// index i of values is stored under the key at index i. Grow the slices to
+// m.Len() beforehand to avoid reallocating. Columns of pointer-free types can
+// be handed to columnar formats such as Apache Arrow as raw buffers with
+// ColumnBytes, without boxing each element.
+func (m *Map[K, V]) AppendColumns(keys []K, values []V) ([]K, []V) {
+	keys, values = slices.Grow(keys, int(m.numElements)), slices.Grow(values, int(m.numElements))
+	for _, elements := range m.tables() {
+		for i := range elements {
+			if elements[i].set {
+				keys = append(keys, elements[i].key)
+				values = append(values, elements[i].value)
+			}
+		}
+	}
+	return keys, values
+}
+
+// Returns the memory backing col as bytes, without copying, for use as a
+// fixed-width column buffer. It fails if T holds pointers, strings or other
+// references, whose bytes mean nothing outside this process. Struct padding
+// is included as it is in memory.
+func ColumnBytes[T any](col []T) ([]byte, error) {
+	t := reflect.TypeFor[T]()
+	if rawtype.HoldsPointers(t) {
+		return nil, fmt.Errorf("rhmap: %v holds pointers and can't be exported as a column buffer", t)
+	}
+	if len(col) == 0 {
+		return nil, nil
+	}
+	return unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(col))), len(col)*int(t.Size())), nil
+}
+
+// Streams every key in the map to w as a sequence of gob values, so callers
+// that only need the key set don't pay to serialize values. The keys can be
+// read back by decoding from a gob.Decoder until it returns io.EOF.
+func (m *Map[K, V]) WriteKeys(w io.Writer) error {
+	enc := gob.NewEncoder(w)
+	for key := range m.Keys() {
+		if err := enc.Encode(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Streams every value in the map to w as a sequence of gob values, in the
+// same order WriteKeys would produce the corresponding keys.
+func (m *Map[K, V]) WriteValues(w io.Writer) error {
+	enc := gob.NewEncoder(w)
+	for value := range m.Values() {
+		if err := enc.Encode(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Wire form of a whole map. Only live elements are stored; the table layout
+// is rebuilt on decode.
+type mapState[K comparable, V any] struct {
+	Hasher      string
+	K0, K1      uint64
+	LoadFactor  float32
+	Size        uint64
+	FastRange   bool
+	ZeroDeletes bool
+	Keys        []K
+	Values      []V
+}
+
+// Encodes the map's seeds, configuration and elements so it can be restored
+// with GobDecode, hashing every key as before. Maps using a custom hasher or
+// a MapHasher can't be encoded, since their hashing can't be reproduced.
+// The encoding contains the seeds, so it needs the same care as seeds shared
+// through WithSeedsFrom.
+func (m *Map[K, V]) GobEncode() ([]byte, error) {
+	name := builtinHasherName(m.hasher)
+	if name == "" {
+		return nil, errors.New("rhmap: can't encode a map with a custom hasher")
+	}
+
+	state := mapState[K, V]{
+		Hasher:      name,
+		K0:          m.k0,
+		K1:          m.k1,
+		LoadFactor:  m.loadFactor,
+		Size:        m.size,
+		FastRange:   m.fastRange,
+		ZeroDeletes: m.zeroDeletes,
+		Keys:        make([]K, 0, m.numElements),
+		Values:      make([]V, 0, m.numElements),
+	}
+	for key, value := range m.All() {
+		state.Keys = append(state.Keys, key)
+		state.Values = append(state.Values, value)
+	}
+
+	if err := injectFault(faultEncodeMap); err != nil {
+		return nil, err
+	}
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(state); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// Replaces the map's contents and configuration with a map encoded by
+// GobEncode. The zero Map is a valid target.
+func (m *Map[K, V]) GobDecode(data []byte) error {
+	var state mapState[K, V]
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&state); err != nil {
+		return err
+	}
+	if len(state.Keys) != len(state.Values) {
+		return errors.New("rhmap: invalid map encoding")
+	}
+	if err := m.restore(&state, uint64(len(state.Keys))); err != nil {
+		return err
+	}
+	for i, key := range state.Keys {
+		m.Set(key, state.Values[i])
+	}
+	m.publish()
+	return nil
+}
+
+// Empties the map and configures it as state describes, sized for count
+// elements, ignoring state's elements
+func (m *Map[K, V]) restore(state *mapState[K, V], count uint64) error {
+	if m.readOnly {
+		return ErrReadOnly
+	}
+	hasher := builtinHasherByName(state.Hasher)
+	if hasher == nil || state.Size == 0 || !(state.LoadFactor > 0 && state.LoadFactor <= 1) {
+		return errors.New("rhmap: invalid map encoding")
+	}
+	enc, err := newKeyEncoder[K]()
+	if err != nil {
+		return err
+	}
+
+	if m.evictor != nil {
+		for key := range m.Keys() {
+			m.evictor.OnRemove(key)
+		}
+	}
+	m.hasher, m.enc = hasher, enc
+	m.k0, m.k1 = state.K0, state.K1
+	m.loadFactor = state.LoadFactor
+	m.fastRange, m.zeroDeletes = state.FastRange, state.ZeroDeletes
+	m.bulkThreshold = cmp.Or(m.bulkThreshold, defaultBulkThreshold)
+	m.size = roundSize(state.Size)
+	m.block.release()
+	if m.draining != nil {
+		m.draining.block.release()
+	}
+	m.allocElements(m.size)
+	m.allocCtrl()
+	m.shared = false
+	m.draining = nil
+	m.numElements, m.totalPsl, m.maxPsl, m.maxFreq = 0, 0, 0, 0
+	m.version++
+
+	m.growFor(count)
+	return nil
+}
+
+func (m *Map[K, V]) MarshalBinary() ([]byte, error) {
+	return m.GobEncode()
+}
+
+func (m *Map[K, V]) UnmarshalBinary(data []byte) error {
+	return m.GobDecode(data)
+}
+
+// Stable name of a built-in hasher whose output depends only on the seeds,
+// or "" for any other hasher
+func builtinHasherName(h Hasher) string {
+	switch h.(type) {
+	case SipHasher:
+		return "siphash"
+	case XXHasher:
+		return "xxhash"
+	case FNV1aHasher:
+		return "fnv1a"
+	case IntHasher:
+		return "int"
+	}
+	return ""
+}
+
+func builtinHasherByName(name string) Hasher {
+	switch name {
+	case "siphash":
+		return SipHasher{}
+	case "xxhash":
+		return XXHasher{}
+	case "fnv1a":
+		return FNV1aHasher{}
+	case "int":
+		return IntHasher{}
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package rhmap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"io"
+	"strconv"
+	"testing"
+)
+
+func TestWriteKeysAndValues(t *testing.T) {
+	m := must(New[int, int]())
+
+	for i := 1; i <= 100; i++ {
+		m.Set(i, i*10)
+	}
+
+	var keyBuf, valBuf bytes.Buffer
+	if err := m.WriteKeys(&keyBuf); err != nil {
+		t.Fatalf("WriteKeys returned an error: %v", err)
+	}
+	if err := m.WriteValues(&valBuf); err != nil {
+		t.Fatalf("WriteValues returned an error: %v", err)
+	}
+
+	keyDec, valDec := gob.NewDecoder(&keyBuf), gob.NewDecoder(&valBuf)
+	seen := 0
+	for {
+		var key, val int
+		err := keyDec.Decode(&key)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Could not decode key: %v", err)
+		}
+		if err := valDec.Decode(&val); err != nil {
+			t.Fatalf("Could not decode value: %v", err)
+		}
+		if val != key*10 {
+			t.Errorf("Value written for key %d was %d. Expected %d", key, val, key*10)
+		}
+		seen++
+	}
+
+	if seen != 100 {
+		t.Errorf("WriteKeys should have written 100 keys. Found %d", seen)
+	}
+}
+
+func TestWriteKeysEmptyMap(t *testing.T) {
+	m := must(New[string, int]())
+
+	var buf bytes.Buffer
+	if err := m.WriteKeys(&buf); err != nil {
+		t.Fatalf("WriteKeys returned an error: %v", err)
+	}
+
+	var key string
+	if err := gob.NewDecoder(&buf).Decode(&key); !errors.Is(err, io.EOF) {
+		t.Errorf("Decoding keys of an empty map should return io.EOF. Got %v", err)
+	}
+}
+
+var errWrite = errors.New("write failed")
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errWrite
+}
+
+func TestWriteKeysAndValuesWriterError(t *testing.T) {
+	m := must(New[int, int]())
+	m.Set(1, 1)
+
+	if err := m.WriteKeys(failingWriter{}); !errors.Is(err, errWrite) {
+		t.Errorf("WriteKeys should return the writer's error. Got %v", err)
+	}
+	if err := m.WriteValues(failingWriter{}); !errors.Is(err, errWrite) {
+		t.Errorf("WriteValues should return the writer's error. Got %v", err)
+	}
+}
+
+func TestGobRoundTrip(t *testing.T) {
+	m := must(New[string, int](WithHasher(XXHasher{}), WithFastRange(), WithZeroDeletes()))
+	for i := 1; i <= 500; i++ {
+		m.Set(strconv.Itoa(i), i)
+	}
+
+	// Embedded in a struct, the map goes through its GobEncoder methods
+	type wrapper struct{ M *Map[string, int] }
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(wrapper{m}); err != nil {
+		t.Fatalf("Could not encode map: %v", err)
+	}
+	var decoded wrapper
+	if err := gob.NewDecoder(&buf).Decode(&decoded); err != nil {
+		t.Fatalf("Could not decode map: %v", err)
+	}
+	d := decoded.M
+
+	if d.Len() != 500 {
+		t.Errorf("Decoded map should contain 500 elements. Found %d", d.Len())
+	}
+	for i := 1; i <= 500; i++ {
+		if val, ok := d.Get(strconv.Itoa(i)); !ok || val != i {
+			t.Errorf("Key %d should map to %d after decoding. Got %d, %t", i, i, val, ok)
+		}
+	}
+	if d.hashKey("key") != m.hashKey("key") {
+		t.Errorf("Decoded map should hash keys with the same hasher and seeds.")
+	}
+	if !d.fastRange || !d.zeroDeletes {
+		t.Errorf("Decoded map should keep its options. Got fastRange %t, zeroDeletes %t", d.fastRange, d.zeroDeletes)
+	}
+}
+
+func TestMarshalBinary(t *testing.T) {
+	m := must(New[int, int]())
+	for i := 0; i < 100; i++ {
+		m.Set(i, -i)
+	}
+
+	data, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary returned an error: %v", err)
+	}
+	var d Map[int, int]
+	if err := d.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary returned an error: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		if val, ok := d.Get(i); !ok || val != -i {
+			t.Errorf("Key %d should map to %d after decoding. Got %d, %t", i, -i, val, ok)
+		}
+	}
+	d.Set(100, 100)
+	if d.Len() != 101 {
+		t.Errorf("Decoded map should accept new keys. Expected 101 elements, Got %d", d.Len())
+	}
+	if d.bulkThreshold != defaultBulkThreshold {
+		t.Errorf("A decoded zero map should take the default bulk threshold. Got %d", d.bulkThreshold)
+	}
+
+	if err := d.UnmarshalBinary(data[:len(data)/2]); err == nil {
+		t.Errorf("Truncated data should fail to decode.")
+	}
+
+	m.SetHasher(NewMapHasher())
+	if _, err := m.MarshalBinary(); err == nil {
+		t.Errorf("A map with a hasher that can't be reproduced should fail to encode.")
+	}
+}
+
+func TestAppendColumns(t *testing.T) {
+	m := must(New[int32, float64](WithIncrementalRehash(1)))
+	for i := int32(0); i < 1000; i++ {
+		m.Set(i, float64(i)/2)
+	}
+
+	keys, values := m.AppendColumns([]int32{-1}, nil)
+	if len(keys) != 1001 || len(values) != 1000 || keys[0] != -1 {
+		t.Fatalf("Columns should be appended to. Got %d keys, %d values", len(keys), len(values))
+	}
+	seen := make(map[int32]bool)
+	for i, k := range keys[1:] {
+		if values[i] != float64(k)/2 {
+			t.Errorf("Columns should be parallel. Key %d sits beside %f", k, values[i])
+		}
+		seen[k] = true
+	}
+	if len(seen) != 1000 {
+		t.Errorf("Columns should hold every element once, even mid-migration. Got %d keys", len(seen))
+	}
+
+	buf, err := ColumnBytes(keys)
+	if err != nil || len(buf) != 4*len(keys) {
+		t.Fatalf("An int32 column should export as 4 bytes per key. Got %d, %v", len(buf), err)
+	}
+	if got := int32(binary.NativeEndian.Uint32(buf[4:])); got != keys[1] {
+		t.Errorf("The buffer should share the column's memory. Expected %d, Got %d", keys[1], got)
+	}
+	if _, err := ColumnBytes([]point{{1, 2}}); err != nil {
+		t.Errorf("Structs of plain fields should export. Got %v", err)
+	}
+	if _, err := ColumnBytes([]string{"a"}); err == nil {
+		t.Errorf("Strings hold pointers and should not export as a buffer.")
+	}
+	if _, err := ColumnBytes([]struct{ A [2]*int }{}); err == nil {
+		t.Errorf("Pointers nested in arrays should be found.")
+	}
+}
@@ -0,0 +1,66 @@
+package rhmap
+
+import "errors"
+
+// How a map reacts to misuse by its caller: writing to a Snapshot, resizing
+// a map while iterating over it, or passing SetMany slices of different
+// lengths. Library code embedding a map may prefer errors to panics, and
+// code that must keep serving may prefer to carry on. Keys gob can't encode
+// and values Digest can't encode panic under every policy, as unhashable
+// keys of built-in maps do.
+type FailurePolicy uint8
+
+const (
+	// Panic with a message describing the misuse. This is the default.
+	PanicOnMisuse FailurePolicy = iota
+	// Abandon the offending operation and record the first such error for
+	// Err to return
+	ErrorReturns
+	// Abandon the offending operation silently
+	SilentBestEffort
+)
+
+var (
+	ErrReadOnly                = errors.New("rhmap: write to a read-only snapshot")
+	ErrModifiedDuringIteration = errors.New("rhmap: map resized or rebuilt during iteration")
+	ErrMismatchedLengths       = errors.New("rhmap: SetMany called with mismatched keys and values")
+)
+
+// Selects how the map reacts to misuse. Wrappers pass it to their tables,
+// and snapshots and clones inherit it.
+func WithFailurePolicy(p FailurePolicy) Option {
+	return func(o *options) {
+		o.failure = p
+	}
+}
+
+// Returns the first misuse recorded under ErrorReturns since the map was
+// created, cloned or Reset, or nil
+func (m *Map[K, V]) Err() error {
+	return m.err
+}
+
+// Reports misuse according to the map's failure policy. Callers abandon
+// the operation when it returns.
+func (m *Map[K, V]) misuse(err error) {
+	switch m.failure {
+	case PanicOnMisuse:
+		panic(err.Error())
+	case ErrorReturns:
+		if m.err == nil {
+			m.err = err
+		}
+	}
+}
+
+// Reports whether the map is a read-only Snapshot, reporting the write the
+// caller was about to make as misuse. Public writes check it before
+// touching anything, so that under the lenient policies they can be
+// abandoned cleanly.
+func (m *Map[K, V]) rejectsWrites() bool {
+	if m.readOnly {
+		m.misuse(ErrReadOnly)
+		return true
+	}
+	return false
+}
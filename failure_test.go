@@ -0,0 +1,77 @@
+package rhmap
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFailurePolicies(t *testing.T) {
+	for _, policy := range []FailurePolicy{ErrorReturns, SilentBestEffort} {
+		m := must(New[int, int](WithFailurePolicy(policy)))
+		for i := 0; i < 100; i++ {
+			m.Set(i, i)
+		}
+
+		s := m.Snapshot()
+		s.Set(1, 10)
+		s.Delete(2)
+		s.SetMany([]int{3}, []int{30})
+		s.Clear()
+		if val, ok := s.GetOrSet(-1, 0); ok || val != 0 {
+			t.Errorf("GetOrSet on a snapshot should return value without storing it. Got %d, %t", val, ok)
+		}
+		if val, ok := s.Compute(4, func(old int, ok bool) (int, bool) { return 40, true }); !ok || val != 4 {
+			t.Errorf("Compute on a snapshot should return the current value. Got %d, %t", val, ok)
+		}
+		if s.Len() != 100 || s.Validate() != nil {
+			t.Errorf("Writes to a snapshot should be abandoned. Found %d elements", s.Len())
+		}
+		if val, _ := s.Get(1); val != 1 {
+			t.Errorf("Writes to a snapshot should be abandoned. Got %d", val)
+		}
+		if _, err := s.ReadFrom(nil); !errors.Is(err, ErrReadOnly) {
+			t.Errorf("Restoring into a snapshot should fail with ErrReadOnly. Got %v", err)
+		}
+
+		m.SetMany([]int{1, 2}, []int{1})
+		n := 0
+		for range m.All() {
+			n++
+			if n == 10 {
+				m.ShrinkToFit()
+				m.GrowTo(1 << 12)
+			}
+		}
+		if n != 10 {
+			t.Errorf("Resizing during iteration should end it. Visited %d", n)
+		}
+
+		switch policy {
+		case ErrorReturns:
+			if !errors.Is(s.Err(), ErrReadOnly) {
+				t.Errorf("The first misuse of a snapshot should be recorded. Got %v", s.Err())
+			}
+			if !errors.Is(m.Err(), ErrMismatchedLengths) {
+				t.Errorf("Only the first misuse should be recorded. Got %v", m.Err())
+			}
+			if c := m.Clone(); c.Err() != nil {
+				t.Errorf("A clone should start without errors. Got %v", c.Err())
+			}
+			m.Reset()
+			if m.Err() != nil {
+				t.Errorf("Reset should clear the recorded error. Got %v", m.Err())
+			}
+		case SilentBestEffort:
+			if s.Err() != nil || m.Err() != nil {
+				t.Errorf("No errors should be recorded. Got %v, %v", s.Err(), m.Err())
+			}
+		}
+	}
+
+	defer func() {
+		if r := recover(); r != ErrMismatchedLengths.Error() {
+			t.Errorf("Misuse should panic by default. Got %v", r)
+		}
+	}()
+	must(New[int, int]()).SetMany([]int{1}, nil)
+}
@@ -0,0 +1,21 @@
+package rhmap
+
+// Places where tests can inject a failure that can't otherwise be provoked
+const (
+	// Gob encoding a key, whether probing K in New or encoding a dynamic key
+	faultEncodeKey = "encode key"
+	// Gob encoding a whole map in GobEncode
+	faultEncodeMap = "encode map"
+)
+
+// Test hook deciding whether the operation at a fault site fails. It is nil
+// outside tests.
+var faultHook func(site string) error
+
+// Returns the error injected at site, if any
+func injectFault(site string) error {
+	if faultHook == nil {
+		return nil
+	}
+	return faultHook(site)
+}
@@ -0,0 +1,75 @@
+package rhmap
+
+import (
+	"errors"
+	"testing"
+)
+
+var errInjected = errors.New("injected fault")
+
+// Fails the nth operation at site, counting from 1, for the rest of the test
+func failNth(t *testing.T, site string, n int) {
+	calls := 0
+	faultHook = func(s string) error {
+		if s != site {
+			return nil
+		}
+		calls++
+		if calls == n {
+			return errInjected
+		}
+		return nil
+	}
+	t.Cleanup(func() { faultHook = nil })
+}
+
+func TestFaultNewKeyProbe(t *testing.T) {
+	failNth(t, faultEncodeKey, 1)
+	if _, err := New[point, int](); !errors.Is(err, errInjected) {
+		t.Errorf("New should return the key probe's error. Got %v", err)
+	}
+
+	// The outer key type probes first, so the second probe is the inner one
+	failNth(t, faultEncodeKey, 2)
+	if _, err := NewNested[point, point, int](); !errors.Is(err, errInjected) {
+		t.Errorf("NewNested should return the inner key probe's error. Got %v", err)
+	}
+}
+
+func TestFaultDynamicKeyLeavesMapIntact(t *testing.T) {
+	m := must(New[any, int]())
+	failNth(t, faultEncodeKey, 2)
+	m.Set(point{1, 2}, 1)
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Set should panic when a dynamic key can't be encoded.")
+			}
+		}()
+		m.Set(point{3, 4}, 2)
+	}()
+
+	if m.Len() != 1 {
+		t.Errorf("A failed Set should not change the map. Found %d elements", m.Len())
+	}
+	if val, ok := m.Get(point{1, 2}); !ok || val != 1 {
+		t.Errorf("Key set before the failure should map to 1. Got %d, %t", val, ok)
+	}
+}
+
+func TestFaultGobEncode(t *testing.T) {
+	m := must(New[int, int]())
+	m.Set(1, 1)
+	failNth(t, faultEncodeMap, 1)
+
+	if _, err := m.MarshalBinary(); !errors.Is(err, errInjected) {
+		t.Errorf("MarshalBinary should return the encoding error. Got %v", err)
+	}
+	data := must(m.MarshalBinary())
+
+	restored := must(New[int, int]())
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Errorf("Encoding should succeed once the fault has passed. Got %v", err)
+	}
+}
@@ -0,0 +1,358 @@
+// Package filemap provides a robin hood hashmap whose table lives in a
+// memory-mapped file, so that it survives process restarts and may grow
+// past RAM, with the operating system paging slots in and out. The table is
+// the same flat open-addressing layout as rhmap's, which suits a file well:
+// every slot sits at a fixed offset and a lookup touches one or two pages.
+//
+// Keys and values must be of fixed size and hold no pointers, since their
+// bytes are stored as they are in memory.
+package filemap
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"math/bits"
+	"math/rand/v2"
+	"os"
+	"reflect"
+	"unsafe"
+
+	rhmap "github.com/micoo227/robin-hood-hashing"
+	"github.com/micoo227/robin-hood-hashing/internal/rawtype"
+)
+
+const (
+	fileMagic   = "RHMF"
+	fileVersion = 1
+	// Bytes before the first slot, leaving room in the header for later
+	// versions
+	headerLen = 64
+	minSize   = 16
+)
+
+// Load at which the table grows, as a fraction of maxLoadDen
+const (
+	maxLoadNum = 7
+	maxLoadDen = 8
+)
+
+// Fixed header at the start of the file
+type header struct {
+	magic     [4]byte
+	version   uint32
+	keySize   uint32
+	valueSize uint32
+	slotSize  uint32
+	_         uint32
+	size      uint64
+	count     uint64
+	k0, k1    uint64
+}
+
+// Table slot. meta is 0 for an empty slot, and one more than the element's
+// probe sequence length otherwise.
+type slot[K comparable, V any] struct {
+	meta  uint32
+	key   K
+	value V
+}
+
+// Robin hood hashmap stored in a memory-mapped file. Keys are hashed and
+// compared by their bytes, so float keys 0 and -0 are distinct and NaN keys
+// can be found. Writes reach the file as the operating system flushes
+// pages; Sync forces them out, and a crash between syncs may leave the
+// file inconsistent. A Map must not be used concurrently, and a file must
+// not be opened by more than one Map at once.
+type Map[K comparable, V any] struct {
+	path  string
+	file  *os.File
+	data  []byte
+	hdr   *header
+	slots []slot[K, V]
+}
+
+// Opens the map stored at path, creating the file with room for capacity
+// elements if it doesn't exist. It returns an error if K or V holds
+// pointers, K has padding whose bytes would be hashed, the file was written
+// for other key or value types, or memory mapping isn't supported.
+func Open[K comparable, V any](path string, capacity uint64) (*Map[K, V], error) {
+	if err := checkTypes[K, V](); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	var m *Map[K, V]
+	if info.Size() == 0 {
+		m, err = create[K, V](path, file, sizeFor(capacity), rand.Uint64(), rand.Uint64())
+	} else {
+		m, err = load[K, V](path, file, info.Size())
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+// Sizes a new empty file for size slots and maps it
+func create[K comparable, V any](path string, file *os.File, size, k0, k1 uint64) (*Map[K, V], error) {
+	slotSize := unsafe.Sizeof(slot[K, V]{})
+	if err := file.Truncate(int64(headerLen + size*uint64(slotSize))); err != nil {
+		return nil, err
+	}
+	m, err := mapFile[K, V](path, file, size)
+	if err != nil {
+		return nil, err
+	}
+	var zeroKey K
+	var zeroVal V
+	*m.hdr = header{
+		version:   fileVersion,
+		keySize:   uint32(unsafe.Sizeof(zeroKey)),
+		valueSize: uint32(unsafe.Sizeof(zeroVal)),
+		slotSize:  uint32(slotSize),
+		size:      size,
+		k0:        k0,
+		k1:        k1,
+	}
+	copy(m.hdr.magic[:], fileMagic)
+	return m, nil
+}
+
+// Maps an existing file after checking its header
+func load[K comparable, V any](path string, file *os.File, fileSize int64) (*Map[K, V], error) {
+	invalid := fmt.Errorf("filemap: %s is not a map file", path)
+	if fileSize < headerLen {
+		return nil, invalid
+	}
+	var buf [headerLen]byte
+	if _, err := file.ReadAt(buf[:], 0); err != nil {
+		return nil, err
+	}
+	hdr := (*header)(unsafe.Pointer(&buf))
+	if string(hdr.magic[:]) != fileMagic {
+		return nil, invalid
+	}
+	if hdr.version != fileVersion {
+		return nil, fmt.Errorf("filemap: %s has unsupported version %d", path, hdr.version)
+	}
+	var zeroKey K
+	var zeroVal V
+	if hdr.keySize != uint32(unsafe.Sizeof(zeroKey)) || hdr.valueSize != uint32(unsafe.Sizeof(zeroVal)) ||
+		hdr.slotSize != uint32(unsafe.Sizeof(slot[K, V]{})) {
+		return nil, fmt.Errorf("filemap: %s holds keys or values of other types", path)
+	}
+	if hdr.size < minSize || hdr.size&(hdr.size-1) != 0 ||
+		uint64(fileSize) != headerLen+hdr.size*uint64(hdr.slotSize) {
+		return nil, invalid
+	}
+	return mapFile[K, V](path, file, hdr.size)
+}
+
+func mapFile[K comparable, V any](path string, file *os.File, size uint64) (*Map[K, V], error) {
+	data, err := mmap(file, headerLen+int(size)*int(unsafe.Sizeof(slot[K, V]{})))
+	if err != nil {
+		return nil, err
+	}
+	return &Map[K, V]{
+		path:  path,
+		file:  file,
+		data:  data,
+		hdr:   (*header)(unsafe.Pointer(&data[0])),
+		slots: unsafe.Slice((*slot[K, V])(unsafe.Pointer(&data[headerLen])), size),
+	}, nil
+}
+
+func (m *Map[K, V]) Get(key K) (V, bool) {
+	if i, ok := m.find(key); ok {
+		return m.slots[i].value, true
+	}
+	var zeroVal V
+	return zeroVal, false
+}
+
+// Stores value under key, growing the file when the table is full. Growing
+// rehashes into a new file beside the old one, so it briefly needs disk
+// space for both.
+func (m *Map[K, V]) Set(key K, value V) error {
+	if i, ok := m.find(key); ok {
+		m.slots[i].value = value
+		return nil
+	}
+	if (m.hdr.count+1)*maxLoadDen > m.hdr.size*maxLoadNum {
+		if err := m.grow(); err != nil {
+			return err
+		}
+	}
+	m.insert(key, value)
+	m.hdr.count++
+	return nil
+}
+
+// Deletes key, shifting the elements after it back so no tombstone is left
+func (m *Map[K, V]) Delete(key K) {
+	i, ok := m.find(key)
+	if !ok {
+		return
+	}
+	mask := m.hdr.size - 1
+	for {
+		next := (i + 1) & mask
+		if m.slots[next].meta <= 1 {
+			break
+		}
+		m.slots[i] = m.slots[next]
+		m.slots[i].meta--
+		i = next
+	}
+	m.slots[i] = slot[K, V]{}
+	m.hdr.count--
+}
+
+func (m *Map[K, V]) Len() uint64 {
+	return m.hdr.count
+}
+
+// Iterates over the map's elements in table order. The map must not be
+// written to during iteration.
+func (m *Map[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for i := range m.slots {
+			if m.slots[i].meta != 0 && !yield(m.slots[i].key, m.slots[i].value) {
+				return
+			}
+		}
+	}
+}
+
+// Flushes the map's pages to disk
+func (m *Map[K, V]) Sync() error {
+	return m.file.Sync()
+}
+
+// Syncs and unmaps the file. The map must not be used afterwards.
+func (m *Map[K, V]) Close() error {
+	err := m.Sync()
+	if uerr := munmap(m.data); err == nil {
+		err = uerr
+	}
+	if cerr := m.file.Close(); err == nil {
+		err = cerr
+	}
+	m.data, m.slots, m.hdr = nil, nil, nil
+	return err
+}
+
+// Returns the slot holding key, if any
+func (m *Map[K, V]) find(key K) (uint64, bool) {
+	mask := m.hdr.size - 1
+	i := m.hash(&key) & mask
+	for psl := uint32(0); ; psl++ {
+		s := &m.slots[i]
+		if s.meta == 0 || s.meta-1 < psl {
+			return 0, false
+		}
+		if s.meta-1 == psl && keyBytes(&s.key) == keyBytes(&key) {
+			return i, true
+		}
+		i = (i + 1) & mask
+	}
+}
+
+// Inserts a key known to be absent, taking slots from richer elements
+func (m *Map[K, V]) insert(key K, value V) {
+	mask := m.hdr.size - 1
+	cur := slot[K, V]{meta: 1, key: key, value: value}
+	i := m.hash(&key) & mask
+	for {
+		s := &m.slots[i]
+		if s.meta == 0 {
+			*s = cur
+			return
+		}
+		if s.meta < cur.meta {
+			*s, cur = cur, *s
+		}
+		cur.meta++
+		i = (i + 1) & mask
+	}
+}
+
+// Rehashes the table into a file twice its size, which then replaces the
+// map's file
+func (m *Map[K, V]) grow() error {
+	tmpPath := m.path + ".grow"
+	file, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	bigger, err := create[K, V](m.path, file, 2*m.hdr.size, m.hdr.k0, m.hdr.k1)
+	if err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	for i := range m.slots {
+		if m.slots[i].meta != 0 {
+			bigger.insert(m.slots[i].key, m.slots[i].value)
+		}
+	}
+	bigger.hdr.count = m.hdr.count
+	if err := bigger.Sync(); err == nil {
+		err = os.Rename(tmpPath, m.path)
+	}
+	if err != nil {
+		bigger.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+
+	munmap(m.data)
+	m.file.Close()
+	*m = *bigger
+	return nil
+}
+
+func (m *Map[K, V]) hash(key *K) uint64 {
+	return rhmap.SipHasher{}.Hash(m.hdr.k0, m.hdr.k1, unsafe.Slice((*byte)(unsafe.Pointer(key)), unsafe.Sizeof(*key)))
+}
+
+// Returns the bytes of a key as a string, so that keys can be compared
+// bitwise with ==
+func keyBytes[K any](key *K) string {
+	return unsafe.String((*byte)(unsafe.Pointer(key)), unsafe.Sizeof(*key))
+}
+
+// Returns the number of slots that holds capacity elements under the
+// maximum load
+func sizeFor(capacity uint64) uint64 {
+	size := uint64(minSize)
+	if need := (capacity*maxLoadDen + maxLoadNum - 1) / maxLoadNum; need > size {
+		size = 1 << bits.Len64(need-1)
+	}
+	return size
+}
+
+// Checks that K and V can be stored as raw bytes and K hashed by them
+func checkTypes[K comparable, V any]() error {
+	kt, vt := reflect.TypeFor[K](), reflect.TypeFor[V]()
+	if rawtype.HoldsPointers(kt) || rawtype.HoldsPointers(vt) {
+		return errors.New("filemap: key and value types must not hold pointers")
+	}
+	if kt.Size() == 0 {
+		return errors.New("filemap: key type must not be empty")
+	}
+	if rawtype.Padded(kt) {
+		return fmt.Errorf("filemap: key type %v has padding, whose bytes are undefined", kt)
+	}
+	return nil
+}
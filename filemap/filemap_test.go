@@ -0,0 +1,93 @@
+//go:build unix
+
+package filemap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+type point struct {
+	X, Y int32
+}
+
+func TestMapSurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "map")
+	m := must(Open[point, int64](path, 0))
+	for i := int32(0); i < 1000; i++ {
+		if err := m.Set(point{i, -i}, int64(i)); err != nil {
+			t.Fatalf("Set returned an error: %v", err)
+		}
+	}
+	m.Set(point{5, -5}, 50)
+	for i := int32(0); i < 1000; i += 2 {
+		m.Delete(point{i, -i})
+	}
+	m.Delete(point{-1, 1})
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close returned an error: %v", err)
+	}
+	if _, err := os.Stat(path + ".grow"); !os.IsNotExist(err) {
+		t.Errorf("Growing should not leave its temporary file behind.")
+	}
+
+	m = must(Open[point, int64](path, 0))
+	defer m.Close()
+	if m.Len() != 500 {
+		t.Errorf("Reopened map should contain 500 elements. Found %d", m.Len())
+	}
+	for i := int32(0); i < 1000; i++ {
+		val, ok := m.Get(point{i, -i})
+		want := int64(i)
+		if i == 5 {
+			want = 50
+		}
+		if i%2 == 0 && ok {
+			t.Errorf("Deleted key %d should stay deleted after reopening.", i)
+		} else if i%2 == 1 && (!ok || val != want) {
+			t.Errorf("Key %d should map to %d after reopening. Got %d, %t", i, want, val, ok)
+		}
+	}
+	n := 0
+	for range m.All() {
+		n++
+	}
+	if n != 500 {
+		t.Errorf("All should visit every element. Visited %d", n)
+	}
+}
+
+func TestOpenChecksTypes(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Open[string, int](filepath.Join(dir, "a"), 0); err == nil {
+		t.Errorf("Keys holding pointers should be rejected.")
+	}
+	if _, err := Open[int, []byte](filepath.Join(dir, "b"), 0); err == nil {
+		t.Errorf("Values holding pointers should be rejected.")
+	}
+	type padded struct {
+		A int8
+		B int64
+	}
+	if _, err := Open[padded, int](filepath.Join(dir, "c"), 0); err == nil {
+		t.Errorf("Keys with padding should be rejected.")
+	}
+
+	path := filepath.Join(dir, "d")
+	must(Open[int64, int64](path, 100)).Close()
+	if _, err := Open[int32, int64](path, 0); err == nil {
+		t.Errorf("A file written for other key types should be rejected.")
+	}
+	os.WriteFile(filepath.Join(dir, "e"), []byte("not a map file"), 0o644)
+	if _, err := Open[int64, int64](filepath.Join(dir, "e"), 0); err == nil {
+		t.Errorf("A file without the map header should be rejected.")
+	}
+}
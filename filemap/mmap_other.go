@@ -0,0 +1,16 @@
+//go:build !unix
+
+package filemap
+
+import (
+	"errors"
+	"os"
+)
+
+func mmap(file *os.File, size int) ([]byte, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func munmap(data []byte) error {
+	return errors.ErrUnsupported
+}
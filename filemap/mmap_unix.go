@@ -0,0 +1,16 @@
+//go:build unix
+
+package filemap
+
+import (
+	"os"
+	"syscall"
+)
+
+func mmap(file *os.File, size int) ([]byte, error) {
+	return syscall.Mmap(int(file.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+}
+
+func munmap(data []byte) error {
+	return syscall.Munmap(data)
+}
@@ -0,0 +1,109 @@
+package rhmap
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// Bits per key and hash probes giving roughly a 1% false-positive rate
+const (
+	filterBitsPerKey = 10
+	filterHashes     = 7
+)
+
+// Bloom filter over the keys of a map at the time it was exported. It may
+// report false positives but never false negatives, so a miss means the key
+// is certainly absent from the map it was built from.
+type Filter[K comparable] struct {
+	bits      []uint64
+	numHashes uint32
+	hasher    Hasher
+	enc       keyEncoder[K]
+	k0        uint64
+	k1        uint64
+}
+
+// Builds a bloom filter of the map's current keys sized for about a 1%
+// false-positive rate. The filter is a copy and does not track later changes.
+func (m *Map[K, V]) ExistenceFilter() *Filter[K] {
+	numBits := max(64, m.numElements*filterBitsPerKey)
+	f := &Filter[K]{
+		bits:      make([]uint64, (numBits+63)/64),
+		numHashes: filterHashes,
+		hasher:    m.hasher,
+		enc:       m.enc,
+		k0:        m.k0,
+		k1:        m.k1,
+	}
+
+	for _, elements := range m.tables() {
+		for i := range elements {
+			if elements[i].set {
+				f.add(elements[i].hash)
+			}
+		}
+	}
+	return f
+}
+
+// Reports whether key may be in the map the filter was built from
+func (f *Filter[K]) MayContain(key K) bool {
+	h1, h2 := f.probes(f.enc.hash(f.hasher, f.k0, f.k1, key))
+	numBits := uint64(len(f.bits)) * 64
+	for i := uint64(0); i < uint64(f.numHashes); i++ {
+		bit := (h1 + i*h2) % numBits
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *Filter[K]) add(hash uint64) {
+	h1, h2 := f.probes(hash)
+	numBits := uint64(len(f.bits)) * 64
+	for i := uint64(0); i < uint64(f.numHashes); i++ {
+		bit := (h1 + i*h2) % numBits
+		f.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// Derives the two hashes combined into every probe (Kirsch-Mitzenmacher)
+func (f *Filter[K]) probes(hash uint64) (uint64, uint64) {
+	return hash & math.MaxUint32, hash>>32 | 1
+}
+
+// Encodes the filter with its seeds so that another process can check keys
+// against it. Only filters built with the default SipHash hasher can be
+// decoded, since a custom hasher can't be serialized.
+func (f *Filter[K]) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, 0, 20+8*len(f.bits))
+	buf = binary.LittleEndian.AppendUint64(buf, f.k0)
+	buf = binary.LittleEndian.AppendUint64(buf, f.k1)
+	buf = binary.LittleEndian.AppendUint32(buf, f.numHashes)
+	for _, word := range f.bits {
+		buf = binary.LittleEndian.AppendUint64(buf, word)
+	}
+	return buf, nil
+}
+
+func (f *Filter[K]) UnmarshalBinary(data []byte) error {
+	if len(data) < 20 || (len(data)-20)%8 != 0 || len(data) == 20 {
+		return errors.New("rhmap: invalid filter encoding")
+	}
+	f.k0 = binary.LittleEndian.Uint64(data)
+	f.k1 = binary.LittleEndian.Uint64(data[8:])
+	f.numHashes = binary.LittleEndian.Uint32(data[16:])
+	f.bits = make([]uint64, (len(data)-20)/8)
+	for i := range f.bits {
+		f.bits[i] = binary.LittleEndian.Uint64(data[20+8*i:])
+	}
+	f.hasher = SipHasher{}
+	enc, err := newKeyEncoder[K]()
+	if err != nil {
+		return err
+	}
+	f.enc = enc
+	return nil
+}
@@ -0,0 +1,55 @@
+package rhmap
+
+import "testing"
+
+func TestExistenceFilter(t *testing.T) {
+	m := must(New[int, int]())
+
+	for i := 0; i < 1000; i++ {
+		m.Set(i, i)
+	}
+	f := m.ExistenceFilter()
+
+	for i := 0; i < 1000; i++ {
+		if !f.MayContain(i) {
+			t.Errorf("Filter should contain key %d stored in the map.", i)
+		}
+	}
+
+	falsePositives := 0
+	for i := 1000; i < 11000; i++ {
+		if f.MayContain(i) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 300 {
+		t.Errorf("False-positive rate should be about 1%%. Got %d of 10000", falsePositives)
+	}
+}
+
+func TestFilterMarshalBinary(t *testing.T) {
+	m := must(New[string, int]())
+
+	for _, k := range []string{"a", "b", "c"} {
+		m.Set(k, 1)
+	}
+
+	data, err := m.ExistenceFilter().MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary returned an error: %v", err)
+	}
+
+	var f Filter[string]
+	if err := f.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary returned an error: %v", err)
+	}
+	for _, k := range []string{"a", "b", "c"} {
+		if !f.MayContain(k) {
+			t.Errorf("Decoded filter should contain key %q.", k)
+		}
+	}
+
+	if err := f.UnmarshalBinary(data[:10]); err == nil {
+		t.Error("UnmarshalBinary should reject truncated data.")
+	}
+}
@@ -0,0 +1,162 @@
+package rhmap
+
+import "math/bits"
+
+// Mean PSL above which a table of at least floodMinElements elements is
+// considered flooded. Random keys average under 6 at the default load factor.
+const (
+	floodMeanPsl     = 16
+	floodMinElements = 256
+)
+
+// Max PSL above which a table of the given size is considered flooded. Random
+// keys stay under 3 log2(size) at the default load factor, even in tables of
+// a million slots.
+func floodPsl(size uint64) uint {
+	return uint(4*bits.Len64(size) + 16)
+}
+
+// Growth of the max PSL reported as a suspected flood when it takes at most
+// floodInsertsPerPsl inserts per step, measured over windows of floodWindow
+// inserts. Colliding keys raise it by about one per insert, while random
+// keys take many inserts per step even as a table nears its load factor.
+const (
+	floodPslJump       = 16
+	floodInsertsPerPsl = 4
+	floodWindow        = 256
+)
+
+// Signal that made a map suspect it is being flooded
+type FloodSignal uint8
+
+const (
+	// Probe lengths past the limits that trigger a reseed
+	FloodLongProbes FloodSignal = iota + 1
+	// Max PSL growing much faster than random keys make it grow
+	FloodPslGrowth
+)
+
+func (s FloodSignal) String() string {
+	switch s {
+	case FloodLongProbes:
+		return "long probes"
+	case FloodPslGrowth:
+		return "PSL growth"
+	}
+	return "unknown"
+}
+
+// Passed to a WithOnSuspectedFlooding callback when a map's probe lengths
+// suggest that an attacker is inserting colliding keys
+type FloodReport struct {
+	Signal FloodSignal
+	MaxPsl uint
+	// Growth of the max PSL over the last Inserts inserts, for PSL growth
+	PslGrowth uint
+	Inserts   uint64
+	MeanPsl   float64
+	Len       uint64
+	Capacity  uint64
+	// Whether the map reseeded itself in response
+	Reseeded bool
+}
+
+// Reseeds the map if its probe lengths are implausible for random keys,
+// which suggests an attacker who learned the seeds is choosing colliding keys.
+// Such probes, and a max PSL growing faster than random keys make it grow,
+// are also reported as suspected floods. It runs after public inserts
+// return, never while a caller still holds hashes computed under the old
+// seeds.
+func (m *Map[K, V]) checkFlooding() {
+	if m.maxPsl > floodPsl(m.size) ||
+		(m.numElements >= floodMinElements && m.totalPsl > floodMeanPsl*m.numElements) {
+		if m.flooded() {
+			report := m.floodReport(FloodLongProbes)
+			report.Reseeded = m.reseed()
+			// A flood reseeding doesn't break would otherwise be reported
+			// on every insert
+			if m.longProbesSize != m.size || report.Reseeded {
+				m.longProbesSize = m.size
+				m.suspectFlooding(report)
+			}
+		}
+	}
+	if m.maxPsl >= m.watchPsl+floodPslJump || m.size != m.watchSize || m.numElements >= m.watchLen+floodWindow {
+		m.watchPslGrowth()
+	}
+}
+
+// Reports the max PSL's growth since the current window opened if it came
+// too fast in a table of the same size, and opens a new window
+func (m *Map[K, V]) watchPslGrowth() {
+	inserts := m.numElements - m.watchLen
+	jump := uint(m.floodScale() * floodPslJump)
+	if m.size == m.watchSize && m.numElements > m.watchLen && m.maxPsl >= m.watchPsl+jump &&
+		inserts <= floodInsertsPerPsl*uint64(m.maxPsl-m.watchPsl) {
+		report := m.floodReport(FloodPslGrowth)
+		report.PslGrowth, report.Inserts = m.maxPsl-m.watchPsl, inserts
+		m.suspectFlooding(report)
+	}
+	m.watchPsl, m.watchLen, m.watchSize = m.maxPsl, m.numElements, m.size
+}
+
+func (m *Map[K, V]) floodReport(signal FloodSignal) FloodReport {
+	r := FloodReport{Signal: signal, MaxPsl: m.maxPsl, Len: m.numElements, Capacity: m.size}
+	if m.numElements > 0 {
+		r.MeanPsl = float64(m.totalPsl) / float64(m.numElements)
+	}
+	return r
+}
+
+func (m *Map[K, V]) suspectFlooding(report FloodReport) {
+	m.suspectedFloods++
+	if m.metrics != nil {
+		m.metrics.Count(MetricSuspectedFloods, 1)
+	}
+	if m.onFlood != nil {
+		m.onFlood(report)
+	}
+}
+
+// Returns how many times the map has suspected it was being flooded
+func (m *Map[K, V]) SuspectedFloods() uint64 {
+	return m.suspectedFloods
+}
+
+// Applies the flood limits scaled to the load factor. Probe lengths grow
+// with 1/(1-load), so tables allowed to fill further than the default are
+// allowed proportionally longer probes.
+func (m *Map[K, V]) flooded() bool {
+	scale := m.floodScale()
+	return float64(m.maxPsl) > scale*float64(floodPsl(m.size)) ||
+		(m.numElements >= floodMinElements && float64(m.totalPsl) > scale*floodMeanPsl*float64(m.numElements))
+}
+
+// Factor by which the map's load factor lengthens probes over the default's
+func (m *Map[K, V]) floodScale() float64 {
+	return max(1, (1-float64(defaultLoadFactor))/(1-float64(m.loadFactor)))
+}
+
+// Draws new random seeds, rehashes every element with them and reports
+// whether it did. Only maps
+// hashing with SipHash under seeds of their own are reseeded: other hashers
+// don't resist crafted keys whatever their seeds, and seeds set with
+// WithSeedsFrom or WithDeterministic are shared or reproducible on purpose.
+// A table that is still flooded after a reseed isn't reseeded again until
+// it resizes, so a flood that reseeding can't break costs one rebuild.
+func (m *Map[K, V]) reseed() bool {
+	if _, ok := m.hasher.(SipHasher); !ok || !m.autoReseed || m.reseededSize == m.size {
+		return false
+	}
+	m.k0, m.k1 = randomSeeds(m.cryptoSeeds)
+	m.rehashAll()
+	m.reseeds++
+	m.reseededSize = m.size
+	return true
+}
+
+// Returns how many times the map has reseeded itself after detecting
+// implausibly long probes, a sign that its seeds leaked and it was flooded
+func (m *Map[K, V]) Reseeds() uint64 {
+	return m.reseeds
+}
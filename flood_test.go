@@ -0,0 +1,132 @@
+package rhmap
+
+import "testing"
+
+// Returns n keys that all share a home slot in m, as an attacker who learned
+// m's seeds could craft
+func collidingKeys(m *Map[int, int], n int) []int {
+	var keys []int
+	for k := 0; len(keys) < n; k++ {
+		if m.indexAtPsl(m.hashKey(k), 0) == 0 {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+func TestReseedOnFlood(t *testing.T) {
+	m := must(New[int, int](WithSize(1024)))
+	k0, k1 := m.ExportSeeds()
+	keys := collidingKeys(m, 200)
+	for _, k := range keys {
+		m.Set(k, k)
+	}
+
+	if m.Reseeds() != 1 || m.Stats().Reseeds != 1 {
+		t.Errorf("A flood of colliding keys should reseed the map once. Got %d reseeds", m.Reseeds())
+	}
+	if n0, n1 := m.ExportSeeds(); n0 == k0 && n1 == k1 {
+		t.Errorf("Reseeding should draw new seeds.")
+	}
+	if m.maxPsl > floodPsl(m.size) {
+		t.Errorf("Keys should spread out once reseeded. Max PSL is %d", m.maxPsl)
+	}
+	for _, k := range keys {
+		if val, ok := m.Get(k); !ok || val != k {
+			t.Errorf("Key %d should survive the reseed. Got %d, %t", k, val, ok)
+		}
+	}
+	if err := m.Validate(); err != nil {
+		t.Errorf("The reseeded map should validate. Got %v", err)
+	}
+}
+
+func TestReseedOnlyOwnSipHashSeeds(t *testing.T) {
+	for name, opts := range map[string][]Option{
+		"deterministic": {WithDeterministic(1)},
+		"xxhash":        {WithHasher(XXHasher{})},
+	} {
+		m := must(New[int, int](append(opts, WithSize(1024))...))
+		for _, k := range collidingKeys(m, 100) {
+			m.Set(k, k)
+		}
+		if m.Reseeds() != 0 {
+			t.Errorf("%s: the map should keep its seeds. Got %d reseeds", name, m.Reseeds())
+		}
+	}
+
+	// A flood reseeding can't break only costs one rebuild per table size
+	m := must(New[int, int](WithSize(1024)))
+	for i := 0; i < 3; i++ {
+		m.maxPsl = floodPsl(m.size) + 1
+		m.checkFlooding()
+	}
+	if m.Reseeds() != 1 {
+		t.Errorf("A table should not reseed again before it resizes. Got %d reseeds", m.Reseeds())
+	}
+}
+
+func TestNoReseedForRandomKeys(t *testing.T) {
+	m := must(New[int, int]())
+	for i := 0; i < 1<<18; i++ {
+		m.Set(i, i)
+	}
+	c := must(NewConcurrent[int, int](4))
+	if c.shards[0].table.autoReseed {
+		t.Errorf("Shards routed by one hash must not reseed themselves.")
+	}
+	if m.Reseeds() != 0 || m.SuspectedFloods() != 0 {
+		t.Errorf("Random keys should never look like a flood. Got %d reseeds, %d suspected floods", m.Reseeds(), m.SuspectedFloods())
+	}
+}
+
+func TestOnSuspectedFlooding(t *testing.T) {
+	var reports []FloodReport
+	m := must(New[int, int](WithSize(1<<14), WithOnSuspectedFlooding(func(r FloodReport) { reports = append(reports, r) })))
+	for i := 0; i < 1000; i++ {
+		m.Set(-i-1, i)
+	}
+	if len(reports) != 0 {
+		t.Fatalf("Random keys should not be reported. Got %v", reports)
+	}
+
+	// Colliding keys raise the max PSL by one each, well before probes are
+	// long enough to reseed
+	for _, k := range collidingKeys(m, 40) {
+		m.Set(k, k)
+	}
+	if len(reports) == 0 || reports[0].Signal != FloodPslGrowth || reports[0].PslGrowth < floodPslJump || reports[0].Reseeded {
+		t.Fatalf("A burst of colliding keys should be reported as PSL growth. Got %v", reports)
+	}
+	if m.Reseeds() != 0 {
+		t.Errorf("PSL growth alone should not reseed. Got %d reseeds", m.Reseeds())
+	}
+
+	reports = nil
+	for _, k := range collidingKeys(m, 100) {
+		m.Set(k, k)
+	}
+	last := reports[len(reports)-1]
+	if last.Signal != FloodLongProbes || !last.Reseeded || m.Reseeds() != 1 {
+		t.Errorf("Probes long enough to reseed should be reported with the reseed. Got %v", reports)
+	}
+	if m.SuspectedFloods() != m.Stats().SuspectedFloods || m.SuspectedFloods() == 0 {
+		t.Errorf("Suspected floods should be counted. Got %d", m.SuspectedFloods())
+	}
+
+	// A flood that reseeding can't break is reported once per table size
+	x := must(New[int, int](WithSize(1024), WithHasher(XXHasher{}), WithOnSuspectedFlooding(func(r FloodReport) { reports = append(reports, r) })))
+	reports = nil
+	for _, k := range collidingKeys(x, 100) {
+		x.Set(k, k)
+	}
+	long := 0
+	for _, r := range reports {
+		if r.Signal == FloodLongProbes {
+			long++
+		}
+	}
+	if long != 1 {
+		t.Errorf("Long probes should be reported once while they last. Got %d reports", long)
+	}
+}
@@ -0,0 +1,46 @@
+package rhmap
+
+import "iter"
+
+// Robin hood hashmap for key types that aren't comparable, such as slices
+// or structs holding maps, hashed and compared by caller-supplied functions.
+// The hashes are mixed again by the underlying map's hasher, so a hash
+// function with poorly distributed bits still spreads keys evenly; keys
+// whose hashes collide outright are told apart by the equality function.
+type FuncMap[K, V any] struct {
+	buckets *bucketTable[uint64, K, V]
+	hash    func(K) uint64
+}
+
+// Creates a map whose keys are hashed by hash and compared by eq. Keys that
+// eq reports as equal must hash identically. Options configure the
+// underlying map; WithZeroDeletes has no effect.
+func NewFunc[K, V any](hash func(K) uint64, eq func(a, b K) bool, opts ...Option) (*FuncMap[K, V], error) {
+	buckets, err := newBucketTable[uint64, K, V](eq, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &FuncMap[K, V]{buckets: buckets, hash: hash}, nil
+}
+
+func (f *FuncMap[K, V]) Set(key K, value V) {
+	f.buckets.set(f.hash(key), key, value)
+}
+
+func (f *FuncMap[K, V]) Get(key K) (V, bool) {
+	return f.buckets.get(f.hash(key), key)
+}
+
+func (f *FuncMap[K, V]) Delete(key K) {
+	f.buckets.delete(f.hash(key), key)
+}
+
+func (f *FuncMap[K, V]) Len() uint64 {
+	return f.buckets.numElements
+}
+
+// Returns an iterator over every key/value pair in the map. The map must not
+// be modified while the iteration is in progress.
+func (f *FuncMap[K, V]) All() iter.Seq2[K, V] {
+	return f.buckets.all()
+}
@@ -0,0 +1,57 @@
+package rhmap
+
+import (
+	"hash/maphash"
+	"slices"
+	"testing"
+)
+
+func TestFuncMap(t *testing.T) {
+	seed := maphash.MakeSeed()
+	hashInts := func(key []int) uint64 {
+		var h maphash.Hash
+		h.SetSeed(seed)
+		for _, v := range key {
+			maphash.WriteComparable(&h, v)
+		}
+		return h.Sum64()
+	}
+	m := must(NewFunc[[]int, string](hashInts, slices.Equal[[]int]))
+
+	m.Set([]int{1, 2}, "a")
+	m.Set([]int{2, 1}, "b")
+	m.Set([]int{}, "empty")
+	m.Set([]int{1, 2}, "c")
+	if m.Len() != 3 {
+		t.Errorf("Map should contain 3 elements. Found %d", m.Len())
+	}
+	if val, ok := m.Get([]int{1, 2}); !ok || val != "c" {
+		t.Errorf("Equal slices should find the same element. Got %q, %t", val, ok)
+	}
+
+	// A constant hash makes every key collide, leaving eq to tell them apart
+	c := must(NewFunc[[]int, int](func([]int) uint64 { return 7 }, slices.Equal[[]int]))
+	for i := 0; i < 50; i++ {
+		c.Set([]int{i}, i)
+	}
+	c.Delete([]int{10})
+	c.Delete([]int{100})
+	if c.Len() != 49 {
+		t.Errorf("Map should contain 49 elements. Found %d", c.Len())
+	}
+	for i := 0; i < 50; i++ {
+		if val, ok := c.Get([]int{i}); ok != (i != 10) || (ok && val != i) {
+			t.Errorf("Key [%d] should be present: %t. Got %d, %t", i, i != 10, val, ok)
+		}
+	}
+	n := 0
+	for k, v := range c.All() {
+		if k[0] != v {
+			t.Errorf("Key %v should map to %d. Got %d", k, k[0], v)
+		}
+		n++
+	}
+	if n != 49 {
+		t.Errorf("All should yield 49 elements. Got %d", n)
+	}
+}
@@ -0,0 +1,60 @@
+package rhmap
+
+// Returns a new map holding the elements for which keep returns true. The
+// new map is configured like m and hashes with its hasher and seeds, so the
+// elements are copied with their cached hashes rather than hashed again.
+// Callbacks, names, metrics and bounds are not carried over.
+func (m *Map[K, V]) Filter(keep func(K, V) bool) *Map[K, V] {
+	result := derive[K, V, V](m, 0)
+	for _, elements := range m.tables() {
+		for i := range elements {
+			if e := &elements[i]; e.set && keep(e.key, e.value) {
+				result.insertAbsent(e.key, e.value, e.hash)
+			}
+		}
+	}
+	return result
+}
+
+// Returns a new map holding every key of m under the value fn returns for
+// its element. Like Filter, the new map is configured like m and reuses its
+// cached hashes.
+func MapValues[K comparable, V, V2 any](m *Map[K, V], fn func(K, V) V2) *Map[K, V2] {
+	result := derive[K, V, V2](m, m.numElements)
+	for _, elements := range m.tables() {
+		for i := range elements {
+			if e := &elements[i]; e.set {
+				result.insertAbsent(e.key, fn(e.key, e.value), e.hash)
+			}
+		}
+	}
+	return result
+}
+
+// Folds every element of m into an accumulator, starting from init, in
+// table order
+func Reduce[K comparable, V, A any](m *Map[K, V], init A, fn func(acc A, key K, value V) A) A {
+	acc := init
+	for k, v := range m.All() {
+		acc = fn(acc, k, v)
+	}
+	return acc
+}
+
+// Creates an empty map with m's hasher, seeds and table settings, sized for
+// n elements
+func derive[K comparable, V, V2 any](m *Map[K, V], n uint64) *Map[K, V2] {
+	opts := []Option{
+		WithHasher(m.hasher),
+		WithSeed(m.k0, m.k1),
+		WithSize(EstimateCapacityFor(n, m.loadFactor)),
+		WithLoadFactor(m.loadFactor),
+		WithShrink(m.shrinkLoad),
+		WithPslGrowth(m.pslGrowth),
+		func(o *options) {
+			o.fastRange, o.grouped, o.rehashStep = m.fastRange, m.grouped, m.rehashStep
+			o.growthFactor, o.growthPolicy, o.lookup = m.growthFactor, m.growthPolicy, m.lookup
+		},
+	}
+	return newMap[K, V2](m.enc, opts...)
+}
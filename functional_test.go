@@ -0,0 +1,57 @@
+package rhmap
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	m := must(New[int, int](WithGroupProbing(), WithIncrementalRehash(4)))
+	for i := 0; i < 1000; i++ {
+		m.Set(i, i)
+	}
+	even := m.Filter(func(k, _ int) bool { return k%2 == 0 })
+	if even.Len() != 500 || m.Len() != 1000 {
+		t.Errorf("Filter should keep 500 of 1000 elements and leave m alone. Got %d and %d", even.Len(), m.Len())
+	}
+	for i := 0; i < 1000; i++ {
+		if _, ok := even.Get(i); ok != (i%2 == 0) {
+			t.Errorf("Key %d should be kept: %t. Got %t", i, i%2 == 0, ok)
+		}
+	}
+	if err := even.Validate(); err != nil {
+		t.Errorf("Filtered map should be valid. Got %v", err)
+	}
+	if !even.grouped || even.rehashStep != 4 {
+		t.Errorf("Filtered map should be configured like its source.")
+	}
+}
+
+func TestMapValues(t *testing.T) {
+	m := must(New[int, int](WithZeroDeletes()))
+	for i := 1; i <= 100; i++ {
+		m.Set(i, i)
+	}
+	strs := MapValues(m, func(k, v int) string { return strconv.Itoa(k * v) })
+	if strs.Len() != 100 {
+		t.Errorf("MapValues should keep every key. Got %d", strs.Len())
+	}
+	if v, ok := strs.Get(7); !ok || v != "49" {
+		t.Errorf("Expected 7 -> 49, Got %q, %t", v, ok)
+	}
+
+	zeros := MapValues(m, func(int, int) int { return 0 })
+	if zeros.Len() != 100 {
+		t.Errorf("Mapping to zero values should keep every key. Got %d", zeros.Len())
+	}
+}
+
+func TestReduce(t *testing.T) {
+	m := must(New[string, int]())
+	m.Set("a", 1)
+	m.Set("b", 2)
+	m.Set("c", 3)
+	if sum := Reduce(m, 0, func(acc int, _ string, v int) int { return acc + v }); sum != 6 {
+		t.Errorf("Expected a sum of 6, Got %d", sum)
+	}
+}
@@ -0,0 +1,159 @@
+package rhmap
+
+import "github.com/micoo227/robin-hood-hashing/internal/slab"
+
+// Identifies a group of a GenerationMap's entries dropped together
+type Generation uint64
+
+// Slot of a GenerationMap's entry slab, linked into its generation's list by
+// index. Each generation's list runs through a sentinel slot of its own.
+type genEntry[K comparable, V any] struct {
+	key   K
+	value V
+	hash  uint64
+	gen   *genState
+}
+
+type genState struct {
+	id    Generation
+	count uint64
+	// Slab index of the sentinel of the generation's list
+	list uint32
+}
+
+// Robin hood hashmap whose entries each belong to a generation, such as the
+// upstream snapshot they were loaded from, so that a whole generation can be
+// invalidated in time proportional to its size without enumerating the
+// other keys or rebuilding the table. The table maps each key to its entry's
+// index in a slab, as in LRU, and each generation's entries are linked
+// together.
+type GenerationMap[K comparable, V any] struct {
+	table   *Map[K, uint32]
+	entries slab.Slab[genEntry[K, V]]
+	gens    *Map[Generation, *genState]
+	last    Generation
+}
+
+// Creates a generation map configured by opts; WithZeroDeletes has no
+// effect. It returns an error if K can't be encoded, as New does.
+func NewGenerationMap[K comparable, V any](opts ...Option) (*GenerationMap[K, V], error) {
+	table, err := New[K, uint32](opts...)
+	if err != nil {
+		return nil, err
+	}
+	table.zeroDeletes = false
+	table.onEvict = nil
+	gens, err := New[Generation, *genState]()
+	if err != nil {
+		return nil, err
+	}
+	return &GenerationMap[K, V]{table: table, gens: gens}, nil
+}
+
+// Returns a generation no entry has been set in yet. Generation 0, which Set
+// uses, is never returned.
+func (g *GenerationMap[K, V]) NewGeneration() Generation {
+	g.last++
+	return g.last
+}
+
+// Sets key to value in generation 0
+func (g *GenerationMap[K, V]) Set(key K, value V) {
+	g.SetInGeneration(0, key, value)
+}
+
+// Sets key to value in gen, moving key out of any other generation
+func (g *GenerationMap[K, V]) SetInGeneration(gen Generation, key K, value V) {
+	hash := g.table.hashKey(key)
+	i, ok, _ := g.table.getWithHash(key, hash)
+	if ok {
+		g.release(i)
+	} else {
+		i = g.entries.Alloc()
+		g.table.setWithHash(key, i, hash)
+	}
+	*g.entries.At(i) = genEntry[K, V]{key: key, value: value, hash: hash}
+	g.join(gen, i)
+}
+
+func (g *GenerationMap[K, V]) Get(key K) (V, bool) {
+	i, ok := g.table.Get(key)
+	if !ok {
+		var zeroVal V
+		return zeroVal, false
+	}
+	return g.entries.At(i).value, true
+}
+
+// Returns the generation key belongs to, if key is present
+func (g *GenerationMap[K, V]) GenerationOf(key K) (Generation, bool) {
+	i, ok := g.table.Get(key)
+	if !ok {
+		return 0, false
+	}
+	return g.entries.At(i).gen.id, true
+}
+
+func (g *GenerationMap[K, V]) Delete(key K) {
+	if i, ok := g.table.Get(key); ok {
+		g.remove(i)
+	}
+}
+
+// Deletes every entry of gen and returns how many there were
+func (g *GenerationMap[K, V]) DropGeneration(gen Generation) uint64 {
+	st, ok := g.gens.Get(gen)
+	if !ok {
+		return 0
+	}
+	n := st.count
+	for st.count > 0 {
+		g.remove(g.entries.Next(st.list))
+	}
+	g.table.maybeShrink()
+	return n
+}
+
+func (g *GenerationMap[K, V]) Len() uint64 {
+	return g.table.Len()
+}
+
+// Returns the number of entries in gen
+func (g *GenerationMap[K, V]) GenerationLen(gen Generation) uint64 {
+	if st, ok := g.gens.Get(gen); ok {
+		return st.count
+	}
+	return 0
+}
+
+// Deletes entry i from the table, takes it out of its generation and frees
+// its slot
+func (g *GenerationMap[K, V]) remove(i uint32) {
+	e := g.entries.At(i)
+	g.table.deleteWithHash(e.key, e.hash)
+	g.release(i)
+	g.entries.Free(i)
+}
+
+// Links entry i into gen, creating the generation's list on its first entry
+func (g *GenerationMap[K, V]) join(gen Generation, i uint32) {
+	st, ok := g.gens.Get(gen)
+	if !ok {
+		st = &genState{id: gen, list: g.entries.NewList()}
+		g.gens.Set(gen, st)
+	}
+	st.count++
+	g.entries.At(i).gen = st
+	g.entries.PushFront(st.list, i)
+}
+
+// Unlinks entry i from its generation, dropping the generation once empty
+func (g *GenerationMap[K, V]) release(i uint32) {
+	st := g.entries.At(i).gen
+	g.entries.Unlink(i)
+	st.count--
+	if st.count == 0 {
+		g.gens.Delete(st.id)
+		g.entries.Free(st.list)
+	}
+}
@@ -0,0 +1,54 @@
+package rhmap
+
+import "testing"
+
+func TestGenerationMap(t *testing.T) {
+	g := must(NewGenerationMap[int, string]())
+	old, next := g.NewGeneration(), g.NewGeneration()
+	if old == 0 || old == next {
+		t.Errorf("New generations should be distinct and nonzero. Got %d, %d", old, next)
+	}
+
+	for i := 0; i < 1000; i++ {
+		g.SetInGeneration(old, i, "old")
+	}
+	g.Set(-1, "pinned")
+	for i := 500; i < 1500; i++ {
+		g.SetInGeneration(next, i, "next")
+	}
+	if g.GenerationLen(old) != 500 || g.GenerationLen(next) != 1000 || g.Len() != 1501 {
+		t.Errorf("Setting a key in another generation should move it. Got %d old, %d next, %d total",
+			g.GenerationLen(old), g.GenerationLen(next), g.Len())
+	}
+	if gen, ok := g.GenerationOf(700); !ok || gen != next {
+		t.Errorf("Key 700 should belong to the newer generation. Got %d, %t", gen, ok)
+	}
+
+	g.Delete(0)
+	if n := g.DropGeneration(old); n != 499 {
+		t.Errorf("Dropping a generation should delete its entries. Expected 499, Got %d", n)
+	}
+	for i := 0; i < 500; i++ {
+		if _, ok := g.Get(i); ok {
+			t.Errorf("Key %d should have been dropped with its generation.", i)
+		}
+	}
+	if val, ok := g.Get(1200); !ok || val != "next" {
+		t.Errorf("Other generations should survive. Got %q, %t", val, ok)
+	}
+	if val, ok := g.Get(-1); !ok || val != "pinned" || g.Len() != 1001 {
+		t.Errorf("Generation 0 should survive. Got %q, %t with %d elements", val, ok, g.Len())
+	}
+	if g.DropGeneration(old) != 0 || g.GenerationLen(old) != 0 {
+		t.Errorf("A dropped generation should be empty.")
+	}
+
+	// Freed slab slots are reused
+	slab := g.entries.Len()
+	for i := 0; i < 400; i++ {
+		g.SetInGeneration(old, i, "again")
+	}
+	if g.entries.Len() != slab {
+		t.Errorf("Freed slots should be reused. Slab grew from %d to %d", slab, g.entries.Len())
+	}
+}
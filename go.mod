@@ -1,5 +1,8 @@
 module github.com/micoo227/robin-hood-hashing
 
-go 1.22.4
+go 1.24
 
-require github.com/dchest/siphash v1.2.3 // indirect
+require (
+	github.com/cespare/xxhash/v2 v2.3.0
+	github.com/dchest/siphash v1.2.3
+)
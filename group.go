@@ -0,0 +1,74 @@
+package rhmap
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// Slots whose control bytes are compared at once
+const groupSize = 8
+
+const (
+	ctrlLows  uint64 = 0x0101010101010101
+	ctrlHighs uint64 = 0x8080808080808080
+)
+
+// Control byte of a slot holding a key with the given hash: the top seven
+// hash bits with the high bit set, so it never equals the 0 of an empty slot
+func ctrlTag(hash uint64) byte {
+	return byte(hash>>57) | 0x80
+}
+
+// Allocates control bytes for a fresh table if the map probes by group. The
+// first group is mirrored after the last slot, so a group starting anywhere
+// in the table loads as one word without wrapping.
+func (m *Map[K, V]) allocCtrl() {
+	m.ctrl = nil
+	if m.grouped && m.size >= groupSize {
+		m.ctrl = make([]byte, m.size+groupSize)
+	}
+}
+
+// Stores e in slot i, keeping its control byte in step
+func (m *Map[K, V]) setSlot(i uint64, e element[K, V]) {
+	m.elements[i] = e
+	if m.ctrl == nil {
+		return
+	}
+	var c byte
+	if e.set {
+		c = ctrlTag(e.hash)
+	}
+	m.ctrl[i] = c
+	if i < groupSize {
+		m.ctrl[m.size+i] = c
+	}
+}
+
+// Looks up key by scanning the control bytes of its probe window a group at
+// a time, only loading elements whose control byte matches
+func (m *Map[K, V]) probeGroups(key K, hash uint64) (V, bool, uint64) {
+	home := m.indexAtPsl(hash, 0)
+	tags := uint64(ctrlTag(hash)) * ctrlLows
+	maxPsl := uint64(m.maxPsl)
+
+	for psl := uint64(0); psl <= maxPsl; psl += groupSize {
+		base := m.wrap(home + psl)
+		x := binary.LittleEndian.Uint64(m.ctrl[base:]) ^ tags
+		// Bytes of x that are zero mark matching tags. A borrow can flag a
+		// byte after a true match, which the element check then rejects.
+		for found := (x - ctrlLows) &^ x & ctrlHighs; found != 0; found &= found - 1 {
+			offset := uint64(bits.TrailingZeros64(found) / 8)
+			if psl+offset > maxPsl {
+				break
+			}
+			i := m.wrap(base + offset)
+			if m.matches(i, key, hash) {
+				return m.elements[i].value, true, i
+			}
+		}
+	}
+
+	var zeroVal V
+	return zeroVal, false, 0
+}
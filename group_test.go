@@ -0,0 +1,129 @@
+package rhmap
+
+import (
+	"strconv"
+	"testing"
+)
+
+// Checks that every control byte matches its slot, including the mirrored
+// first group
+func checkCtrl[K comparable, V any](t *testing.T, m *Map[K, V]) {
+	t.Helper()
+	if m.ctrl == nil {
+		if m.size >= groupSize {
+			t.Fatalf("A grouped table of %d slots should have control bytes.", m.size)
+		}
+		return
+	}
+	for i := range m.elements {
+		want := byte(0)
+		if m.elements[i].set {
+			want = ctrlTag(m.elements[i].hash)
+		}
+		if m.ctrl[i] != want || (i < groupSize && m.ctrl[int(m.size)+i] != want) {
+			t.Fatalf("Control byte of slot %d should be %#x. Got %#x", i, want, m.ctrl[i])
+		}
+	}
+}
+
+func TestGroupProbing(t *testing.T) {
+	for name, h := range map[string]Hasher{
+		"siphash":      SipHasher{},
+		"bucket clash": CollidingHasher(SipHasher{}, 7, CollideBucket),
+		"full collide": CollidingHasher(SipHasher{}, 13, CollideHash),
+	} {
+		m := must(New[string, int](WithGroupProbing(), WithHasher(h), WithSize(2)))
+		want := make(map[string]int)
+		for i := 0; i < 3000; i++ {
+			key := strconv.Itoa(i)
+			m.Set(key, i)
+			want[key] = i
+			if i%4 == 0 {
+				m.Delete(strconv.Itoa(i / 2))
+				delete(want, strconv.Itoa(i/2))
+			}
+		}
+		checkCtrl(t, m)
+
+		m.DeleteAll([]string{"1", "3", "5", "2999"})
+		m.DeleteFunc(func(k string, v int) bool { return v%10 == 0 })
+		for k, v := range want {
+			if v%10 == 0 || k == "1" || k == "3" || k == "5" || k == "2999" {
+				delete(want, k)
+			}
+		}
+		checkCtrl(t, m)
+
+		if m.Len() != uint64(len(want)) {
+			t.Errorf("%s: expected %d elements, Got %d", name, len(want), m.Len())
+		}
+		for k, v := range want {
+			if val, ok := m.Get(k); !ok || val != v {
+				t.Errorf("%s: key %s should map to %d. Got %d, %t", name, k, v, val, ok)
+			}
+		}
+		for i := 3000; i < 3100; i++ {
+			if _, ok := m.Get(strconv.Itoa(i)); ok {
+				t.Errorf("%s: key %d was never set.", name, i)
+			}
+		}
+	}
+}
+
+func TestGroupProbingCopies(t *testing.T) {
+	m := must(New[int, int](WithGroupProbing(), WithIncrementalRehash(2)))
+	for i := 0; i < 500; i++ {
+		m.Set(i, i)
+	}
+	clone := m.Clone()
+	for i := 0; i < 500; i++ {
+		m.Set(i, -i)
+		m.Delete(i + 250)
+	}
+	checkCtrl(t, m)
+	checkCtrl(t, clone)
+	for i := 0; i < 500; i++ {
+		if val, ok := clone.Get(i); !ok || val != i {
+			t.Errorf("The clone should keep key %d mapped to %d. Got %d, %t", i, i, val, ok)
+		}
+	}
+
+	data := must(m.MarshalBinary())
+	decoded := must(New[int, int](WithGroupProbing()))
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	checkCtrl(t, decoded)
+	if !decoded.Equal(m, func(a, b int) bool { return a == b }) {
+		t.Errorf("A decoded grouped map should equal the original.")
+	}
+}
+
+func BenchmarkGroupProbing(b *testing.B) {
+	keys := make([]string, 1<<20)
+	for i := range keys {
+		keys[i] = "key-" + strconv.Itoa(i)
+	}
+	for _, bench := range []struct {
+		name string
+		opts []Option
+	}{
+		{"elements", nil},
+		{"groups", []Option{WithGroupProbing()}},
+	} {
+		m := must(New[string, int](bench.opts...))
+		for i, key := range keys {
+			m.Set(key, i)
+		}
+		b.Run(bench.name+"/hit", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				m.Get(keys[(i*7919)&(len(keys)-1)])
+			}
+		})
+		b.Run(bench.name+"/miss", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				m.Get("absent")
+			}
+		})
+	}
+}
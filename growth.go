@@ -0,0 +1,58 @@
+package rhmap
+
+import "math"
+
+// Returns the number of slots a table of size slots holding n elements
+// grows to once it crosses its load factor
+type GrowthPolicy func(size, n uint64) uint64
+
+// Grows the table by factor instead of doubling it, such as 1.5 to trade
+// more frequent rehashes for less memory held in reserve. Factors of 1 or
+// less are ignored. Tables whose size isn't a power of two map hashes to
+// slots as under WithFastRange, which any growth factor turns on.
+func WithGrowthFactor(factor float64) Option {
+	return func(o *options) {
+		if factor > 1 {
+			o.growthFactor = factor
+		}
+	}
+}
+
+// Sizes each grow with policy, for sizing a factor can't express, such as
+// page-aligned or prime table sizes. A policy returning no more slots than
+// the table has doubles it instead. Like WithGrowthFactor, it turns on
+// WithFastRange. Sizes set explicitly, by WithSize or GrowTo, are still
+// rounded up to a power of two.
+func WithGrowthPolicy(policy GrowthPolicy) Option {
+	return func(o *options) {
+		o.growthPolicy = policy
+	}
+}
+
+// Sets the load at which the table grows. It is WithLoadFactor, named to
+// pair with WithMinLoadFactor.
+func WithMaxLoadFactor(lf float32) Option {
+	return WithLoadFactor(lf)
+}
+
+// Sets the load below which deletes shrink the table. It is WithShrink,
+// named to pair with WithMaxLoadFactor.
+func WithMinLoadFactor(lf float32) Option {
+	return WithShrink(lf)
+}
+
+// Returns the size the table grows to, by the growth policy or factor if
+// either is set, and doubling otherwise
+func (m *Map[K, V]) nextSize() uint64 {
+	var size uint64
+	switch {
+	case m.growthPolicy != nil:
+		size = m.growthPolicy(m.size, m.numElements)
+	case m.growthFactor > 1:
+		size = uint64(math.Ceil(float64(m.size) * m.growthFactor))
+	}
+	if size <= m.size {
+		size = m.size * 2
+	}
+	return size
+}
@@ -0,0 +1,68 @@
+package rhmap
+
+import "testing"
+
+func TestWithGrowthFactor(t *testing.T) {
+	for _, opts := range [][]Option{
+		{WithGrowthFactor(1.5)},
+		{WithGrowthFactor(1.5), WithGroupProbing()},
+		{WithGrowthFactor(1.5), WithIncrementalRehash(4)},
+		{WithGrowthFactor(1.5), WithMinLoadFactor(.1)},
+	} {
+		m := must(New[int, int](opts...))
+		var sizes []uint64
+		for i := 0; i < 5000; i++ {
+			m.Set(i, i)
+			if len(sizes) == 0 || sizes[len(sizes)-1] != m.size {
+				sizes = append(sizes, m.size)
+			}
+		}
+		if err := m.Validate(); err != nil {
+			t.Fatalf("Table should be valid after growing by 1.5. Got %v", err)
+		}
+		if cfg := m.Config(); cfg.GrowthFactor != 1.5 || !cfg.FastRange {
+			t.Errorf("Config should report growth by 1.5 with fast range. Got %+v", cfg)
+		}
+		for i := 1; i < len(sizes); i++ {
+			if want := (sizes[i-1]*3 + 1) / 2; sizes[i] != want {
+				t.Errorf("A table of %d slots should grow to %d. Got %d", sizes[i-1], want, sizes[i])
+			}
+		}
+
+		m.DeleteFunc(func(k, _ int) bool { return k%3 == 0 })
+		for i := 0; i < 1000; i++ {
+			m.Delete(i)
+		}
+		popped, _, _ := m.PopAny()
+		if err := m.Validate(); err != nil {
+			t.Fatalf("Table should be valid after deletes. Got %v", err)
+		}
+		for i := 1000; i < 5000; i++ {
+			if _, ok := m.Get(i); ok != (i%3 != 0) && i != popped {
+				t.Errorf("Expected key %d present: %t. Got %t", i, i%3 != 0, ok)
+			}
+		}
+	}
+}
+
+func TestWithGrowthPolicy(t *testing.T) {
+	// Grows by a fixed 100 slots at a time
+	m := must(New[int, int](WithGrowthPolicy(func(size, n uint64) uint64 { return size + 100 })))
+	for i := 0; i < 1000; i++ {
+		m.Set(i, i)
+	}
+	if (m.size-defaultSize)%100 != 0 {
+		t.Errorf("Table should grow 100 slots at a time from %d. Got %d", defaultSize, m.size)
+	}
+	if err := m.Validate(); err != nil {
+		t.Errorf("Table should be valid. Got %v", err)
+	}
+
+	shrinking := must(New[int, int](WithGrowthPolicy(func(size, n uint64) uint64 { return 0 })))
+	for i := 0; i < 100; i++ {
+		shrinking.Set(i, i)
+	}
+	if shrinking.size != 128 {
+		t.Errorf("A policy returning too few slots should double the table. Expected 128, Got %d", shrinking.size)
+	}
+}
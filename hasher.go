@@ -0,0 +1,123 @@
+package rhmap
+
+import (
+	"encoding/binary"
+	"hash/maphash"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/dchest/siphash"
+)
+
+// Hasher computes the 64-bit hash of an encoded key using the map's seeds.
+// It works on the encoded bytes rather than on K itself, so one implementation
+// serves maps of every key type.
+type Hasher interface {
+	Hash(k0, k1 uint64, p []byte) uint64
+}
+
+// HasherFunc adapts an ordinary function to the Hasher interface
+type HasherFunc func(k0, k1 uint64, p []byte) uint64
+
+func (f HasherFunc) Hash(k0, k1 uint64, p []byte) uint64 {
+	return f(k0, k1, p)
+}
+
+// SipHasher hashes with SipHash-2-4 keyed by both seeds. It is the default,
+// and the only built-in hasher that resists hash flooding by clients who
+// choose the keys.
+type SipHasher struct{}
+
+func (SipHasher) Hash(k0, k1 uint64, p []byte) uint64 {
+	return siphash.Hash(k0, k1, p)
+}
+
+// XXHasher hashes with xxHash64 seeded by k0. It is considerably faster than
+// SipHash but offers no protection against crafted colliding keys.
+type XXHasher struct{}
+
+func (XXHasher) Hash(k0, k1 uint64, p []byte) uint64 {
+	var d xxhash.Digest
+	d.ResetWithSeed(k0)
+	d.Write(p)
+	return d.Sum64()
+}
+
+// FNV1aHasher hashes with 64-bit FNV-1a, with k0 folded into the offset
+// basis. It is fastest on short keys but distributes poorly on some inputs
+// and offers no protection against crafted colliding keys.
+type FNV1aHasher struct{}
+
+func (FNV1aHasher) Hash(k0, k1 uint64, p []byte) uint64 {
+	const prime = 1099511628211
+	h := uint64(14695981039346656037) ^ k0
+	for _, b := range p {
+		h ^= uint64(b)
+		h *= prime
+	}
+	return h
+}
+
+// IntHasher hashes 8-byte keys, such as int64 and uint64 keys, as one word
+// with the SplitMix64 finalizer keyed by k0, and longer or shorter keys as
+// XXHasher does. It is the fastest hasher for integer keys but offers no
+// protection against crafted colliding keys. See IntMap.
+type IntHasher struct{}
+
+func (IntHasher) Hash(k0, k1 uint64, p []byte) uint64 {
+	if len(p) == 8 {
+		return mixInt(k0, binary.NativeEndian.Uint64(p))
+	}
+	return XXHasher{}.Hash(k0, k1, p)
+}
+
+// Mixes an integer key with a seed so that every bit of the key affects the
+// low bits that pick its slot
+func mixInt(k0, x uint64) uint64 {
+	x ^= k0
+	x = (x ^ x>>30) * 0xbf58476d1ce4e5b9
+	x = (x ^ x>>27) * 0x94d049bb133111eb
+	return x ^ x>>31
+}
+
+// MapHasher hashes with the runtime's hash/maphash, which is fast and
+// randomized per hasher. Its seed can't be derived from k0 and k1, so maps
+// using it don't honor WithSeedsFrom or WithDeterministic and their layout
+// can't be reproduced in another process.
+type MapHasher struct {
+	seed maphash.Seed
+}
+
+func NewMapHasher() *MapHasher {
+	return &MapHasher{seed: maphash.MakeSeed()}
+}
+
+func (h *MapHasher) Hash(k0, k1 uint64, p []byte) uint64 {
+	return maphash.Bytes(h.seed, p)
+}
+
+// Kinds of collision a CollidingHasher forces
+type CollisionMode int
+
+const (
+	// Colliding keys all get the same full 64-bit hash
+	CollideHash CollisionMode = iota
+	// Colliding keys keep distinct hashes whose low 32 bits are zero, so
+	// they share slot 0 of any power-of-two table with up to 2^32 slots
+	CollideBucket
+)
+
+// Wraps base for tests so that roughly one key in every, chosen by its base
+// hash, collides with all the other chosen keys, letting callers exercise
+// worst-case clustering. An every of 1 makes every key collide.
+func CollidingHasher(base Hasher, every uint64, mode CollisionMode) Hasher {
+	return HasherFunc(func(k0, k1 uint64, p []byte) uint64 {
+		hash := base.Hash(k0, k1, p)
+		if every > 1 && hash%every != 0 {
+			return hash
+		}
+		if mode == CollideBucket {
+			return hash &^ (1<<32 - 1)
+		}
+		return 0
+	})
+}
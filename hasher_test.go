@@ -0,0 +1,91 @@
+package rhmap
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+func TestCollidingHasher(t *testing.T) {
+	for _, mode := range []CollisionMode{CollideHash, CollideBucket} {
+		m := must(New[int, int]())
+		m.SetHasher(CollidingHasher(SipHasher{}, 1, mode))
+
+		for i := 0; i < 200; i++ {
+			m.Set(i, i)
+		}
+
+		for i := 0; i < 200; i++ {
+			if m.getIndexOfKeyAtPsl(i, 0) != 0 {
+				t.Fatalf("Mode %d: key %d should hash to slot 0.", mode, i)
+			}
+			if val, ok := m.Get(i); !ok || val != i {
+				t.Errorf("Mode %d: key %d should map to %d under collisions. Got %d, %t", mode, i, i, val, ok)
+			}
+		}
+		if m.maxPsl != 199 {
+			t.Errorf("Mode %d: 200 fully colliding keys should form one cluster with max PSL 199. Got %d", mode, m.maxPsl)
+		}
+	}
+}
+
+func TestCollidingHasherFraction(t *testing.T) {
+	h := CollidingHasher(SipHasher{}, 4, CollideHash)
+
+	colliding := 0
+	for i := 0; i < 1000; i++ {
+		if h.Hash(1, 2, []byte{byte(i), byte(i >> 8)}) == 0 {
+			colliding++
+		}
+	}
+	if colliding < 150 || colliding > 350 {
+		t.Errorf("About a quarter of keys should collide. Got %d of 1000", colliding)
+	}
+}
+
+func builtinHashers() map[string]Hasher {
+	return map[string]Hasher{
+		"SipHasher":   SipHasher{},
+		"XXHasher":    XXHasher{},
+		"FNV1aHasher": FNV1aHasher{},
+		"IntHasher":   IntHasher{},
+		"MapHasher":   NewMapHasher(),
+	}
+}
+
+func TestBuiltinHashers(t *testing.T) {
+	for name, h := range builtinHashers() {
+		m := must(New[string, int](WithHasher(h)))
+
+		for i := 0; i < 1000; i++ {
+			m.Set(strconv.Itoa(i), i)
+		}
+		for i := 0; i < 1000; i++ {
+			if val, ok := m.Get(strconv.Itoa(i)); !ok || val != i {
+				t.Errorf("%s: key %d should map to %d. Got %d, %t", name, i, i, val, ok)
+			}
+		}
+
+		p := []byte("same input")
+		if h.Hash(1, 2, p) != h.Hash(1, 2, p) {
+			t.Errorf("%s should hash equal inputs equally.", name)
+		}
+		if name != "MapHasher" && h.Hash(1, 2, p) == h.Hash(3, 2, p) {
+			t.Errorf("%s should depend on the k0 seed.", name)
+		}
+	}
+}
+
+func BenchmarkHashers(b *testing.B) {
+	for _, size := range []int{8, 64, 1024} {
+		p := make([]byte, size)
+		for name, h := range builtinHashers() {
+			b.Run(fmt.Sprintf("%s/%d", name, size), func(b *testing.B) {
+				b.SetBytes(int64(size))
+				for i := 0; i < b.N; i++ {
+					h.Hash(1, 2, p)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package rhmap
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Makes the map count the hits and misses of its Get calls with exponential
+// decay, so that a hit or miss halfLife ago weighs half as much as one now,
+// reported by Stats.RecentHits, RecentMisses and HitRatio. Unlike lifetime
+// counts, the ratio follows how well a cache is doing now, dropping within
+// a few half-lives of a change in traffic. Time is read from the clock of
+// WithClock. Counts are kept under a lock of their own, so concurrent
+// readers stay safe but contend on every Get.
+func WithHitRatio(halfLife time.Duration) Option {
+	return func(o *options) {
+		o.hitHalfLife = halfLife
+	}
+}
+
+// Hit and miss counts decayed to the time of the last one recorded
+type hitRatio struct {
+	mu       sync.Mutex
+	clock    Clock
+	halfLife float64
+	hits     float64
+	misses   float64
+	last     int64
+}
+
+func newHitRatio(halfLife time.Duration, clock Clock) *hitRatio {
+	if clock == nil {
+		clock = realClock{}
+	}
+	return &hitRatio{clock: clock, halfLife: float64(halfLife), last: clock.Now().UnixNano()}
+}
+
+// Decays the counts from the last update to now. The caller holds mu.
+func (r *hitRatio) decay(now int64) {
+	if now <= r.last {
+		return
+	}
+	f := math.Exp2(-float64(now-r.last) / r.halfLife)
+	r.hits *= f
+	r.misses *= f
+	r.last = now
+}
+
+func (r *hitRatio) record(hit bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.decay(r.clock.Now().UnixNano())
+	if hit {
+		r.hits++
+	} else {
+		r.misses++
+	}
+}
+
+// Returns the counts decayed to now
+func (r *hitRatio) counts() (hits, misses float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.decay(r.clock.Now().UnixNano())
+	return r.hits, r.misses
+}
+
+func (r *hitRatio) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hits, r.misses = 0, 0
+}
+
+func (r *hitRatio) clone() *hitRatio {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &hitRatio{clock: r.clock, halfLife: r.halfLife, hits: r.hits, misses: r.misses, last: r.last}
+}
+
+// Returns the share of hits among hits and misses, or 0 if there were none
+func ratioOf(hits, misses float64) float64 {
+	if hits+misses == 0 {
+		return 0
+	}
+	return hits / (hits + misses)
+}
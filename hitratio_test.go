@@ -0,0 +1,59 @@
+package rhmap
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestWithHitRatio(t *testing.T) {
+	clock := newFakeClock()
+	m := must(New[int, int](WithHitRatio(time.Minute), WithClock(clock)))
+	m.Set(1, 1)
+	for i := 0; i < 90; i++ {
+		m.Get(1)
+	}
+	for i := 0; i < 10; i++ {
+		m.Get(2)
+	}
+	if s := m.Stats(); s.RecentHits != 90 || s.RecentMisses != 10 || s.HitRatio != 0.9 {
+		t.Errorf("Expected 90 hits, 10 misses and a ratio of 0.9, Got %v, %v and %v", s.RecentHits, s.RecentMisses, s.HitRatio)
+	}
+
+	// A half-life later, the cache starts missing everything
+	clock.Advance(time.Minute)
+	for i := 0; i < 45; i++ {
+		m.Get(2)
+	}
+	s := m.Stats()
+	if math.Abs(s.RecentHits-45) > 1e-9 || math.Abs(s.RecentMisses-50) > 1e-9 {
+		t.Errorf("Expected 45 hits and 50 misses, Got %v and %v", s.RecentHits, s.RecentMisses)
+	}
+	if s.HitRatio >= 0.5 {
+		t.Errorf("The ratio should follow recent misses. Got %v", s.HitRatio)
+	}
+
+	c := m.Clone()
+	c.Get(1)
+	m.Reset()
+	if s := m.Stats(); s.RecentHits != 0 || s.HitRatio != 0 {
+		t.Errorf("Reset should start the counts over. Got %v hits", s.RecentHits)
+	}
+	if s := c.Stats(); s.RecentHits < 45 {
+		t.Errorf("A clone should keep counting on its own. Got %v hits", s.RecentHits)
+	}
+}
+
+func TestConcurrentMapHitRatio(t *testing.T) {
+	clock := newFakeClock()
+	c := must(NewConcurrent[int, int](4, WithHitRatio(time.Minute), WithClock(clock)))
+	for k := 0; k < 10; k++ {
+		c.Set(k, k)
+	}
+	for k := 0; k < 20; k++ {
+		c.Get(k)
+	}
+	if s := concurrentStats(c.shards); s.RecentHits != 10 || s.RecentMisses != 10 || s.HitRatio != 0.5 {
+		t.Errorf("Expected counts summed across shards, Got %v hits, %v misses and a ratio of %v", s.RecentHits, s.RecentMisses, s.HitRatio)
+	}
+}
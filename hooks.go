@@ -0,0 +1,56 @@
+package rhmap
+
+import "time"
+
+// Callbacks for a map's resizes and rehashes, so that applications can log,
+// trace or time them when chasing latency spikes. Nil fields are skipped.
+// Hooks run on the goroutine whose write triggered the event, in the middle
+// of that write, so they must not use the map.
+type Hooks struct {
+	// Called when the table grows, with its old and new sizes in slots
+	OnGrow func(oldSize, newSize uint64)
+	// Called when the table shrinks, with its old and new sizes in slots
+	OnShrink func(oldSize, newSize uint64)
+	// Called before elements start moving into a new table, which may be
+	// the same size when the map rehashes under new seeds or compacts.
+	// Under WithIncrementalRehash they move over later writes, so a trace
+	// region begun here may end on another goroutine.
+	OnRehashStart func(oldSize, newSize uint64)
+	// Called once every element has moved, or Clear has left none to move,
+	// with the time since the rehash started
+	OnRehashDone func(oldSize, newSize uint64, elapsed time.Duration)
+}
+
+// Calls the hooks on the map's resizes and rehashes. Wrappers pass them to
+// each of their tables, and clones share them.
+func WithHooks(h Hooks) Option {
+	return func(o *options) {
+		o.hooks = &h
+	}
+}
+
+// Reports a rehash from a table of oldSize slots into the current table to
+// the hooks, along with the resize it makes if any, and starts timing it
+func (m *Map[K, V]) rehashStarted(oldSize uint64) {
+	h := m.hooks
+	if h == nil {
+		return
+	}
+	switch {
+	case m.size > oldSize && h.OnGrow != nil:
+		h.OnGrow(oldSize, m.size)
+	case m.size < oldSize && h.OnShrink != nil:
+		h.OnShrink(oldSize, m.size)
+	}
+	if h.OnRehashStart != nil {
+		h.OnRehashStart(oldSize, m.size)
+	}
+	m.rehashFrom, m.rehashStart = oldSize, time.Now()
+}
+
+// Reports the end of the rehash rehashStarted reported to the hooks
+func (m *Map[K, V]) rehashDone() {
+	if h := m.hooks; h != nil && h.OnRehashDone != nil {
+		h.OnRehashDone(m.rehashFrom, m.size, time.Since(m.rehashStart))
+	}
+}
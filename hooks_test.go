@@ -0,0 +1,77 @@
+package rhmap
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+// Records every hook call as a line of text
+func recordingHooks(events *[]string) Hooks {
+	return Hooks{
+		OnGrow:   func(from, to uint64) { *events = append(*events, fmt.Sprintf("grow %d %d", from, to)) },
+		OnShrink: func(from, to uint64) { *events = append(*events, fmt.Sprintf("shrink %d %d", from, to)) },
+		OnRehashStart: func(from, to uint64) {
+			*events = append(*events, fmt.Sprintf("start %d %d", from, to))
+		},
+		OnRehashDone: func(from, to uint64, elapsed time.Duration) {
+			if elapsed < 0 {
+				panic("negative rehash duration")
+			}
+			*events = append(*events, fmt.Sprintf("done %d %d", from, to))
+		},
+	}
+}
+
+func TestHooks(t *testing.T) {
+	var events []string
+	m := must(New[int, int](WithHooks(recordingHooks(&events)), WithShrink(.2)))
+	for i := 0; i < 9; i++ {
+		m.Set(i, i)
+	}
+	want := []string{"grow 8 16", "start 8 16", "done 8 16"}
+	if !slices.Equal(events, want) {
+		t.Errorf("Expected %v, Got %v", want, events)
+	}
+
+	events = nil
+	m.GrowTo(64)
+	for i := 0; i < 9; i++ {
+		m.Delete(i)
+	}
+	m.SetHasher(XXHasher{})
+	want = []string{"grow 16 64", "start 16 64", "done 16 64", "shrink 64 32", "start 64 32", "done 64 32",
+		"shrink 32 16", "start 32 16", "done 32 16", "shrink 16 8", "start 16 8", "done 16 8", "start 8 8", "done 8 8"}
+	if !slices.Equal(events, want) {
+		t.Errorf("Expected %v, Got %v", want, events)
+	}
+}
+
+func TestHooksIncremental(t *testing.T) {
+	var events []string
+	m := must(New[int, int](WithHooks(recordingHooks(&events)), WithIncrementalRehash(2)))
+	for i := 0; i < 9; i++ {
+		m.Set(i, i)
+	}
+	want := []string{"grow 8 16", "start 8 16"}
+	if !slices.Equal(events, want) {
+		t.Errorf("A rehash should start as the table grows. Expected %v, Got %v", want, events)
+	}
+	for i := 9; i < 13; i++ {
+		m.Set(i, i)
+	}
+	want = append(want, "done 8 16")
+	if !slices.Equal(events, want) {
+		t.Errorf("A rehash should end once the old table drains. Expected %v, Got %v", want, events)
+	}
+
+	for i := 13; i < 16; i++ {
+		m.Set(i, i)
+	}
+	m.Clear()
+	want = append(want, "grow 16 32", "start 16 32", "done 16 32")
+	if !slices.Equal(events, want) {
+		t.Errorf("Clear should end a rehash in progress. Expected %v, Got %v", want, events)
+	}
+}
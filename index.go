@@ -0,0 +1,43 @@
+package rhmap
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Returned, wrapped, by IndexByUnique when two items share a key
+var ErrDuplicateKey = errors.New("rhmap: duplicate key")
+
+// Builds a map from the key of each item to the item, reserving room for
+// every item up front. Later items replace earlier ones with the same key.
+// It returns an error if K can't be encoded, as New does.
+func IndexBy[T any, K comparable](items []T, keyFn func(T) K, opts ...Option) (*Map[K, T], error) {
+	m, err := New[K, T](opts...)
+	if err != nil {
+		return nil, err
+	}
+	m.growFor(uint64(len(items)))
+	for _, item := range items {
+		m.Set(keyFn(item), item)
+	}
+	return m, nil
+}
+
+// Like IndexBy, but fails with an error wrapping ErrDuplicateKey if two
+// items share a key
+func IndexByUnique[T any, K comparable](items []T, keyFn func(T) K, opts ...Option) (*Map[K, T], error) {
+	m, err := New[K, T](opts...)
+	if err != nil {
+		return nil, err
+	}
+	m.growFor(uint64(len(items)))
+	for i, item := range items {
+		key := keyFn(item)
+		hash := m.hashKey(key)
+		if _, ok, _ := m.getWithHash(key, hash); ok {
+			return nil, fmt.Errorf("%w %v at item %d", ErrDuplicateKey, key, i)
+		}
+		m.setWithHash(key, item, hash)
+	}
+	return m, nil
+}
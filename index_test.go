@@ -0,0 +1,46 @@
+package rhmap
+
+import (
+	"errors"
+	"testing"
+)
+
+type user struct {
+	ID   int
+	Name string
+}
+
+func TestIndexBy(t *testing.T) {
+	users := []user{{1, "ann"}, {2, "bob"}, {1, "amy"}}
+
+	m := must(IndexBy(users, func(u user) int { return u.ID }))
+	if m.Len() != 2 {
+		t.Errorf("Index should contain 2 keys. Found %d", m.Len())
+	}
+	if u, ok := m.Get(1); !ok || u.Name != "amy" {
+		t.Errorf("Later items should replace earlier ones. Got %+v, %t", u, ok)
+	}
+
+	if _, err := IndexByUnique(users, func(u user) int { return u.ID }); !errors.Is(err, ErrDuplicateKey) {
+		t.Errorf("Duplicate IDs should fail with ErrDuplicateKey. Got %v", err)
+	}
+	byName := must(IndexByUnique(users, func(u user) string { return u.Name }))
+	if u, ok := byName.Get("bob"); !ok || u.ID != 2 {
+		t.Errorf("bob should map to ID 2. Got %+v, %t", u, ok)
+	}
+}
+
+func TestIndexBySingleReservation(t *testing.T) {
+	items := make([]int, 1000)
+	for i := range items {
+		items[i] = i
+	}
+
+	m := must(IndexBy(items, func(i int) int { return i }))
+	if float32(float64(m.Len())/float64(m.size)) >= m.loadFactor {
+		t.Errorf("Index should be sized for every item. Got %d items in %d slots", m.Len(), m.size)
+	}
+	if m.size != 2048 {
+		t.Errorf("1000 items should need one reservation of 2048 slots. Got %d", m.size)
+	}
+}
@@ -0,0 +1,53 @@
+package rhmap
+
+import "strings"
+
+// Set of canonical strings, so that equal strings parsed from input share
+// one copy of their bytes. Interning a string already in the set doesn't
+// allocate, whether it comes as a string or as bytes. An Interner is not
+// safe for concurrent use.
+type Interner struct {
+	strings *BytesMap[string]
+}
+
+// Creates an empty interner. Options configure the underlying map, as for
+// NewBytesMap; WithBorrowedKeys has no effect.
+func NewInterner(opts ...Option) (*Interner, error) {
+	strs, err := NewBytesMap[string](opts...)
+	if err != nil {
+		return nil, err
+	}
+	strs.borrow = false
+	return &Interner{strings: strs}, nil
+}
+
+// Returns the canonical string equal to s, adding a copy of s if there is
+// none, so that an s sliced from a larger string doesn't keep it alive
+func (in *Interner) Intern(s string) string {
+	t := in.strings.table
+	hash := t.hashKey(s)
+	if canonical, ok, _ := t.getWithHash(s, hash); ok {
+		return canonical
+	}
+	s = strings.Clone(s)
+	t.insertAbsent(s, s, hash)
+	return s
+}
+
+// Returns the canonical string equal to b, adding a copy of b if there is
+// none
+func (in *Interner) InternBytes(b []byte) string {
+	t := in.strings.table
+	hash := in.strings.hash(b)
+	if canonical, ok, _ := t.getWithHash(view(b), hash); ok {
+		return canonical
+	}
+	s := string(b)
+	t.insertAbsent(s, s, hash)
+	return s
+}
+
+// Returns the number of canonical strings
+func (in *Interner) Len() uint64 {
+	return in.strings.Len()
+}
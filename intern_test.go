@@ -0,0 +1,61 @@
+package rhmap
+
+import (
+	"strconv"
+	"testing"
+	"unsafe"
+)
+
+func TestInterner(t *testing.T) {
+	in := must(NewInterner())
+	buf := []byte("field")
+	a := in.InternBytes(buf)
+	copy(buf, "xxxxx")
+	if a != "field" {
+		t.Errorf("Interned bytes should be copied. Got %q", a)
+	}
+	b := in.Intern("field")
+	if unsafe.StringData(a) != unsafe.StringData(b) {
+		t.Errorf("Equal strings should intern to the same bytes.")
+	}
+
+	line := "key=value"
+	k := in.Intern(line[:3])
+	if unsafe.StringData(k) == unsafe.StringData(line) {
+		t.Errorf("A new string should be copied rather than pin the string it was sliced from.")
+	}
+	if c := in.InternBytes([]byte("key")); unsafe.StringData(c) != unsafe.StringData(k) {
+		t.Errorf("Bytes should intern to the string interned before.")
+	}
+
+	for i := 0; i < 1000; i++ {
+		in.InternBytes(strconv.AppendInt(nil, int64(i), 10))
+		in.Intern(strconv.Itoa(i))
+	}
+	if in.Len() != 1002 {
+		t.Errorf("Interner should hold 1002 strings. Found %d", in.Len())
+	}
+
+	hit, s := []byte("500"), "field"
+	allocs := testing.AllocsPerRun(100, func() {
+		in.InternBytes(hit)
+		in.Intern(s)
+	})
+	if allocs != 0 {
+		t.Errorf("Interning a known string should not allocate. Got %f allocations", allocs)
+	}
+}
+
+func BenchmarkInternBytes(b *testing.B) {
+	in := must(NewInterner())
+	keys := make([][]byte, 1024)
+	for i := range keys {
+		keys[i] = strconv.AppendInt(nil, int64(i), 10)
+		in.InternBytes(keys[i])
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		in.InternBytes(keys[i&1023])
+	}
+}
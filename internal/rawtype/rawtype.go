@@ -0,0 +1,43 @@
+// Package rawtype reports whether a type's values can be handled as raw
+// bytes, as rhmap's column export and arena and filemap's file layout need.
+package rawtype
+
+import "reflect"
+
+// Reports whether values of type t hold pointers or references
+func HoldsPointers(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Array:
+		return HoldsPointers(t.Elem())
+	case reflect.Struct:
+		for i := range t.NumField() {
+			if HoldsPointers(t.Field(i).Type) {
+				return true
+			}
+		}
+		return false
+	case reflect.Pointer, reflect.UnsafePointer, reflect.String, reflect.Slice, reflect.Map,
+		reflect.Interface, reflect.Chan, reflect.Func:
+		return true
+	}
+	return false
+}
+
+// Reports whether values of type t have bytes that belong to no field
+func Padded(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Array:
+		return Padded(t.Elem())
+	case reflect.Struct:
+		var sum uintptr
+		for i := range t.NumField() {
+			f := t.Field(i)
+			if Padded(f.Type) {
+				return true
+			}
+			sum += f.Type.Size()
+		}
+		return sum != t.Size()
+	}
+	return false
+}
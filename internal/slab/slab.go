@@ -0,0 +1,115 @@
+// Package slab provides the entry slab behind rhmap's LRU, TenantMap and
+// GenerationMap: values stored by uint32 index, a free list of released
+// slots, and doubly linked lists threaded through the slots by index rather
+// than by pointer, so that the lists allocate nothing per entry. Each list
+// runs through a sentinel slot of its own, whose next is the list's front
+// and whose prev is its back.
+package slab
+
+import (
+	"slices"
+	"unsafe"
+)
+
+type link struct {
+	prev, next uint32
+}
+
+// Slab of T values. The zero Slab is empty and ready to use.
+type Slab[T any] struct {
+	items []T
+	links []link
+	free  []uint32
+}
+
+// Reserves room for n more slots
+func (s *Slab[T]) Grow(n int) {
+	s.items = slices.Grow(s.items, n)
+	s.links = slices.Grow(s.links, n)
+}
+
+// Returns a free slot, reusing released ones first
+func (s *Slab[T]) Alloc() uint32 {
+	if n := len(s.free); n > 0 {
+		i := s.free[n-1]
+		s.free = s.free[:n-1]
+		return i
+	}
+	var zero T
+	s.items = append(s.items, zero)
+	s.links = append(s.links, link{})
+	return uint32(len(s.items) - 1)
+}
+
+// Clears slot i, so it doesn't keep its value reachable, and releases it
+func (s *Slab[T]) Free(i uint32) {
+	var zero T
+	s.items[i] = zero
+	s.links[i] = link{}
+	s.free = append(s.free, i)
+}
+
+// Returns the value in slot i, which stays valid until the slab grows
+func (s *Slab[T]) At(i uint32) *T {
+	return &s.items[i]
+}
+
+// Returns the number of slots, free or not
+func (s *Slab[T]) Len() int {
+	return len(s.items)
+}
+
+// Allocates the sentinel of a new empty list and returns its slot
+func (s *Slab[T]) NewList() uint32 {
+	list := s.Alloc()
+	s.links[list] = link{list, list}
+	return list
+}
+
+// Links slot i in at the front of list
+func (s *Slab[T]) PushFront(list, i uint32) {
+	head := s.links[list].next
+	s.links[i] = link{list, head}
+	s.links[head].prev = i
+	s.links[list].next = i
+}
+
+// Unlinks slot i from its list
+func (s *Slab[T]) Unlink(i uint32) {
+	l := s.links[i]
+	s.links[l.prev].next = l.next
+	s.links[l.next].prev = l.prev
+}
+
+// Returns the slot after i in its list; the front if i is the sentinel
+func (s *Slab[T]) Next(i uint32) uint32 {
+	return s.links[i].next
+}
+
+// Returns the slot before i in its list; the back if i is the sentinel
+func (s *Slab[T]) Prev(i uint32) uint32 {
+	return s.links[i].prev
+}
+
+// Returns a copy of the slab that shares no memory with it
+func (s *Slab[T]) Clone() Slab[T] {
+	return Slab[T]{
+		items: slices.Clone(s.items),
+		links: slices.Clone(s.links),
+		free:  slices.Clone(s.free),
+	}
+}
+
+// Returns the bytes the slab has allocated
+func (s *Slab[T]) Footprint() uint64 {
+	var zero T
+	return uint64(cap(s.items))*uint64(unsafe.Sizeof(zero)) +
+		uint64(cap(s.links))*uint64(unsafe.Sizeof(link{})) +
+		uint64(cap(s.free))*uint64(unsafe.Sizeof(uint32(0)))
+}
+
+// Returns the bytes one slot takes
+func SlotSize[T any]() uint64 {
+	var zero T
+	return uint64(unsafe.Sizeof(zero)) + uint64(unsafe.Sizeof(link{}))
+}
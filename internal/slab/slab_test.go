@@ -0,0 +1,66 @@
+package slab
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSlabLists(t *testing.T) {
+	var s Slab[int]
+	a, b := s.NewList(), s.NewList()
+	for v := range 6 {
+		i := s.Alloc()
+		*s.At(i) = v
+		if v%2 == 0 {
+			s.PushFront(a, i)
+		} else {
+			s.PushFront(b, i)
+		}
+	}
+
+	values := func(list uint32) []int {
+		var out []int
+		for i := s.Next(list); i != list; i = s.Next(i) {
+			out = append(out, *s.At(i))
+		}
+		return out
+	}
+	if got := values(a); !slices.Equal(got, []int{4, 2, 0}) {
+		t.Errorf("Expected list a to be [4 2 0]. Got %v", got)
+	}
+	if got := values(b); !slices.Equal(got, []int{5, 3, 1}) {
+		t.Errorf("Expected list b to be [5 3 1]. Got %v", got)
+	}
+	if back := *s.At(s.Prev(a)); back != 0 {
+		t.Errorf("Expected the back of list a to be 0. Got %d", back)
+	}
+
+	front := s.Next(a)
+	s.Unlink(front)
+	s.Free(front)
+	if got := values(a); !slices.Equal(got, []int{2, 0}) {
+		t.Errorf("Expected list a to be [2 0] after unlinking its front. Got %v", got)
+	}
+	if *s.At(front) != 0 {
+		t.Errorf("Freed slots should be cleared. Got %d", *s.At(front))
+	}
+	n := s.Len()
+	if i := s.Alloc(); i != front || s.Len() != n {
+		t.Errorf("Freed slots should be reused. Got slot %d of %d, expected %d of %d", i, s.Len(), front, n)
+	}
+}
+
+func TestSlabClone(t *testing.T) {
+	var s Slab[int]
+	list := s.NewList()
+	i := s.Alloc()
+	*s.At(i) = 1
+	s.PushFront(list, i)
+
+	d := s.Clone()
+	*d.At(i) = 2
+	d.Unlink(i)
+	if *s.At(i) != 1 || s.Next(list) != i {
+		t.Errorf("Changing a clone should leave the original alone")
+	}
+}
@@ -0,0 +1,53 @@
+package rhmap
+
+import "iter"
+
+// Robin hood hashmap keyed by int64, the most common key type, hashed as a
+// single word by IntHasher's mixer without going through the Hasher
+// interface or a key encoding, so no operation allocates except for the
+// rehashes that grow the table. Like IntHasher, it offers no protection
+// against crafted colliding keys; use a Map[int64, V] for keys chosen by
+// untrusted clients. See BenchmarkIntMap for a comparison with Map and with
+// map[int64]V.
+type IntMap[V any] struct {
+	table *Map[int64, V]
+}
+
+// Creates an integer-keyed map configured by opts. WithHasher has no effect.
+func NewIntMap[V any](opts ...Option) (*IntMap[V], error) {
+	table, err := New[int64, V](append(opts, WithHasher(IntHasher{}))...)
+	if err != nil {
+		return nil, err
+	}
+	return &IntMap[V]{table: table}, nil
+}
+
+func (m *IntMap[V]) Set(key int64, value V) {
+	m.table.setWithHash(key, value, m.hash(key))
+}
+
+func (m *IntMap[V]) Get(key int64) (V, bool) {
+	val, ok, _ := m.table.getWithHash(key, m.hash(key))
+	return val, ok
+}
+
+func (m *IntMap[V]) Delete(key int64) {
+	if m.table.numElements > 0 && m.table.removeWithHash(key, m.hash(key)) {
+		m.table.maybeShrink()
+	}
+}
+
+func (m *IntMap[V]) Len() uint64 {
+	return m.table.Len()
+}
+
+// Returns an iterator over every key/value pair in the map, in table order.
+// The map must not be modified while the iteration is in progress.
+func (m *IntMap[V]) All() iter.Seq2[int64, V] {
+	return m.table.All()
+}
+
+// Hashes key exactly as IntHasher hashes its encoding
+func (m *IntMap[V]) hash(key int64) uint64 {
+	return mixInt(m.table.k0, uint64(key))
+}
@@ -0,0 +1,73 @@
+package rhmap
+
+import "testing"
+
+func TestIntMap(t *testing.T) {
+	m := must(NewIntMap[string](WithShrink(.1)))
+	for i := int64(-500); i < 500; i++ {
+		m.Set(i, "v")
+	}
+	for i := int64(-500); i < 500; i += 2 {
+		m.Delete(i)
+	}
+	if m.Len() != 500 {
+		t.Errorf("Map should contain 500 elements. Found %d", m.Len())
+	}
+	for i := int64(-500); i < 500; i++ {
+		if _, ok := m.Get(i); ok != (i%2 != 0) {
+			t.Errorf("Key %d should be present: %t. Got %t", i, i%2 != 0, ok)
+		}
+	}
+	if err := m.table.Validate(); err != nil {
+		t.Errorf("Table should be valid. Got %v", err)
+	}
+
+	// The map's own lookups hash through IntHasher, which must agree
+	for i := int64(-499); i < 500; i += 2 {
+		if _, ok := m.table.Get(i); !ok {
+			t.Fatalf("IntHasher should hash key %d as IntMap does.", i)
+		}
+	}
+
+	if allocs := testing.AllocsPerRun(100, func() {
+		m.Set(1, "w")
+		m.Get(1)
+		m.Delete(2)
+	}); allocs != 0 {
+		t.Errorf("Operations on present keys should not allocate. Got %f allocations", allocs)
+	}
+}
+
+func BenchmarkIntMap(b *testing.B) {
+	const n = 1024
+	b.Run("IntMap", func(b *testing.B) {
+		m := must(NewIntMap[int64]())
+		for i := int64(0); i < n; i++ {
+			m.Set(i, i)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			m.Get(int64(i % n))
+		}
+	})
+	b.Run("Map", func(b *testing.B) {
+		m := must(New[int64, int64]())
+		for i := int64(0); i < n; i++ {
+			m.Set(i, i)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			m.Get(int64(i % n))
+		}
+	})
+	b.Run("builtin", func(b *testing.B) {
+		m := make(map[int64]int64)
+		for i := int64(0); i < n; i++ {
+			m[i] = i
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_ = m[int64(i%n)]
+		}
+	})
+}
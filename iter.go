@@ -0,0 +1,220 @@
+package rhmap
+
+import (
+	"iter"
+	"slices"
+	"sync/atomic"
+)
+
+// Returns an iterator over every key/value pair in the map, in table order.
+// During an incremental rehash, the current table is followed by what
+// remains of the old one, so every element is still yielded exactly once.
+//
+// The loop body may delete the element just yielded, or any element not yet
+// yielded, and may set the value of an existing key; no element is then
+// skipped or yielded twice. While an iteration is in progress deletes
+// neither shrink the table nor advance an incremental rehash, so that no
+// element moves under the iterator. Inserting other keys leaves unspecified
+// which elements are yielded, and resizing or rebuilding the table
+// is misuse that ends the iteration. Use SnapshotIter to mutate freely.
+func (m *Map[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		atomic.AddInt32(&m.iterators, 1)
+		defer atomic.AddInt32(&m.iterators, -1)
+
+		layout := m.layout
+		for t := range m.tables() {
+			if !m.iterateTable(t, layout, yield) {
+				return
+			}
+		}
+	}
+}
+
+// Yields the elements of m.tables()[t], reporting false if yield stopped
+// early. The scan starts from an empty slot, so that backward-shift deletes
+// only ever move an element into the slot being visited, never back past
+// it. The table is refetched after every yield, as a delete may copy it.
+func (m *Map[K, V]) iterateTable(t int, layout uint64, yield func(K, V) bool) bool {
+	size := uint64(len(m.tables()[t]))
+	// Only a table of one slot can be full
+	start := uint64(max(slices.IndexFunc(m.tables()[t], func(e element[K, V]) bool { return !e.set }), 0))
+	for p := start; p < start+size; {
+		i := p % size
+		elem := &m.tables()[t][i]
+		if !elem.set {
+			p++
+			continue
+		}
+		key, hash := elem.key, elem.hash
+		if !yield(key, elem.value) {
+			return false
+		}
+		if m.layout != layout {
+			m.misuse(ErrModifiedDuringIteration)
+			return false
+		}
+
+		// If the yielded element was deleted, the slot may now hold the
+		// next element of its cluster, which must be visited in turn. Keys
+		// holding NaN never equal themselves and are recognized by hash.
+		if elem := &m.tables()[t][i]; !elem.set || (elem.hash == hash && (elem.key == key || key != key)) {
+			p++
+		}
+	}
+	return true
+}
+
+// Returns an iterator over every key in the map, in the same order as All
+func (m *Map[K, V]) Keys() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		for k := range m.All() {
+			if !yield(k) {
+				return
+			}
+		}
+	}
+}
+
+// Returns an iterator over every value in the map, in the same order as All
+func (m *Map[K, V]) Values() iter.Seq[V] {
+	return func(yield func(V) bool) {
+		for _, v := range m.All() {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// Returns an iterator over every element as All does, with values copied by
+// clone, so that the loop body can keep or mutate what it gets without
+// reaching into the map through a shared pointer, slice or map. All is the
+// faster choice when values hold no references or are only read.
+func (m *Map[K, V]) AllCloned(clone func(V) V) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for k, v := range m.All() {
+			if !yield(k, clone(v)) {
+				return
+			}
+		}
+	}
+}
+
+// Returns every key in the map, in the same order as All
+func (m *Map[K, V]) KeysSlice() []K {
+	keys := make([]K, 0, m.numElements)
+	for k := range m.All() {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Returns every value in the map, in the same order as All
+func (m *Map[K, V]) ValuesSlice() []V {
+	values := make([]V, 0, m.numElements)
+	for _, v := range m.All() {
+		values = append(values, v)
+	}
+	return values
+}
+
+// Returns a built-in map holding every element
+func (m *Map[K, V]) ToMap() map[K]V {
+	result := make(map[K]V, m.numElements)
+	for k, v := range m.All() {
+		result[k] = v
+	}
+	return result
+}
+
+// Returns every key in the map sorted by cmp, such as cmp.Compare for
+// ordered key types, for listings that must not depend on the table order
+func (m *Map[K, V]) SortedKeys(cmp func(a, b K) int) []K {
+	keys := m.KeysSlice()
+	slices.SortFunc(keys, cmp)
+	return keys
+}
+
+// Returns an iterator over a frozen view of the map as of this call. The view
+// shares the table with the map until the map's next mutation, which copies
+// the table first, so an analysis job can iterate the snapshot on another
+// goroutine while the single writer keeps mutating the map. The iterator
+// of a WithArena map holds the table mapped until its first range ends, and
+// yields nothing after that.
+func (m *Map[K, V]) SnapshotIter() iter.Seq2[K, V] {
+	tables := m.tables()
+	blocks := [2]*arenaBlock{m.block}
+	m.shared = true
+	if m.draining != nil {
+		m.draining.shared = true
+		blocks[1] = m.draining.block
+	}
+	blocks[0].retain()
+	blocks[1].retain()
+
+	var released bool
+	return func(yield func(K, V) bool) {
+		if blocks != [2]*arenaBlock{} {
+			if released {
+				return
+			}
+			released = true
+			defer blocks[0].release()
+			defer blocks[1].release()
+		}
+		for _, elements := range tables {
+			for i := range elements {
+				if elements[i].set && !yield(elements[i].key, elements[i].value) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Calls fn for every element whose hash has value in its leading prefixBits
+// bits, stopping early if fn returns false. Workers that each take a distinct
+// value partition the map deterministically without coordinating, since the
+// partition depends only on the hasher and seeds.
+func (m *Map[K, V]) RangeWhereHash(prefixBits uint, value uint64, fn func(K, V) bool) {
+	prefixBits = min(prefixBits, 64)
+	for _, elements := range m.tables() {
+		for i := range elements {
+			if !elements[i].set {
+				continue
+			}
+			if prefixBits > 0 && elements[i].hash>>(64-prefixBits) != value {
+				continue
+			}
+			if !fn(elements[i].key, elements[i].value) {
+				return
+			}
+		}
+	}
+}
+
+// Partitions the table into n contiguous, disjoint ranges of slots and
+// returns an iterator over each, so that n goroutines can each process a
+// share of the map with no coordination. Together the iterators yield every
+// element exactly once; during an incremental rehash each also covers its
+// share of the old table. n below 1 is taken as 1. The map must not be
+// modified until every iteration is done.
+func (m *Map[K, V]) Split(n int) []iter.Seq2[K, V] {
+	n = max(n, 1)
+	tables := m.tables()
+	parts := make([]iter.Seq2[K, V], n)
+	for p := range parts {
+		parts[p] = func(yield func(K, V) bool) {
+			for _, elements := range tables {
+				lo, hi := len(elements)*p/n, len(elements)*(p+1)/n
+				for i := lo; i < hi; i++ {
+					if elements[i].set && !yield(elements[i].key, elements[i].value) {
+						return
+					}
+				}
+			}
+		}
+	}
+	return parts
+}
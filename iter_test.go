@@ -0,0 +1,296 @@
+package rhmap
+
+import (
+	"cmp"
+	"maps"
+	"math"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestAll(t *testing.T) {
+	m := must(New[int, int]())
+
+	for i := 1; i <= 100; i++ {
+		m.Set(i, i*2)
+	}
+
+	seen := make(map[int]int)
+	for k, v := range m.All() {
+		seen[k] = v
+	}
+	if len(seen) != 100 {
+		t.Errorf("All should yield 100 elements. Got %d", len(seen))
+	}
+	for i := 1; i <= 100; i++ {
+		if seen[i] != i*2 {
+			t.Errorf("All should yield key %d with value %d. Got %d", i, i*2, seen[i])
+		}
+	}
+
+	n := 0
+	for range m.All() {
+		n++
+		if n == 10 {
+			break
+		}
+	}
+	if n != 10 {
+		t.Errorf("Breaking out of All should stop the iteration. Iterated %d times", n)
+	}
+}
+
+func TestAllDeleteDuringIteration(t *testing.T) {
+	for name, opts := range map[string][]Option{
+		"default":     nil,
+		"shrink":      {WithShrink(.2)},
+		"incremental": {WithIncrementalRehash(2)},
+		"grouped":     {WithGroupProbing()},
+	} {
+		m := must(New[int, int](opts...))
+		for i := 0; i < 5000; i++ {
+			m.Set(i, i)
+		}
+		if name == "incremental" && m.draining == nil {
+			t.Fatalf("The incremental map should be mid-migration.")
+		}
+		c := m.Clone()
+
+		// Delete every element as it is yielded, and every odd key ahead
+		// of the iterator, while updating the rest
+		seen := make(map[int]int)
+		for k, v := range m.All() {
+			seen[k]++
+			if k%2 == 0 && k+1 < 5000 {
+				m.Delete(k + 1)
+				m.Set(k, -v)
+			} else {
+				m.Delete(k)
+			}
+		}
+
+		for k, n := range seen {
+			if n != 1 {
+				t.Errorf("%s: key %d should be yielded once. Got %d", name, k, n)
+			}
+		}
+		if len(seen) < 2500 {
+			t.Errorf("%s: every even key should be yielded. Got %d keys", name, len(seen))
+		}
+		for i := 0; i < 5000; i += 2 {
+			if val, ok := m.Get(i); !ok || val != -i {
+				t.Errorf("%s: key %d should have been updated. Got %d, %t", name, i, val, ok)
+			}
+		}
+		if m.Len() != 2500 || c.Len() != 5000 {
+			t.Errorf("%s: Expected 2500 elements left and the clone untouched. Got %d, %d", name, m.Len(), c.Len())
+		}
+		if err := m.Validate(); err != nil {
+			t.Errorf("%s: the map should stay valid. Got %v", name, err)
+		}
+	}
+
+	nan := must(New[float64, int]())
+	for i := 0; i < 100; i++ {
+		nan.Set(math.NaN(), i)
+		nan.Set(float64(i), i)
+	}
+	n := 0
+	for k := range nan.All() {
+		n++
+		if k == k {
+			nan.Delete(k)
+		}
+	}
+	if n != 200 || nan.Len() != 100 {
+		t.Errorf("NaN keys should be yielded once each. Yielded %d, %d left", n, nan.Len())
+	}
+}
+
+func TestAllPanicsOnResize(t *testing.T) {
+	m := must(New[int, int]())
+	m.Set(0, 0)
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Growing the table during iteration should panic.")
+		}
+		if m.iterating() {
+			t.Errorf("A panicking iteration should not stay registered.")
+		}
+	}()
+	for k := range m.All() {
+		for i := 1; i < 100; i++ {
+			m.Set(k+i, i)
+		}
+	}
+}
+
+func TestKeysAndValues(t *testing.T) {
+	m := must(New[int, int]())
+
+	for i := 1; i <= 50; i++ {
+		m.Set(i, i*2)
+	}
+
+	var keys, values []int
+	for k := range m.Keys() {
+		keys = append(keys, k)
+	}
+	for v := range m.Values() {
+		values = append(values, v)
+	}
+
+	if len(keys) != 50 || len(values) != 50 {
+		t.Fatalf("Keys and Values should yield 50 items each. Got %d and %d", len(keys), len(values))
+	}
+	for i := range keys {
+		if values[i] != keys[i]*2 {
+			t.Errorf("Keys and Values should yield in the same order. Key %d paired with %d", keys[i], values[i])
+		}
+	}
+}
+
+func TestSnapshotIter(t *testing.T) {
+	m := must(New[int, int]())
+
+	for i := 1; i <= 50; i++ {
+		m.Set(i, i)
+	}
+
+	snapshot := m.SnapshotIter()
+
+	m.Set(1, 100)
+	m.Set(51, 51)
+	m.Delete(2)
+
+	seen := make(map[int]int)
+	for k, v := range snapshot {
+		seen[k] = v
+	}
+
+	if len(seen) != 50 {
+		t.Errorf("Snapshot should contain the 50 elements present when it was taken. Found %d", len(seen))
+	}
+	for i := 1; i <= 50; i++ {
+		if seen[i] != i {
+			t.Errorf("Snapshot should map key %d to %d. Got %d", i, i, seen[i])
+		}
+	}
+
+	if val, _ := m.Get(1); val != 100 {
+		t.Errorf("Writes after the snapshot should still reach the map. Key 1 maps to %d", val)
+	}
+	if _, ok := m.Get(2); ok {
+		t.Error("Key 2 should have been deleted from the map.")
+	}
+}
+
+func TestSnapshotIterConcurrentWriter(t *testing.T) {
+	m := must(New[int, int]())
+
+	for i := 0; i < 1000; i++ {
+		m.Set(i, i)
+	}
+
+	snapshot := m.SnapshotIter()
+	done := make(chan int)
+	go func() {
+		n := 0
+		for range snapshot {
+			n++
+		}
+		done <- n
+	}()
+
+	for i := 0; i < 1000; i++ {
+		m.Set(i, -i)
+		m.Delete(i / 2)
+	}
+
+	if n := <-done; n != 1000 {
+		t.Errorf("Snapshot iterated concurrently with writes should yield 1000 elements. Got %d", n)
+	}
+}
+
+func TestRangeWhereHash(t *testing.T) {
+	m := must(New[int, int]())
+
+	for i := 0; i < 1000; i++ {
+		m.Set(i, i)
+	}
+
+	seen := make(map[int]int)
+	for part := uint64(0); part < 4; part++ {
+		m.RangeWhereHash(2, part, func(k, v int) bool {
+			if m.hashKey(k)>>62 != part {
+				t.Errorf("Key %d visited by partition %d has the wrong hash prefix.", k, part)
+			}
+			seen[k]++
+			return true
+		})
+	}
+
+	if len(seen) != 1000 {
+		t.Errorf("Partitions should cover all 1000 keys. Covered %d", len(seen))
+	}
+	for k, n := range seen {
+		if n != 1 {
+			t.Errorf("Key %d should be visited by exactly one partition. Visited %d times", k, n)
+		}
+	}
+}
+
+func TestExportSlices(t *testing.T) {
+	m := must(New[string, int]())
+	want := map[string]int{"b": 2, "c": 3, "a": 1}
+	for k, v := range want {
+		m.Set(k, v)
+	}
+	if got := m.ToMap(); !maps.Equal(got, want) {
+		t.Errorf("ToMap should return every element. Expected %v, Got %v", want, got)
+	}
+	if got := m.SortedKeys(cmp.Compare[string]); !slices.Equal(got, []string{"a", "b", "c"}) {
+		t.Errorf("Expected sorted keys [a b c], Got %v", got)
+	}
+	keys, values := m.KeysSlice(), m.ValuesSlice()
+	if len(keys) != 3 || len(values) != 3 {
+		t.Fatalf("Expected 3 keys and values, Got %d and %d", len(keys), len(values))
+	}
+	for i, k := range keys {
+		if values[i] != want[k] {
+			t.Errorf("Keys and values should be in the same order. Key %s came with %d", k, values[i])
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	m := must(New[int, int](WithIncrementalRehash(8)))
+	for i := 0; i < 5000; i++ {
+		m.Set(i, i)
+	}
+	for _, n := range []int{0, 1, 3, 64, 100000} {
+		parts := m.Split(n)
+		seen := make([]int, 5000)
+		var wg sync.WaitGroup
+		for _, part := range parts {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for k := range part {
+					seen[k]++
+				}
+			}()
+		}
+		wg.Wait()
+		if len(parts) != max(n, 1) {
+			t.Errorf("Split(%d) should return %d iterators. Got %d", n, max(n, 1), len(parts))
+		}
+		for k, count := range seen {
+			if count != 1 {
+				t.Errorf("Split(%d) should yield key %d once. Yielded %d times", n, k, count)
+				break
+			}
+		}
+	}
+}
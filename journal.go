@@ -0,0 +1,151 @@
+package rhmap
+
+import (
+	"errors"
+	"slices"
+)
+
+// Returned by JournaledMap.Since when entries the caller needs have been
+// truncated; the follower must start over from a Snapshot
+var ErrJournalTruncated = errors.New("rhmap: journal truncated past the requested sequence number")
+
+// Returned by JournaledMap.Apply when entries don't continue the follower's
+// journal
+var ErrJournalGap = errors.New("rhmap: journal entries skip a sequence number")
+
+type JournalOp uint8
+
+const (
+	OpSet JournalOp = iota + 1
+	OpDelete
+)
+
+// Change recorded in a JournaledMap's journal. Sequence numbers start at 1
+// and increase by one per change. Value is the zero value for deletes.
+type JournalEntry[K comparable, V any] struct {
+	Seq   uint64
+	Op    JournalOp
+	Key   K
+	Value V
+}
+
+// Robin hood hashmap that appends every change to an in-memory journal, so
+// that a follower can replicate it over whatever transport the caller
+// chooses: it starts from a Snapshot, then repeatedly ships Since(seq) from
+// the leader and Applies it. The journal grows until Truncate drops the
+// entries every follower has seen.
+type JournaledMap[K comparable, V any] struct {
+	table   *Map[K, V]
+	journal []JournalEntry[K, V]
+	// Sequence number of the last change, journaled or truncated
+	seq uint64
+}
+
+// Creates a journaled map configured by opts; WithZeroDeletes has no effect.
+// It returns an error if K can't be encoded, as New does.
+func NewJournaled[K comparable, V any](opts ...Option) (*JournaledMap[K, V], error) {
+	table, err := New[K, V](opts...)
+	if err != nil {
+		return nil, err
+	}
+	table.zeroDeletes = false
+	return &JournaledMap[K, V]{table: table}, nil
+}
+
+// Creates a follower from a snapshot taken at seq, as returned by Snapshot.
+// The follower owns m from then on and continues its journal after seq.
+func NewJournaledFrom[K comparable, V any](m *Map[K, V], seq uint64) *JournaledMap[K, V] {
+	m.zeroDeletes = false
+	return &JournaledMap[K, V]{table: m, seq: seq}
+}
+
+func (j *JournaledMap[K, V]) Set(key K, value V) {
+	j.table.Set(key, value)
+	j.record(OpSet, key, value)
+}
+
+// Deletes key, journaling the change only if key was present
+func (j *JournaledMap[K, V]) Delete(key K) {
+	before := j.table.Len()
+	j.table.Delete(key)
+	if j.table.Len() < before {
+		var zeroVal V
+		j.record(OpDelete, key, zeroVal)
+	}
+}
+
+func (j *JournaledMap[K, V]) Get(key K) (V, bool) {
+	return j.table.Get(key)
+}
+
+func (j *JournaledMap[K, V]) Len() uint64 {
+	return j.table.Len()
+}
+
+// Returns the sequence number of the latest change
+func (j *JournaledMap[K, V]) Seq() uint64 {
+	return j.seq
+}
+
+// Returns a copy of the map's contents and the sequence number they reflect,
+// for starting a follower with NewJournaledFrom. The copy shares the table
+// until either side is modified, so taking it is cheap.
+func (j *JournaledMap[K, V]) Snapshot() (*Map[K, V], uint64) {
+	return j.table.Clone(), j.seq
+}
+
+// Returns the changes after seq, oldest first. It returns
+// ErrJournalTruncated if some of them have been truncated.
+func (j *JournaledMap[K, V]) Since(seq uint64) ([]JournalEntry[K, V], error) {
+	first := j.seq - uint64(len(j.journal)) + 1
+	if seq+1 < first {
+		return nil, ErrJournalTruncated
+	}
+	if seq >= j.seq {
+		return nil, nil
+	}
+	return slices.Clone(j.journal[seq+1-first:]), nil
+}
+
+// Drops the changes up to and including seq from the journal. Followers
+// still behind seq can only catch up from a new Snapshot.
+func (j *JournaledMap[K, V]) Truncate(seq uint64) {
+	first := j.seq - uint64(len(j.journal)) + 1
+	if seq < first {
+		return
+	}
+	n := min(seq+1-first, uint64(len(j.journal)))
+	j.journal = slices.Delete(j.journal, 0, int(n))
+}
+
+// Applies changes read from a leader's Since, journaling them under the
+// same sequence numbers so the follower can in turn lead. Entries the
+// follower already has are skipped; if the rest don't start right after its
+// latest change, nothing is applied and ErrJournalGap is returned.
+func (j *JournaledMap[K, V]) Apply(entries []JournalEntry[K, V]) error {
+	for len(entries) > 0 && entries[0].Seq <= j.seq {
+		entries = entries[1:]
+	}
+	for i, e := range entries {
+		if e.Seq != j.seq+1+uint64(i) {
+			return ErrJournalGap
+		}
+	}
+
+	for _, e := range entries {
+		switch e.Op {
+		case OpSet:
+			j.table.Set(e.Key, e.Value)
+		case OpDelete:
+			j.table.Delete(e.Key)
+		}
+		j.journal = append(j.journal, e)
+		j.seq = e.Seq
+	}
+	return nil
+}
+
+func (j *JournaledMap[K, V]) record(op JournalOp, key K, value V) {
+	j.seq++
+	j.journal = append(j.journal, JournalEntry[K, V]{Seq: j.seq, Op: op, Key: key, Value: value})
+}
@@ -0,0 +1,66 @@
+package rhmap
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestJournaledMap(t *testing.T) {
+	leader := must(NewJournaled[string, int]())
+	leader.Set("a", 1)
+	leader.Set("b", 2)
+	leader.Delete("missing")
+	leader.Delete("a")
+	if leader.Seq() != 3 {
+		t.Errorf("Only changes should be journaled. Expected 3, Got %d", leader.Seq())
+	}
+
+	entries := must(leader.Since(1))
+	if len(entries) != 2 || entries[0] != (JournalEntry[string, int]{2, OpSet, "b", 2}) ||
+		entries[1] != (JournalEntry[string, int]{3, OpDelete, "a", 0}) {
+		t.Errorf("Since should return the changes after seq. Got %v", entries)
+	}
+	if entries := must(leader.Since(3)); len(entries) != 0 {
+		t.Errorf("A caught-up follower should get nothing. Got %v", entries)
+	}
+
+	snap, seq := leader.Snapshot()
+	follower := NewJournaledFrom(snap, seq)
+	leader.Set("c", 3)
+	leader.Set("b", 0)
+	leader.Delete("c")
+	if err := follower.Apply(must(leader.Since(follower.Seq()))); err != nil {
+		t.Errorf("Applying the leader's new changes should succeed. Got %v", err)
+	}
+	if val, ok := follower.Get("b"); !ok || val != 0 || follower.Len() != 1 || follower.Seq() != leader.Seq() {
+		t.Errorf("The follower should match the leader. Got %d, %t, %d elements at seq %d", val, ok, follower.Len(), follower.Seq())
+	}
+	if _, ok := snap.Get("a"); ok {
+		t.Errorf("The snapshot should not see changes made after it.")
+	}
+
+	// Replays and gaps
+	if err := follower.Apply(must(leader.Since(0))); err != nil || follower.Seq() != leader.Seq() {
+		t.Errorf("Entries the follower already has should be skipped. Got %v", err)
+	}
+	leader.Set("d", 4)
+	leader.Set("e", 5)
+	if err := follower.Apply(must(leader.Since(leader.Seq() - 1))); !errors.Is(err, ErrJournalGap) {
+		t.Errorf("Skipping a change should be refused. Got %v", err)
+	}
+	if _, ok := follower.Get("e"); ok {
+		t.Errorf("A refused batch should not be partly applied.")
+	}
+
+	leader.Truncate(6)
+	if _, err := leader.Since(5); !errors.Is(err, ErrJournalTruncated) {
+		t.Errorf("Reading truncated changes should fail. Got %v", err)
+	}
+	if entries := must(leader.Since(6)); len(entries) != 2 || entries[0].Key != "d" {
+		t.Errorf("Changes after the truncation point should remain. Got %v", entries)
+	}
+	leader.Truncate(100)
+	if entries := must(leader.Since(leader.Seq())); len(entries) != 0 || leader.Seq() != 8 {
+		t.Errorf("Truncating everything should keep the sequence. Got %v at %d", entries, leader.Seq())
+	}
+}
@@ -0,0 +1,88 @@
+package rhmap
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"reflect"
+)
+
+// Encodes the map as a JSON object when encoding/json accepts K as an
+// object key (strings, integers and encoding.TextMarshaler types), and as an
+// array of {"Key": ..., "Value": ...} entries otherwise. Object members are
+// sorted by key, as for built-in maps.
+func (m *Map[K, V]) MarshalJSON() ([]byte, error) {
+	if jsonObjectKey[K]() {
+		obj := make(map[K]V, m.numElements)
+		for k, v := range m.All() {
+			obj[k] = v
+		}
+		return json.Marshal(obj)
+	}
+
+	entries := make([]Entry[K, V], 0, m.numElements)
+	for k, v := range m.All() {
+		entries = append(entries, Entry[K, V]{k, v})
+	}
+	return json.Marshal(entries)
+}
+
+// Adds the elements of a JSON object or entry array to the map, keeping
+// existing elements as encoding/json does for built-in maps. The zero Map is
+// a valid target and is initialized with default options.
+func (m *Map[K, V]) UnmarshalJSON(data []byte) error {
+	if m.elements == nil {
+		if err := m.initZero(); err != nil {
+			return err
+		}
+	}
+	if m.readOnly {
+		return ErrReadOnly
+	}
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		var entries []Entry[K, V]
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return err
+		}
+		m.growFor(m.numElements + uint64(len(entries)))
+		for _, e := range entries {
+			m.Set(e.Key, e.Value)
+		}
+		return nil
+	}
+
+	var obj map[K]V
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+	m.growFor(m.numElements + uint64(len(obj)))
+	for k, v := range obj {
+		m.Set(k, v)
+	}
+	return nil
+}
+
+// Reports whether encoding/json can use K as an object key
+func jsonObjectKey[K comparable]() bool {
+	t := reflect.TypeFor[K]()
+	if t.Implements(reflect.TypeFor[encoding.TextMarshaler]()) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
+// Sets up a zero Map as New would with no options
+func (m *Map[K, V]) initZero() error {
+	enc, err := newKeyEncoder[K]()
+	if err != nil {
+		return err
+	}
+	*m = *newMap[K, V](enc)
+	return nil
+}
@@ -0,0 +1,73 @@
+package rhmap
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestJSONObject(t *testing.T) {
+	m := must(New[string, int]())
+	m.Set("b", 2)
+	m.Set("a", 1)
+
+	data, err := json.Marshal(struct{ M *Map[string, int] }{m})
+	if err != nil {
+		t.Fatalf("Could not marshal map: %v", err)
+	}
+	if string(data) != `{"M":{"a":1,"b":2}}` {
+		t.Errorf("String keys should marshal to a sorted object. Got %s", data)
+	}
+
+	var decoded struct{ M *Map[string, int] }
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Could not unmarshal map: %v", err)
+	}
+	if decoded.M.Len() != 2 {
+		t.Errorf("Decoded map should contain 2 elements. Found %d", decoded.M.Len())
+	}
+	for k, want := range map[string]int{"a": 1, "b": 2} {
+		if val, ok := decoded.M.Get(k); !ok || val != want {
+			t.Errorf("Key %s should map to %d. Got %d, %t", k, want, val, ok)
+		}
+	}
+}
+
+func TestJSONIntKeysMerge(t *testing.T) {
+	m := must(New[int, string]())
+	m.Set(7, "seven")
+
+	if err := json.Unmarshal([]byte(`{"1":"one","2":"two"}`), m); err != nil {
+		t.Fatalf("Could not unmarshal map: %v", err)
+	}
+	if m.Len() != 3 {
+		t.Errorf("Unmarshaling should keep existing elements. Expected 3, Got %d", m.Len())
+	}
+	if val, ok := m.Get(2); !ok || val != "two" {
+		t.Errorf("Key 2 should map to two. Got %q, %t", val, ok)
+	}
+}
+
+func TestJSONEntryArray(t *testing.T) {
+	m := must(New[point, bool]())
+	m.Set(point{1, 2}, true)
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("Could not marshal map: %v", err)
+	}
+	if string(data) != `[{"Key":{"X":1,"Y":2},"Value":true}]` {
+		t.Errorf("Struct keys should marshal to an entry array. Got %s", data)
+	}
+
+	var d Map[point, bool]
+	if err := json.Unmarshal(data, &d); err != nil {
+		t.Fatalf("Could not unmarshal map: %v", err)
+	}
+	if val, ok := d.Get(point{1, 2}); !ok || !val {
+		t.Errorf("Key {1 2} should map to true. Got %t, %t", val, ok)
+	}
+
+	if err := json.Unmarshal([]byte(`{"a":true}`), &d); err == nil {
+		t.Errorf("An object can't hold struct keys and should fail to unmarshal.")
+	}
+}
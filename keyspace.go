@@ -0,0 +1,47 @@
+package rhmap
+
+import (
+	"math"
+	"math/bits"
+)
+
+// Bits of a key's hash choosing its HyperLogLog register, giving 4096
+// one-byte registers and a standard error of about 1.6%
+const keyspaceBits = 12
+
+// HyperLogLog estimating how many distinct keys a map has ever inserted. It
+// reuses the hash each key is stored with, so tracking costs a few
+// operations per insert and no extra hashing.
+type keyspace [1 << keyspaceBits]uint8
+
+func (ks *keyspace) add(hash uint64) {
+	i := hash >> (64 - keyspaceBits)
+	// The remaining bits, with a sentinel so the rank is bounded
+	rank := uint8(bits.LeadingZeros64(hash<<keyspaceBits|1<<(keyspaceBits-1))) + 1
+	ks[i] = max(ks[i], rank)
+}
+
+func (ks *keyspace) estimate() uint64 {
+	const m = float64(len(keyspace{}))
+	var sum float64
+	zeros := 0
+	for _, r := range ks {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	e := 0.7213 / (1 + 1.079/m) * m * m / sum
+	// Linear counting is more accurate while many registers are empty
+	if e <= 2.5*m && zeros > 0 {
+		e = m * math.Log(m/float64(zeros))
+	}
+	return uint64(math.Round(e))
+}
+
+// Records a key inserted under hash, if the map tracks its keyspace
+func (m *Map[K, V]) noteKey(hash uint64) {
+	if m.keyspace != nil {
+		m.keyspace.add(hash)
+	}
+}
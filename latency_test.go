@@ -0,0 +1,63 @@
+package rhmap
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyStats(t *testing.T) {
+	m := must(New[int, int](WithLatencyStats()))
+	if s := must(New[int, int]()).Stats(); s.Latency != nil {
+		t.Errorf("Latencies should only be recorded under WithLatencyStats.")
+	}
+
+	for i := 0; i < 1000; i++ {
+		m.Set(i, i)
+	}
+	for i := 0; i < 100; i++ {
+		m.Set(i, -i)
+		m.Get(i)
+		m.Get(-1 - i)
+	}
+	for i := 0; i < 10; i++ {
+		m.Delete(i)
+	}
+
+	s := m.Stats()
+	want := map[string]uint64{
+		LatencyGetHit: 100, LatencyGetMiss: 100, LatencySetNew: 1000, LatencySetUpdate: 100, LatencyDelete: 10,
+	}
+	for name, count := range want {
+		if h := s.Latency[name]; h.Count != count {
+			t.Errorf("Expected %d %s latencies. Got %d", count, name, h.Count)
+		}
+	}
+	if h := s.Latency[LatencyRehash]; h.Count == 0 || h.Count != s.Resizes {
+		t.Errorf("Every resize should record a rehash latency. Got %d for %d resizes", h.Count, s.Resizes)
+	}
+	h := s.Latency[LatencySetNew]
+	if h.Quantile(0.5) > h.Quantile(0.99) || h.Quantile(0.99) <= 0 || h.Mean() <= 0 {
+		t.Errorf("Quantiles should grow with q. Got p50 %v, p99 %v, mean %v", h.Quantile(0.5), h.Quantile(0.99), h.Mean())
+	}
+
+	c := m.Clone()
+	c.Get(0)
+	if m.Stats().Latency[LatencyGetMiss].Count != 100 {
+		t.Errorf("A clone should record its latencies apart from the original.")
+	}
+}
+
+func TestLatencyHistogramQuantile(t *testing.T) {
+	var h LatencyHistogram
+	if h.Quantile(0.5) != 0 || h.Mean() != 0 {
+		t.Errorf("An empty histogram should report no latency.")
+	}
+	h.Buckets[3], h.Buckets[10] = 99, 1
+	h.Count, h.Total = 100, 99*5+1000
+	if q := h.Quantile(0.5); q != 8*time.Nanosecond {
+		t.Errorf("Expected a median under 8ns. Got %v", q)
+	}
+	if q := h.Quantile(0.999); q != 1024*time.Nanosecond {
+		t.Errorf("Expected the tail under 1024ns. Got %v", q)
+	}
+}
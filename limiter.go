@@ -0,0 +1,112 @@
+package rhmap
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Token bucket state for one key
+type tokenBucket struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// Per-key token bucket rate limiter. Each key gets a bucket of burst tokens
+// refilled at rate tokens per second. A bucket left idle long enough to
+// refill completely is indistinguishable from a new one, so such buckets are
+// reclaimed lazily, by a sweep piggybacked on Allow at most once per refill
+// period, keeping memory proportional to the recently active keys.
+type KeyedLimiter[K comparable] struct {
+	buckets *ConcurrentMap[K, *tokenBucket]
+	rate    float64
+	burst   float64
+	refill  time.Duration
+	// Unix nanoseconds of the last sweep
+	lastSweep atomic.Int64
+	clock     Clock
+}
+
+// Creates a limiter allowing rate events per second per key with bursts of
+// up to burst events. Options configure the underlying concurrent map, and
+// WithClock sets the limiter's clock. It returns an error if rate or burst
+// is not positive or K can't be encoded.
+func NewKeyedLimiter[K comparable](rate float64, burst int, opts ...Option) (*KeyedLimiter[K], error) {
+	if rate <= 0 || burst <= 0 {
+		return nil, errors.New("rhmap: limiter rate and burst must be positive")
+	}
+	buckets, err := NewConcurrent[K, *tokenBucket](0, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	clock := resolveOptions(opts).clock
+	if clock == nil {
+		clock = realClock{}
+	}
+
+	l := &KeyedLimiter[K]{
+		buckets: buckets,
+		rate:    rate,
+		burst:   float64(burst),
+		refill:  time.Duration(float64(burst) / rate * float64(time.Second)),
+		clock:   clock,
+	}
+	l.lastSweep.Store(clock.Now().UnixNano())
+	return l, nil
+}
+
+// Reports whether an event for key may happen now, consuming a token if so
+func (l *KeyedLimiter[K]) Allow(key K) bool {
+	return l.AllowN(key, 1)
+}
+
+// Reports whether n events for key may happen now, consuming n tokens if so
+func (l *KeyedLimiter[K]) AllowN(key K, n int) bool {
+	now := l.clock.Now()
+	l.maybeSweep(now)
+
+	b, ok := l.buckets.Get(key)
+	if !ok {
+		b, _ = l.buckets.loadOrStore(key, &tokenBucket{tokens: l.burst, last: now})
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	l.refillBucket(b, now)
+	if b.tokens < float64(n) {
+		return false
+	}
+	b.tokens -= float64(n)
+	return true
+}
+
+// Returns the number of keys with a bucket, including idle ones not yet
+// reclaimed
+func (l *KeyedLimiter[K]) Len() uint64 {
+	return l.buckets.Len()
+}
+
+func (l *KeyedLimiter[K]) refillBucket(b *tokenBucket, now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = min(l.burst, b.tokens+elapsed.Seconds()*l.rate)
+		b.last = now
+	}
+}
+
+// Reclaims full buckets if a refill period has passed since the last sweep.
+// Only the caller that wins the swap sweeps.
+func (l *KeyedLimiter[K]) maybeSweep(now time.Time) {
+	last := l.lastSweep.Load()
+	if now.UnixNano()-last < int64(l.refill) || !l.lastSweep.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+
+	l.buckets.deleteWhere(func(_ K, b *tokenBucket) bool {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return now.Sub(b.last) >= l.refill
+	})
+}
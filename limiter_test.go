@@ -0,0 +1,52 @@
+package rhmap
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKeyedLimiter(t *testing.T) {
+	clock := newFakeClock()
+	l := must(NewKeyedLimiter[string](10, 3, WithClock(clock)))
+
+	for i := 0; i < 3; i++ {
+		if !l.Allow("a") {
+			t.Errorf("Event %d should fit in the burst.", i)
+		}
+	}
+	if l.Allow("a") {
+		t.Errorf("Event past the burst should be denied.")
+	}
+	if !l.Allow("b") {
+		t.Errorf("Keys should have independent buckets.")
+	}
+
+	clock.Advance(100 * time.Millisecond)
+	if !l.Allow("a") || l.Allow("a") {
+		t.Errorf("100ms at 10/s should refill exactly one token.")
+	}
+	if l.AllowN("b", 4) {
+		t.Errorf("AllowN should deny more tokens than the bucket holds.")
+	}
+	if !l.AllowN("b", 3) {
+		t.Errorf("AllowN should allow the tokens the bucket holds.")
+	}
+
+	// After a full refill period every bucket is idle and gets reclaimed
+	clock.Advance(300 * time.Millisecond)
+	if !l.Allow("c") {
+		t.Errorf("A new key should be allowed.")
+	}
+	if l.Len() != 1 {
+		t.Errorf("Idle buckets should be reclaimed, leaving only c. Found %d", l.Len())
+	}
+	for i := 0; i < 3; i++ {
+		if !l.Allow("a") {
+			t.Errorf("A reclaimed key should start with a full burst.")
+		}
+	}
+
+	if _, err := NewKeyedLimiter[string](0, 1); err == nil {
+		t.Errorf("A rate of 0 should be rejected.")
+	}
+}
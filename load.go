@@ -0,0 +1,231 @@
+package rhmap
+
+import (
+	"context"
+	"errors"
+	"io"
+	"iter"
+	"runtime"
+	"sync"
+)
+
+// Number of records LoadStream reserves capacity for at a time
+const loadChunkSize = 4096
+
+// RecordReader yields key/value records to LoadStream. Next returns io.EOF
+// once the records are exhausted.
+type RecordReader[K comparable, V any] interface {
+	Next() (K, V, error)
+}
+
+// Inserts every record read from r and returns how many records were read.
+// Capacity is reserved one chunk of records at a time rather than up front,
+// so ingestion stays memory-bounded, and progress (if non-nil) is called with
+// the running total after each chunk. Reading stops at the first error other
+// than io.EOF, which is returned with the records loaded so far kept.
+func (m *Map[K, V]) LoadStream(r RecordReader[K, V], progress func(loaded uint64)) (uint64, error) {
+	if m.readOnly {
+		return 0, ErrReadOnly
+	}
+	var loaded uint64
+	for {
+		m.growFor(m.numElements + loadChunkSize)
+
+		for n := 0; n < loadChunkSize; n++ {
+			key, value, err := r.Next()
+			if errors.Is(err, io.EOF) {
+				if progress != nil && n > 0 {
+					progress(loaded)
+				}
+				return loaded, nil
+			}
+			if err != nil {
+				return loaded, err
+			}
+			m.Set(key, value)
+			loaded++
+		}
+
+		if progress != nil {
+			progress(loaded)
+		}
+	}
+}
+
+// Records read from the source of LoadFrom, and their hashes once a worker
+// has filled them in and closed hashed
+type loadBatch[K comparable, V any] struct {
+	keys   []K
+	values []V
+	hashes []uint64
+	hashed chan struct{}
+}
+
+// Indices of the records of a batch whose hashes fall in a segment's share
+type loadShare[K comparable, V any] struct {
+	batch   *loadBatch[K, V]
+	indices []int
+}
+
+// Inserts every element src yields, spreading the work over parallelism
+// goroutines, or GOMAXPROCS if parallelism is below 1. Workers hash batches
+// of keys while src is read, and each builds a segment of its own from the
+// keys whose hashes fall in its share; the segments are then merged into the
+// map, which is reserved for them all at once, without hashing any key
+// again. A key yielded more than once keeps its last value, as with Set.
+//
+// Until the merge the elements are held in the segments, so loading takes
+// up to twice the memory of the elements loaded. If ctx is done before src
+// is exhausted, loading stops and the map is left as it was, and ctx's
+// error is returned. src is read on the calling goroutine.
+func (m *Map[K, V]) LoadFrom(ctx context.Context, src iter.Seq2[K, V], parallelism int) error {
+	if m.readOnly {
+		return ErrReadOnly
+	}
+	if parallelism < 1 {
+		parallelism = runtime.GOMAXPROCS(0)
+	}
+
+	hashing := make(chan *loadBatch[K, V], parallelism)
+	ordered := make(chan *loadBatch[K, V], 2*parallelism)
+	shares := make([]chan loadShare[K, V], parallelism)
+	segments := make([]*Map[K, V], parallelism)
+	var workers sync.WaitGroup
+
+	for range parallelism {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for batch := range hashing {
+				batch.hashes = m.HashMany(batch.keys)
+				close(batch.hashed)
+			}
+		}()
+	}
+
+	// Each segment takes the elements of its share of hashes in the order
+	// src yielded them, so that later values of a key replace earlier ones
+	for s := range segments {
+		segments[s] = derive[K, V, V](m, 0)
+		segments[s].zeroDeletes = false
+		shares[s] = make(chan loadShare[K, V], 2)
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for share := range shares[s] {
+				batch := share.batch
+				for _, i := range share.indices {
+					segments[s].setWithHash(batch.keys[i], batch.values[i], batch.hashes[i])
+				}
+			}
+		}()
+	}
+
+	// Splits hashed batches into shares, in order
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		defer func() {
+			for s := range shares {
+				close(shares[s])
+			}
+		}()
+		split := make([][]int, parallelism)
+		for batch := range ordered {
+			<-batch.hashed
+			for i, hash := range batch.hashes {
+				s := (hash >> 32) * uint64(parallelism) >> 32
+				split[s] = append(split[s], i)
+			}
+			for s := range split {
+				shares[s] <- loadShare[K, V]{batch, split[s]}
+				split[s] = nil
+			}
+		}
+	}()
+
+	err := readBatches(ctx, src, hashing, ordered)
+	workers.Wait()
+	if err != nil {
+		return err
+	}
+
+	var total uint64
+	for _, seg := range segments {
+		total += seg.numElements
+	}
+	m.Reserve(total)
+	for _, seg := range segments {
+		for _, elements := range seg.tables() {
+			for i := range elements {
+				if e := &elements[i]; e.set {
+					m.setWithHash(e.key, e.value, e.hash)
+				}
+			}
+		}
+	}
+	m.checkFlooding()
+	m.publish()
+	return nil
+}
+
+// Reads src in batches of loadChunkSize records, handing each to both the
+// hashing workers and, in order, the splitter, until src is exhausted or
+// ctx is done. Both channels are closed on return, even if src panics.
+func readBatches[K comparable, V any](ctx context.Context, src iter.Seq2[K, V], hashing, ordered chan<- *loadBatch[K, V]) error {
+	defer close(ordered)
+	defer close(hashing)
+	send := func(batch *loadBatch[K, V]) {
+		hashing <- batch
+		ordered <- batch
+	}
+	batch := &loadBatch[K, V]{hashed: make(chan struct{})}
+	for k, v := range src {
+		batch.keys = append(batch.keys, k)
+		batch.values = append(batch.values, v)
+		if len(batch.keys) == loadChunkSize {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			send(batch)
+			batch = &loadBatch[K, V]{hashed: make(chan struct{})}
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(batch.keys) > 0 {
+		send(batch)
+	}
+	return nil
+}
+
+// Inserts every entry received from entries until it is closed, as
+// LoadFrom does. If ctx is done first, loading stops and the map is left
+// as it was, and ctx's error is returned.
+func (m *Map[K, V]) LoadFromChan(ctx context.Context, entries <-chan Entry[K, V], parallelism int) error {
+	return m.LoadFrom(ctx, func(yield func(K, V) bool) {
+		for {
+			select {
+			case e, ok := <-entries:
+				if !ok || !yield(e.Key, e.Value) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}, parallelism)
+}
+
+// Rebuilds the table once, if needed, so that n elements fit without
+// crossing the load factor
+func (m *Map[K, V]) growFor(n uint64) {
+	size := m.size
+	for float32(float64(n)/float64(size)) >= m.loadFactor {
+		size *= 2
+	}
+	if size != m.size {
+		m.rebuild(size)
+	}
+}
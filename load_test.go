@@ -0,0 +1,188 @@
+package rhmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+)
+
+type sliceReader struct {
+	keys []int
+	err  error
+}
+
+func (r *sliceReader) Next() (int, int, error) {
+	if len(r.keys) == 0 {
+		if r.err != nil {
+			return 0, 0, r.err
+		}
+		return 0, 0, io.EOF
+	}
+	key := r.keys[0]
+	r.keys = r.keys[1:]
+	return key, key * 2, nil
+}
+
+func TestLoadStream(t *testing.T) {
+	m := must(New[int, int]())
+
+	keys := make([]int, 10000)
+	for i := range keys {
+		keys[i] = i
+	}
+
+	var reports []uint64
+	n, err := m.LoadStream(&sliceReader{keys: keys}, func(loaded uint64) {
+		reports = append(reports, loaded)
+	})
+	if err != nil {
+		t.Fatalf("LoadStream returned an error: %v", err)
+	}
+	if n != 10000 || m.Len() != 10000 {
+		t.Errorf("LoadStream should load 10000 records. Reported %d, map has %d", n, m.Len())
+	}
+	if len(reports) != 3 || reports[len(reports)-1] != 10000 {
+		t.Errorf("Progress should be reported once per chunk ending at 10000. Got %v", reports)
+	}
+	for i := range keys {
+		if val, ok := m.Get(i); !ok || val != i*2 {
+			t.Errorf("Key %d should map to %d. Got %d, %t", i, i*2, val, ok)
+		}
+	}
+}
+
+func TestLoadStreamError(t *testing.T) {
+	m := must(New[int, int]())
+	readErr := errors.New("read failed")
+
+	n, err := m.LoadStream(&sliceReader{keys: []int{1, 2, 3}, err: readErr}, nil)
+	if !errors.Is(err, readErr) {
+		t.Errorf("LoadStream should return the reader's error. Got %v", err)
+	}
+	if n != 3 || m.Len() != 3 {
+		t.Errorf("Records read before the error should be kept. Reported %d, map has %d", n, m.Len())
+	}
+}
+
+// Yields n records, key i%keys with value i
+func loadSource(n, keys int) func(yield func(int, int) bool) {
+	return func(yield func(int, int) bool) {
+		for i := 0; i < n; i++ {
+			if !yield(i%keys, i) {
+				return
+			}
+		}
+	}
+}
+
+func TestLoadFrom(t *testing.T) {
+	for _, parallelism := range []int{0, 1, 3} {
+		m := must(New[int, int](WithZeroDeletes()))
+		m.Set(-1, 1)
+		m.Set(0, 1)
+		if err := m.LoadFrom(context.Background(), loadSource(90000, 30000), parallelism); err != nil {
+			t.Fatalf("LoadFrom returned an error: %v", err)
+		}
+		if m.Len() != 30001 {
+			t.Errorf("Expected 30001 elements, Got %d", m.Len())
+		}
+		if v, _ := m.Get(-1); v != 1 {
+			t.Errorf("Elements not loaded should be kept. Expected 1, Got %d", v)
+		}
+		for k := 0; k < 30000; k++ {
+			if v, _ := m.Get(k); v != 60000+k {
+				t.Fatalf("Key %d should keep its last value %d. Got %d", k, 60000+k, v)
+			}
+		}
+		if err := m.Validate(); err != nil {
+			t.Errorf("%v", err)
+		}
+
+		// The zero value loaded last deletes key 0
+		if err := m.LoadFrom(context.Background(), loadSource(1, 1), parallelism); err != nil {
+			t.Fatalf("LoadFrom returned an error: %v", err)
+		}
+		if _, ok := m.Get(0); ok || m.Len() != 30000 {
+			t.Errorf("A zero value loaded should delete its key.")
+		}
+	}
+}
+
+func TestLoadFromCanceled(t *testing.T) {
+	m := must(New[int, int]())
+	m.Set(1, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	src := func(yield func(int, int) bool) {
+		for i := 0; ; i++ {
+			if i == 3*loadChunkSize {
+				cancel()
+			}
+			if !yield(i, i) {
+				return
+			}
+		}
+	}
+	if err := m.LoadFrom(ctx, src, 2); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, Got %v", err)
+	}
+	if v, _ := m.Get(1); m.Len() != 1 || v != 1 {
+		t.Errorf("A canceled load should leave the map as it was. Got %d elements", m.Len())
+	}
+
+	if err := m.Snapshot().LoadFrom(context.Background(), loadSource(10, 10), 2); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Expected ErrReadOnly, Got %v", err)
+	}
+}
+
+func TestLoadFromChan(t *testing.T) {
+	entries := make(chan Entry[string, int])
+	go func() {
+		for i := 0; i < 10000; i++ {
+			entries <- Entry[string, int]{string(rune('a' + i%26)), i}
+		}
+		close(entries)
+	}()
+	m := must(New[string, int]())
+	if err := m.LoadFromChan(context.Background(), entries, 4); err != nil {
+		t.Fatalf("LoadFromChan returned an error: %v", err)
+	}
+	if v, _ := m.Get("a"); m.Len() != 26 || v != 9984 {
+		t.Errorf("Expected 26 elements with a = 9984, Got %d elements with a = %d", m.Len(), v)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := m.LoadFromChan(ctx, make(chan Entry[string, int]), 4); !errors.Is(err, context.Canceled) {
+		t.Errorf("A load from a silent channel should end with its context. Got %v", err)
+	}
+}
+
+func BenchmarkLoadFrom(b *testing.B) {
+	keys := make([]string, 1<<20)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+	}
+	src := func(yield func(string, int) bool) {
+		for i, k := range keys {
+			if !yield(k, i) {
+				return
+			}
+		}
+	}
+	b.Run("serial", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			m := must(New[string, int]())
+			for k, v := range src {
+				m.Set(k, v)
+			}
+		}
+	})
+	b.Run("parallel", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			m := must(New[string, int]())
+			m.LoadFrom(context.Background(), src, 0)
+		}
+	})
+}
@@ -0,0 +1,43 @@
+package rhmap
+
+// Order in which lookups visit a key's probe window
+type LookupStrategy uint8
+
+const (
+	// Start at the table's mean PSL and branch out above and below it. It
+	// finds keys in few probes once a large table's clusters run long. This
+	// is the default.
+	MeanFirst LookupStrategy = iota
+	// Walk forward from the key's home slot, stopping at the first empty
+	// slot or element closer to its home than the key would be. Misses end
+	// early and the walk is sequential, which suits small tables whose mean
+	// PSL is under one slot.
+	Linear
+)
+
+// Selects the order lookups probe in, MeanFirst by default; see
+// BenchmarkLookupStrategy. Maps under WithGroupProbing, and those keyed by
+// types with their own equality, scan their probe window as they always do.
+func WithLookupStrategy(s LookupStrategy) Option {
+	return func(o *options) {
+		o.lookup = s
+	}
+}
+
+// Looks up key walking forward from its home slot. Only the current table
+// may stop early: one being drained has holes, and as its lookup strategy is
+// left at MeanFirst it never gets here.
+func (m *Map[K, V]) probeLinear(key K, hash uint64) (V, bool, uint64) {
+	for psl := uint(0); psl <= m.maxPsl; psl++ {
+		i := m.indexAtPsl(hash, psl)
+		elem := &m.elements[i]
+		if !elem.set || elem.psl < psl {
+			break
+		}
+		if elem.hash == hash && elem.key == key {
+			return elem.value, true, i
+		}
+	}
+	var zeroVal V
+	return zeroVal, false, 0
+}
@@ -0,0 +1,76 @@
+package rhmap
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestLookupStrategy(t *testing.T) {
+	for _, opts := range [][]Option{
+		{WithLookupStrategy(Linear)},
+		{WithLookupStrategy(Linear), WithIncrementalRehash(2)},
+		{WithLookupStrategy(Linear), WithHasher(CollidingHasher(SipHasher{}, 4, CollideBucket))},
+	} {
+		m := must(New[int, int](opts...))
+		for i := 0; i < 2000; i++ {
+			m.Set(i, i)
+			if i%3 == 0 {
+				m.Delete(i / 2)
+			}
+		}
+		want := make(map[int]bool)
+		for i := 0; i < 2000; i++ {
+			want[i] = true
+			if i%3 == 0 {
+				delete(want, i/2)
+			}
+		}
+		for i := -10; i < 2010; i++ {
+			if v, ok := m.Get(i); ok != want[i] || (ok && v != i) {
+				t.Fatalf("Get(%d) should return %d, %t. Got %d, %t", i, i, want[i], v, ok)
+			}
+		}
+		if err := m.Validate(); err != nil {
+			t.Error(err)
+		}
+	}
+
+	m := must(New[int, int](WithLookupStrategy(Linear)))
+	if c := m.Filter(func(int, int) bool { return true }); c.lookup != Linear {
+		t.Errorf("Derived maps should keep the lookup strategy.")
+	}
+}
+
+// Compares the strategies at sizes from one that fits in a cache line to
+// one that doesn't fit in cache, for hits and misses
+func BenchmarkLookupStrategy(b *testing.B) {
+	for _, n := range []int{8, 64, 1 << 10, 1 << 14, 1 << 20} {
+		keys := make([]string, n)
+		for i := range keys {
+			keys[i] = "key-" + strconv.Itoa(i)
+		}
+		for _, strategy := range []struct {
+			name string
+			s    LookupStrategy
+		}{
+			{"mean-first", MeanFirst},
+			{"linear", Linear},
+		} {
+			m := must(New[string, int](WithLookupStrategy(strategy.s)))
+			for i, key := range keys {
+				m.Set(key, i)
+			}
+			name := strconv.Itoa(n) + "/" + strategy.name
+			b.Run(name+"/hit", func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					m.Get(keys[(i*7919)%n])
+				}
+			})
+			b.Run(name+"/miss", func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					m.Get("absent")
+				}
+			})
+		}
+	}
+}
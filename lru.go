@@ -0,0 +1,179 @@
+package rhmap
+
+import (
+	"errors"
+
+	"github.com/micoo227/robin-hood-hashing/internal/slab"
+)
+
+// Slot of an LRU's entry slab, linked into the recency list by index
+type lruEntry[K comparable, V any] struct {
+	key   K
+	value V
+	hash  uint64
+}
+
+// Least-recently-used cache of at most a fixed number of elements. The
+// table maps each key to its entry's index in a slab, and the recency list
+// links entries by index rather than by pointer, so the cache allocates
+// nothing per element and keeps every key hashed exactly once per call.
+type LRU[K comparable, V any] struct {
+	table *Map[K, uint32]
+	// Slot 0 is the sentinel of the recency list, whose front is the most
+	// recently used entry
+	entries  slab.Slab[lruEntry[K, V]]
+	capacity int
+	onEvict  func(K, V)
+	// WithOnEvict callback, called for evictions and Remove
+	onRemove func(K, V)
+}
+
+// Creates an LRU cache holding up to capacity elements. onEvict, if non-nil,
+// is called with every element evicted to make room for a new one. Options
+// configure the underlying map; WithSize and WithZeroDeletes have no effect.
+// It returns an error if capacity is not positive or K can't be encoded.
+func NewLRU[K comparable, V any](capacity int, onEvict func(K, V), opts ...Option) (*LRU[K, V], error) {
+	if capacity <= 0 {
+		return nil, errors.New("rhmap: LRU capacity must be positive")
+	}
+	table, err := New[K, uint32](opts...)
+	if err != nil {
+		return nil, err
+	}
+	table.zeroDeletes = false
+	table.onEvict = nil
+	table.growFor(uint64(capacity))
+
+	c := &LRU[K, V]{
+		table:    table,
+		capacity: capacity,
+		onEvict:  onEvict,
+		onRemove: evictHook[K, V](resolveOptions(opts)),
+	}
+	c.entries.Grow(capacity + 1)
+	c.entries.NewList()
+	return c, nil
+}
+
+// Sets key to value and marks it most recently used, evicting the least
+// recently used element if the cache is full. It reports whether an element
+// was evicted.
+func (c *LRU[K, V]) Add(key K, value V) bool {
+	hash := c.table.hashKey(key)
+	if i, ok, _ := c.table.getWithHash(key, hash); ok {
+		c.entries.At(i).value = value
+		c.moveToFront(i)
+		return false
+	}
+
+	evicted := false
+	if c.table.Len() >= uint64(c.capacity) {
+		k, v := c.removeEntry(c.entries.Prev(0))
+		if c.onEvict != nil {
+			c.onEvict(k, v)
+		}
+		if c.onRemove != nil {
+			c.onRemove(k, v)
+		}
+		evicted = true
+	}
+
+	i := c.entries.Alloc()
+	*c.entries.At(i) = lruEntry[K, V]{key: key, value: value, hash: hash}
+	c.entries.PushFront(0, i)
+	c.table.setWithHash(key, i, hash)
+	return evicted
+}
+
+// Returns the value under key and marks it most recently used
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	i, ok := c.table.Get(key)
+	if !ok {
+		var zeroVal V
+		return zeroVal, false
+	}
+	c.moveToFront(i)
+	return c.entries.At(i).value, true
+}
+
+// Returns the value under key without changing its recency
+func (c *LRU[K, V]) Peek(key K) (V, bool) {
+	i, ok := c.table.Get(key)
+	if !ok {
+		var zeroVal V
+		return zeroVal, false
+	}
+	return c.entries.At(i).value, true
+}
+
+// Removes key and reports whether it was present
+func (c *LRU[K, V]) Remove(key K) bool {
+	i, ok := c.table.Get(key)
+	if ok {
+		k, v := c.removeEntry(i)
+		if c.onRemove != nil {
+			c.onRemove(k, v)
+		}
+	}
+	return ok
+}
+
+// Removes and returns the least recently used element, which is handed to
+// the caller rather than to a WithOnEvict callback
+func (c *LRU[K, V]) RemoveOldest() (K, V, bool) {
+	if c.table.Len() == 0 {
+		var zeroKey K
+		var zeroVal V
+		return zeroKey, zeroVal, false
+	}
+	k, v := c.removeEntry(c.entries.Prev(0))
+	return k, v, true
+}
+
+// Returns up to n elements, most recently used first, without changing
+// their recency
+func (c *LRU[K, V]) Hottest(n int) []Entry[K, V] {
+	return c.ranked(n, c.entries.Next)
+}
+
+// Returns up to n elements, least recently used first, without changing
+// their recency
+func (c *LRU[K, V]) Coldest(n int) []Entry[K, V] {
+	return c.ranked(n, c.entries.Prev)
+}
+
+// Returns up to n elements in the order step walks the recency list
+func (c *LRU[K, V]) ranked(n int, step func(uint32) uint32) []Entry[K, V] {
+	var entries []Entry[K, V]
+	for i := step(0); i != 0 && len(entries) < n; i = step(i) {
+		e := c.entries.At(i)
+		entries = append(entries, Entry[K, V]{e.key, e.value})
+	}
+	return entries
+}
+
+func (c *LRU[K, V]) Len() uint64 {
+	return c.table.Len()
+}
+
+// Unlinks entry i, deletes it from the table and frees its slot
+func (c *LRU[K, V]) removeEntry(i uint32) (K, V) {
+	e := *c.entries.At(i)
+	c.entries.Unlink(i)
+	c.table.deleteWithHash(e.key, e.hash)
+	c.entries.Free(i)
+	return e.key, e.value
+}
+
+// Returns a copy of the cache that shares nothing mutable with it
+func (c *LRU[K, V]) clone() *LRU[K, V] {
+	d := *c
+	d.table = c.table.Clone()
+	d.entries = c.entries.Clone()
+	return &d
+}
+
+func (c *LRU[K, V]) moveToFront(i uint32) {
+	c.entries.Unlink(i)
+	c.entries.PushFront(0, i)
+}
@@ -0,0 +1,101 @@
+package rhmap
+
+import "testing"
+
+func TestLRU(t *testing.T) {
+	var evicted []int
+	c := must(NewLRU[int, int](3, func(k, v int) { evicted = append(evicted, k) }))
+
+	c.Add(1, 10)
+	c.Add(2, 20)
+	c.Add(3, 30)
+	c.Get(1)
+	if !c.Add(4, 40) {
+		t.Errorf("Adding past capacity should report an eviction.")
+	}
+	if len(evicted) != 1 || evicted[0] != 2 {
+		t.Errorf("The least recently used key 2 should be evicted. Got %v", evicted)
+	}
+
+	c.Peek(3)
+	c.Add(5, 50)
+	if _, ok := c.Peek(3); ok {
+		t.Errorf("Peek should not promote, so 3 should have been evicted.")
+	}
+	if c.Add(1, 11) {
+		t.Errorf("Updating a present key should not evict.")
+	}
+	if val, ok := c.Get(1); !ok || val != 11 {
+		t.Errorf("Key 1 should map to 11. Got %d, %t", val, ok)
+	}
+
+	if k, v, ok := c.RemoveOldest(); !ok || k != 4 || v != 40 {
+		t.Errorf("Oldest element should be 4 => 40. Got %d => %d, %t", k, v, ok)
+	}
+	if !c.Remove(5) || c.Remove(5) {
+		t.Errorf("Remove should report whether the key was present.")
+	}
+	if c.Len() != 1 {
+		t.Errorf("Cache should contain 1 element. Found %d", c.Len())
+	}
+	if len(evicted) != 2 {
+		t.Errorf("Only capacity evictions should call onEvict. Got %v", evicted)
+	}
+
+	c.Remove(1)
+	if _, _, ok := c.RemoveOldest(); ok {
+		t.Errorf("Empty cache should have no oldest element.")
+	}
+
+	if _, err := NewLRU[int, int](0, nil); err == nil {
+		t.Errorf("A capacity of 0 should be rejected.")
+	}
+}
+
+func TestLRUChurn(t *testing.T) {
+	c := must(NewLRU[int, int](100, nil))
+	size := c.table.size
+	for i := 0; i < 10000; i++ {
+		c.Add(i, i)
+		if i%3 == 0 {
+			c.Remove(i - 50)
+		}
+	}
+
+	if c.Len() > 100 {
+		t.Errorf("Cache should never exceed its capacity. Found %d", c.Len())
+	}
+	if c.entries.Len() > 101 {
+		t.Errorf("Freed slots should be reused. Slab grew to %d", c.entries.Len())
+	}
+	if c.table.size != size {
+		t.Errorf("Table sized for the capacity should never rehash. Grew from %d to %d", size, c.table.size)
+	}
+	for i := 9900; i < 10000; i++ {
+		if val, ok := c.Peek(i); ok && val != i {
+			t.Errorf("Key %d should map to itself. Got %d", i, val)
+		}
+	}
+	if _, ok := c.Peek(9999); !ok {
+		t.Errorf("The most recent key should be present.")
+	}
+}
+
+func TestLRUHottestColdest(t *testing.T) {
+	c := must(NewLRU[int, string](4, nil))
+	for k, v := range []string{"a", "b", "c", "d"} {
+		c.Add(k, v)
+	}
+	c.Get(1)
+	hot := c.Hottest(2)
+	if len(hot) != 2 || hot[0] != (Entry[int, string]{1, "b"}) || hot[1] != (Entry[int, string]{3, "d"}) {
+		t.Errorf("Expected the most recently used first, Got %v", hot)
+	}
+	cold := c.Coldest(10)
+	if len(cold) != 4 || cold[0].Key != 0 || cold[1].Key != 2 || cold[3].Key != 1 {
+		t.Errorf("Expected the least recently used first, Got %v", cold)
+	}
+	if c.Add(4, "e"); c.Coldest(1)[0].Key != 2 {
+		t.Errorf("Ranking shouldn't count as a use.")
+	}
+}
@@ -1,28 +1,46 @@
 package rhmap
 
 import (
-	"bytes"
-	"encoding/gob"
-	"log"
-	"math/rand"
+	"cmp"
+	"math/bits"
+	"reflect"
+	"slices"
+	"sync/atomic"
+	"time"
 
-	"github.com/dchest/siphash"
+	"github.com/micoo227/robin-hood-hashing/internal/rawtype"
 )
 
 // Default size for hash map when no size is specified on instantiation
 const defaultSize uint64 = 8
 
+// Rounds size up to a power of two. Tables are a power of two in size, so
+// that home slots are found with a mask, unless a growth factor or policy
+// chose their size.
+func roundSize(size uint64) uint64 {
+	if size <= 1 {
+		return 1
+	}
+	return 1 << bits.Len64(size-1)
+}
+
+// Load at which the table grows
+const defaultLoadFactor float32 = .9
+
 // Item in hashmap
 type element[K comparable, V any] struct {
 	key   K
 	value V
-	psl   uint
-	set   bool
+	// Hash of key, compared before the key itself and reused on rebuilds
+	hash uint64
+	psl  uint
+	set  bool
 }
 
 // Implementation of robin hood hashmap
 type Map[K comparable, V any] struct {
-	hasher      func(k0, k1 uint64, p []byte) uint64
+	hasher      Hasher
+	enc         keyEncoder[K]
 	k0          uint64
 	k1          uint64
 	numElements uint64
@@ -32,52 +50,390 @@ type Map[K comparable, V any] struct {
 	totalPsl    uint64
 	maxPsl      uint
 	maxFreq     uint
+	fastRange   bool
+	shared      bool
+	zeroDeletes bool
+	// Load below which deletes halve the table, or 0 to never shrink
+	shrinkLoad float32
+	// Size the table was created with, which it never shrinks below
+	minSize uint64
+	// Old slots migrated per write during an incremental rehash, or 0 to
+	// rehash in one step
+	rehashStep uint64
+	// Table being migrated by an incremental rehash, with its own element
+	// count and PSL statistics, and the next of its slots to migrate
+	draining    *Map[K, V]
+	drainCursor uint64
+	// Whether lookups scan control bytes a group at a time, and the control
+	// bytes themselves, which are nil for tables smaller than a group
+	grouped bool
+	ctrl    []byte
+	// Order lookups probe in when the table has no control bytes
+	lookup LookupStrategy
+	// Max PSL past which the table grows early, or 0 to grow on load alone
+	pslGrowth uint
+	// Smallest SetMany batch inserted in table order
+	bulkThreshold int
+	// Number of times the table has grown or shrunk
+	resizes uint64
+	// Whether the map may reseed itself when flooded, how many times it has,
+	// and the table size at the last reseed
+	autoReseed   bool
+	cryptoSeeds  bool
+	reseeds      uint64
+	reseededSize uint64
+	// Called with every element deleted or cleared, or nil
+	onEvict func(K, V)
+	// Suspected floods, the callback they are reported to, the table size
+	// long probes were last reported at, and the max PSL, element count and
+	// table size when the current PSL growth window opened
+	suspectedFloods uint64
+	onFlood         func(FloodReport)
+	longProbesSize  uint64
+	watchPsl        uint
+	watchLen        uint64
+	watchSize       uint64
+	// Distinct keys ever inserted, under WithKeyspaceStats
+	keyspace *keyspace
+	// Most missed keys, under WithMissStats
+	misses *missTracker[K]
+	// Decayed hit and miss counts, under WithHitRatio
+	hits *hitRatio
+	// Operation latencies, under WithLatencyStats
+	latency *latencyRecorder
+
+	registration *registration
+	auditCursor  uint64
+	popCursor    uint64
+
+	// Number of iterations in progress, updated atomically since readers may
+	// iterate concurrently, and a count of the rebuilds and migrations that
+	// move elements, which iterators check to detect them
+	iterators int32
+	layout    uint64
+	// Count of the writes the map has taken, which read transactions check
+	version uint64
+	// Whether the map is a Snapshot, whose every write is misuse
+	readOnly bool
+	// How misuse is reported, and the first misuse recorded under
+	// ErrorReturns
+	failure FailurePolicy
+	err     error
+	// Where metrics are reported, or nil, and the Sets and Deletes since the
+	// map was created, which pace gauge reports
+	metrics   MetricsSink
+	metricOps uint64
+	// Bound of WithMaxEntries, or 0, what happens once it is reached, the
+	// policy choosing victims unless it is EvictRandom or RejectNew, and the
+	// keys evicted and rejected
+	maxEntries uint64
+	eviction   EvictionPolicy
+	evictor    Evictor[K]
+	// Frequency filter of WithAdmission, or nil
+	admission *admission[K]
+	// Elements evicted at once, and the worker passing them to onEvict
+	// under WithAsyncEviction, or nil
+	evictBatch  int
+	evictWorker *evictWorker[K, V]
+	evictions   uint64
+	rejected    uint64
+	// Factor and policy sizing each grow, both unset to double the table
+	growthFactor float64
+	growthPolicy GrowthPolicy
+	// Whether tables are mapped from an arena under WithArena, and the block
+	// the table was mapped from, nil for tables on the heap
+	arena bool
+	block *arenaBlock
+	// Callbacks of WithHooks, or nil, and the size and start time of the
+	// rehash in progress, which they are told of when it ends
+	hooks       *Hooks
+	rehashFrom  uint64
+	rehashStart time.Time
+	// Values of keys recently removed by Delete under WithSoftDelete, or nil
+	deleted *softDeletes[K, V]
+}
+
+// Creates a map configured by opts. It returns an error if K is a type whose
+// keys can't be encoded for hashing, such as a struct with no exported
+// fields.
+func New[K comparable, V any](opts ...Option) (*Map[K, V], error) {
+	enc, err := newKeyEncoder[K]()
+	if err != nil {
+		return nil, err
+	}
+	return newMap[K, V](enc, opts...), nil
 }
 
-func New[K comparable, V any](size ...uint64) *Map[K, V] {
+// Creates a map whose key encoder is already known to work
+func newMap[K comparable, V any](enc keyEncoder[K], opts ...Option) *Map[K, V] {
+	o := resolveOptions(opts)
+
 	mapSize := defaultSize
-	if len(size) > 0 && size[0] > 0 {
-		mapSize = size[0]
+	if o.size > 0 {
+		mapSize = roundSize(o.size)
 	}
+	hasher, k0, k1 := o.hashing()
+	loadFactor := cmp.Or(o.loadFactor, defaultLoadFactor)
 
-	return &Map[K, V]{
-		hasher:      siphash.Hash,
-		k0:          rand.Uint64(),
-		k1:          rand.Uint64(),
+	m := &Map[K, V]{
+		hasher:      hasher,
+		enc:         enc,
+		k0:          k0,
+		k1:          k1,
 		numElements: 0,
-		elements:    make([]element[K, V], mapSize),
 		size:        mapSize,
-		loadFactor:  .9,
+		loadFactor:  loadFactor,
+		pslGrowth:   o.pslGrowth,
+		fastRange:   o.fastRange || o.growthFactor > 1 || o.growthPolicy != nil,
+		zeroDeletes: o.zeroDeletes,
+		shrinkLoad:  min(o.shrinkLoad, loadFactor/4),
+		minSize:     mapSize,
+		rehashStep:  o.rehashStep,
+		grouped:     o.grouped,
+		lookup:      o.lookup,
+		autoReseed:  !o.seeded,
+		cryptoSeeds: o.cryptoSeeds,
+		onEvict:     evictHook[K, V](o),
+		onFlood:     o.onFlood,
+		failure:     o.failure,
+		metrics:     o.metricsSink(),
+		maxEntries:  o.maxEntries,
+		eviction:    o.eviction,
+
+		growthFactor: o.growthFactor,
+		growthPolicy: o.growthPolicy,
+		arena:        o.arena && !rawtype.HoldsPointers(reflect.TypeFor[element[K, V]]()),
+		hooks:        o.hooks,
+	}
+	m.allocElements(mapSize)
+	m.bulkThreshold = cmp.Or(o.bulk, defaultBulkThreshold)
+	if o.keyspace {
+		m.keyspace = new(keyspace)
+	}
+	if o.misses > 0 {
+		m.misses = newMissTracker[K](o.misses)
+	}
+	if o.latency {
+		m.latency = new(latencyRecorder)
+	}
+	if o.hitHalfLife > 0 {
+		m.hits = newHitRatio(o.hitHalfLife, o.clock)
+	}
+	m.evictor = newEvictor(enc, o)
+	m.bindEvictor()
+	if o.admission && o.maxEntries > 0 && (m.evictor != nil || o.eviction == EvictRandom) {
+		m.admission = newAdmission[K](o.maxEntries)
+	}
+	m.evictBatch = o.evictBatch
+	if o.asyncEvict && o.maxEntries > 0 && m.onEvict != nil {
+		m.evictWorker = &evictWorker[K, V]{fn: m.onEvict}
 	}
+	m.allocCtrl()
+	if o.softWindow > 0 && o.softCapacity > 0 {
+		m.deleted = newSoftDeletes(enc, o, m.onEvict)
+	}
+	if o.name != "" {
+		register(m, o.name, o.labels)
+	}
+	return m
 }
 
 func (m *Map[K, V]) Set(key K, value V) {
+	if m.rejectsWrites() {
+		return
+	}
+	if m.latency != nil {
+		defer m.recordSet(time.Now(), m.numElements+m.evictions+m.rejected)
+	}
+	m.setWithHash(key, value, m.hashKey(key))
+	m.checkFlooding()
+	if m.metrics != nil {
+		m.countOp(MetricSets)
+	}
+}
 
-	load := float32(float64(m.numElements) / float64(m.size))
+// Sets key given its precomputed hash
+func (m *Map[K, V]) setWithHash(key K, value V, hash uint64) {
+	if m.zeroDeletes && isZero(value) {
+		if m.numElements > 0 {
+			m.removeWithHash(key, hash)
+		}
+		return
+	}
 
-	if load >= m.loadFactor {
+	if m.iterating() && m.setInPlace(key, value, hash) {
+		m.accessed(key)
+		return
+	}
+	if m.overloaded() {
 		m.rehashTable()
 	}
+	m.unshare()
+	m.stepRehash()
 
-	_, ok, i := m.GetWithIndex(key)
+	_, ok, i := m.getForUpdate(key, hash)
 	if ok {
 		m.elements[i].value = value
+		m.accessed(key)
 		return
 	}
 
-	m.insertKeyValuePair(key, value)
+	if m.maxEntries > 0 && !m.makeRoom(hash) {
+		return
+	}
+	m.noteKey(hash)
+	m.insertWithHash(key, value, hash)
+	m.inserted(key)
+}
+
+// Replaces the value of key where it is, in whichever table holds it, and
+// reports whether key was present. Updates made while iterating use it so
+// that they move no elements.
+func (m *Map[K, V]) setInPlace(key K, value V, hash uint64) bool {
+	if _, ok, i := m.probe(key, hash); ok {
+		m.unshare()
+		m.elements[i].value = value
+		return true
+	}
+	if m.draining != nil {
+		if _, ok, i := m.draining.probe(key, hash); ok {
+			m.draining.unshare()
+			m.draining.elements[i].value = value
+			m.version++
+			return true
+		}
+	}
+	return false
+}
+
+// Reports whether an iteration over the map is in progress
+func (m *Map[K, V]) iterating() bool {
+	return atomic.LoadInt32(&m.iterators) > 0
 }
 
 func (m *Map[K, V]) Get(key K) (V, bool) {
-	val, ok, _ := m.GetWithIndex(key)
+	return m.getRecorded(key, m.hashKey(key))
+}
+
+// Looks up key given its precomputed hash as Get does, recording a miss,
+// the Get metric and the access under an Evictor
+func (m *Map[K, V]) getRecorded(key K, hash uint64) (V, bool) {
+	var start time.Time
+	if m.latency != nil {
+		start = time.Now()
+	}
+	val, ok, _ := m.getWithHash(key, hash)
+	if m.latency != nil {
+		op := opGetHit
+		if !ok {
+			op = opGetMiss
+		}
+		m.latency.record(op, start)
+	}
+	if !ok && m.misses != nil {
+		m.misses.add(key, hash)
+	}
+	if m.hits != nil {
+		m.hits.record(ok)
+	}
+	if m.metrics != nil {
+		m.metrics.Count(MetricGets, 1)
+	}
+	if ok && m.evictor != nil {
+		m.accessed(key)
+	}
+	if m.admission != nil {
+		m.admission.record(hash)
+	}
 	return val, ok
 }
 
+// Returns a copy of the value under key made by clone, so that a caller can
+// mutate what it gets back without reaching into the map, as it would
+// through a shared pointer, slice or map. clone is only called for present
+// keys.
+func (m *Map[K, V]) GetClone(key K, clone func(V) V) (V, bool) {
+	val, ok := m.Get(key)
+	if !ok {
+		return val, false
+	}
+	return clone(val), true
+}
+
+// Looks up every key in keys and returns the hits. Keys are hashed as one
+// batch and the result is pre-sized for the case where every key is present.
+func (m *Map[K, V]) GetAll(keys []K) map[K]V {
+	result := make(map[K]V, len(keys))
+	for i, hash := range m.HashMany(keys) {
+		if val, ok, _ := m.getWithHash(keys[i], hash); ok {
+			result[keys[i]] = val
+		}
+	}
+	return result
+}
+
+// Returns the value under key and the slot holding it. During an incremental
+// rehash, a key still in the old table is first migrated so that the slot is
+// one of the current table's.
 func (m *Map[K, V]) GetWithIndex(key K) (V, bool, uint64) {
+	return m.getForUpdate(key, m.hashKey(key))
+}
+
+// Looks up key given its precomputed hash. The index is only meaningful if
+// no incremental rehash is in progress; callers that write through it use
+// getForUpdate.
+func (m *Map[K, V]) getWithHash(key K, hash uint64) (V, bool, uint64) {
+	val, ok, i := m.probe(key, hash)
+	if !ok && m.draining != nil {
+		val, ok, _ = m.draining.probe(key, hash)
+	}
+	return val, ok, i
+}
+
+// Looks up key for an update through the returned index, first moving it
+// into the current table if an incremental rehash left it in the old one
+func (m *Map[K, V]) getForUpdate(key K, hash uint64) (V, bool, uint64) {
+	if m.draining != nil {
+		if val, ok, _ := m.draining.probe(key, hash); ok {
+			m.layout++
+			m.draining.deleteWithHash(key, hash)
+			m.unshareForMove()
+			m.numElements--
+			m.insertWithHash(key, val, hash)
+		}
+	}
+	return m.probe(key, hash)
+}
+
+// Looks up a key whose type has its own equality, checking the probe window
+// in PSL order. It is kept apart from probe so that the common case compares
+// keys with == inline.
+func (m *Map[K, V]) probeEqual(key K, hash uint64) (V, bool, uint64) {
+	for psl := uint(0); psl <= m.maxPsl; psl++ {
+		i := m.indexAtPsl(hash, psl)
+		if elem := &m.elements[i]; elem.set && elem.hash == hash && m.enc.equalStruct(elem.key, key) {
+			return elem.value, true, i
+		}
+	}
+	var zeroVal V
+	return zeroVal, false, 0
+}
+
+// Looks up key in the current table only
+func (m *Map[K, V]) probe(key K, hash uint64) (V, bool, uint64) {
 	var zeroVal V
 	if m.numElements == 0 {
 		return zeroVal, false, 0
 	}
+	if m.enc.equalStruct != nil {
+		return m.probeEqual(key, hash)
+	}
+	if m.ctrl != nil {
+		return m.probeGroups(key, hash)
+	}
+	if m.lookup == Linear {
+		return m.probeLinear(key, hash)
+	}
 
 	// The PSL of keys clusters around the mean PSL (roughly).
 	// Therefore, start search using the mean PSL and iteratively
@@ -86,29 +442,29 @@ func (m *Map[K, V]) GetWithIndex(key K) (V, bool, uint64) {
 	upPsl := uint(downPsl + 1)
 
 	for ; downPsl >= 0 && upPsl <= m.maxPsl; downPsl, upPsl = downPsl-1, upPsl+1 {
-		downIndex := m.getIndexOfKeyAtPsl(key, uint(downPsl))
-		upIndex := m.getIndexOfKeyAtPsl(key, upPsl)
+		downIndex := m.indexAtPsl(hash, uint(downPsl))
+		upIndex := m.indexAtPsl(hash, upPsl)
 
-		if m.elements[downIndex].set && m.elements[downIndex].key == key {
+		if m.matches(downIndex, key, hash) {
 			return m.elements[downIndex].value, true, downIndex
 		}
-		if m.elements[upIndex].set && m.elements[upIndex].key == key {
+		if m.matches(upIndex, key, hash) {
 			return m.elements[upIndex].value, true, upIndex
 		}
 	}
 
 	for ; downPsl >= 0; downPsl-- {
-		downIndex := m.getIndexOfKeyAtPsl(key, uint(downPsl))
+		downIndex := m.indexAtPsl(hash, uint(downPsl))
 
-		if m.elements[downIndex].set && m.elements[downIndex].key == key {
+		if m.matches(downIndex, key, hash) {
 			return m.elements[downIndex].value, true, downIndex
 		}
 	}
 
 	for ; upPsl <= m.maxPsl; upPsl++ {
-		upIndex := m.getIndexOfKeyAtPsl(key, upPsl)
+		upIndex := m.indexAtPsl(hash, upPsl)
 
-		if m.elements[upIndex].set && m.elements[upIndex].key == key {
+		if m.matches(upIndex, key, hash) {
 			return m.elements[upIndex].value, true, upIndex
 		}
 	}
@@ -116,14 +472,184 @@ func (m *Map[K, V]) GetWithIndex(key K) (V, bool, uint64) {
 	return zeroVal, false, 0
 }
 
+// Reports whether slot i holds key, comparing the cached hash first so that
+// most mismatches skip the key comparison
+func (m *Map[K, V]) matches(i uint64, key K, hash uint64) bool {
+	elem := &m.elements[i]
+	return elem.set && elem.hash == hash && elem.key == key
+}
+
 func (m *Map[K, V]) Delete(key K) {
-	if m.numElements == 0 {
+	if m.metrics != nil {
+		m.countOp(MetricDeletes)
+	}
+	if m.numElements == 0 || m.rejectsWrites() {
+		return
+	}
+	if m.latency != nil {
+		defer m.latency.record(opDelete, time.Now())
+	}
+
+	if m.softRemove(key, m.hashKey(key)) {
+		m.maybeShrink()
+	}
+}
+
+// Deletes key and returns the value it held, probing once. The value is
+// handed to the caller rather than to a WithOnEvict callback.
+func (m *Map[K, V]) GetAndDelete(key K) (V, bool) {
+	if m.numElements == 0 || m.rejectsWrites() {
+		var zeroVal V
+		return zeroVal, false
+	}
+	val, ok := m.takeWithHash(key, m.hashKey(key))
+	if ok {
+		m.maybeShrink()
+	}
+	return val, ok
+}
+
+// Deletes and returns an arbitrary element, for draining a map as a work
+// queue. Successive calls resume scanning where the last one stopped, so
+// draining the whole map costs one pass over the table. Any incremental
+// rehash is finished first. The element is handed to the caller rather than
+// to a WithOnEvict callback.
+func (m *Map[K, V]) PopAny() (K, V, bool) {
+	if m.numElements == 0 || m.rejectsWrites() {
+		var zeroKey K
+		var zeroVal V
+		return zeroKey, zeroVal, false
+	}
+	m.finishRehash()
+	for {
+		i := m.popCursor % m.size
+		if elem := m.elements[i]; elem.set {
+			// Backward shift may move another element into slot i, so the
+			// cursor stays put
+			m.deleteWithHash(elem.key, elem.hash)
+			m.maybeShrink()
+			return elem.key, elem.value, true
+		}
+		m.popCursor = i + 1
+	}
+}
+
+// Deletes key given its precomputed hash, as deleteWithHash does, and passes
+// the removed element to the eviction callback. deleteWithHash alone is for
+// elements that are moved rather than removed.
+func (m *Map[K, V]) removeWithHash(key K, hash uint64) bool {
+	old, ok := m.takeWithHash(key, hash)
+	if ok && m.onEvict != nil {
+		m.onEvict(key, old)
+	}
+	return ok
+}
+
+// Removes every element while keeping the table's capacity, so that a map
+// reused across request cycles doesn't reallocate. The slots are zeroed in
+// place unless a clone still shares them.
+func (m *Map[K, V]) Clear() {
+	if m.rejectsWrites() {
+		return
+	}
+	var removed []Entry[K, V]
+	if m.onEvict != nil {
+		removed = make([]Entry[K, V], 0, m.numElements)
+		for k, v := range m.All() {
+			removed = append(removed, Entry[K, V]{k, v})
+		}
+	}
+	if m.evictor != nil {
+		for key := range m.Keys() {
+			m.evictor.OnRemove(key)
+		}
+	}
+
+	m.checkWritable()
+	if m.draining != nil {
+		m.draining.block.release()
+		m.rehashDone()
+	}
+	m.draining, m.drainCursor = nil, 0
+	m.layout++
+	m.version++
+	if m.shared {
+		m.block.release()
+		m.allocElements(m.size)
+		m.allocCtrl()
+		m.shared = false
+	} else {
+		clear(m.elements)
+		clear(m.ctrl)
+	}
+	m.numElements = 0
+	m.totalPsl, m.maxPsl, m.maxFreq = 0, 0, 0
+	m.auditCursor = 0
+	m.publish()
+	m.notifyEvicted(removed)
+}
+
+// Clears the map as Clear does and draws fresh random seeds, so that a
+// reused map doesn't keep hashing keys the way an earlier client may have
+// learned. Seeds given by WithSeed, WithSeedsFrom or WithDeterministic are
+// kept. The keyspace history of WithKeyspaceStats, the misses of
+// WithMissStats, the counts of WithHitRatio and the error Err returns start
+// over.
+func (m *Map[K, V]) Reset() {
+	if m.rejectsWrites() {
 		return
 	}
+	m.Clear()
+	m.err = nil
+	if m.autoReseed {
+		m.k0, m.k1 = randomSeeds(m.cryptoSeeds)
+		m.reseededSize = 0
+	}
+	if m.keyspace != nil {
+		m.keyspace = new(keyspace)
+	}
+	if m.misses != nil {
+		m.misses.reset()
+	}
+	if m.hits != nil {
+		m.hits.reset()
+	}
+}
+
+// Deletes key given its precomputed hash and reports whether it was present
+func (m *Map[K, V]) deleteWithHash(key K, hash uint64) bool {
+	_, ok := m.takeWithHash(key, hash)
+	return ok
+}
+
+// Deletes key given its precomputed hash and returns the value it held
+func (m *Map[K, V]) takeWithHash(key K, hash uint64) (V, bool) {
+	m.checkWritable()
+	if m.draining != nil {
+		// Migrating would move elements under an iterator
+		if !m.iterating() {
+			m.stepRehash()
+		}
+		if m.draining != nil {
+			if val, ok := m.draining.takeWithHash(key, hash); ok {
+				m.numElements--
+				m.version++
+				if m.evictor != nil {
+					m.evictor.OnRemove(key)
+				}
+				m.publish()
+				return val, true
+			}
+		}
+	}
 
-	_, ok, i := m.GetWithIndex(key)
+	val, ok, i := m.probe(key, hash)
 
 	if ok {
+		m.unshare()
+		if m.evictor != nil {
+			m.evictor.OnRemove(key)
+		}
 		m.totalPsl -= uint64(m.elements[i].psl)
 		m.numElements--
 		if m.numElements == 0 {
@@ -132,53 +658,593 @@ func (m *Map[K, V]) Delete(key K) {
 		} else if m.elements[i].psl == m.maxPsl {
 			m.updateMaxStatsOnDelete()
 		}
-		m.elements[i] = element[K, V]{}
+		m.setSlot(i, element[K, V]{})
 
-		// Calculate i, j in this way to wrap around array when i, j >= m.size
-		for j := (i + 1) % m.size; m.elements[j].set && m.elements[j].psl > 0; i, j = (i+1)%m.size, (j+1)%m.size {
-			if m.elements[i].psl == m.maxPsl {
+		for j := m.wrap(i + 1); m.elements[j].set && m.elements[j].psl > 0; i, j = m.wrap(i+1), m.wrap(j+1) {
+			if m.elements[j].psl == m.maxPsl {
 				m.updateMaxStatsOnDelete()
 			}
 			m.elements[j].psl--
 			m.totalPsl--
-			m.elements[i] = m.elements[j]
-			m.elements[j] = element[K, V]{}
+			m.setSlot(i, m.elements[j])
+			m.setSlot(j, element[K, V]{})
+		}
+	}
+	m.publish()
+	return val, ok
+}
+
+// Deletes every key in keys and returns how many were present. Targets are
+// cleared first and each affected cluster is then compacted in a single
+// backward-shift sweep, so overlapping clusters are shifted once rather than
+// once per key.
+func (m *Map[K, V]) DeleteAll(keys []K) int {
+	if m.rejectsWrites() {
+		return 0
+	}
+	var removed []Entry[K, V]
+	var collect *[]Entry[K, V]
+	if m.onEvict != nil {
+		collect = &removed
+	}
+	deleted := m.deleteAll(keys, collect)
+	if deleted > 0 {
+		m.maybeShrink()
+	}
+	m.notifyEvicted(removed)
+	return deleted
+}
+
+// Deletes every element for which fn returns true and returns how many
+// were deleted. The table is compacted in one pass: surviving elements keep
+// their order and each moves back to the first free slot at or after its
+// home, which is where repeated backward-shift deletes would leave it. fn
+// must not modify the map.
+func (m *Map[K, V]) DeleteFunc(fn func(K, V) bool) uint64 {
+	if m.numElements == 0 || m.rejectsWrites() {
+		return 0
+	}
+	m.finishRehash()
+	m.unshare()
+	m.layout++
+
+	// Starting from an empty slot, no cluster wraps past the start, so
+	// positions can be tracked unwrapped
+	start := slices.IndexFunc(m.elements, func(e element[K, V]) bool { return !e.set })
+	var deleted uint64
+	var removed []Entry[K, V]
+	if start < 0 {
+		for i := range m.elements {
+			if fn(m.elements[i].key, m.elements[i].value) {
+				if m.onEvict != nil {
+					removed = append(removed, Entry[K, V]{m.elements[i].key, m.elements[i].value})
+				}
+				if m.evictor != nil {
+					m.evictor.OnRemove(m.elements[i].key)
+				}
+				m.setSlot(uint64(i), element[K, V]{})
+				deleted++
+			}
+		}
+		m.numElements -= deleted
+		if deleted > 0 {
+			m.rebuild(m.size)
+		}
+		m.notifyEvicted(removed)
+		return deleted
+	}
+
+	// Positions are offset by a table size so that the home of an element
+	// wrapped around from the end of the table doesn't go below zero
+	next := uint64(start) + m.size
+	for p := next; p < uint64(start)+2*m.size; p++ {
+		elem := m.elements[p%m.size]
+		if !elem.set {
+			continue
 		}
+		m.setSlot(p%m.size, element[K, V]{})
+		if fn(elem.key, elem.value) {
+			if m.onEvict != nil {
+				removed = append(removed, Entry[K, V]{elem.key, elem.value})
+			}
+			if m.evictor != nil {
+				m.evictor.OnRemove(elem.key)
+			}
+			deleted++
+			continue
+		}
+
+		home := p - uint64(elem.psl)
+		pos := max(home, next)
+		elem.psl = uint(pos - home)
+		m.setSlot(pos%m.size, elem)
+		next = pos + 1
+	}
+
+	m.numElements -= deleted
+	m.RecomputeStats()
+	if deleted > 0 {
+		m.maybeShrink()
+	}
+	m.publish()
+	m.notifyEvicted(removed)
+	return deleted
+}
+
+// Passes elements removed by a batch delete to the eviction callback
+func (m *Map[K, V]) notifyEvicted(removed []Entry[K, V]) {
+	for _, e := range removed {
+		m.onEvict(e.Key, e.Value)
+	}
+}
+
+// DeleteAll without shrinking, for callers that manage the table size.
+// Deleted elements are appended to removed unless it is nil.
+func (m *Map[K, V]) deleteAll(keys []K, removed *[]Entry[K, V]) int {
+	if m.numElements == 0 {
+		return 0
+	}
+	m.finishRehash()
+
+	// Cleared slots are remembered with the PSL of the element they held, so
+	// that cluster boundaries can still be recognized after clearing.
+	cleared := make(map[uint64]uint)
+	for _, key := range keys {
+		_, found, i := m.probe(key, m.hashKey(key))
+		if !found {
+			continue
+		}
+		m.unshare()
+		if removed != nil {
+			*removed = append(*removed, Entry[K, V]{m.elements[i].key, m.elements[i].value})
+		}
+		if m.evictor != nil {
+			m.evictor.OnRemove(m.elements[i].key)
+		}
+		cleared[i] = m.elements[i].psl
+		m.totalPsl -= uint64(m.elements[i].psl)
+		m.numElements--
+		m.setSlot(i, element[K, V]{})
+	}
+
+	deleted := len(cleared)
+	m.publish()
+	if m.numElements == 0 {
+		m.maxPsl = 0
+		m.maxFreq = 0
+		return deleted
+	}
+
+	for len(cleared) > 0 {
+		var i uint64
+		for i = range cleared {
+			break
+		}
+		start, ok := m.clusterStart(i, cleared)
+		if !ok {
+			m.rebuild(m.size)
+			return deleted
+		}
+		m.compactCluster(start, cleared)
+	}
+
+	// Compaction leaves the max PSL statistics as upper bounds; after a large
+	// batch they are likely loose enough to slow down misses, so tighten them.
+	if uint64(deleted) >= m.numElements/4 {
+		m.RecomputeStats()
+	}
+	return deleted
+}
+
+// Rebuilds totalPsl, maxPsl and maxFreq exactly from the table. Deletes keep
+// maxPsl and maxFreq only as upper bounds, which is enough for lookups to be
+// correct but lets misses probe further than needed, so this is run after
+// large batch deletes. Tests can also use it to cross-check the incremental
+// bookkeeping.
+func (m *Map[K, V]) RecomputeStats() {
+	m.finishRehash()
+	m.totalPsl = 0
+	m.maxPsl = 0
+	m.maxFreq = 0
+	for i := range m.elements {
+		if m.elements[i].set {
+			m.totalPsl += uint64(m.elements[i].psl)
+			m.updateMaxStatsOnInsert(m.elements[i].psl)
+		}
+	}
+}
+
+// Walks back from slot i to the start of its cluster: a slot that was empty
+// or held an element in its home slot before clearing, since no probe
+// sequence crosses it. Reports false if the table has no such slot.
+func (m *Map[K, V]) clusterStart(i uint64, cleared map[uint64]uint) (uint64, bool) {
+	for n := uint64(0); n < m.size; n, i = n+1, (i+m.size-1)%m.size {
+		if psl, ok := cleared[i]; ok {
+			if psl == 0 {
+				return i, true
+			}
+			continue
+		}
+		if !m.elements[i].set || m.elements[i].psl == 0 {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Shifts every element of the cluster beginning at start back as close to
+// its home slot as the cleared slots allow, stopping at the first slot that
+// was already empty before clearing. Cleared slots that are passed are
+// removed from cleared. The max PSL statistics are left as an upper bound.
+func (m *Map[K, V]) compactCluster(start uint64, cleared map[uint64]uint) {
+	// Positions are measured relative to start so that wrapping clusters
+	// can be handled without modular comparisons.
+	var next uint64
+	for pos := uint64(0); pos < m.size; pos++ {
+		i := m.wrap(start + pos)
+		if !m.elements[i].set {
+			if _, ok := cleared[i]; !ok && pos > 0 {
+				return
+			}
+			delete(cleared, i)
+			continue
+		}
+
+		home := pos - uint64(m.elements[i].psl)
+		target := max(next, home)
+		if target < pos {
+			j := m.wrap(start + target)
+			elem := m.elements[i]
+			elem.psl = uint(target - home)
+			m.setSlot(j, elem)
+			m.setSlot(i, element[K, V]{})
+			m.totalPsl -= pos - target
+		}
+		next = target + 1
+	}
+}
+
+// Rebuilds the table with the given number of slots, so that a burst of
+// inserts expected later doesn't pay for a rehash mid-request. The capacity is
+// rounded up to a power of two, and further if the current elements would
+// not fit under the load factor. The table never shrinks: capacities at or
+// below the current size are ignored.
+func (m *Map[K, V]) GrowTo(capacity uint64) {
+	if capacity <= m.size || m.rejectsWrites() {
+		return
+	}
+	capacity = roundSize(capacity)
+	for float32(float64(m.numElements)/float64(capacity)) >= m.loadFactor {
+		capacity *= 2
+	}
+	m.rebuild(capacity)
+}
+
+// Grows the table at most once so that n more elements can be set without a
+// rehash, for bulk loads of a known size
+func (m *Map[K, V]) Reserve(n uint64) {
+	if m.rejectsWrites() {
+		return
+	}
+	m.growFor(m.numElements + n)
+}
+
+// Rebuilds the table at the smallest size, down to the default size, that
+// holds the current elements under the load factor, releasing the memory
+// left behind by deletes
+func (m *Map[K, V]) ShrinkToFit() {
+	if m.rejectsWrites() {
+		return
+	}
+	size := m.size
+	for size/2 >= defaultSize && float32(float64(m.numElements)/float64(size/2)) < m.loadFactor {
+		size /= 2
+	}
+	if size != m.size {
+		m.rebuild(size)
+	}
+}
+
+// Halves the table, repeatedly if needed, while its load is below the shrink
+// threshold. The threshold is at most a quarter of the load factor, so a
+// shrunk table is at most half full and the next inserts can't grow it back.
+func (m *Map[K, V]) maybeShrink() {
+	if m.shrinkLoad == 0 || m.iterating() {
+		return
+	}
+	size := m.size
+	for size/2 >= m.minSize && float32(float64(m.numElements)/float64(size)) < m.shrinkLoad {
+		size /= 2
+	}
+	if size != m.size {
+		m.rebuild(size)
+	}
+}
+
+// Re-hashes the keys in the next n slots, resuming where the previous call
+// stopped, and calls report for every key that no longer hashes to the value
+// cached when it was set. Such keys can't be found by lookups and indicate that the
+// caller mutated a key's hashed identity, e.g. through a pointer inside a
+// struct key or a gob encoding that changed. Calling Audit with a small n
+// during idle time eventually covers the whole table. Returns the number of
+// mismatched keys found.
+func (m *Map[K, V]) Audit(n int, report func(K)) int {
+	m.finishRehash()
+	mismatched := 0
+	for ; n > 0 && m.size > 0; n-- {
+		i := m.auditCursor % m.size
+		m.auditCursor = i + 1
+
+		elem := &m.elements[i]
+		if elem.set && m.hashKey(elem.key) != elem.hash {
+			mismatched++
+			if report != nil {
+				report(elem.key)
+			}
+		}
+	}
+	return mismatched
+}
+
+// Returns the seeds the map hashes keys with. Together with the hasher they
+// fully determine where keys land, so see WithSeedsFrom before sharing them.
+func (m *Map[K, V]) ExportSeeds() (uint64, uint64) {
+	return m.k0, m.k1
+}
+
+// Switches the hash function used by the map and rebuilds the table with it.
+// Every stored element is rehashed before SetHasher returns, so lookups never
+// observe a mix of old and new hashes and no contents are lost. Passing nil
+// restores the default SipHash hasher.
+func (m *Map[K, V]) SetHasher(h Hasher) {
+	if m.rejectsWrites() {
+		return
+	}
+	if h == nil {
+		h = SipHasher{}
+	}
+	m.hasher = h
+	m.rehashAll()
+}
+
+// Recomputes every element's hash after a change of hasher or seeds and
+// rebuilds the table with the new hashes
+func (m *Map[K, V]) rehashAll() {
+	m.finishRehash()
+	m.unshare()
+	for i := range m.elements {
+		if m.elements[i].set {
+			m.elements[i].hash = m.hashKey(m.elements[i].key)
+		}
+	}
+	m.rebuild(m.size)
+}
+
+// Hashes every key in keys with the map's hasher and seeds, reusing a single
+// encoding buffer across the batch for keys that aren't strings. The hashes
+// stay valid until the hasher or seeds change.
+func (m *Map[K, V]) HashMany(keys []K) []uint64 {
+	hashes := make([]uint64, len(keys))
+	var scratch [keyScratchSize]byte
+	buf := scratch[:0]
+	for i, key := range keys {
+		if m.enc.hash64 != nil {
+			hashes[i] = m.enc.hash64(key, m.k0, m.k1)
+			continue
+		}
+		if b, ok := m.enc.view(key); ok {
+			hashes[i] = hashBytes(m.hasher, m.k0, m.k1, b)
+			continue
+		}
+		buf = m.enc.append(buf[:0], key)
+		hashes[i] = hashBytes(m.hasher, m.k0, m.k1, buf)
 	}
+	return hashes
+}
+
+func (m *Map[K, V]) hashKey(key K) uint64 {
+	return m.enc.hash(m.hasher, m.k0, m.k1, key)
 }
 
 func (m *Map[K, V]) getIndexOfKeyAtPsl(key K, psl uint) uint64 {
-	encodedBytes := encodeKey(key)
-	hash := m.hasher(m.k0, m.k1, encodedBytes)
-	i := hash % m.size
-	return (i + uint64(psl)) % m.size
+	return m.indexAtPsl(m.hashKey(key), psl)
+}
+
+func (m *Map[K, V]) indexAtPsl(hash uint64, psl uint) uint64 {
+	var i uint64
+	if m.fastRange {
+		// Lemire's multiply-shift reduction: the high word of hash * size
+		// is uniform over [0, size) without a division.
+		i, _ = bits.Mul64(hash, m.size)
+	} else {
+		i = hash & (m.size - 1)
+	}
+	return m.wrap(i + uint64(psl))
+}
+
+// Wraps a slot index less than twice the table size around the table. Sizes
+// chosen by a growth factor or policy need not be powers of two, so the
+// index can't simply be masked.
+func (m *Map[K, V]) wrap(i uint64) uint64 {
+	if i >= m.size {
+		i -= m.size
+	}
+	return i
+}
+
+// Returns the current table and, during an incremental rehash, the old table
+// being drained. Every element is in exactly one of them.
+func (m *Map[K, V]) tables() [2][]element[K, V] {
+	if m.draining == nil {
+		return [2][]element[K, V]{m.elements}
+	}
+	return [2][]element[K, V]{m.elements, m.draining.elements}
+}
+
+// Copies the table before its first mutation after a snapshot, so that
+// snapshots keep seeing the elements as they were when taken. Every write
+// passes through here, so it also counts the write.
+func (m *Map[K, V]) unshare() {
+	m.version++
+	m.unshareForMove()
+}
+
+// Copies a shared table before elements are moved within the map, which
+// unlike other writes leaves its contents and version as they were
+func (m *Map[K, V]) unshareForMove() {
+	m.checkWritable()
+	if m.shared {
+		old, block := m.elements, m.block
+		m.allocElements(uint64(len(old)))
+		copy(m.elements, old)
+		block.release()
+		m.ctrl = slices.Clone(m.ctrl)
+		m.shared = false
+	}
+}
+
+// Panics if the map is a read-only Snapshot. Every write copies a shared
+// table or rebuilds it first, so the check sits there as a backstop behind
+// rejectsWrites, whatever the failure policy.
+func (m *Map[K, V]) checkWritable() {
+	if m.readOnly {
+		panic(ErrReadOnly.Error())
+	}
 }
 
 func (m *Map[K, V]) rehashTable() {
-	m.size *= 2
-	oldElems := m.elements
-	m.elements = make([]element[K, V], m.size)
+	m.checkWritable()
+	if m.rehashStep == 0 {
+		m.rebuild(m.nextSize())
+		return
+	}
+
+	// The old table keeps its elements and statistics while it drains, and
+	// stays shared with any snapshot until a migration step writes to it
+	m.finishRehash()
+	m.draining = &Map[K, V]{
+		enc:         m.enc,
+		numElements: m.numElements,
+		elements:    m.elements,
+		size:        m.size,
+		totalPsl:    m.totalPsl,
+		maxPsl:      m.maxPsl,
+		maxFreq:     m.maxFreq,
+		fastRange:   m.fastRange,
+		shared:      m.shared,
+		arena:       m.arena,
+		block:       m.block,
+	}
+	m.drainCursor = 0
+	m.resizes++
+	m.layout++
+	if m.metrics != nil {
+		m.metrics.Count(MetricRehashes, 1)
+	}
+	m.size = m.nextSize()
+	m.allocElements(m.size)
+	m.allocCtrl()
+	m.shared = false
+	m.totalPsl, m.maxPsl, m.maxFreq = 0, 0, 0
+	m.publish()
+	m.rehashStarted(m.draining.size)
+}
+
+// Migrates the next rehashStep slots of the table being drained, if any
+func (m *Map[K, V]) stepRehash() {
+	if m.draining != nil {
+		m.migrate(m.rehashStep)
+	}
+}
+
+// Migrates whatever remains of the table being drained, if any
+func (m *Map[K, V]) finishRehash() {
+	if m.draining != nil {
+		m.migrate(m.draining.size)
+	}
+}
+
+// Moves the elements of the next n slots of the draining table into the
+// current one, dropping the draining table once it is empty. Migrated slots
+// are cleared without shifting their clusters back: lookups probe every PSL
+// up to the maximum rather than stopping at an empty slot, so the elements
+// behind them stay reachable.
+func (m *Map[K, V]) migrate(n uint64) {
+	m.layout++
+	d := m.draining
+	d.unshareForMove()
+	m.unshareForMove()
+	for ; n > 0 && d.numElements > 0; n, m.drainCursor = n-1, m.drainCursor+1 {
+		elem := d.elements[m.drainCursor]
+		if !elem.set {
+			continue
+		}
+		d.elements[m.drainCursor] = element[K, V]{}
+		d.numElements--
+		d.totalPsl -= uint64(elem.psl)
+		m.numElements--
+		m.insertWithHash(elem.key, elem.value, elem.hash)
+	}
+	if d.numElements == 0 {
+		d.block.release()
+		m.draining = nil
+		m.rehashDone()
+		if m.metrics != nil {
+			m.reportGauges()
+		}
+	}
+}
+
+// Reinserts every set element into a fresh table of the given size, which
+// must be a power of two unless the map maps hashes by fast range
+func (m *Map[K, V]) rebuild(size uint64) {
+	m.checkWritable()
+	var start time.Time
+	if m.metrics != nil || m.latency != nil {
+		start = time.Now()
+	}
+	m.finishRehash()
+	oldElems, oldBlock, oldSize := m.elements, m.block, m.size
+	m.layout++
+	if size != m.size {
+		m.resizes++
+	}
+	m.size = size
+	m.allocElements(m.size)
+	m.allocCtrl()
+	m.shared = false
 	m.numElements = 0
 	m.totalPsl = 0
 	m.maxPsl = 0
 	m.maxFreq = 0
 
+	m.rehashStarted(oldSize)
 	for _, elem := range oldElems {
-		m.insertKeyValuePair(elem.key, elem.value)
+		if elem.set {
+			m.insertWithHash(elem.key, elem.value, elem.hash)
+		}
 	}
+	oldBlock.release()
+	m.rehashDone()
+	if m.latency != nil {
+		m.latency.record(opRehash, start)
+	}
+	if m.metrics != nil {
+		m.metrics.Count(MetricRehashes, 1)
+		m.metrics.Observe(MetricRehashDuration, time.Since(start))
+		m.reportGauges()
+	}
+	m.publish()
 }
 
-func (m *Map[K, V]) insertKeyValuePair(key K, value V) {
-	encodedBytes := encodeKey(key)
-	hash := m.hasher(m.k0, m.k1, encodedBytes)
-	i := hash % m.size
+func (m *Map[K, V]) insertWithHash(key K, value V, hash uint64) {
+	i := m.indexAtPsl(hash, 0)
 
-	newElem := element[K, V]{key: key, value: value, psl: 0, set: true}
-	// Calculate i in this way to wrap around array when i >= m.size
-	for ; m.elements[i].set; i = (i + 1) % m.size {
+	newElem := element[K, V]{key: key, value: value, hash: hash, psl: 0, set: true}
+	for ; m.elements[i].set; i = m.wrap(i + 1) {
 		if newElem.psl > m.elements[i].psl {
 			oldElem := m.elements[i]
-			m.elements[i] = newElem
+			m.setSlot(i, newElem)
 
 			m.updateMaxStatsOnInsert(newElem.psl)
 			m.totalPsl += uint64(newElem.psl - oldElem.psl)
@@ -188,13 +1254,22 @@ func (m *Map[K, V]) insertKeyValuePair(key K, value V) {
 		newElem.psl += 1
 	}
 
-	m.elements[i] = newElem
+	m.setSlot(i, newElem)
 	m.numElements++
+	m.publish()
 
 	m.updateMaxStatsOnInsert(newElem.psl)
 	m.totalPsl += uint64(newElem.psl)
 }
 
+// Reports whether the table should grow before the next insert: once it
+// reaches the load factor, or with WithPslGrowth, once probes run past the
+// limit and the table is at least half that full
+func (m *Map[K, V]) overloaded() bool {
+	load := float32(float64(m.numElements) / float64(m.size))
+	return load >= m.loadFactor || (m.pslGrowth > 0 && m.maxPsl > m.pslGrowth && load >= m.loadFactor/2)
+}
+
 func (m *Map[K, V]) updateMaxStatsOnInsert(newElemPsl uint) {
 	if newElemPsl > m.maxPsl {
 		m.maxPsl = newElemPsl
@@ -204,22 +1279,24 @@ func (m *Map[K, V]) updateMaxStatsOnInsert(newElemPsl uint) {
 	}
 }
 
+// Records that an element left the max PSL. maxPsl and maxFreq are kept as
+// upper bounds, which is all lookups need: once the last element at maxPsl
+// is gone, the count at the next PSL down is unknown, so it is assumed to be
+// as large as it could be.
 func (m *Map[K, V]) updateMaxStatsOnDelete() {
-	if m.maxFreq == 1 {
+	if m.maxPsl == 0 {
+		return
+	}
+	if m.maxFreq <= 1 {
 		m.maxPsl--
+		m.maxFreq = uint(m.numElements)
 	} else {
 		m.maxFreq--
 	}
 }
 
-func encodeKey[T comparable](key T) []byte {
-	var buffer bytes.Buffer
-	enc := gob.NewEncoder(&buffer)
-	err := enc.Encode(key)
-	if err != nil {
-		log.Fatal("Could not encode key: ", err)
-	}
-	return buffer.Bytes()
+func isZero[T any](v T) bool {
+	return reflect.ValueOf(&v).Elem().IsZero()
 }
 
 func (m *Map[K, V]) Len() uint64 {
@@ -1,12 +1,21 @@
 package rhmap
 
 import (
+	"slices"
 	"strconv"
 	"testing"
 )
 
+// Unwraps a constructor's result, panicking on error
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
 func TestMapCreation(t *testing.T) {
-	m := New[int, int]()
+	m := must(New[int, int]())
 	if m.Len() != 0 {
 		t.Errorf("New map should be empty but has %d items.", m.Len())
 	}
@@ -14,7 +23,7 @@ func TestMapCreation(t *testing.T) {
 }
 
 func TestSet(t *testing.T) {
-	m := New[int, string]()
+	m := must(New[int, string]())
 
 	for i := 1; i <= 10; i++ {
 		m.Set(i, strconv.Itoa(i))
@@ -33,7 +42,7 @@ func TestSet(t *testing.T) {
 }
 
 func TestUpdate(t *testing.T) {
-	m := New[int, string]()
+	m := must(New[int, string]())
 
 	m.Set(1, "apple")
 	val, ok := m.Get(1)
@@ -57,3 +66,780 @@ func TestUpdate(t *testing.T) {
 	}
 }
 
+func TestRehashKeepsOnlySetElements(t *testing.T) {
+	m := must(New[int, int]())
+
+	for i := 1; i <= 100; i++ {
+		m.Set(i, i)
+	}
+
+	if m.Len() != 100 {
+		t.Errorf("Map should contain 100 elements after rehashing. Found %d", m.Len())
+	}
+	if _, ok := m.Get(0); ok {
+		t.Error("Key '0' was never set but was found after rehashing.")
+	}
+}
+
+func TestSetHasher(t *testing.T) {
+	m := must(New[int, string]())
+
+	for i := 1; i <= 50; i++ {
+		m.Set(i, strconv.Itoa(i))
+	}
+
+	// Every key hashes to bucket 0, so the rebuilt table must be one cluster
+	// starting at index 0.
+	calls := 0
+	constant := HasherFunc(func(k0, k1 uint64, p []byte) uint64 {
+		calls++
+		return 0
+	})
+	m.SetHasher(constant)
+
+	if calls == 0 {
+		t.Error("SetHasher should rehash stored elements with the new hasher.")
+	}
+	for i := uint64(0); i < 50; i++ {
+		if !m.elements[i].set || m.elements[i].psl != uint(i) {
+			t.Errorf("Slot %d should hold an element with PSL %d after switching hashers.", i, i)
+		}
+	}
+	if m.Len() != 50 {
+		t.Errorf("Map should contain 50 elements after switching hashers. Found %d", m.Len())
+	}
+
+	calls = 0
+	for i := 1; i <= 50; i++ {
+		val, ok := m.Get(i)
+		if !ok || val != strconv.Itoa(i) {
+			t.Errorf("Key %d should map to %s after switching hashers. Got %s, %t", i, strconv.Itoa(i), val, ok)
+		}
+	}
+	if calls == 0 {
+		t.Error("Get should hash keys with the new hasher.")
+	}
+}
+
+func TestRehashReusesCachedHashes(t *testing.T) {
+	calls := 0
+	counting := HasherFunc(func(k0, k1 uint64, p []byte) uint64 {
+		calls++
+		return SipHasher{}.Hash(k0, k1, p)
+	})
+	m := must(New[int, int](WithHasher(counting)))
+
+	for i := 0; i < 1000; i++ {
+		m.Set(i, i)
+	}
+	if calls != 1000 {
+		t.Errorf("Growing should reuse cached hashes. Expected 1000 hashes for 1000 Sets, Got %d", calls)
+	}
+
+	calls = 0
+	m.GrowTo(1 << 14)
+	m.ShrinkToFit()
+	if calls != 0 {
+		t.Errorf("Resizing should not rehash any keys. Got %d hashes", calls)
+	}
+	for i := 0; i < 1000; i++ {
+		if val, ok := m.Get(i); !ok || val != i {
+			t.Errorf("Key %d should map to %d after resizing. Got %d, %t", i, i, val, ok)
+		}
+	}
+}
+
+func TestSetHasherNilRestoresDefault(t *testing.T) {
+	m := must(New[int, int]())
+
+	for i := 1; i <= 20; i++ {
+		m.Set(i, i)
+	}
+	m.SetHasher(nil)
+
+	if m.hasher == nil {
+		t.Fatal("SetHasher(nil) should restore the default hasher.")
+	}
+	for i := 1; i <= 20; i++ {
+		if val, ok := m.Get(i); !ok || val != i {
+			t.Errorf("Key %d should map to %d after SetHasher(nil). Got %d, %t", i, i, val, ok)
+		}
+	}
+}
+
+func TestDeleteAll(t *testing.T) {
+	m := must(New[int, int]())
+
+	for i := 1; i <= 200; i++ {
+		m.Set(i, i)
+	}
+
+	var keys []int
+	for i := 2; i <= 200; i += 2 {
+		keys = append(keys, i)
+	}
+	keys = append(keys, 1000, 2000)
+
+	if n := m.DeleteAll(keys); n != 100 {
+		t.Errorf("DeleteAll should report 100 deleted keys. Got %d", n)
+	}
+	if m.Len() != 100 {
+		t.Errorf("Map should contain 100 elements after DeleteAll. Found %d", m.Len())
+	}
+	for i := 1; i <= 200; i++ {
+		_, ok := m.Get(i)
+		if i%2 == 0 && ok {
+			t.Errorf("Key %d should have been deleted.", i)
+		}
+		if i%2 == 1 && !ok {
+			t.Errorf("Key %d should still be in the map.", i)
+		}
+	}
+}
+
+func TestDeleteAllFullTable(t *testing.T) {
+	m := must(New[int, int]())
+
+	for i := 1; i <= 8; i++ {
+		m.Set(i, i)
+	}
+
+	if n := m.DeleteAll([]int{1, 3, 5}); n != 3 {
+		t.Errorf("DeleteAll should report 3 deleted keys. Got %d", n)
+	}
+	if m.Len() != 5 {
+		t.Errorf("Map should contain 5 elements after DeleteAll. Found %d", m.Len())
+	}
+	for _, i := range []int{1, 3, 5} {
+		if _, ok := m.Get(i); ok {
+			t.Errorf("Key %d should have been deleted.", i)
+		}
+	}
+	for _, i := range []int{2, 4, 6, 7, 8} {
+		if val, ok := m.Get(i); !ok || val != i {
+			t.Errorf("Key %d should still map to %d. Got %d, %t", i, i, val, ok)
+		}
+	}
+}
+
+func TestDeleteAllDuplicateKeys(t *testing.T) {
+	m := must(New[int, int]())
+
+	for i := 1; i <= 20; i++ {
+		m.Set(i, i)
+	}
+
+	if n := m.DeleteAll([]int{4, 4, 9, 4}); n != 2 {
+		t.Errorf("DeleteAll should count each deleted key once and report 2. Got %d", n)
+	}
+	if m.Len() != 18 {
+		t.Errorf("Map should contain 18 elements after DeleteAll. Found %d", m.Len())
+	}
+}
+
+func TestHashMany(t *testing.T) {
+	m := must(New[string, int]())
+
+	keys := []string{"a", "b", "c", "a"}
+	hashes := m.HashMany(keys)
+
+	if len(hashes) != len(keys) {
+		t.Fatalf("HashMany should return one hash per key. Got %d", len(hashes))
+	}
+	for i, key := range keys {
+		if hashes[i] != m.hashKey(key) {
+			t.Errorf("Hash of key %q from HashMany differs from a single-key hash.", key)
+		}
+	}
+	if hashes[0] != hashes[3] {
+		t.Error("Equal keys should hash equally within a batch.")
+	}
+}
+
+func TestGrowTo(t *testing.T) {
+	m := must(New[int, int]())
+
+	for i := 1; i <= 5; i++ {
+		m.Set(i, i)
+	}
+
+	m.GrowTo(1000)
+	if m.size != 1024 {
+		t.Errorf("GrowTo(1000) should resize the table to 1024 slots. Found %d", m.size)
+	}
+	for i := 1; i <= 5; i++ {
+		if val, ok := m.Get(i); !ok || val != i {
+			t.Errorf("Key %d should map to %d after GrowTo. Got %d, %t", i, i, val, ok)
+		}
+	}
+
+	m.GrowTo(10)
+	if m.size != 1024 {
+		t.Errorf("GrowTo should never shrink the table. Found %d slots", m.size)
+	}
+
+	for i := 1; i <= 800; i++ {
+		m.Set(i, i)
+	}
+	if m.size != 1024 {
+		t.Errorf("800 inserts should fit in 1024 slots without a rehash. Found %d slots", m.size)
+	}
+}
+
+func TestDeleteFunc(t *testing.T) {
+	m := must(New[int, int]())
+	for i := 0; i < 5000; i++ {
+		m.Set(i, i)
+	}
+
+	deleted := m.DeleteFunc(func(k, v int) bool { return k%3 != 0 })
+	if deleted != 5000-1667 || m.Len() != 1667 {
+		t.Errorf("DeleteFunc should delete the 3333 keys not divisible by 3. Deleted %d, %d left", deleted, m.Len())
+	}
+	for i := 0; i < 5000; i++ {
+		if val, ok := m.Get(i); ok != (i%3 == 0) || (ok && val != i) {
+			t.Errorf("Key %d should be present: %t. Got %d, %t", i, i%3 == 0, val, ok)
+		}
+	}
+	if n := m.Audit(int(m.size), nil); n != 0 {
+		t.Errorf("Every survivor should sit at the slot its PSL implies. Found %d mismatches", n)
+	}
+
+	totalPsl, maxPsl := m.totalPsl, m.maxPsl
+	m.RecomputeStats()
+	if m.totalPsl != totalPsl || m.maxPsl != maxPsl {
+		t.Errorf("DeleteFunc should leave exact stats. Got total %d max %d, Expected %d %d", totalPsl, maxPsl, m.totalPsl, m.maxPsl)
+	}
+
+	if m.DeleteFunc(func(k, v int) bool { return true }) != 1667 || m.Len() != 0 {
+		t.Errorf("Deleting everything should empty the map. Found %d", m.Len())
+	}
+}
+
+func TestDeleteFuncFullTable(t *testing.T) {
+	m := must(New[int, int](WithSize(16)))
+	m.loadFactor = 1.1
+	for i := 0; i < 16; i++ {
+		m.Set(i, i)
+	}
+
+	if m.DeleteFunc(func(k, v int) bool { return k < 8 }) != 8 {
+		t.Errorf("DeleteFunc should delete 8 keys from a full table.")
+	}
+	for i := 0; i < 16; i++ {
+		if _, ok := m.Get(i); ok != (i >= 8) {
+			t.Errorf("Key %d should be present: %t", i, i >= 8)
+		}
+	}
+}
+
+func TestReserve(t *testing.T) {
+	m := must(New[int, int]())
+	m.Set(-1, -1)
+
+	m.Reserve(1000)
+	size := m.size
+	if float32(1001.0/float64(size)) >= m.loadFactor {
+		t.Errorf("1000 more elements should fit under the load factor. Found %d slots", size)
+	}
+	for i := 0; i < 1000; i++ {
+		m.Set(i, i)
+	}
+	if m.size != size {
+		t.Errorf("Reserved inserts should not rehash. Went from %d to %d slots", size, m.size)
+	}
+
+	m.Reserve(0)
+	if m.size != size {
+		t.Errorf("Reserving nothing should not resize. Went from %d to %d slots", size, m.size)
+	}
+}
+
+func TestShrinkOnDelete(t *testing.T) {
+	m := must(New[int, int](WithShrink(.2)))
+	for i := 0; i < 10000; i++ {
+		m.Set(i, i)
+	}
+	peak := m.size
+
+	for i := 0; i < 9990; i++ {
+		m.Delete(i)
+	}
+	if m.size >= peak/64 {
+		t.Errorf("Deleting almost everything should shrink the table well below %d slots. Found %d", peak, m.size)
+	}
+	if load := float64(m.numElements) / float64(m.size); load >= .45 {
+		t.Errorf("A shrunk table should be under half full. Got load %.2f", load)
+	}
+	for i := 9990; i < 10000; i++ {
+		if val, ok := m.Get(i); !ok || val != i {
+			t.Errorf("Key %d should survive shrinking. Got %d, %t", i, val, ok)
+		}
+	}
+
+	// Churning around the threshold must not resize on every operation
+	size := m.size
+	for i := 0; i < 100; i++ {
+		m.Set(-1, 0)
+		m.Delete(-1)
+	}
+	if m.size != size {
+		t.Errorf("Set/Delete churn should not resize the table. Went from %d to %d slots", size, m.size)
+	}
+
+	m.DeleteAll([]int{9990, 9991, 9992, 9993, 9994, 9995, 9996, 9997, 9998, 9999})
+	if m.size != defaultSize {
+		t.Errorf("The table should never shrink below its initial size. Found %d", m.size)
+	}
+
+	plain := must(New[int, int]())
+	for i := 0; i < 1000; i++ {
+		plain.Set(i, i)
+	}
+	for i := 0; i < 1000; i++ {
+		plain.Delete(i)
+	}
+	if plain.size < 1000 {
+		t.Errorf("Maps without WithShrink should keep their size. Found %d", plain.size)
+	}
+}
+
+func TestShrinkToFit(t *testing.T) {
+	m := must(New[int, int]())
+	for i := 0; i < 1000; i++ {
+		m.Set(i, i)
+	}
+	for i := 0; i < 900; i++ {
+		m.Delete(i)
+	}
+
+	m.ShrinkToFit()
+	if m.size != 128 {
+		t.Errorf("100 elements should fit in 128 slots. Found %d", m.size)
+	}
+	for i := 900; i < 1000; i++ {
+		if val, ok := m.Get(i); !ok || val != i {
+			t.Errorf("Key %d should survive ShrinkToFit. Got %d, %t", i, val, ok)
+		}
+	}
+}
+
+func TestClear(t *testing.T) {
+	m := must(New[int, int](WithGroupProbing()))
+	for i := 0; i < 1000; i++ {
+		m.Set(i, i)
+	}
+	size := m.size
+	backing := &m.elements[0]
+
+	m.Clear()
+	if m.Len() != 0 || m.size != size || &m.elements[0] != backing {
+		t.Errorf("Clear should empty the table in place. Found %d elements in %d slots", m.Len(), m.size)
+	}
+	if err := m.Validate(); err != nil {
+		t.Errorf("A cleared map should be valid. Got %v", err)
+	}
+	if _, ok := m.Get(5); ok {
+		t.Errorf("Cleared keys should be gone.")
+	}
+	m.Set(5, 5)
+	if val, ok := m.Get(5); !ok || val != 5 || m.Len() != 1 {
+		t.Errorf("A cleared map should be reusable. Got %d, %t", val, ok)
+	}
+
+	// A clone keeps the elements it shares
+	c := m.Clone()
+	m.Clear()
+	if val, ok := c.Get(5); !ok || val != 5 {
+		t.Errorf("Clearing should not reach a clone. Got %d, %t", val, ok)
+	}
+
+	inc := must(New[int, int](WithIncrementalRehash(1)))
+	for i := 0; inc.draining == nil; i++ {
+		inc.Set(i, i)
+	}
+	inc.Clear()
+	if inc.Len() != 0 || inc.draining != nil || inc.Validate() != nil {
+		t.Errorf("Clearing mid-migration should drop the table being drained. Found %d elements", inc.Len())
+	}
+}
+
+func TestReset(t *testing.T) {
+	m := must(New[int, int]())
+	m.Set(1, 1)
+	k0, k1 := m.ExportSeeds()
+	m.Reset()
+	if n0, n1 := m.ExportSeeds(); m.Len() != 0 || (n0 == k0 && n1 == k1) {
+		t.Errorf("Reset should clear the map and reseed it. Found %d elements", m.Len())
+	}
+	m.Set(2, 2)
+	if val, ok := m.Get(2); !ok || val != 2 {
+		t.Errorf("Keys set after Reset should hash with the new seeds. Got %d, %t", val, ok)
+	}
+
+	seeded := must(New[int, int](WithSeed(1, 2)))
+	seeded.Reset()
+	if k0, k1 := seeded.ExportSeeds(); k0 != 1 || k1 != 2 {
+		t.Errorf("Reset should keep seeds given as options. Got %d, %d", k0, k1)
+	}
+}
+
+func TestGetAll(t *testing.T) {
+	m := must(New[int, string]())
+
+	for i := 1; i <= 10; i++ {
+		m.Set(i, strconv.Itoa(i))
+	}
+
+	result := m.GetAll([]int{2, 4, 42, 6, 4})
+	if len(result) != 3 {
+		t.Errorf("GetAll should return only the 3 hits. Got %d", len(result))
+	}
+	for _, i := range []int{2, 4, 6} {
+		if result[i] != strconv.Itoa(i) {
+			t.Errorf("GetAll should map key %d to %s. Got %s", i, strconv.Itoa(i), result[i])
+		}
+	}
+	if _, ok := result[42]; ok {
+		t.Error("GetAll should not include missing key 42.")
+	}
+}
+
+type auditKey struct {
+	Name *string
+}
+
+func TestGetClone(t *testing.T) {
+	m := must(New[string, []int]())
+	m.Set("a", []int{1, 2})
+	got, ok := m.GetClone("a", slices.Clone)
+	if !ok || !slices.Equal(got, []int{1, 2}) {
+		t.Errorf("GetClone should return a copy of the value. Got %v, %t", got, ok)
+	}
+	got[0] = 100
+	if val, _ := m.Get("a"); val[0] != 1 {
+		t.Errorf("Mutating the copy should not change the stored value. Got %v", val)
+	}
+
+	called := false
+	if _, ok := m.GetClone("missing", func(v []int) []int { called = true; return v }); ok || called {
+		t.Errorf("A missing key should not be cloned. Got %t, %t", ok, called)
+	}
+
+	c := must(NewConcurrent[string, []int](4))
+	c.Set("a", []int{1})
+	got, ok = c.GetClone("a", slices.Clone)
+	got[0] = 100
+	if val, _ := c.Get("a"); !ok || val[0] != 1 {
+		t.Errorf("ConcurrentMap.GetClone should return a copy. Got %v, %t", val, ok)
+	}
+}
+
+func TestAllCloned(t *testing.T) {
+	m := must(New[int, []int]())
+	for i := 0; i < 100; i++ {
+		m.Set(i, []int{i})
+	}
+	for k, v := range m.AllCloned(slices.Clone) {
+		v[0] = -1
+		if k == 50 {
+			break
+		}
+	}
+	for k, v := range m.All() {
+		if v[0] != k {
+			t.Errorf("Mutating the copies should not change the stored values. Got %v under %d", v, k)
+		}
+	}
+
+	c := must(NewConcurrent[int, []int](4))
+	for i := 0; i < 100; i++ {
+		c.Set(i, []int{i})
+	}
+	n := 0
+	c.RangeCloned(slices.Clone, func(k int, v []int) bool {
+		v[0] = -1
+		n++
+		return true
+	})
+	if val, _ := c.Get(7); n != 100 || val[0] != 7 {
+		t.Errorf("ConcurrentMap.RangeCloned should yield copies of every value. Got %d, %v", n, val)
+	}
+}
+
+func TestGetAndDelete(t *testing.T) {
+	m := must(New[int, int](WithIncrementalRehash(1)))
+	n := 0
+	for ; m.draining == nil; n++ {
+		m.Set(n, n*10)
+	}
+	for i := 0; i < n; i++ {
+		if val, ok := m.GetAndDelete(i); !ok || val != i*10 {
+			t.Errorf("GetAndDelete should return the value under %d. Got %d, %t", i, val, ok)
+		}
+		if err := m.Validate(); err != nil {
+			t.Fatalf("The map should stay valid after GetAndDelete. Got %v", err)
+		}
+	}
+	if val, ok := m.GetAndDelete(0); ok || m.Len() != 0 {
+		t.Errorf("A deleted key should not be returned again. Got %d, %t", val, ok)
+	}
+}
+
+func TestPopAny(t *testing.T) {
+	m := must(New[int, int](WithIncrementalRehash(4)))
+	for i := 0; i < 1000; i++ {
+		m.Set(i, -i)
+	}
+	seen := make(map[int]bool)
+	for {
+		k, v, ok := m.PopAny()
+		if !ok {
+			break
+		}
+		if v != -k || seen[k] {
+			t.Errorf("PopAny should return each element once with its value. Got %d, %d", k, v)
+		}
+		seen[k] = true
+		if _, ok := m.Get(k); ok {
+			t.Errorf("A popped key should be deleted. Found %d", k)
+		}
+	}
+	if len(seen) != 1000 || m.Len() != 0 {
+		t.Errorf("PopAny should drain every element. Popped %d, %d left", len(seen), m.Len())
+	}
+}
+
+func TestAudit(t *testing.T) {
+	// Fixed seeds keep the mutated key from landing on its old slot by chance
+	m := must(New[auditKey, int](WithDeterministic(1)))
+
+	names := make([]string, 20)
+	for i := range names {
+		names[i] = strconv.Itoa(i)
+		m.Set(auditKey{&names[i]}, i)
+	}
+
+	if n := m.Audit(int(m.size), nil); n != 0 {
+		t.Errorf("Audit should find no mismatches in an untouched map. Found %d", n)
+	}
+
+	// gob encodes the pointed-to string, so mutating it changes the hash of
+	// a key that compares equal to the stored one.
+	names[3] = "mutated"
+
+	var reported []auditKey
+	for i := uint64(0); i < m.size; i += 4 {
+		m.Audit(4, func(k auditKey) {
+			reported = append(reported, k)
+		})
+	}
+	if len(reported) != 1 || reported[0].Name != &names[3] {
+		t.Errorf("Audit should report exactly the mutated key. Reported %d keys", len(reported))
+	}
+}
+
+func TestRecomputeStats(t *testing.T) {
+	m := must(New[int, int]())
+
+	for i := 0; i < 2000; i++ {
+		m.Set(i, i)
+	}
+	for i := 0; i < 2000; i += 3 {
+		m.Delete(i)
+	}
+
+	totalPsl, maxPsl, maxFreq := m.totalPsl, m.maxPsl, m.maxFreq
+	m.RecomputeStats()
+
+	if m.totalPsl != totalPsl {
+		t.Errorf("Incremental totalPsl %d should match the recomputed %d.", totalPsl, m.totalPsl)
+	}
+	if m.maxPsl > maxPsl || (m.maxPsl == maxPsl && m.maxFreq > maxFreq) {
+		t.Errorf("Incremental max PSL %d (x%d) should bound the recomputed %d (x%d).", maxPsl, maxFreq, m.maxPsl, m.maxFreq)
+	}
+
+	var actualMax uint
+	var actualFreq uint
+	for _, elem := range m.elements {
+		if !elem.set {
+			continue
+		}
+		if elem.psl > actualMax {
+			actualMax, actualFreq = elem.psl, 0
+		}
+		if elem.psl == actualMax {
+			actualFreq++
+		}
+	}
+	if m.maxPsl != actualMax || m.maxFreq != actualFreq {
+		t.Errorf("RecomputeStats should find max PSL %d (x%d). Got %d (x%d)", actualMax, actualFreq, m.maxPsl, m.maxFreq)
+	}
+	for i := 1; i < 2000; i++ {
+		if _, ok := m.Get(i); ok != (i%3 != 0) {
+			t.Errorf("Key %d should be present: %t", i, i%3 != 0)
+		}
+	}
+}
+
+func TestTableSizeIsPowerOfTwo(t *testing.T) {
+	m := must(New[int, int](WithSize(3), WithShrink(.1)))
+	check := func(step string) {
+		if m.size&(m.size-1) != 0 {
+			t.Errorf("%s: table size should be a power of two. Found %d", step, m.size)
+		}
+	}
+	check("New")
+	for i := 0; i < 1000; i++ {
+		m.Set(i, i)
+	}
+	check("grow")
+	m.GrowTo(5000)
+	check("GrowTo")
+	m.DeleteFunc(func(k, v int) bool { return k >= 10 })
+	check("shrink")
+	m.ShrinkToFit()
+	check("ShrinkToFit")
+	for i := 0; i < 10; i++ {
+		if val, ok := m.Get(i); !ok || val != i {
+			t.Errorf("Key %d should map to %d. Got %d, %t", i, i, val, ok)
+		}
+	}
+}
+
+func BenchmarkIndexAtPsl(b *testing.B) {
+	for _, bench := range []struct {
+		name string
+		opts []Option
+	}{
+		{"mask", nil},
+		{"fastRange", []Option{WithFastRange()}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			m := must(New[int, int](append(bench.opts, WithSize(1<<16))...))
+			var sink uint64
+			for i := 0; i < b.N; i++ {
+				sink += m.indexAtPsl(uint64(i)*0x9e3779b97f4a7c15, uint(i&7))
+			}
+			_ = sink
+		})
+	}
+}
+
+func TestIncrementalRehash(t *testing.T) {
+	m := must(New[int, int](WithIncrementalRehash(4)))
+	want := make(map[int]int)
+	check := func(step string) {
+		if m.Len() != uint64(len(want)) {
+			t.Fatalf("%s: expected %d elements, Got %d", step, len(want), m.Len())
+		}
+		for k, v := range want {
+			if val, ok := m.Get(k); !ok || val != v {
+				t.Fatalf("%s: key %d should map to %d. Got %d, %t", step, k, v, val, ok)
+			}
+		}
+		seen := make(map[int]bool)
+		for k := range m.All() {
+			if seen[k] {
+				t.Fatalf("%s: All yielded key %d twice", step, k)
+			}
+			seen[k] = true
+		}
+		if len(seen) != len(want) {
+			t.Fatalf("%s: All should yield %d elements. Got %d", step, len(want), len(seen))
+		}
+	}
+
+	sawMigration := false
+	for i := 0; i < 2000; i++ {
+		m.Set(i, i)
+		want[i] = i
+		if i%3 == 0 {
+			m.Delete(i / 2)
+			delete(want, i/2)
+		}
+		if i%5 == 0 {
+			m.Compute(i/3, func(old int, ok bool) (int, bool) { return old + 1, ok })
+			if v, ok := want[i/3]; ok {
+				want[i/3] = v + 1
+			}
+		}
+		if m.draining != nil {
+			sawMigration = true
+			if i%97 == 0 {
+				check("during migration")
+			}
+		}
+	}
+	if !sawMigration {
+		t.Fatal("Growing should leave the old table to be migrated incrementally.")
+	}
+	check("after inserts")
+
+	m.DeleteFunc(func(k, v int) bool { return k%2 == 0 })
+	for k := range want {
+		if k%2 == 0 {
+			delete(want, k)
+		}
+	}
+	if m.draining != nil {
+		t.Error("DeleteFunc should finish the migration.")
+	}
+	check("after DeleteFunc")
+}
+
+func TestIncrementalRehashBoundsWork(t *testing.T) {
+	m := must(New[int, int](WithIncrementalRehash(16), WithSize(1024)))
+	for i := 0; m.draining == nil; i++ {
+		m.Set(i, i)
+	}
+	size := m.Len()
+	// The new key went straight into the new table, and one step moved at
+	// most 16 of the old elements
+	if left := m.draining.numElements; left < size-1-16 {
+		t.Errorf("The Set that grew the table should migrate at most one step. %d of %d elements left to migrate", left, size-1)
+	}
+
+	snapshot := m.Clone()
+	for i := 0; m.draining != nil; i++ {
+		m.Set(-1, i)
+	}
+	if snapshot.draining == nil || snapshot.Len() != size {
+		t.Errorf("A clone should keep its own migration state. Expected %d elements, Got %d", size, snapshot.Len())
+	}
+	var n uint64
+	for k, v := range snapshot.All() {
+		if k != v {
+			t.Errorf("Key %d should map to itself in the clone. Got %d", k, v)
+		}
+		n++
+	}
+	if n != size {
+		t.Errorf("The clone should iterate its %d elements. Got %d", size, n)
+	}
+}
+
+func TestIncrementalRehashPage(t *testing.T) {
+	m := must(New[int, int](WithIncrementalRehash(1)))
+	for i := 0; i < 500; i++ {
+		m.Set(i, i)
+	}
+	if m.draining == nil {
+		t.Fatal("500 Sets migrating a slot at a time should leave a migration in progress.")
+	}
+
+	seen := make(map[int]bool)
+	var entries []Entry[int, int]
+	for cursor := (Cursor{}); !cursor.Done(); {
+		entries, cursor = m.Page(cursor, 64)
+		for _, e := range entries {
+			if seen[e.Key] {
+				t.Errorf("Key %d was paged twice", e.Key)
+			}
+			seen[e.Key] = true
+		}
+	}
+	if len(seen) != 500 {
+		t.Errorf("Paging should return all 500 elements. Got %d", len(seen))
+	}
+}
@@ -0,0 +1,149 @@
+package rhmap
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// Result of one call to a memoized function, shared by every caller that
+// asked for the same key while it was running
+type memoCall[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+	// Under WithResultTTL, when the result goes stale and when it is
+	// refreshed ahead of that, in Unix nanoseconds, and whether a refresh
+	// is running
+	expires    int64
+	refreshAt  int64
+	refreshing atomic.Bool
+}
+
+var errMemoPanicked = errors.New("rhmap: memoized function panicked")
+
+// Makes the functions Memoize returns call fn again for a key once ttl has
+// passed since its result was cached, rather than caching it for good.
+// WithClock sets the clock results age by.
+func WithResultTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.resultTTL = ttl
+	}
+}
+
+// Makes the functions Memoize returns with WithResultTTL refresh a result
+// once fraction of its TTL has passed, calling fn in the background on the
+// first call after that while every caller still gets the cached result, so
+// that keys in steady use never wait for fn once cached. A refresh that
+// fails or panics leaves the cached result to be refreshed by the next call
+// or to expire.
+func WithRefreshAhead(fraction float64) Option {
+	return func(o *options) {
+		o.refreshAhead = fraction
+	}
+}
+
+// Returns a version of fn that caches its results in a concurrent map
+// configured by opts. Concurrent calls for a key that isn't cached yet share
+// a single call to fn. Errors aren't cached, so the next call for that key
+// tries again. Results are kept for good unless WithResultTTL is given. It
+// returns an error if K can't be encoded, as New does.
+func Memoize[K comparable, V any](fn func(K) (V, error), opts ...Option) (func(K) (V, error), error) {
+	cache, err := NewConcurrent[K, *memoCall[V]](0, opts...)
+	if err != nil {
+		return nil, err
+	}
+	o := resolveOptions(opts)
+	clock := o.clock
+	if clock == nil {
+		clock = realClock{}
+	}
+	ttl := int64(o.resultTTL)
+	ahead := ttl
+	if o.refreshAhead > 0 && o.refreshAhead < 1 {
+		ahead = int64(o.refreshAhead * float64(ttl))
+	}
+
+	// Calls fn to fill call, which the caller has just cached under key
+	run := func(key K, call *memoCall[V]) (V, error) {
+		completed := false
+		defer func() {
+			if !completed {
+				// Release waiters before the panic propagates
+				call.err = errMemoPanicked
+				cache.Delete(key)
+				close(call.done)
+			}
+		}()
+		call.value, call.err = fn(key)
+		completed = true
+
+		if call.err != nil {
+			cache.Delete(key)
+		} else if ttl > 0 {
+			now := clock.Now().UnixNano()
+			call.expires, call.refreshAt = now+ttl, now+ahead
+		}
+		close(call.done)
+		return call.value, call.err
+	}
+
+	// Calls fn for key in the background and caches its result in place of
+	// call, if call is still cached
+	refresh := func(key K, call *memoCall[V]) {
+		defer func() {
+			if recover() != nil {
+				call.refreshing.Store(false)
+			}
+		}()
+		value, err := fn(key)
+		if err != nil {
+			call.refreshing.Store(false)
+			return
+		}
+		now := clock.Now().UnixNano()
+		next := &memoCall[V]{done: make(chan struct{}), value: value, expires: now + ttl, refreshAt: now + ahead}
+		close(next.done)
+		replaceMemoCall(cache, key, call, next, false)
+	}
+
+	return func(key K) (V, error) {
+		call, loaded := cache.loadOrStore(key, &memoCall[V]{done: make(chan struct{})})
+		if !loaded {
+			return run(key, call)
+		}
+		<-call.done
+		if ttl == 0 || call.err != nil {
+			return call.value, call.err
+		}
+
+		now := clock.Now().UnixNano()
+		if now >= call.expires {
+			next := &memoCall[V]{done: make(chan struct{})}
+			if cur := replaceMemoCall(cache, key, call, next, true); cur != next {
+				// Another caller got there first
+				<-cur.done
+				return cur.value, cur.err
+			}
+			return run(key, next)
+		}
+		if now >= call.refreshAt && call.refreshing.CompareAndSwap(false, true) {
+			go refresh(key, call)
+		}
+		return call.value, call.err
+	}, nil
+}
+
+// Caches next under key if old is still cached there, or if nothing is and
+// insert is set, and returns the call cached under key afterwards
+func replaceMemoCall[K comparable, V any](cache *ConcurrentMap[K, *memoCall[V]], key K, old, next *memoCall[V], insert bool) *memoCall[V] {
+	hash := cache.shards[0].table.hashKey(key)
+	s := cache.shardFor(hash)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cur, ok, _ := s.table.getWithHash(key, hash); (ok && cur != old) || (!ok && !insert) {
+		return cur
+	}
+	s.table.setWithHash(key, next, hash)
+	return next
+}
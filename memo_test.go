@@ -0,0 +1,133 @@
+package rhmap
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemoize(t *testing.T) {
+	var calls atomic.Int32
+	square := must(Memoize(func(n int) (int, error) {
+		calls.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return n * n, nil
+	}))
+
+	var wg sync.WaitGroup
+	for g := 0; g < 10; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if val, err := square(7); err != nil || val != 49 {
+				t.Errorf("square(7) should be 49. Got %d, %v", val, err)
+			}
+		}()
+	}
+	wg.Wait()
+	square(7)
+
+	if calls.Load() != 1 {
+		t.Errorf("Concurrent and repeated calls should share one call. Got %d calls", calls.Load())
+	}
+}
+
+func TestMemoizeErrors(t *testing.T) {
+	errFlaky := errors.New("flaky")
+	calls := 0
+	flaky := must(Memoize(func(n int) (int, error) {
+		calls++
+		if calls == 1 {
+			return 0, errFlaky
+		}
+		return n, nil
+	}))
+
+	if _, err := flaky(1); !errors.Is(err, errFlaky) {
+		t.Errorf("First call should fail. Got %v", err)
+	}
+	if val, err := flaky(1); err != nil || val != 1 {
+		t.Errorf("Errors should not be cached. Got %d, %v", val, err)
+	}
+	flaky(1)
+	if calls != 2 {
+		t.Errorf("Only the successful result should be cached. Got %d calls", calls)
+	}
+}
+
+func TestMemoizePanic(t *testing.T) {
+	calls := 0
+	f := must(Memoize(func(n int) (int, error) {
+		calls++
+		if calls == 1 {
+			panic("boom")
+		}
+		return n, nil
+	}))
+
+	func() {
+		defer func() { recover() }()
+		f(1)
+	}()
+	if val, err := f(1); err != nil || val != 1 {
+		t.Errorf("A panicking call should not be cached. Got %d, %v", val, err)
+	}
+}
+
+func TestMemoizeResultTTL(t *testing.T) {
+	clock := newFakeClock()
+	var calls atomic.Int32
+	f := must(Memoize(func(n int) (int, error) {
+		return n + int(calls.Add(1)), nil
+	}, WithResultTTL(time.Minute), WithClock(clock)))
+
+	if val, _ := f(10); val != 11 {
+		t.Errorf("Expected 11, Got %d", val)
+	}
+	clock.Advance(59 * time.Second)
+	if val, _ := f(10); val != 11 || calls.Load() != 1 {
+		t.Errorf("A fresh result should be cached. Got %d after %d calls", val, calls.Load())
+	}
+	clock.Advance(time.Second)
+	if val, _ := f(10); val != 12 || calls.Load() != 2 {
+		t.Errorf("An expired result should be computed again. Got %d after %d calls", val, calls.Load())
+	}
+	if val, _ := f(10); val != 12 {
+		t.Errorf("The new result should be cached. Got %d", val)
+	}
+}
+
+func TestMemoizeRefreshAhead(t *testing.T) {
+	clock := newFakeClock()
+	var calls atomic.Int32
+	refreshed := make(chan struct{}, 1)
+	f := must(Memoize(func(n int) (int, error) {
+		c := calls.Add(1)
+		if c > 1 {
+			defer func() { refreshed <- struct{}{} }()
+		}
+		return n + int(c), nil
+	}, WithResultTTL(time.Minute), WithRefreshAhead(0.5), WithClock(clock)))
+
+	f(10)
+	clock.Advance(30 * time.Second)
+	if val, _ := f(10); val != 11 {
+		t.Errorf("A result due for refresh should still be returned at once. Got %d", val)
+	}
+	<-refreshed
+	for val, _ := f(10); val != 12; val, _ = f(10) {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The refreshed result lives a full TTL from its refresh
+	clock.Advance(50 * time.Second)
+	if val, _ := f(10); val != 12 {
+		t.Errorf("The refreshed result should not have expired. Got %d", val)
+	}
+	<-refreshed
+	if n := calls.Load(); n != 3 {
+		t.Errorf("Expected one call per refresh, Got %d calls", n)
+	}
+}
@@ -0,0 +1,162 @@
+package rhmap
+
+import (
+	"expvar"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Receives a map's metrics, for export to a monitoring system. A sink may
+// be shared by several maps, such as the shards of a ConcurrentMap, and
+// called from several goroutines at once, so it must be safe for concurrent
+// use. Metric names are the Metric constants.
+type MetricsSink interface {
+	// Adds delta to a counter
+	Count(name string, delta uint64)
+	// Sets a gauge
+	Gauge(name string, value float64)
+	// Records one timing of an event
+	Observe(name string, d time.Duration)
+}
+
+// MetricsSink that tells maps apart by name. A map created with WithName
+// and WithMetrics(sink) reports to the sink Named returns for its name and
+// labels rather than to sink itself, so that one sink can serve every map of
+// a service with the maps' names and labels attached to their metrics.
+type NamedSink interface {
+	MetricsSink
+	Named(name string, labels map[string]string) MetricsSink
+}
+
+// Returns the sink a map configured by o reports to
+func (o options) metricsSink() MetricsSink {
+	if ns, ok := o.metrics.(NamedSink); ok && o.name != "" {
+		return ns.Named(o.name, o.labels)
+	}
+	return o.metrics
+}
+
+// Names of the metrics a map reports
+const (
+	// Counters of Get, Set and Delete calls
+	MetricGets    = "gets"
+	MetricSets    = "sets"
+	MetricDeletes = "deletes"
+	// Counter of full or incremental rehashes started, and timings of full
+	// rehashes, which pause the caller
+	MetricRehashes       = "rehashes"
+	MetricRehashDuration = "rehash_duration"
+	// Counter of suspected floods, see WithOnSuspectedFlooding
+	MetricSuspectedFloods = "suspected_floods"
+	// Gauges of the element count, table size, load and max PSL
+	MetricLen        = "len"
+	MetricCapacity   = "capacity"
+	MetricLoadFactor = "load_factor"
+	MetricMaxPsl     = "max_psl"
+)
+
+// Operations between gauge reports, besides the report after every rehash
+const metricsGaugeInterval = 64
+
+// Reports the map's metrics to sink: counts of operations, rehashes and
+// suspected floods, timings of full rehashes, and gauges of size, load and
+// max PSL, reported after every rehash and every 64 operations. Reporting
+// costs an interface call per Get, Set and Delete.
+func WithMetrics(sink MetricsSink) Option {
+	return func(o *options) {
+		o.metrics = sink
+	}
+}
+
+// Counts an operation, reporting the gauges every metricsGaugeInterval
+// operations. Callers check m.metrics first.
+func (m *Map[K, V]) countOp(name string) {
+	m.metrics.Count(name, 1)
+	m.metricOps++
+	if m.metricOps%metricsGaugeInterval == 0 {
+		m.reportGauges()
+	}
+}
+
+func (m *Map[K, V]) reportGauges() {
+	m.metrics.Gauge(MetricLen, float64(m.numElements))
+	m.metrics.Gauge(MetricCapacity, float64(m.size))
+	if m.size > 0 {
+		m.metrics.Gauge(MetricLoadFactor, float64(m.numElements)/float64(m.size))
+	}
+	m.metrics.Gauge(MetricMaxPsl, float64(m.maxPsl))
+}
+
+// MetricsSink publishing to expvar, so that metrics show up on
+// /debug/vars. Counters are expvar.Ints, gauges expvar.Floats, and each
+// timed event a count under its name and a total in seconds under its name
+// with a "_seconds" suffix.
+type ExpvarSink struct {
+	vars  *expvar.Map
+	mu    sync.Mutex
+	named map[string]*ExpvarSink
+}
+
+// Creates a sink publishing its metrics as an expvar.Map under name. Like
+// expvar.Publish, it panics if name is already in use.
+func NewExpvarSink(name string) *ExpvarSink {
+	return &ExpvarSink{vars: expvar.NewMap(name)}
+}
+
+func (s *ExpvarSink) Count(name string, delta uint64) {
+	s.vars.Add(name, int64(delta))
+}
+
+func (s *ExpvarSink) Gauge(name string, value float64) {
+	s.float(name).Set(value)
+}
+
+func (s *ExpvarSink) Observe(name string, d time.Duration) {
+	s.vars.Add(name, 1)
+	s.vars.AddFloat(name+"_seconds", d.Seconds())
+}
+
+// Returns a sink publishing into an expvar.Map nested in this one under
+// name, followed by the labels in braces if there are any, such as
+// "sessions{region=eu}". Maps of the same name and labels share a sink.
+func (s *ExpvarSink) Named(name string, labels map[string]string) MetricsSink {
+	key := name
+	if len(labels) > 0 {
+		pairs := make([]string, 0, len(labels))
+		for _, label := range slices.Sorted(maps.Keys(labels)) {
+			pairs = append(pairs, label+"="+labels[label])
+		}
+		key += "{" + strings.Join(pairs, ",") + "}"
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n, ok := s.named[key]; ok {
+		return n
+	}
+	n := &ExpvarSink{vars: new(expvar.Map)}
+	s.vars.Set(key, n.vars)
+	if s.named == nil {
+		s.named = make(map[string]*ExpvarSink)
+	}
+	s.named[key] = n
+	return n
+}
+
+// Returns the gauge under name, creating it on first use
+func (s *ExpvarSink) float(name string) *expvar.Float {
+	if f, ok := s.vars.Get(name).(*expvar.Float); ok {
+		return f
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f, ok := s.vars.Get(name).(*expvar.Float); ok {
+		return f
+	}
+	f := new(expvar.Float)
+	s.vars.Set(name, f)
+	return f
+}
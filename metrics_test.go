@@ -0,0 +1,136 @@
+package rhmap
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// MetricsSink recording what it is sent
+type recordingSink struct {
+	mu        sync.Mutex
+	counters  map[string]uint64
+	gauges    map[string]float64
+	reports   int
+	durations map[string][]time.Duration
+}
+
+func newRecordingSink() *recordingSink {
+	return &recordingSink{counters: map[string]uint64{}, gauges: map[string]float64{}, durations: map[string][]time.Duration{}}
+}
+
+func (s *recordingSink) Count(name string, delta uint64) {
+	s.mu.Lock()
+	s.counters[name] += delta
+	s.mu.Unlock()
+}
+
+func (s *recordingSink) Gauge(name string, value float64) {
+	s.mu.Lock()
+	s.gauges[name] = value
+	s.reports++
+	s.mu.Unlock()
+}
+
+func (s *recordingSink) Observe(name string, d time.Duration) {
+	s.mu.Lock()
+	s.durations[name] = append(s.durations[name], d)
+	s.mu.Unlock()
+}
+
+func TestWithMetrics(t *testing.T) {
+	sink := newRecordingSink()
+	m := must(New[int, int](WithMetrics(sink)))
+	for i := 0; i < 100; i++ {
+		m.Set(i, i)
+	}
+	for i := 0; i < 150; i++ {
+		m.Get(i)
+	}
+	for i := 0; i < 30; i++ {
+		m.Delete(i)
+	}
+
+	if sink.counters[MetricSets] != 100 || sink.counters[MetricGets] != 150 || sink.counters[MetricDeletes] != 30 {
+		t.Errorf("Every Set, Get and Delete should be counted. Got %v", sink.counters)
+	}
+	if sink.counters[MetricRehashes] == 0 || len(sink.durations[MetricRehashDuration]) != int(sink.counters[MetricRehashes]) {
+		t.Errorf("Every full rehash should be counted and timed. Got %d rehashes, %d timings",
+			sink.counters[MetricRehashes], len(sink.durations[MetricRehashDuration]))
+	}
+	if sink.gauges[MetricCapacity] != float64(m.size) || sink.gauges[MetricLen] == 0 || sink.gauges[MetricLoadFactor] == 0 {
+		t.Errorf("Gauges should report the table's size and load. Got %v", sink.gauges)
+	}
+
+	inc := must(New[int, int](WithMetrics(sink), WithIncrementalRehash(1)))
+	before := sink.counters[MetricRehashes]
+	for i := 0; inc.draining == nil; i++ {
+		inc.Set(i, i)
+	}
+	if sink.counters[MetricRehashes] != before+1 {
+		t.Errorf("Starting an incremental rehash should be counted.")
+	}
+}
+
+func TestMetricsGaugePacing(t *testing.T) {
+	sink := newRecordingSink()
+	m := must(New[int, int](WithMetrics(sink), WithSize(1024)))
+	for i := 0; i < metricsGaugeInterval-1; i++ {
+		m.Set(i, i)
+	}
+	if sink.reports != 0 {
+		t.Errorf("Inserts should not report gauges between intervals. Got %d reports", sink.reports)
+	}
+	m.Set(-1, -1)
+	if sink.reports != 4 || sink.gauges[MetricLen] != metricsGaugeInterval {
+		t.Errorf("Expected one report of 4 gauges after %d operations, Got %d reports of %v",
+			metricsGaugeInterval, sink.reports, sink.gauges)
+	}
+
+	m.GrowTo(4096)
+	if sink.reports != 8 || sink.gauges[MetricCapacity] != float64(m.size) {
+		t.Errorf("A rehash should report the gauges once. Got %d reports of %v", sink.reports, sink.gauges)
+	}
+}
+
+// Runs of TestExpvarSink, which names each run's sink apart since expvar
+// names can't be reused
+var expvarSinkRuns atomic.Int32
+
+func TestExpvarSink(t *testing.T) {
+	name := fmt.Sprintf("rhmap_test_metrics_%d", expvarSinkRuns.Add(1))
+	s := NewExpvarSink(name)
+	m := must(New[int, int](WithMetrics(s)))
+	for i := 0; i < 100; i++ {
+		m.Set(i, i)
+	}
+	vars := expvar.Get(name).(*expvar.Map)
+	if got := vars.Get(MetricSets).(*expvar.Int).Value(); got != 100 {
+		t.Errorf("Sets should be published as a counter. Expected 100, Got %d", got)
+	}
+	if got := vars.Get(MetricLen).(*expvar.Float).Value(); got == 0 {
+		t.Errorf("The element count should be published as a gauge.")
+	}
+	if vars.Get(MetricRehashDuration+"_seconds") == nil {
+		t.Errorf("Rehash timings should be published in seconds.")
+	}
+}
+
+func TestExpvarSinkNamed(t *testing.T) {
+	name := fmt.Sprintf("rhmap_test_named_metrics_%d", expvarSinkRuns.Add(1))
+	s := NewExpvarSink(name)
+	m := must(New[int, int](WithMetrics(s), WithName("sessions"), WithLabels(map[string]string{"region": "eu", "env": "prod"})))
+	for i := 0; i < 10; i++ {
+		m.Set(i, i)
+	}
+	named, ok := expvar.Get(name).(*expvar.Map).Get("sessions{env=prod,region=eu}").(*expvar.Map)
+	if !ok {
+		t.Fatalf("A named map's metrics should be published under its name and labels.")
+	}
+	if got := named.Get(MetricSets).(*expvar.Int).Value(); got != 10 {
+		t.Errorf("Expected 10 sets, Got %d", got)
+	}
+}
@@ -0,0 +1,112 @@
+package rhmap
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+)
+
+// A key Get often failed to find, and an upper bound on how many times it
+// missed
+type MissCount struct {
+	Key   any
+	Count uint64
+}
+
+type missCandidate[K comparable] struct {
+	key   K
+	count uint32
+}
+
+// Tracks the keys Get misses most often in fixed memory: a Count-Min sketch
+// estimates every missed key's count, and the n keys with the highest
+// estimates so far are kept as candidates. Misses happen on lookups, which
+// readers may make concurrently, so it has its own lock.
+type missTracker[K comparable] struct {
+	mu         sync.Mutex
+	sketch     CountMinSketch[K]
+	candidates []missCandidate[K]
+	n          int
+	total      uint64
+}
+
+// Sketch rows, and counters per row for each tracked key
+const (
+	missSketchDepth = 4
+	missSketchWidth = 64
+)
+
+func newMissTracker[K comparable](n int) *missTracker[K] {
+	width := max(1024, missSketchWidth*n)
+	return &missTracker[K]{
+		sketch: CountMinSketch[K]{
+			counters: make([]uint32, width*missSketchDepth),
+			width:    uint64(width),
+			depth:    missSketchDepth,
+		},
+		n: n,
+	}
+}
+
+// Records a miss on key, which hashes to hash
+func (t *missTracker[K]) add(key K, hash uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.total++
+	t.sketch.addHash(hash, 1)
+	count := t.sketch.estimateHash(hash)
+
+	coldest := 0
+	for i := range t.candidates {
+		if t.candidates[i].key == key {
+			t.candidates[i].count = count
+			return
+		}
+		if t.candidates[i].count < t.candidates[coldest].count {
+			coldest = i
+		}
+	}
+	if len(t.candidates) < t.n {
+		t.candidates = append(t.candidates, missCandidate[K]{key, count})
+	} else if count > t.candidates[coldest].count {
+		t.candidates[coldest] = missCandidate[K]{key, count}
+	}
+}
+
+// Returns the total misses and the candidates, most missed first
+func (t *missTracker[K]) top() (uint64, []MissCount) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	top := make([]MissCount, len(t.candidates))
+	for i, c := range t.candidates {
+		top[i] = MissCount{c.key, uint64(c.count)}
+	}
+	slices.SortStableFunc(top, func(a, b MissCount) int {
+		return cmp.Compare(b.Count, a.Count)
+	})
+	return t.total, top
+}
+
+// Forgets every miss, in place since the map's registry entry reads the
+// tracker
+func (t *missTracker[K]) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	clear(t.sketch.counters)
+	clear(t.candidates)
+	t.candidates = t.candidates[:0]
+	t.total = 0
+}
+
+func (t *missTracker[K]) clone() *missTracker[K] {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c := &missTracker[K]{
+		sketch:     t.sketch,
+		candidates: slices.Clone(t.candidates),
+		n:          t.n,
+		total:      t.total,
+	}
+	c.sketch.counters = slices.Clone(t.sketch.counters)
+	return c
+}
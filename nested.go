@@ -0,0 +1,93 @@
+package rhmap
+
+// Two-level map grouping values by an outer and an inner key. Inner maps are
+// created on the first Set under an outer key and dropped once their last
+// element is deleted.
+type Nested[K1, K2 comparable, V any] struct {
+	outer       *Map[K1, *Map[K2, V]]
+	innerEnc    keyEncoder[K2]
+	opts        []Option
+	numElements uint64
+}
+
+// Creates a nested map whose inner maps are configured by opts. It returns
+// an error if either key type can't be encoded, as New does.
+func NewNested[K1, K2 comparable, V any](opts ...Option) (*Nested[K1, K2, V], error) {
+	outer, err := New[K1, *Map[K2, V]]()
+	if err != nil {
+		return nil, err
+	}
+	innerEnc, err := newKeyEncoder[K2]()
+	if err != nil {
+		return nil, err
+	}
+	return &Nested[K1, K2, V]{outer: outer, innerEnc: innerEnc, opts: opts}, nil
+}
+
+func (n *Nested[K1, K2, V]) Set(k1 K1, k2 K2, value V) {
+	inner, ok := n.outer.Get(k1)
+	if !ok {
+		inner = newMap[K2, V](n.innerEnc, n.opts...)
+		n.outer.Set(k1, inner)
+	}
+
+	before := inner.Len()
+	inner.Set(k2, value)
+	n.numElements = n.numElements - before + inner.Len()
+	n.dropIfEmpty(k1, inner)
+}
+
+func (n *Nested[K1, K2, V]) Get(k1 K1, k2 K2) (V, bool) {
+	inner, ok := n.outer.Get(k1)
+	if !ok {
+		var zeroVal V
+		return zeroVal, false
+	}
+	return inner.Get(k2)
+}
+
+func (n *Nested[K1, K2, V]) Delete(k1 K1, k2 K2) {
+	inner, ok := n.outer.Get(k1)
+	if !ok {
+		return
+	}
+
+	before := inner.Len()
+	inner.Delete(k2)
+	n.numElements -= before - inner.Len()
+	n.dropIfEmpty(k1, inner)
+}
+
+// Returns the inner map under k1, if any. It remains owned by the nested
+// map and must not be modified.
+func (n *Nested[K1, K2, V]) Group(k1 K1) (*Map[K2, V], bool) {
+	return n.outer.Get(k1)
+}
+
+// Returns the number of values across all inner maps
+func (n *Nested[K1, K2, V]) Len() uint64 {
+	return n.numElements
+}
+
+// Returns the number of outer keys with at least one value
+func (n *Nested[K1, K2, V]) Groups() uint64 {
+	return n.outer.Len()
+}
+
+// Calls fn for every value, stopping early if fn returns false
+func (n *Nested[K1, K2, V]) Range(fn func(K1, K2, V) bool) {
+	for k1, inner := range n.outer.All() {
+		for k2, v := range inner.All() {
+			if !fn(k1, k2, v) {
+				return
+			}
+		}
+	}
+}
+
+// Removes an inner map left empty, for example by a zero-deleting Set
+func (n *Nested[K1, K2, V]) dropIfEmpty(k1 K1, inner *Map[K2, V]) {
+	if inner.Len() == 0 {
+		n.outer.Delete(k1)
+	}
+}
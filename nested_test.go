@@ -0,0 +1,59 @@
+package rhmap
+
+import "testing"
+
+func TestNested(t *testing.T) {
+	n := must(NewNested[string, int, int]())
+
+	for g, group := range []string{"a", "b", "c"} {
+		for i := 0; i < 100; i++ {
+			n.Set(group, i, g*100+i)
+		}
+	}
+	n.Set("a", 5, -5)
+
+	if n.Len() != 300 || n.Groups() != 3 {
+		t.Errorf("Map should hold 300 values in 3 groups. Found %d in %d", n.Len(), n.Groups())
+	}
+	if val, ok := n.Get("a", 5); !ok || val != -5 {
+		t.Errorf("Key a/5 should map to -5. Got %d, %t", val, ok)
+	}
+	if val, ok := n.Get("c", 99); !ok || val != 299 {
+		t.Errorf("Key c/99 should map to 299. Got %d, %t", val, ok)
+	}
+	if _, ok := n.Get("d", 0); ok {
+		t.Errorf("Missing outer key should not be found.")
+	}
+	if inner, ok := n.Group("b"); !ok || inner.Len() != 100 {
+		t.Errorf("Group b should hold 100 values.")
+	}
+
+	for i := 0; i < 100; i++ {
+		n.Delete("b", i)
+	}
+	n.Delete("d", 0)
+	if n.Len() != 200 || n.Groups() != 2 {
+		t.Errorf("Emptied group should be dropped. Found %d values in %d groups", n.Len(), n.Groups())
+	}
+
+	count := 0
+	n.Range(func(k1 string, k2, v int) bool {
+		if k1 == "b" {
+			t.Errorf("Range should not visit the deleted group.")
+		}
+		count++
+		return true
+	})
+	if count != 200 {
+		t.Errorf("Range should visit 200 values. Visited %d", count)
+	}
+}
+
+func TestNestedZeroDeletes(t *testing.T) {
+	n := must(NewNested[int, int, int](WithZeroDeletes()))
+	n.Set(1, 1, 1)
+	n.Set(1, 1, 0)
+	if n.Len() != 0 || n.Groups() != 0 {
+		t.Errorf("Setting the only value to zero should drop its group. Found %d values in %d groups", n.Len(), n.Groups())
+	}
+}
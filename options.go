@@ -0,0 +1,434 @@
+package rhmap
+
+import (
+	cryptorand "crypto/rand"
+	"encoding/binary"
+	"maps"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Option configures a map at construction
+type Option func(*options)
+
+type options struct {
+	size   uint64
+	hasher Hasher
+	seeded bool
+	k0     uint64
+	k1     uint64
+
+	fastRange   bool
+	zeroDeletes bool
+	name        string
+	labels      map[string]string
+	clock       Clock
+	sliding     bool
+	// Under Memoize, how long results are kept and the fraction of that
+	// after which they are refreshed
+	resultTTL    time.Duration
+	refreshAhead float64
+	shrinkLoad   float32
+	rehashStep   uint64
+	grouped      bool
+	bulk         int
+	loadFactor   float32
+	pslGrowth    uint
+	cryptoSeeds  bool
+
+	tenantQuotas map[string]uint64
+	tenantEvict  bool
+	borrowKeys   bool
+	// A func(K, V), typed when the map is created
+	onEvict  any
+	onFlood  func(FloodReport)
+	keyspace bool
+	misses   int
+	latency  bool
+	// Half-life of WithHitRatio, or 0
+	hitHalfLife time.Duration
+	failure     FailurePolicy
+	metrics     MetricsSink
+
+	maxEntries uint64
+	eviction   EvictionPolicy
+	// A func() Evictor[K], typed when the map is created
+	evictor    any
+	admission  bool
+	evictBatch int
+	asyncEvict bool
+
+	growthFactor float64
+	growthPolicy GrowthPolicy
+
+	arena  bool
+	hooks  *Hooks
+	lookup LookupStrategy
+
+	softWindow   time.Duration
+	softCapacity int
+}
+
+// Returns the hasher and seeds the options select, defaulting to SipHash
+// with random seeds
+func (o options) hashing() (Hasher, uint64, uint64) {
+	hasher := o.hasher
+	if hasher == nil {
+		hasher = SipHasher{}
+	}
+	if !o.seeded {
+		k0, k1 := randomSeeds(o.cryptoSeeds)
+		return hasher, k0, k1
+	}
+	return hasher, o.k0, o.k1
+}
+
+// Draws a pair of random seeds, from crypto/rand if crypto is set, or the
+// next pair from the package's seed stream under SetReproducible
+func randomSeeds(crypto bool) (uint64, uint64) {
+	reproducible.Lock()
+	defer reproducible.Unlock()
+	if reproducible.on {
+		return splitmix64(&reproducible.state), splitmix64(&reproducible.state)
+	}
+
+	if !crypto {
+		return rand.Uint64(), rand.Uint64()
+	}
+	var b [16]byte
+	cryptorand.Read(b[:])
+	return binary.LittleEndian.Uint64(b[:]), binary.LittleEndian.Uint64(b[8:])
+}
+
+// Applies the default profile's options and then opts to fresh options, for
+// constructors that need to read them before creating their maps
+func resolveOptions(opts []Option) options {
+	var o options
+	for _, opt := range Profile(defaultProfile.Load()).options() {
+		opt(&o)
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// Sets the initial number of slots in the table, rounded up to a power of two
+func WithSize(size uint64) Option {
+	return func(o *options) {
+		o.size = size
+	}
+}
+
+// Makes the new map hash keys with the same seeds and hasher as other, so a
+// fleet of processes can agree on where every key hashes and reproduce each
+// other's shard routing.
+//
+// Random per-map seeds are what keep an attacker from precomputing keys that
+// all collide. Seeds shared this way must be treated as secrets: anyone who
+// learns them can degrade every map using them to linear probing, so only
+// share them among trusted processes and never derive them from client input.
+func WithSeedsFrom[K comparable, V any](other *Map[K, V]) Option {
+	return func(o *options) {
+		o.seeded = true
+		o.k0, o.k1 = other.ExportSeeds()
+		o.hasher = other.hasher
+	}
+}
+
+// Maps hashes to slots by their high bits, with Lemire's multiply-shift range
+// reduction, instead of masking their low bits. This suits custom hashers
+// whose low bits are poorly distributed.
+func WithFastRange() Option {
+	return func(o *options) {
+		o.fastRange = true
+	}
+}
+
+// Makes setting a key to the zero value of V delete it instead, so sparse
+// maps such as counters don't accumulate zero entries as values return to zero
+func WithZeroDeletes() Option {
+	return func(o *options) {
+		o.zeroDeletes = true
+	}
+}
+
+// Makes the map fully reproducible for deterministic simulation testing: its
+// seeds are derived from seed instead of drawn at random, so the same
+// sequence of operations always produces the same layout and iteration order
+func WithDeterministic(seed uint64) Option {
+	return func(o *options) {
+		o.seeded = true
+		o.k0 = splitmix64(&seed)
+		o.k1 = splitmix64(&seed)
+	}
+}
+
+// Makes the new map hash keys with the seeds k0 and k1, so its layout and
+// iteration order are the same on every run. Like WithDeterministic, this
+// turns off reseeding on floods; see WithSeedsFrom before exposing the seeds.
+func WithSeed(k0, k1 uint64) Option {
+	return func(o *options) {
+		o.seeded = true
+		o.k0, o.k1 = k0, k1
+	}
+}
+
+var reproducible struct {
+	sync.Mutex
+	on    bool
+	state uint64
+}
+
+// Makes every map, map variant and sketch created afterwards without
+// explicit seeds draw them in turn from a SplitMix64 stream starting at
+// seed, instead of at random, including the new seeds of a reseed. A program
+// or test that creates its maps in the same order then sees the same layouts
+// and iteration orders on every run, which golden tests can rely on. It
+// overrides WithCryptoSeeds, so it must never be called in production.
+// MapHasher draws its own seed and stays random.
+func SetReproducible(seed uint64) {
+	reproducible.Lock()
+	reproducible.on, reproducible.state = true, seed
+	reproducible.Unlock()
+}
+
+// Restores random seeds for maps created afterwards
+func ClearReproducible() {
+	reproducible.Lock()
+	reproducible.on = false
+	reproducible.Unlock()
+}
+
+// Advances state and returns the next SplitMix64 output
+func splitmix64(state *uint64) uint64 {
+	*state += 0x9e3779b97f4a7c15
+	z := *state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// Names the map and lists it in the process-wide Registry. Metrics sinks
+// implementing NamedSink attach the name to the map's metrics.
+func WithName(name string) Option {
+	return func(o *options) {
+		o.name = name
+	}
+}
+
+// Attaches key/value labels to a named map, reported alongside its name by
+// Registry and NamedSink metrics sinks so that services with many maps can
+// tell them apart. Later calls add to the labels of earlier ones.
+func WithLabels(labels map[string]string) Option {
+	return func(o *options) {
+		if o.labels == nil {
+			o.labels = make(map[string]string, len(labels))
+		}
+		maps.Copy(o.labels, labels)
+	}
+}
+
+// Sets the hasher the map hashes encoded keys with. The default is
+// SipHasher; see the other built-in hashers for faster alternatives when
+// keys aren't attacker-controlled.
+func WithHasher(h Hasher) Option {
+	return func(o *options) {
+		o.hasher = h
+	}
+}
+
+// Sets the clock time-based maps read the time from and create timers with.
+// The default is the real clock.
+func WithClock(c Clock) Option {
+	return func(o *options) {
+		o.clock = c
+	}
+}
+
+// Makes an ExpiringMap restart an element's TTL whenever Get finds it, so
+// that elements in use never expire, as sessions shouldn't. Get then takes
+// the map's write lock.
+func WithSlidingExpiration() Option {
+	return func(o *options) {
+		o.sliding = true
+	}
+}
+
+// Makes deletes halve the table whenever its load drops below lowWater,
+// releasing memory after mass deletions. lowWater is capped at a quarter of
+// the load factor so that a shrink can't be undone by the next few inserts.
+// The table never shrinks below its initial size.
+func WithShrink(lowWater float32) Option {
+	return func(o *options) {
+		o.shrinkLoad = lowWater
+	}
+}
+
+// Default number of old slots migrated per write under WithIncrementalRehash
+const defaultRehashStep = 64
+
+// Spreads each grow over the writes that follow it instead of rebuilding the
+// whole table in one Set, bounding the pause a single write can take on a
+// large map. A grow allocates the new table, and every later write migrates
+// step slots of the old one, or 64 if step is 0; lookups check both tables
+// until the migration completes. Operations on the whole table, such as
+// DeleteFunc or GrowTo, finish the migration first.
+func WithIncrementalRehash(step int) Option {
+	return func(o *options) {
+		o.rehashStep = defaultRehashStep
+		if step > 0 {
+			o.rehashStep = uint64(step)
+		}
+	}
+}
+
+// Keeps a control byte per slot holding seven bits of its key's hash, so
+// lookups compare a group of eight slots per word-wide operation, as in
+// SwissTable, instead of loading each element of the probe window. It costs
+// a byte per slot. Misses gain the most, since they rarely load an element
+// at all; see BenchmarkGroupProbing.
+func WithGroupProbing() Option {
+	return func(o *options) {
+		o.grouped = true
+	}
+}
+
+// Default smallest SetMany batch inserted in table order
+const defaultBulkThreshold = 1 << 14
+
+// Sets the smallest SetMany batch that is bucketed by home slot and inserted
+// in table order, 16384 by default. Ordering costs two passes over the batch
+// and pays off once the table no longer fits in cache; n of 0 or less turns
+// it off.
+func WithBulkThreshold(n int) Option {
+	return func(o *options) {
+		o.bulk = n
+		if n <= 0 {
+			o.bulk = math.MaxInt
+		}
+	}
+}
+
+// Sets the load at which the table grows, .9 by default. Lower factors
+// shorten probes at the cost of memory. Factors outside (0, .95] are ignored.
+func WithLoadFactor(lf float32) Option {
+	return func(o *options) {
+		if lf > 0 && lf <= .95 {
+			o.loadFactor = lf
+		}
+	}
+}
+
+// Grows the table early once an insert leaves an element more than limit
+// slots from its home, bounding probe lengths on unlucky key sets. Tables
+// less than half as full as the load factor allows never grow early, so a
+// flood of colliding keys can't grow the table without bound. A limit of 0
+// grows on load alone, which is the default.
+func WithPslGrowth(limit uint) Option {
+	return func(o *options) {
+		o.pslGrowth = limit
+	}
+}
+
+// Draws the map's random seeds from crypto/rand instead of math/rand,
+// including the new seeds of a reseed after a flood
+func WithCryptoSeeds() Option {
+	return func(o *options) {
+		o.cryptoSeeds = true
+	}
+}
+
+// Caps the number of elements a TenantMap charges to tenant at maxEntries.
+// Sets beyond it fail with a *QuotaError, or with WithTenantEviction evict
+// the tenant's least recently used entries. A maxEntries of 0 is ignored.
+func WithTenantQuota(tenant string, maxEntries uint64) Option {
+	return func(o *options) {
+		if maxEntries == 0 {
+			return
+		}
+		if o.tenantQuotas == nil {
+			o.tenantQuotas = make(map[string]uint64)
+		}
+		o.tenantQuotas[tenant] = maxEntries
+	}
+}
+
+// Makes a TenantMap evict an over-quota tenant's least recently used entries
+// to make room for its new ones, instead of refusing them, so a noisy tenant
+// of a shared cache churns its own entries rather than squeezing out others
+func WithTenantEviction() Option {
+	return func(o *options) {
+		o.tenantEvict = true
+	}
+}
+
+// Makes a BytesMap store new keys by reference instead of copying them,
+// saving an allocation per insert. The caller must not modify a key's bytes
+// while it is in the map, as parsers holding an immutable input buffer can
+// guarantee.
+func WithBorrowedKeys() Option {
+	return func(o *options) {
+		o.borrowKeys = true
+	}
+}
+
+// Calls fn with every element removed from the map by Delete, DeleteAll,
+// DeleteFunc, Compute, a zero-deleting Set or Clear, so that values holding
+// resources such as file handles or pooled buffers can be released. It also
+// applies to ConcurrentMap, SegmentedMap, SortedMap and JournaledMap, to
+// elements an ExpiringMap deletes or reclaims after they expire, and to
+// elements an LRU or TenantMap deletes or evicts for capacity. Replacing a
+// value doesn't count as a removal. fn runs once the map is consistent
+// again, but under the lock of a concurrent map, so it must not call back
+// into that map.
+func WithOnEvict[K comparable, V any](fn func(K, V)) Option {
+	return func(o *options) {
+		o.onEvict = fn
+	}
+}
+
+// Returns the WithOnEvict callback if it was given for elements of type K
+// and V, and nil otherwise, so that wrappers whose tables hold other types
+// don't receive it
+func evictHook[K comparable, V any](o options) func(K, V) {
+	fn, _ := o.onEvict.(func(K, V))
+	return fn
+}
+
+// Calls fn whenever the map suspects it is being flooded with colliding keys:
+// when its probes grow long enough to trigger a reseed, or its max PSL grows
+// far faster than random keys make it grow. Reports are rare for honest
+// traffic, so security teams can alert on them as attempted HashDoS. fn runs
+// on the inserting goroutine, after the insert.
+func WithOnSuspectedFlooding(fn func(FloodReport)) Option {
+	return func(o *options) {
+		o.onFlood = fn
+	}
+}
+
+// Makes the map estimate how many distinct keys it has ever inserted, as
+// Stats.DistinctKeys, so operators can tell heavy churn over a small
+// keyspace from a keyspace that keeps growing when sizing maps. It costs
+// 4KB per map and a few operations per insert. Keys are recognized by the
+// hashes they are stored with, so after the map reseeds or changes hasher
+// its current keys may be counted again.
+func WithKeyspaceStats() Option {
+	return func(o *options) {
+		o.keyspace = true
+	}
+}
+
+// Makes the map track the n keys its Get calls miss most often, reported by
+// Stats.TopMisses, so cache owners can see which keys thrash and fix their
+// loaders or TTLs. Counts are estimated with a Count-Min sketch of 1KB per
+// tracked key, 16KB at least, and may overcount slightly. Misses are
+// recorded under a lock of their own, so concurrent readers stay safe but
+// contend on missing lookups.
+func WithMissStats(n int) Option {
+	return func(o *options) {
+		o.misses = n
+	}
+}
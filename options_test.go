@@ -0,0 +1,279 @@
+package rhmap
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestWithSize(t *testing.T) {
+	m := must(New[int, int](WithSize(100)))
+	if m.size != 128 {
+		t.Errorf("WithSize(100) should round up to 128 slots. Found %d", m.size)
+	}
+	if m := must(New[int, int](WithSize(64))); m.size != 64 {
+		t.Errorf("WithSize(64) should keep 64 slots. Found %d", m.size)
+	}
+}
+
+func TestWithSeedsFrom(t *testing.T) {
+	base := must(New[string, int]())
+	m := must(New[string, int](WithSeedsFrom(base)))
+
+	k0, k1 := base.ExportSeeds()
+	m0, m1 := m.ExportSeeds()
+	if k0 != m0 || k1 != m1 {
+		t.Errorf("Seeds should be shared. Base has (%d, %d), map has (%d, %d)", k0, k1, m0, m1)
+	}
+
+	for _, key := range []string{"a", "b", "shard-key"} {
+		if base.getIndexOfKeyAtPsl(key, 0) != m.getIndexOfKeyAtPsl(key, 0) {
+			t.Errorf("Key %q should hash to the same slot in both maps.", key)
+		}
+	}
+}
+
+func TestWithFastRange(t *testing.T) {
+	m := must(New[int, int](WithFastRange(), WithSize(1000)))
+
+	for i := 0; i < 5000; i++ {
+		m.Set(i, i)
+	}
+	for i := 0; i < 5000; i++ {
+		if val, ok := m.Get(i); !ok || val != i {
+			t.Errorf("Key %d should map to %d. Got %d, %t", i, i, val, ok)
+		}
+	}
+}
+
+func TestFastRangeDistribution(t *testing.T) {
+	const buckets, samples = 1024, 200000
+	m := must(New[int, int](WithFastRange(), WithSize(buckets)))
+
+	counts := make([]float64, buckets)
+	for i := 0; i < samples; i++ {
+		counts[m.getIndexOfKeyAtPsl(i, 0)]++
+	}
+
+	// Chi-squared with 1023 degrees of freedom has mean 1023 and standard
+	// deviation ~45.2, so 6 standard deviations leaves ample slack.
+	expected := float64(samples) / buckets
+	var chi2 float64
+	for _, c := range counts {
+		chi2 += (c - expected) * (c - expected) / expected
+	}
+	if limit := buckets - 1 + 6*math.Sqrt(2*(buckets-1)); chi2 > limit {
+		t.Errorf("Slot distribution is not uniform: chi-squared %.1f exceeds %.1f", chi2, limit)
+	}
+}
+
+func TestWithZeroDeletes(t *testing.T) {
+	m := must(New[string, int](WithZeroDeletes()))
+
+	m.Set("a", 2)
+	m.Set("b", 1)
+	m.Set("a", 0)
+	m.Set("c", 0)
+
+	if m.Len() != 1 {
+		t.Errorf("Map should only contain key 'b'. Found %d elements", m.Len())
+	}
+	if _, ok := m.Get("a"); ok {
+		t.Error("Setting key 'a' to zero should delete it.")
+	}
+	if _, ok := m.Get("c"); ok {
+		t.Error("Setting missing key 'c' to zero should not insert it.")
+	}
+}
+
+func TestWithDeterministic(t *testing.T) {
+	run := func(seed uint64) []int {
+		m := must(New[int, int](WithDeterministic(seed)))
+		for i := 0; i < 100; i++ {
+			m.Set(i*7, i)
+		}
+		for i := 0; i < 100; i += 3 {
+			m.Delete(i * 7)
+		}
+		var keys []int
+		for k := range m.SnapshotIter() {
+			keys = append(keys, k)
+		}
+		return keys
+	}
+
+	a, b := run(42), run(42)
+	if len(a) != len(b) {
+		t.Fatalf("Runs with the same seed should produce the same elements. Got %d and %d", len(a), len(b))
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("Runs with the same seed should iterate in the same order. Differ at %d: %d vs %d", i, a[i], b[i])
+		}
+	}
+
+	a0, a1 := must(New[int, int](WithDeterministic(1))).ExportSeeds()
+	b0, b1 := must(New[int, int](WithDeterministic(2))).ExportSeeds()
+	if a0 == b0 && a1 == b1 {
+		t.Error("Different deterministic seeds should produce different hash seeds.")
+	}
+}
+
+func TestWithLoadFactor(t *testing.T) {
+	m := must(New[int, int](WithLoadFactor(.5), WithShrink(.2)))
+	for i := 0; i < 1000; i++ {
+		m.Set(i, i)
+	}
+	if load := float64(m.Len()) / float64(m.size); load >= .5 {
+		t.Errorf("The table should grow at half full. Load is %f", load)
+	}
+	if m.shrinkLoad != .125 {
+		t.Errorf("The shrink threshold should be capped at a quarter of the load factor. Got %f", m.shrinkLoad)
+	}
+
+	for _, lf := range []float32{0, -1, .99, 2} {
+		if got := must(New[int, int](WithLoadFactor(lf))).loadFactor; got != defaultLoadFactor {
+			t.Errorf("Load factor %f should be ignored. Got %f", lf, got)
+		}
+	}
+}
+
+func TestWithPslGrowth(t *testing.T) {
+	m := must(New[int, int](WithPslGrowth(4)))
+	grewEarly := false
+	for i := 0; i < 10000; i++ {
+		size, load := m.size, float32(float64(m.Len())/float64(m.size))
+		due := m.maxPsl > 4 && load >= defaultLoadFactor/2
+		m.Set(i, i)
+		if due != (m.size > size) && load < defaultLoadFactor {
+			t.Fatalf("The table should grow exactly when a probe passed the limit. Max PSL %d at load %f", m.maxPsl, load)
+		}
+		grewEarly = grewEarly || due
+	}
+	if !grewEarly {
+		t.Errorf("Random keys should pass a PSL limit of 4 at some point.")
+	}
+	if err := m.Validate(); err != nil {
+		t.Errorf("The map should stay valid when growing early. Got %v", err)
+	}
+
+	// Colliding keys grow the table no more than halfway below the load factor
+	c := must(New[int, int](WithPslGrowth(4), WithHasher(CollidingHasher(SipHasher{}, 1, CollideBucket))))
+	for i := 0; i < 100; i++ {
+		c.Set(i, i)
+	}
+	if c.size > 256 {
+		t.Errorf("A flood should not grow the table without bound. Got %d slots for 100 keys", c.size)
+	}
+}
+
+func TestWithSeed(t *testing.T) {
+	a := must(New[int, int](WithSeed(1, 2)))
+	b := must(New[int, int](WithSeed(1, 2)))
+	if k0, k1 := a.ExportSeeds(); k0 != 1 || k1 != 2 {
+		t.Errorf("The map should hash with the given seeds. Got %d, %d", k0, k1)
+	}
+	for i := 0; i < 100; i++ {
+		a.Set(i, i)
+		b.Set(i, i)
+	}
+	if !slices.Equal(slices.Collect(a.Keys()), slices.Collect(b.Keys())) {
+		t.Errorf("Maps with the same seeds should iterate in the same order.")
+	}
+	if a.autoReseed {
+		t.Errorf("Maps with explicit seeds should not reseed themselves.")
+	}
+}
+
+func TestSetReproducible(t *testing.T) {
+	t.Cleanup(ClearReproducible)
+
+	run := func() ([]int, []uint64) {
+		SetReproducible(7)
+		m := must(New[int, int]())
+		s := must(NewSet[int]())
+		for i := 0; i < 100; i++ {
+			m.Set(i, i)
+			s.Add(i)
+		}
+		k0, k1 := s.table.ExportSeeds()
+		return slices.Collect(m.Keys()), []uint64{k0, k1}
+	}
+	keysA, seedsA := run()
+	keysB, seedsB := run()
+	if !slices.Equal(keysA, keysB) || !slices.Equal(seedsA, seedsB) {
+		t.Errorf("Maps created in the same order should get the same seeds on every run.")
+	}
+	if m0, _ := must(New[int, int]()).ExportSeeds(); m0 == seedsA[0] {
+		t.Errorf("Each map should draw the next seeds from the stream.")
+	}
+
+	ClearReproducible()
+	a0, a1 := must(New[int, int]()).ExportSeeds()
+	b0, b1 := must(New[int, int]()).ExportSeeds()
+	if a0 == b0 && a1 == b1 {
+		t.Errorf("Seeds should be random again once cleared.")
+	}
+}
+
+func TestWithOnEvict(t *testing.T) {
+	var evicted []int
+	onEvict := WithOnEvict(func(k, v int) { evicted = append(evicted, k*100+v) })
+	expect := func(what string, want ...int) {
+		t.Helper()
+		slices.Sort(evicted)
+		if !slices.Equal(evicted, want) {
+			t.Errorf("%s should pass the removed elements to the callback. Expected %v, Got %v", what, want, evicted)
+		}
+		evicted = nil
+	}
+
+	m := must(New[int, int](onEvict, WithZeroDeletes()))
+	for i := 1; i <= 9; i++ {
+		m.Set(i, i)
+	}
+	m.Set(1, 2)
+	expect("Replacing a value")
+	m.Delete(1)
+	m.Delete(1)
+	expect("Delete", 102)
+	m.DeleteAll([]int{2, 3, 3, 42})
+	expect("DeleteAll", 202, 303)
+	m.DeleteFunc(func(k, v int) bool { return k == 4 })
+	expect("DeleteFunc", 404)
+	m.Compute(5, func(int, bool) (int, bool) { return 0, false })
+	expect("Compute", 505)
+	m.Set(6, 0)
+	expect("A zero-deleting Set", 606)
+	m.Clear()
+	expect("Clear", 707, 808, 909)
+	if m.Len() != 0 || m.Validate() != nil {
+		t.Errorf("Clear should leave a valid empty map. Found %d elements", m.Len())
+	}
+
+	clock := newFakeClock()
+	e := must(NewExpiring[int, int](time.Second, WithClock(clock), onEvict))
+	e.Set(1, 1)
+	e.Set(2, 2)
+	e.Delete(1)
+	clock.Advance(time.Second)
+	e.Sweep()
+	expect("ExpiringMap", 101, 202)
+
+	c := must(NewLRU[int, int](1, nil, onEvict))
+	c.Add(1, 1)
+	c.Add(2, 2)
+	c.Remove(2)
+	expect("LRU", 101, 202)
+
+	// An LRU's table of slab indices has the types of an LRU of uint32 values,
+	// but only the LRU itself reports removals
+	var calls []uint32
+	byIndex := must(NewLRU[int, uint32](2, nil, WithOnEvict(func(_ int, v uint32) { calls = append(calls, v) })))
+	byIndex.Add(1, 7)
+	byIndex.Remove(1)
+	if !slices.Equal(calls, []uint32{7}) {
+		t.Errorf("Only the LRU's own value should reach the callback. Got %v", calls)
+	}
+}
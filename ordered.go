@@ -0,0 +1,106 @@
+package rhmap
+
+import "iter"
+
+// Link in an OrderedMap's insertion-order list
+type orderedEntry[K comparable, V any] struct {
+	key        K
+	value      V
+	prev, next *orderedEntry[K, V]
+}
+
+// Robin hood hashmap that remembers insertion order. The table maps each key
+// to a node of a doubly-linked list, so lookups stay O(1) and iteration
+// follows the order keys were first set. Nodes live outside the table because
+// robin hood displacement moves elements between slots, which would
+// invalidate links stored in the slots themselves.
+type OrderedMap[K comparable, V any] struct {
+	table *Map[K, *orderedEntry[K, V]]
+	// Sentinel whose next is the oldest entry and prev the newest
+	root orderedEntry[K, V]
+}
+
+// Creates an ordered map configured by opts; WithZeroDeletes has no effect.
+// It returns an error if K can't be encoded, as New does.
+func NewOrdered[K comparable, V any](opts ...Option) (*OrderedMap[K, V], error) {
+	table, err := New[K, *orderedEntry[K, V]](opts...)
+	if err != nil {
+		return nil, err
+	}
+	table.zeroDeletes = false
+
+	o := &OrderedMap[K, V]{table: table}
+	o.root.prev, o.root.next = &o.root, &o.root
+	return o, nil
+}
+
+// Sets key to value. Updating an existing key keeps its position.
+func (o *OrderedMap[K, V]) Set(key K, value V) {
+	hash := o.table.hashKey(key)
+	if e, ok, _ := o.table.getWithHash(key, hash); ok {
+		e.value = value
+		return
+	}
+
+	e := &orderedEntry[K, V]{key: key, value: value, prev: o.root.prev, next: &o.root}
+	o.root.prev.next = e
+	o.root.prev = e
+	o.table.setWithHash(key, e, hash)
+}
+
+func (o *OrderedMap[K, V]) Get(key K) (V, bool) {
+	e, ok := o.table.Get(key)
+	if !ok {
+		var zeroVal V
+		return zeroVal, false
+	}
+	return e.value, true
+}
+
+func (o *OrderedMap[K, V]) Delete(key K) {
+	hash := o.table.hashKey(key)
+	e, ok, _ := o.table.getWithHash(key, hash)
+	if !ok {
+		return
+	}
+	o.table.deleteWithHash(key, hash)
+	e.prev.next = e.next
+	e.next.prev = e.prev
+}
+
+func (o *OrderedMap[K, V]) Len() uint64 {
+	return o.table.Len()
+}
+
+// Returns the element set longest ago
+func (o *OrderedMap[K, V]) Oldest() (K, V, bool) {
+	return o.at(o.root.next)
+}
+
+// Returns the element set most recently
+func (o *OrderedMap[K, V]) Newest() (K, V, bool) {
+	return o.at(o.root.prev)
+}
+
+// Returns an iterator over every key/value pair in insertion order. Deleting
+// the current element during iteration is safe.
+func (o *OrderedMap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for e := o.root.next; e != &o.root; {
+			next := e.next
+			if !yield(e.key, e.value) {
+				return
+			}
+			e = next
+		}
+	}
+}
+
+func (o *OrderedMap[K, V]) at(e *orderedEntry[K, V]) (K, V, bool) {
+	if e == &o.root {
+		var zeroKey K
+		var zeroVal V
+		return zeroKey, zeroVal, false
+	}
+	return e.key, e.value, true
+}
@@ -0,0 +1,60 @@
+package rhmap
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestOrderedMap(t *testing.T) {
+	o := must(NewOrdered[int, string]())
+	if _, _, ok := o.Oldest(); ok {
+		t.Errorf("Empty map should have no oldest element.")
+	}
+
+	for _, k := range []int{5, 3, 9, 1, 7} {
+		o.Set(k, "v")
+	}
+	o.Set(3, "updated")
+	o.Delete(9)
+	o.Delete(100)
+	o.Set(9, "again")
+
+	var keys []int
+	for k := range o.All() {
+		keys = append(keys, k)
+	}
+	if want := []int{5, 3, 1, 7, 9}; !slices.Equal(keys, want) {
+		t.Errorf("All should follow insertion order. Expected %v, Got %v", want, keys)
+	}
+	if val, ok := o.Get(3); !ok || val != "updated" {
+		t.Errorf("Key 3 should map to updated. Got %q, %t", val, ok)
+	}
+	if k, _, ok := o.Oldest(); !ok || k != 5 {
+		t.Errorf("Oldest key should be 5. Got %d", k)
+	}
+	if k, v, ok := o.Newest(); !ok || k != 9 || v != "again" {
+		t.Errorf("Newest element should be 9 => again. Got %d => %q", k, v)
+	}
+	if o.Len() != 5 {
+		t.Errorf("Map should contain 5 elements. Found %d", o.Len())
+	}
+}
+
+func TestOrderedMapDeleteDuringAll(t *testing.T) {
+	o := must(NewOrdered[int, int]())
+	for i := 0; i < 1000; i++ {
+		o.Set(i, i)
+	}
+
+	next := 0
+	for k := range o.All() {
+		if k != next {
+			t.Fatalf("Expected key %d, Got %d", next, k)
+		}
+		next++
+		o.Delete(k)
+	}
+	if next != 1000 || o.Len() != 0 {
+		t.Errorf("Deleting during All should visit and remove all 1000 keys. Visited %d, %d left", next, o.Len())
+	}
+}
@@ -0,0 +1,133 @@
+package rhmap
+
+import (
+	"cmp"
+	"container/heap"
+	"errors"
+	"slices"
+	"strconv"
+)
+
+// Key/value pair returned by Page
+type Entry[K comparable, V any] struct {
+	Key   K
+	Value V
+}
+
+// Position in a paged enumeration of a map. The zero Cursor starts at the
+// beginning. Cursors implement encoding.TextMarshaler so they can be handed
+// to HTTP clients and sent back on the next request.
+type Cursor struct {
+	after   uint64
+	started bool
+	done    bool
+}
+
+// Reports whether the enumeration the cursor belongs to has finished
+func (c Cursor) Done() bool {
+	return c.done
+}
+
+func (c Cursor) MarshalText() ([]byte, error) {
+	switch {
+	case c.done:
+		return []byte("end"), nil
+	case !c.started:
+		return []byte{}, nil
+	}
+	return strconv.AppendUint(nil, c.after, 16), nil
+}
+
+func (c *Cursor) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "end":
+		*c = Cursor{done: true}
+		return nil
+	case "":
+		*c = Cursor{}
+		return nil
+	}
+	after, err := strconv.ParseUint(string(text), 16, 64)
+	if err != nil {
+		return errors.New("rhmap: invalid cursor")
+	}
+	*c = Cursor{after: after, started: true}
+	return nil
+}
+
+// Returns up to limit entries following cursor and the cursor to pass for
+// the next page. Entries come in increasing hash order rather than table
+// order, so robin hood shifts and rehashes between pages don't disturb the
+// enumeration: every key present for the whole enumeration is returned
+// exactly once, and keys set or deleted part way may or may not be. Keys
+// sharing a full 64-bit hash always land on the same page, which may then
+// exceed limit.
+//
+// Each page scans the whole table but holds only limit entries.
+func (m *Map[K, V]) Page(cursor Cursor, limit int) ([]Entry[K, V], Cursor) {
+	if cursor.done || limit <= 0 {
+		return nil, cursor
+	}
+
+	// Max-heap of the limit smallest hashes past the cursor
+	var page pageHeap[K, V]
+	for _, elements := range m.tables() {
+		for i := range elements {
+			if !elements[i].set {
+				continue
+			}
+			hash := elements[i].hash
+			if cursor.started && hash <= cursor.after {
+				continue
+			}
+			if len(page) < limit {
+				heap.Push(&page, pageSlot[K, V]{hash, &elements[i]})
+			} else if hash < page[0].hash {
+				page[0] = pageSlot[K, V]{hash, &elements[i]}
+				heap.Fix(&page, 0)
+			}
+		}
+	}
+
+	if len(page) < limit {
+		cursor = Cursor{done: true}
+	} else {
+		// Pick up keys tied with the last hash that didn't fit
+		boundary := page[0].hash
+		for _, elements := range m.tables() {
+			for i := range elements {
+				if elements[i].set && elements[i].hash == boundary &&
+					!slices.ContainsFunc(page, func(s pageSlot[K, V]) bool { return s.elem == &elements[i] }) {
+					page = append(page, pageSlot[K, V]{boundary, &elements[i]})
+				}
+			}
+		}
+		cursor = Cursor{after: boundary, started: true}
+	}
+
+	slices.SortFunc(page, func(a, b pageSlot[K, V]) int { return cmp.Compare(a.hash, b.hash) })
+	entries := make([]Entry[K, V], len(page))
+	for i, s := range page {
+		entries[i] = Entry[K, V]{s.elem.key, s.elem.value}
+	}
+	return entries, cursor
+}
+
+type pageSlot[K comparable, V any] struct {
+	hash uint64
+	elem *element[K, V]
+}
+
+type pageHeap[K comparable, V any] []pageSlot[K, V]
+
+func (h pageHeap[K, V]) Len() int           { return len(h) }
+func (h pageHeap[K, V]) Less(i, j int) bool { return h[i].hash > h[j].hash }
+func (h pageHeap[K, V]) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *pageHeap[K, V]) Push(x any)        { *h = append(*h, x.(pageSlot[K, V])) }
+
+func (h *pageHeap[K, V]) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
@@ -0,0 +1,90 @@
+package rhmap
+
+import "testing"
+
+func TestPage(t *testing.T) {
+	m := must(New[int, int]())
+	for i := 0; i < 1100; i++ {
+		m.Set(i, i)
+	}
+
+	seen := make(map[int]int)
+	var cursor Cursor
+	pages := 0
+	for !cursor.Done() {
+		var entries []Entry[int, int]
+		entries, cursor = m.Page(cursor, 64)
+		if len(entries) > 64 {
+			t.Fatalf("Page should hold at most 64 entries. Got %d", len(entries))
+		}
+		for _, e := range entries {
+			if e.Key != e.Value {
+				t.Errorf("Key %d should map to itself. Got %d", e.Key, e.Value)
+			}
+			seen[e.Key]++
+		}
+
+		// Mutate between pages: churn keys and force a rehash
+		m.Delete(1000 + pages)
+		m.Set(2000+pages, 2000+pages)
+		if pages == 5 {
+			m.GrowTo(4096)
+		}
+		pages++
+	}
+
+	for i := 0; i < 1000; i++ {
+		if seen[i] != 1 {
+			t.Errorf("Key %d should be returned exactly once. Returned %d times", i, seen[i])
+		}
+	}
+	if pages < 1000/64 {
+		t.Errorf("1000 keys should take at least %d pages of 64. Took %d", 1000/64, pages)
+	}
+
+	if entries, next := m.Page(cursor, 64); entries != nil || !next.Done() {
+		t.Errorf("A finished cursor should return no entries.")
+	}
+}
+
+func TestPageTiedHashes(t *testing.T) {
+	m := must(New[int, int](WithHasher(CollidingHasher(SipHasher{}, 1, CollideHash))))
+	for i := 0; i < 10; i++ {
+		m.Set(i, i)
+	}
+
+	entries, cursor := m.Page(Cursor{}, 3)
+	if len(entries) != 10 {
+		t.Errorf("Keys sharing a hash should land on one page. Expected 10, Got %d", len(entries))
+	}
+	if entries, cursor = m.Page(cursor, 3); len(entries) != 0 || !cursor.Done() {
+		t.Errorf("Second page should be empty and done. Got %d entries, done %t", len(entries), cursor.Done())
+	}
+}
+
+func TestCursorText(t *testing.T) {
+	m := must(New[int, int]())
+	for i := 0; i < 100; i++ {
+		m.Set(i, i)
+	}
+
+	_, cursor := m.Page(Cursor{}, 10)
+	for _, c := range []Cursor{{}, cursor, {done: true}} {
+		text, err := c.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded Cursor
+		if err := decoded.UnmarshalText(text); err != nil {
+			t.Fatal(err)
+		}
+		if decoded != c {
+			t.Errorf("Cursor should round-trip through %q. Expected %+v, Got %+v", text, c, decoded)
+		}
+	}
+
+	var c Cursor
+	if err := c.UnmarshalText([]byte("zz")); err == nil {
+		t.Errorf("Invalid cursor text should fail to decode.")
+	}
+}
@@ -0,0 +1,172 @@
+package rhmap
+
+import (
+	"math"
+	"runtime/debug"
+	"runtime/metrics"
+	"sync"
+	"time"
+)
+
+// Map a PressureResponder can make give memory back, such as a Map or
+// ConcurrentMap
+type Relievable interface {
+	// Releases what memory it can and returns the bytes of table freed
+	Relieve(fraction float64) uint64
+}
+
+// What a PressureResponder saw when the process reached its ceiling
+type PressureEvent struct {
+	// Bytes the runtime held from the OS, as debug.SetMemoryLimit counts
+	// them, and the ceiling they reached
+	InUse   uint64
+	Ceiling uint64
+}
+
+// Watches the process's memory and, once it reaches a ceiling, makes the
+// maps it watches give memory back before the runtime's memory limit
+// forces the garbage collector to run flat out. Maps shrink tables left
+// over-provisioned by deletes, and bounded maps evict a fraction of their
+// elements as their policy would. Memory is measured as the runtime
+// measures it against debug.SetMemoryLimit.
+type PressureResponder struct {
+	ceiling  uint64
+	fraction float64
+	clock    Clock
+	// Returns the bytes in use, replaced by tests
+	inUse func() uint64
+
+	mu         sync.Mutex
+	targets    []Relievable
+	onPressure func(PressureEvent) bool
+}
+
+// Creates a responder for a ceiling in bytes, or for 90% of the memory
+// limit set by debug.SetMemoryLimit or GOMEMLIMIT if ceiling is 0, making
+// bounded maps evict fraction of their elements each time it is reached.
+// WithClock sets the clock Start ticks by.
+func NewPressureResponder(ceiling uint64, fraction float64, opts ...Option) *PressureResponder {
+	if ceiling == 0 {
+		if limit := debug.SetMemoryLimit(-1); limit < math.MaxInt64 {
+			ceiling = uint64(limit) / 10 * 9
+		} else {
+			ceiling = math.MaxUint64
+		}
+	}
+	clock := resolveOptions(opts).clock
+	if clock == nil {
+		clock = realClock{}
+	}
+	return &PressureResponder{ceiling: ceiling, fraction: min(max(fraction, 0), 1), clock: clock, inUse: memoryInUse}
+}
+
+// Adds a map to those relieved under pressure
+func (r *PressureResponder) Watch(target Relievable) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.targets = append(r.targets, target)
+}
+
+// Sets fn to be called each time the ceiling is reached, before any map is
+// relieved, so a host can coordinate with other caches or shed load. If fn
+// returns false, the maps are left alone that time.
+func (r *PressureResponder) OnPressure(fn func(PressureEvent) bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onPressure = fn
+}
+
+// Reads the memory in use and, if it has reached the ceiling, relieves
+// every watched map in turn. It returns the bytes of table freed.
+func (r *PressureResponder) Check() uint64 {
+	inUse := r.inUse()
+	if inUse < r.ceiling {
+		return 0
+	}
+	r.mu.Lock()
+	targets, onPressure := r.targets, r.onPressure
+	r.mu.Unlock()
+
+	if onPressure != nil && !onPressure(PressureEvent{InUse: inUse, Ceiling: r.ceiling}) {
+		return 0
+	}
+	var freed uint64
+	for _, t := range targets {
+		freed += t.Relieve(r.fraction)
+	}
+	return freed
+}
+
+// Starts a goroutine that calls Check every interval until the returned
+// function is called
+func (r *PressureResponder) Start(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		for {
+			timer := r.clock.NewTimer(interval)
+			select {
+			case <-timer.C():
+				r.Check()
+			case <-done:
+				timer.Stop()
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-stopped
+		})
+	}
+}
+
+// Returns the bytes of memory the runtime holds from the OS, the total the
+// memory limit applies to
+func memoryInUse() uint64 {
+	samples := []metrics.Sample{
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/memory/classes/heap/released:bytes"},
+	}
+	metrics.Read(samples)
+	return samples[0].Value.Uint64() - samples[1].Value.Uint64()
+}
+
+// Shrinks the table to fit and, if the map is bounded by an evicting
+// policy, first evicts fraction of its elements as the policy would, for a
+// PressureResponder. It returns the bytes of table freed.
+func (m *Map[K, V]) Relieve(fraction float64) uint64 {
+	if m.rejectsWrites() {
+		return 0
+	}
+	before := m.MemoryFootprint()
+	if m.maxEntries > 0 {
+		for n := uint64(math.Ceil(fraction * float64(m.numElements))); n > 0; n-- {
+			victim, hash, ok := m.nextVictim()
+			if !ok {
+				break
+			}
+			m.removeWithHash(victim, hash)
+			m.evictions++
+		}
+	}
+	m.ShrinkToFit()
+	return before - min(m.MemoryFootprint(), before)
+}
+
+// Relieves every shard as Map.Relieve does, one at a time under its lock
+func (c *ConcurrentMap[K, V]) Relieve(fraction float64) uint64 {
+	var freed uint64
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		freed += s.table.Relieve(fraction)
+		s.mu.Unlock()
+	}
+	return freed
+}
@@ -0,0 +1,84 @@
+package rhmap
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPressureResponder(t *testing.T) {
+	if memoryInUse() == 0 {
+		t.Errorf("The runtime should report memory in use.")
+	}
+	inUse := uint64(0)
+	r := NewPressureResponder(1000, 0.5)
+	r.inUse = func() uint64 { return inUse }
+
+	sparse := must(New[int, int]())
+	for i := 0; i < 10000; i++ {
+		sparse.Set(i, i)
+	}
+	for i := 10; i < 10000; i++ {
+		sparse.Delete(i)
+	}
+	bounded := must(New[int, int](WithMaxEntries(100), WithEvictionPolicy(EvictLRU)))
+	for i := 0; i < 100; i++ {
+		bounded.Set(i, i)
+	}
+	r.Watch(sparse)
+	r.Watch(bounded)
+
+	if freed := r.Check(); freed != 0 || sparse.Len() != 10 || bounded.Len() != 100 {
+		t.Errorf("Nothing should be relieved below the ceiling. Got %d bytes freed", freed)
+	}
+
+	inUse = 1000
+	var events []PressureEvent
+	r.OnPressure(func(e PressureEvent) bool {
+		events = append(events, e)
+		return len(events) > 1
+	})
+	if freed := r.Check(); freed != 0 || len(events) != 1 || events[0] != (PressureEvent{InUse: 1000, Ceiling: 1000}) {
+		t.Errorf("The hook should be able to hold off relief. Got %d bytes freed and %v", freed, events)
+	}
+
+	size := sparse.size
+	if freed := r.Check(); freed == 0 || len(events) != 2 {
+		t.Errorf("Reaching the ceiling should free memory.")
+	}
+	if sparse.Len() != 10 || sparse.size >= size {
+		t.Errorf("Expected the sparse table shrunk without losing elements, Got %d elements in %d slots", sparse.Len(), sparse.size)
+	}
+	if _, ok := bounded.Get(49); ok || bounded.Len() != 50 || bounded.Stats().Evictions != 50 {
+		t.Errorf("Expected the least recently used half evicted, Got %d elements", bounded.Len())
+	}
+}
+
+func TestPressureResponderStart(t *testing.T) {
+	clock := newFakeClock()
+	r := NewPressureResponder(1, 0.5, WithClock(clock))
+	r.inUse = func() uint64 { return 1 }
+	c := must(NewConcurrent[int, int](4, WithMaxEntries(400), WithEvictionPolicy(EvictFIFO)))
+	for i := 0; i < 400; i++ {
+		c.Set(i, i)
+	}
+	r.Watch(c)
+	checked := make(chan PressureEvent, 1)
+	r.OnPressure(func(e PressureEvent) bool {
+		select {
+		case checked <- e:
+		default:
+		}
+		return true
+	})
+
+	stop := r.Start(time.Second)
+	defer stop()
+	for len(checked) == 0 {
+		clock.Advance(time.Second)
+		time.Sleep(time.Millisecond)
+	}
+	stop()
+	if n := c.Len(); n > 200 {
+		t.Errorf("Expected each shard to evict half its elements, Got %d left", n)
+	}
+}
@@ -0,0 +1,46 @@
+package rhmap
+
+import "sync/atomic"
+
+// Profile is a named set of options applied to every map created after it
+// is made the default with SetDefaults
+type Profile int
+
+const (
+	// No options beyond the package defaults
+	DefaultProfile Profile = iota
+	// Load factor .75, growing early once a probe runs past 24 slots. Costs
+	// memory for shorter, more predictable probes.
+	LowLatency
+	// Load factor .95, halving tables whose load drops below .2. Costs
+	// longer probes for denser tables that release memory after deletes.
+	LowMemory
+	// SipHash under seeds from crypto/rand. Such maps reseed themselves if
+	// flooded with colliding keys, as any map hashing with SipHash under
+	// seeds of its own does.
+	Secure
+)
+
+func (p Profile) options() []Option {
+	switch p {
+	case LowLatency:
+		return []Option{WithLoadFactor(.75), WithPslGrowth(24)}
+	case LowMemory:
+		return []Option{WithLoadFactor(.95), WithShrink(.2)}
+	case Secure:
+		return []Option{WithHasher(SipHasher{}), WithCryptoSeeds()}
+	}
+	return nil
+}
+
+var defaultProfile atomic.Int64
+
+// Makes p the profile every map, map variant and sketch created afterwards
+// starts from, so a codebase can standardize its tuning in one place rather
+// than at every call site. Options passed to a constructor still take
+// precedence over the profile. Maps created earlier are unaffected. It is
+// safe to call concurrently with constructors, though it is best called once
+// during initialization.
+func SetDefaults(p Profile) {
+	defaultProfile.Store(int64(p))
+}
@@ -0,0 +1,40 @@
+package rhmap
+
+import "testing"
+
+func TestSetDefaults(t *testing.T) {
+	t.Cleanup(func() { SetDefaults(DefaultProfile) })
+
+	SetDefaults(LowMemory)
+	m := must(New[int, int]())
+	if m.loadFactor != .95 || m.shrinkLoad != .2 {
+		t.Errorf("LowMemory should raise the load factor and enable shrinking. Got %f, %f", m.loadFactor, m.shrinkLoad)
+	}
+	if got := must(New[int, int](WithLoadFactor(.8))).loadFactor; got != .8 {
+		t.Errorf("Options should take precedence over the profile. Got load factor %f", got)
+	}
+	var zero Map[int, int]
+	if err := zero.UnmarshalJSON([]byte(`{"1": 1}`)); err != nil || zero.loadFactor != .95 {
+		t.Errorf("A zero map should start from the profile too. Got %f, %v", zero.loadFactor, err)
+	}
+
+	SetDefaults(LowLatency)
+	m = must(New[int, int]())
+	if m.loadFactor != .75 || m.pslGrowth == 0 {
+		t.Errorf("LowLatency should lower the load factor and grow on long probes. Got %f, %d", m.loadFactor, m.pslGrowth)
+	}
+
+	SetDefaults(Secure)
+	m = must(New[int, int]())
+	if _, ok := m.hasher.(SipHasher); !ok || !m.cryptoSeeds || !m.autoReseed {
+		t.Errorf("Secure should hash with SipHash under crypto seeds that reseed on floods.")
+	}
+	if m = must(New[int, int](WithDeterministic(1))); m.autoReseed {
+		t.Errorf("Deterministic seeds should not reseed under the Secure profile.")
+	}
+
+	SetDefaults(DefaultProfile)
+	if m = must(New[int, int]()); m.loadFactor != defaultLoadFactor || m.cryptoSeeds || m.shrinkLoad != 0 {
+		t.Errorf("DefaultProfile should restore the package defaults.")
+	}
+}
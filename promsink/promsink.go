@@ -0,0 +1,214 @@
+// Package promsink exports rhmap metrics in the Prometheus text exposition
+// format. A Sink collects the metrics of the maps created with
+// rhmap.WithMetrics(sink), and Handler serves any number of sinks for
+// Prometheus to scrape, without depending on the Prometheus client library.
+package promsink
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	rhmap "github.com/micoo227/robin-hood-hashing"
+)
+
+// rhmap.MetricsSink holding the latest value of every metric reported to
+// it. Counters are exported with a "_total" suffix, and timed events as
+// summaries in seconds with their sum and count. Every metric carries the
+// sink's labels, so that the maps of one service can be told apart.
+type Sink struct {
+	namespace string
+	labels    string
+	labelSet  map[string]string
+
+	mu       sync.Mutex
+	named    map[string]*Sink
+	counters map[string]uint64
+	gauges   map[string]float64
+	sums     map[string]float64
+	counts   map[string]uint64
+}
+
+// Creates a sink whose metric names start with namespace and an
+// underscore, such as "sessions_gets_total" for namespace "sessions", and
+// carry labels
+func New(namespace string, labels map[string]string) *Sink {
+	var b strings.Builder
+	for i, name := range slices.Sorted(maps.Keys(labels)) {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=%s", name, quote(labels[name]))
+	}
+	return &Sink{
+		namespace: namespace,
+		labels:    b.String(),
+		labelSet:  maps.Clone(labels),
+		counters:  make(map[string]uint64),
+		gauges:    make(map[string]float64),
+		sums:      make(map[string]float64),
+		counts:    make(map[string]uint64),
+	}
+}
+
+func (s *Sink) Count(name string, delta uint64) {
+	s.mu.Lock()
+	s.counters[name] += delta
+	s.mu.Unlock()
+}
+
+func (s *Sink) Gauge(name string, value float64) {
+	s.mu.Lock()
+	s.gauges[name] = value
+	s.mu.Unlock()
+}
+
+func (s *Sink) Observe(name string, d time.Duration) {
+	s.mu.Lock()
+	s.sums[name] += d.Seconds()
+	s.counts[name]++
+	s.mu.Unlock()
+}
+
+// Returns a sink for the maps named name, as rhmap.NamedSink requires,
+// whose metrics carry this sink's labels, a "map" label holding name, and
+// labels. It is exported along with this sink, so that one sink serves
+// every named map of a service. Maps of the same name and labels share a
+// sink.
+func (s *Sink) Named(name string, labels map[string]string) rhmap.MetricsSink {
+	merged := maps.Clone(s.labelSet)
+	if merged == nil {
+		merged = make(map[string]string, len(labels)+1)
+	}
+	merged["map"] = name
+	maps.Copy(merged, labels)
+	n := New(s.namespace, merged)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.named[n.labels]; ok {
+		return existing
+	}
+	if s.named == nil {
+		s.named = make(map[string]*Sink)
+	}
+	s.named[n.labels] = n
+	return n
+}
+
+// Writes the sink's metrics in the text exposition format
+func (s *Sink) WriteTo(w io.Writer) (int64, error) {
+	return writeFamilies(w, []*Sink{s})
+}
+
+// Serves the sink's metrics, for mounting on a /metrics endpoint
+func (s *Sink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	Handler(s).ServeHTTP(w, r)
+}
+
+// Returns a handler serving the metrics of every sink. Metrics of the same
+// name are grouped into one family, told apart by the sinks' labels.
+func Handler(sinks ...*Sink) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		writeFamilies(w, sinks)
+	})
+}
+
+// Sample of one metric family
+type sample struct {
+	suffix string
+	labels string
+	value  string
+}
+
+type family struct {
+	typ     string
+	samples []sample
+}
+
+func (s *Sink) families(into map[string]*family) {
+	add := func(name, typ, suffix, value string) {
+		name = s.namespace + "_" + name
+		f := into[name]
+		if f == nil {
+			f = &family{typ: typ}
+			into[name] = f
+		}
+		f.samples = append(f.samples, sample{suffix, s.labels, value})
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, n := range s.named {
+		n.families(into)
+	}
+	for name, v := range s.counters {
+		add(name+"_total", "counter", "", strconv.FormatUint(v, 10))
+	}
+	for name, v := range s.gauges {
+		add(name, "gauge", "", strconv.FormatFloat(v, 'g', -1, 64))
+	}
+	for name, v := range s.sums {
+		add(name+"_seconds", "summary", "_sum", strconv.FormatFloat(v, 'g', -1, 64))
+		add(name+"_seconds", "summary", "_count", strconv.FormatUint(s.counts[name], 10))
+	}
+}
+
+func writeFamilies(w io.Writer, sinks []*Sink) (int64, error) {
+	families := make(map[string]*family)
+	for _, s := range sinks {
+		s.families(families)
+	}
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	for _, name := range slices.Sorted(maps.Keys(families)) {
+		f := families[name]
+		fmt.Fprintf(cw, "# TYPE %s %s\n", name, f.typ)
+		slices.SortStableFunc(f.samples, func(a, b sample) int {
+			return cmp.Compare(a.labels, b.labels)
+		})
+		for _, smp := range f.samples {
+			if smp.labels == "" {
+				fmt.Fprintf(cw, "%s%s %s\n", name, smp.suffix, smp.value)
+			} else {
+				fmt.Fprintf(cw, "%s%s{%s} %s\n", name, smp.suffix, smp.labels, smp.value)
+			}
+		}
+	}
+	if cw.err != nil {
+		return cw.n, cw.err
+	}
+	return cw.n, cw.w.Flush()
+}
+
+// Quotes a label value, escaping as the exposition format requires
+func quote(value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+	return `"` + value + `"`
+}
+
+// Buffered writer counting the bytes written and keeping the first error
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
@@ -0,0 +1,77 @@
+package promsink
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	rhmap "github.com/micoo227/robin-hood-hashing"
+)
+
+var _ rhmap.NamedSink = (*Sink)(nil)
+
+func TestHandler(t *testing.T) {
+	a := New("cache", map[string]string{"map": "users"})
+	b := New("cache", map[string]string{"map": `say "hi"`})
+	a.Count(rhmap.MetricSets, 3)
+	b.Count(rhmap.MetricSets, 4)
+	a.Gauge(rhmap.MetricLoadFactor, 0.5)
+	a.Observe(rhmap.MetricRehashDuration, 1500*time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	Handler(a, b).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	want := `# TYPE cache_load_factor gauge
+cache_load_factor{map="users"} 0.5
+# TYPE cache_rehash_duration_seconds summary
+cache_rehash_duration_seconds_sum{map="users"} 1.5
+cache_rehash_duration_seconds_count{map="users"} 1
+# TYPE cache_sets_total counter
+cache_sets_total{map="say \"hi\""} 4
+cache_sets_total{map="users"} 3
+`
+	if got := rec.Body.String(); got != want {
+		t.Errorf("Metrics should be served in the text exposition format. Expected\n%s\nGot\n%s", want, got)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("The exposition format version should be declared. Got %q", ct)
+	}
+}
+
+func TestSinkWithMap(t *testing.T) {
+	s := New("m", nil)
+	m, err := rhmap.New[int, int](rhmap.WithMetrics(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		m.Set(i, i)
+	}
+	var b strings.Builder
+	if _, err := s.WriteTo(&b); err != nil {
+		t.Fatalf("WriteTo returned an error: %v", err)
+	}
+	if !strings.Contains(b.String(), "m_sets_total 10\n") {
+		t.Errorf("A map's metrics should reach the sink. Got\n%s", b.String())
+	}
+}
+
+func TestSinkNamed(t *testing.T) {
+	s := New("svc", map[string]string{"env": "prod"})
+	m, err := rhmap.New[int, int](rhmap.WithMetrics(s), rhmap.WithName("sessions"),
+		rhmap.WithLabels(map[string]string{"region": "eu"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		m.Set(i, i)
+	}
+	if s.Named("sessions", map[string]string{"region": "eu"}) != s.Named("sessions", map[string]string{"region": "eu"}) {
+		t.Errorf("Maps of the same name and labels should share a sink.")
+	}
+	var b strings.Builder
+	s.WriteTo(&b)
+	if !strings.Contains(b.String(), `svc_sets_total{env="prod",map="sessions",region="eu"} 10`+"\n") {
+		t.Errorf("A named map's metrics should carry its name and labels. Got\n%s", b.String())
+	}
+}
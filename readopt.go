@@ -0,0 +1,158 @@
+package rhmap
+
+import (
+	"iter"
+	"sync"
+	"sync/atomic"
+)
+
+// Value slot shared between a ReadOptimizedMap's published and dirty
+// tables, so that overwriting or deleting a key is seen by readers of
+// either at once. A nil pointer marks a deleted key.
+type readEntry[V any] struct {
+	p atomic.Pointer[V]
+}
+
+// Table published to readers. amended reports that the dirty table holds
+// keys this one lacks, so a miss must be checked under the lock.
+type readView[K comparable, V any] struct {
+	table   *Map[K, *readEntry[V]]
+	amended bool
+}
+
+// Robin hood hashmap for read-mostly workloads, in the style of sync.Map.
+// Reads look keys up in an immutable snapshot published through an atomic
+// pointer and take no lock, so they scale with cores where a
+// ConcurrentMap's readers still share a lock word per shard. Writes take a
+// mutex and go to a dirty table of which the snapshot is a copy-on-write
+// view; overwrites and deletes of published keys reach readers at once
+// through shared value slots, while new keys are found under the lock
+// until enough misses republish the dirty table. Each republish costs a
+// copy of the table on the next write, so the map suits caches that are
+// overwhelmingly read.
+type ReadOptimizedMap[K comparable, V any] struct {
+	read    atomic.Pointer[readView[K, V]]
+	mu      sync.Mutex
+	dirty   *Map[K, *readEntry[V]]
+	misses  uint64
+	onEvict func(K, V)
+}
+
+// Creates a read-optimized map configured by opts; WithZeroDeletes has no
+// effect. It returns an error if K can't be encoded, as New does.
+func NewReadOptimized[K comparable, V any](opts ...Option) (*ReadOptimizedMap[K, V], error) {
+	dirty, err := New[K, *readEntry[V]](opts...)
+	if err != nil {
+		return nil, err
+	}
+	dirty.zeroDeletes = false
+	dirty.onEvict = nil
+	r := &ReadOptimizedMap[K, V]{
+		dirty:   dirty,
+		onEvict: evictHook[K, V](resolveOptions(opts)),
+	}
+	r.read.Store(&readView[K, V]{table: dirty.Snapshot()})
+	return r, nil
+}
+
+// Returns the value under key. Keys present when the table was last
+// published are found without locking.
+func (r *ReadOptimizedMap[K, V]) Get(key K) (V, bool) {
+	view := r.read.Load()
+	e, ok := view.table.Get(key)
+	if ok {
+		if p := e.p.Load(); p != nil {
+			return *p, true
+		}
+	}
+	if !view.amended {
+		var zeroVal V
+		return zeroVal, false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok = r.dirty.Get(key)
+	r.missed()
+	if ok {
+		if p := e.p.Load(); p != nil {
+			return *p, true
+		}
+	}
+	var zeroVal V
+	return zeroVal, false
+}
+
+func (r *ReadOptimizedMap[K, V]) Set(key K, value V) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if e, ok := r.dirty.Get(key); ok {
+		e.p.Store(&value)
+		return
+	}
+	e := &readEntry[V]{}
+	e.p.Store(&value)
+	r.dirty.Set(key, e)
+	if view := r.read.Load(); !view.amended {
+		r.read.Store(&readView[K, V]{table: view.table, amended: true})
+	}
+}
+
+func (r *ReadOptimizedMap[K, V]) Delete(key K) {
+	r.mu.Lock()
+	e, ok := r.dirty.GetAndDelete(key)
+	var p *V
+	if ok {
+		p = e.p.Swap(nil)
+	}
+	r.mu.Unlock()
+	if p != nil && r.onEvict != nil {
+		r.onEvict(key, *p)
+	}
+}
+
+func (r *ReadOptimizedMap[K, V]) Len() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.dirty.Len()
+}
+
+// Iterates over the map's elements, publishing the dirty table first if it
+// holds keys readers can't yet see. Iteration runs over that snapshot
+// without locking: writes made meanwhile may or may not be seen, but every
+// key is visited at most once.
+func (r *ReadOptimizedMap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		view := r.read.Load()
+		if view.amended {
+			r.mu.Lock()
+			r.publish()
+			view = r.read.Load()
+			r.mu.Unlock()
+		}
+		for k, e := range view.table.All() {
+			if p := e.p.Load(); p != nil {
+				if !yield(k, *p) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Counts a lookup that had to take the lock, publishing the dirty table
+// once such lookups have cost as much as copying it. Callers hold r.mu.
+func (r *ReadOptimizedMap[K, V]) missed() {
+	r.misses++
+	if r.misses >= r.dirty.Len() {
+		r.publish()
+	}
+}
+
+// Publishes a snapshot of the dirty table to readers. Callers hold r.mu.
+func (r *ReadOptimizedMap[K, V]) publish() {
+	if r.read.Load().amended {
+		r.read.Store(&readView[K, V]{table: r.dirty.Snapshot()})
+	}
+	r.misses = 0
+}
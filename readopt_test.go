@@ -0,0 +1,94 @@
+package rhmap
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestReadOptimizedMap(t *testing.T) {
+	var evicted []int
+	m := must(NewReadOptimized[int, int](WithOnEvict(func(k, v int) { evicted = append(evicted, v) })))
+	for i := 0; i < 1000; i++ {
+		m.Set(i, i)
+	}
+	if m.Len() != 1000 {
+		t.Errorf("Map should contain 1000 elements. Found %d", m.Len())
+	}
+	for i := 0; i < 1000; i++ {
+		if val, ok := m.Get(i); !ok || val != i {
+			t.Errorf("Key %d should map to %d. Got %d, %t", i, i, val, ok)
+		}
+	}
+	if m.read.Load().amended {
+		t.Errorf("Enough misses should publish the dirty table to readers.")
+	}
+
+	m.Set(5, 50)
+	if val, ok := m.Get(5); !ok || val != 50 {
+		t.Errorf("Overwriting a published key should reach readers at once. Got %d, %t", val, ok)
+	}
+	m.Delete(6)
+	if _, ok := m.Get(6); ok {
+		t.Errorf("Deleting a published key should hide it from readers at once.")
+	}
+	if len(evicted) != 1 || evicted[0] != 6 {
+		t.Errorf("Delete should pass the removed value to the eviction hook. Got %v", evicted)
+	}
+	m.Set(6, 60)
+	if val, ok := m.Get(6); !ok || val != 60 {
+		t.Errorf("A key set again after its delete should be found. Got %d, %t", val, ok)
+	}
+	if _, ok := m.Get(-1); ok {
+		t.Errorf("Missing keys should not be found.")
+	}
+
+	m.Set(1000, 1000)
+	seen := make(map[int]int)
+	for k, v := range m.All() {
+		seen[k] = v
+	}
+	if len(seen) != 1001 || seen[5] != 50 || seen[6] != 60 || seen[1000] != 1000 {
+		t.Errorf("All should visit every element with its latest value. Got %d elements", len(seen))
+	}
+}
+
+func TestReadOptimizedMapConcurrent(t *testing.T) {
+	m := must(NewReadOptimized[int, int]())
+	for i := 0; i < 100; i++ {
+		m.Set(i, i)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 2000; n++ {
+				if val, ok := m.Get(n % 100); !ok || val%100 != n%100 {
+					t.Errorf("Key %d should stay present with a matching value. Got %d, %t", n%100, val, ok)
+					return
+				}
+				m.Get(100 + n%50)
+			}
+		}()
+	}
+	for n := 0; n < 2000; n++ {
+		m.Set(n%100, n%100+100*(n%7))
+		m.Set(100+n%50, n)
+		m.Delete(100 + (n+25)%50)
+	}
+	wg.Wait()
+}
+
+func BenchmarkReadOptimizedGet(b *testing.B) {
+	m := must(NewReadOptimized[int, int]())
+	for i := 0; i < 1024; i++ {
+		m.Set(i, i)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			m.Get(i % 1024)
+		}
+	})
+}
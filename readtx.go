@@ -0,0 +1,61 @@
+package rhmap
+
+import "errors"
+
+// Returned by a ReadTx once the map has been written since the transaction
+// began
+var ErrConcurrentModification = errors.New("rhmap: map written during a read transaction")
+
+// Returns a number that changes with every write to the map, so that a
+// reader can tell whether the map changed between two points. A write the
+// map abandons partway, such as a Set a full bounded map rejects, may
+// change it too.
+func (m *Map[K, V]) Version() uint64 {
+	return m.version
+}
+
+// Sequence of reads that must all see the map as it was when the sequence
+// began. Once the map is written, every read fails fast with
+// ErrConcurrentModification, so that a reader taking several steps without
+// holding a lock throughout can tell its results apart from a consistent
+// view and retry. The map itself is no safer for concurrent use: each read
+// must still be excluded from writes while it runs.
+type ReadTx[K comparable, V any] struct {
+	m       *Map[K, V]
+	version uint64
+}
+
+// Begins a read transaction at the map's current version
+func (m *Map[K, V]) ReadTx() *ReadTx[K, V] {
+	return &ReadTx[K, V]{m: m, version: m.version}
+}
+
+// Returns the value of key, or ErrConcurrentModification if the map has
+// been written since the transaction began
+func (tx *ReadTx[K, V]) Get(key K) (V, bool, error) {
+	if err := tx.Validate(); err != nil {
+		var zeroVal V
+		return zeroVal, false, err
+	}
+	val, ok := tx.m.Get(key)
+	return val, ok, nil
+}
+
+// Returns the number of elements in the map, or ErrConcurrentModification
+// if the map has been written since the transaction began
+func (tx *ReadTx[K, V]) Len() (uint64, error) {
+	if err := tx.Validate(); err != nil {
+		return 0, err
+	}
+	return tx.m.Len(), nil
+}
+
+// Returns ErrConcurrentModification if the map has been written since the
+// transaction began, and nil otherwise. Checking it after the last read
+// confirms that every read saw the same map.
+func (tx *ReadTx[K, V]) Validate() error {
+	if tx.m.version != tx.version {
+		return ErrConcurrentModification
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package rhmap
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestVersion(t *testing.T) {
+	m := must(New[int, int](WithIncrementalRehash(1)))
+	last := m.Version()
+	changed := func(op string) {
+		t.Helper()
+		if v := m.Version(); v == last {
+			t.Errorf("%s should change the version. Got %d before and after", op, v)
+		} else {
+			last = v
+		}
+	}
+	unchanged := func(op string) {
+		t.Helper()
+		if v := m.Version(); v != last {
+			t.Errorf("%s should leave the version at %d. Got %d", op, last, v)
+		}
+	}
+
+	for i := 0; i < 100; i++ {
+		m.Set(i, i)
+		changed("Set")
+	}
+	m.Get(1)
+	m.Delete(1000)
+	unchanged("Reading or deleting a missing key")
+	m.Set(2, 3)
+	changed("Updating a value")
+
+	// Writes reaching the table being drained by an incremental rehash
+	m.Delete(50)
+	changed("Delete")
+	for k := range m.All() {
+		m.Set(k, -1)
+		changed("Set while iterating")
+		break
+	}
+
+	s := m.Snapshot()
+	m.Set(3, 4)
+	changed("Set after a snapshot")
+	if s.Version() == m.Version() {
+		t.Errorf("A snapshot should keep the version it was taken at.")
+	}
+	m.Clear()
+	changed("Clear")
+}
+
+func TestReadTx(t *testing.T) {
+	m := must(New[string, int]())
+	m.Set("a", 1)
+	m.Set("b", 2)
+
+	tx := m.ReadTx()
+	if v, ok, err := tx.Get("a"); v != 1 || !ok || err != nil {
+		t.Errorf("Expected 1 true <nil>, Got %v %v %v", v, ok, err)
+	}
+	if n, err := tx.Len(); n != 2 || err != nil {
+		t.Errorf("Expected 2 <nil>, Got %v %v", n, err)
+	}
+	if err := tx.Validate(); err != nil {
+		t.Errorf("An unwritten map should validate. Got %v", err)
+	}
+
+	m.Set("b", 3)
+	if _, _, err := tx.Get("b"); !errors.Is(err, ErrConcurrentModification) {
+		t.Errorf("Get after a write should fail with ErrConcurrentModification. Got %v", err)
+	}
+	if _, err := tx.Len(); !errors.Is(err, ErrConcurrentModification) {
+		t.Errorf("Len after a write should fail with ErrConcurrentModification. Got %v", err)
+	}
+	if err := tx.Validate(); !errors.Is(err, ErrConcurrentModification) {
+		t.Errorf("Validate after a write should fail with ErrConcurrentModification. Got %v", err)
+	}
+
+	if v, _, err := m.ReadTx().Get("b"); v != 3 || err != nil {
+		t.Errorf("A new transaction should see the write. Got %v %v", v, err)
+	}
+}
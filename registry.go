@@ -0,0 +1,132 @@
+package rhmap
+
+import (
+	"maps"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Statistics of a named map as listed by Registry. Only the statistics a
+// map keeps running totals of are filled in: Len, Capacity, Load, MeanPsl,
+// MaxPsl and Resizes, and Misses and TopMisses under WithMissStats. Those
+// that take a scan of the table, such as PslHistogram, are left zero; call
+// the map's Stats for them.
+type MapInfo struct {
+	Name   string
+	Labels map[string]string
+	Stats
+}
+
+// Registry entry of a named map. The map publishes its statistics into the
+// entry with atomic stores, so Registry can read them from any goroutine
+// without racing the map's single writer.
+type registration struct {
+	name     string
+	labels   map[string]string
+	len      atomic.Uint64
+	capacity atomic.Uint64
+	totalPsl atomic.Uint64
+	maxPsl   atomic.Uint64
+	resizes  atomic.Uint64
+	// Returns the map's Get misses and most missed keys, or nil without
+	// WithMissStats. The tracker it reads has its own lock.
+	misses func() (uint64, []MissCount)
+	// Gathers the statistics of a wrapper such as ConcurrentMap under its
+	// own locks, or nil for a Map, which publishes them
+	collect func() Stats
+}
+
+// Returns the statistics published into the entry
+func (r *registration) stats() Stats {
+	if r.collect != nil {
+		return r.collect()
+	}
+	s := Stats{
+		Len:      r.len.Load(),
+		Capacity: r.capacity.Load(),
+		MaxPsl:   uint(r.maxPsl.Load()),
+		Resizes:  r.resizes.Load(),
+	}
+	if s.Capacity > 0 {
+		s.Load = float64(s.Len) / float64(s.Capacity)
+	}
+	if s.Len > 0 {
+		s.MeanPsl = float64(r.totalPsl.Load()) / float64(s.Len)
+	}
+	if r.misses != nil {
+		s.Misses, s.TopMisses = r.misses()
+	}
+	return s
+}
+
+var registry struct {
+	sync.Mutex
+	entries map[*registration]struct{}
+}
+
+// Lists every live map created with WithName, sorted by name, so an admin
+// endpoint can enumerate the maps a service created and spot the one eating
+// memory. Maps drop out of the registry once they are garbage collected.
+func Registry() []MapInfo {
+	registry.Lock()
+	defer registry.Unlock()
+
+	infos := make([]MapInfo, 0, len(registry.entries))
+	for r := range registry.entries {
+		infos = append(infos, MapInfo{
+			Name:   r.name,
+			Labels: maps.Clone(r.labels),
+			Stats:  r.stats(),
+		})
+	}
+	slices.SortFunc(infos, func(a, b MapInfo) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return infos
+}
+
+// Adds m to the registry under name until m is garbage collected
+func register[K comparable, V any](m *Map[K, V], name string, labels map[string]string) {
+	r := &registration{name: name, labels: labels}
+	if m.misses != nil {
+		r.misses = m.misses.top
+	}
+	addRegistration(r)
+	m.registration = r
+	m.publish()
+	runtime.AddCleanup(m, unregister, r)
+}
+
+// Lists r in the registry
+func addRegistration(r *registration) {
+	registry.Lock()
+	if registry.entries == nil {
+		registry.entries = make(map[*registration]struct{})
+	}
+	registry.entries[r] = struct{}{}
+	registry.Unlock()
+}
+
+func unregister(r *registration) {
+	registry.Lock()
+	delete(registry.entries, r)
+	registry.Unlock()
+}
+
+// Publishes the map's statistics to its registry entry, if it has one
+func (m *Map[K, V]) publish() {
+	if r := m.registration; r != nil {
+		totalPsl := m.totalPsl
+		if m.draining != nil {
+			totalPsl += m.draining.totalPsl
+		}
+		r.len.Store(m.numElements)
+		r.capacity.Store(m.size)
+		r.totalPsl.Store(totalPsl)
+		r.maxPsl.Store(uint64(m.MaxPSL()))
+		r.resizes.Store(m.resizes)
+	}
+}
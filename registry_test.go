@@ -0,0 +1,72 @@
+package rhmap
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func findInfo(name string) (MapInfo, bool) {
+	for _, info := range Registry() {
+		if info.Name == name {
+			return info, true
+		}
+	}
+	return MapInfo{}, false
+}
+
+func TestRegistry(t *testing.T) {
+	m := must(New[int, int](WithName("registry-test"), WithLabels(map[string]string{"team": "search"}), WithMissStats(4)))
+
+	for i := 0; i < 100; i++ {
+		m.Set(i, i)
+	}
+	m.Delete(0)
+	m.Get(0)
+	m.Get(0)
+
+	info, ok := findInfo("registry-test")
+	if !ok {
+		t.Fatal("Registry should list the map named 'registry-test'.")
+	}
+	if info.Len != 99 || info.Capacity != m.size {
+		t.Errorf("Registry should report 99 elements in %d slots. Got %d in %d", m.size, info.Len, info.Capacity)
+	}
+
+	if info.Load != m.LoadFactor() || info.MeanPsl != m.MeanPSL() || info.MaxPsl != m.MaxPSL() || info.Resizes == 0 {
+		t.Errorf("Registry should report the map's probe statistics. Got %+v", info.Stats)
+	}
+	if info.Misses != 2 || len(info.TopMisses) != 1 || info.TopMisses[0].Key != 0 {
+		t.Errorf("Registry should report the map's misses. Got %d, %v", info.Misses, info.TopMisses)
+	}
+
+	m.Reset()
+	if info, _ := findInfo("registry-test"); info.Len != 0 || info.Misses != 0 {
+		t.Errorf("Registry should follow a Reset. Got %+v", info.Stats)
+	}
+
+	if info.Labels["team"] != "search" {
+		t.Errorf("Registry should report the map's labels. Got %v", info.Labels)
+	}
+
+	if _, ok := findInfo(""); ok {
+		t.Error("Unnamed maps should not be registered.")
+	}
+	runtime.KeepAlive(m)
+}
+
+func TestRegistryDropsCollectedMaps(t *testing.T) {
+	func() {
+		m := must(New[int, int](WithName("registry-collected")))
+		m.Set(1, 1)
+	}()
+
+	for i := 0; i < 50; i++ {
+		runtime.GC()
+		if _, ok := findInfo("registry-collected"); !ok {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Error("Registry should drop maps once they are garbage collected.")
+}
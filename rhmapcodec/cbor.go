@@ -0,0 +1,298 @@
+package rhmapcodec
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// CBOR major types
+const (
+	cborUint = iota
+	cborNegInt
+	cborBytes
+	cborString
+	cborArray
+	cborMap
+	cborTag
+	cborSimple
+)
+
+// Additional information of an indefinite length, or of the break code
+// under major type 7
+const cborIndefinite = 31
+
+// Writes CBOR in preferred serialization: every length and integer in its
+// shortest form, and every float in the shortest of half, single or double
+// precision that holds it exactly
+type cborWriter struct{}
+
+// Appends the initial byte of a data item of the given major type and its
+// argument
+func appendCBORHead(b []byte, major byte, arg uint64) []byte {
+	switch {
+	case arg < 24:
+		return append(b, major<<5|byte(arg))
+	case arg <= math.MaxUint8:
+		return append(b, major<<5|24, byte(arg))
+	case arg <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, major<<5|25), uint16(arg))
+	case arg <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, major<<5|26), uint32(arg))
+	}
+	return binary.BigEndian.AppendUint64(append(b, major<<5|27), arg)
+}
+
+func (cborWriter) appendNil(b []byte) []byte {
+	return append(b, 0xf6)
+}
+
+func (cborWriter) appendBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, 0xf5)
+	}
+	return append(b, 0xf4)
+}
+
+func (cborWriter) appendInt(b []byte, v int64) []byte {
+	if v < 0 {
+		return appendCBORHead(b, cborNegInt, uint64(-1-v))
+	}
+	return appendCBORHead(b, cborUint, uint64(v))
+}
+
+func (cborWriter) appendUint(b []byte, v uint64) []byte {
+	return appendCBORHead(b, cborUint, v)
+}
+
+func (cborWriter) appendFloat(b []byte, v float64, _ int) []byte {
+	if f := float32(v); float64(f) == v || v != v {
+		if h, ok := float16Bits(f); ok {
+			return binary.BigEndian.AppendUint16(append(b, 0xf9), h)
+		}
+		return binary.BigEndian.AppendUint32(append(b, 0xfa), math.Float32bits(f))
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0xfb), math.Float64bits(v))
+}
+
+func (cborWriter) appendString(b []byte, s string) []byte {
+	return append(appendCBORHead(b, cborString, uint64(len(s))), s...)
+}
+
+func (cborWriter) appendBytes(b []byte, p []byte) []byte {
+	return append(appendCBORHead(b, cborBytes, uint64(len(p))), p...)
+}
+
+func (cborWriter) appendArray(b []byte, n uint64) []byte {
+	return appendCBORHead(b, cborArray, n)
+}
+
+func (cborWriter) appendMap(b []byte, n uint64) []byte {
+	return appendCBORHead(b, cborMap, n)
+}
+
+// Returns the half-precision bits of f, and false if f can't be held
+// exactly in half precision. NaNs become the canonical quiet NaN.
+func float16Bits(f float32) (uint16, bool) {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int(bits>>23) & 0xff
+	mant := bits & 0x7fffff
+
+	switch {
+	case exp == 0xff && mant != 0:
+		return 0x7e00, true
+	case exp == 0xff:
+		return sign | 0x7c00, true
+	case exp == 0 && mant == 0:
+		return sign, true
+	case exp == 0:
+		// Single-precision subnormals are far below half precision's range
+		return 0, false
+	}
+
+	e := exp - 127
+	switch {
+	case e >= -14 && e <= 15:
+		if mant&0x1fff != 0 {
+			return 0, false
+		}
+		return sign | uint16(e+15)<<10 | uint16(mant>>13), true
+	case e >= -24 && e < -14:
+		// Half-precision subnormal: the significand, leading bit included,
+		// counted in units of 2^-24
+		full := mant | 0x800000
+		shift := uint(-14-e) + 13
+		if full&(1<<shift-1) != 0 {
+			return 0, false
+		}
+		return sign | uint16(full>>shift), true
+	}
+	return 0, false
+}
+
+// Returns the value of half-precision bits h
+func float16Value(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	var v float64
+	switch exp {
+	case 0:
+		v = math.Ldexp(mant, -24)
+	case 0x1f:
+		if mant == 0 {
+			v = math.Inf(1)
+		} else {
+			v = math.NaN()
+		}
+	default:
+		v = math.Ldexp(mant+0x400, exp-25)
+	}
+	if h&0x8000 != 0 {
+		v = -v
+	}
+	return v
+}
+
+// Reads CBOR, including indefinite-length strings, arrays and maps. Tags
+// are skipped, leaving the value they tag.
+type cborReader struct {
+	r *bufio.Reader
+}
+
+// Reads the initial byte of a data item and its argument, and reports
+// whether the item has an indefinite length
+func (c cborReader) head() (major byte, info byte, arg uint64, err error) {
+	ib, err := c.r.ReadByte()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	major, info = ib>>5, ib&0x1f
+	var n int
+	switch {
+	case info < 24:
+		return major, info, uint64(info), nil
+	case info <= 27:
+		n = 1 << (info - 24)
+	case info == cborIndefinite:
+		return major, info, 0, nil
+	default:
+		return 0, 0, 0, fmt.Errorf("%w: reserved CBOR initial byte %#x", ErrMalformed, ib)
+	}
+
+	var buf [8]byte
+	if _, err := io.ReadFull(c.r, buf[8-n:]); err != nil {
+		return 0, 0, 0, noEOF(err)
+	}
+	return major, info, binary.BigEndian.Uint64(buf[:]), nil
+}
+
+func (c cborReader) next() (token, error) {
+	for {
+		major, info, arg, err := c.head()
+		if err != nil {
+			return token{}, err
+		}
+		indef := info == cborIndefinite
+
+		switch major {
+		case cborUint:
+			if !indef {
+				return token{kind: tokUint, u: arg}, nil
+			}
+		case cborNegInt:
+			if indef {
+				break
+			}
+			if arg > math.MaxInt64 {
+				return token{}, fmt.Errorf("%w: CBOR integer -1-%d overflows int64", ErrMalformed, arg)
+			}
+			return token{kind: tokInt, i: -1 - int64(arg)}, nil
+		case cborBytes, cborString:
+			kind := tokBytes
+			if major == cborString {
+				kind = tokString
+			}
+			if !indef {
+				s, err := readString(c.r, arg)
+				return token{kind: kind, s: s}, err
+			}
+			s, err := c.chunks(major)
+			return token{kind: kind, s: s}, err
+		case cborArray, cborMap:
+			kind := tokArray
+			if major == cborMap {
+				kind = tokMap
+			}
+			if indef {
+				arg = indefinite
+			}
+			return token{kind: kind, n: arg}, nil
+		case cborTag:
+			if !indef {
+				continue
+			}
+		case cborSimple:
+			return c.simple(info, arg)
+		}
+		return token{}, fmt.Errorf("%w: CBOR major type %d with indefinite length", ErrMalformed, major)
+	}
+}
+
+// Reads the definite-length chunks of an indefinite-length string of the
+// given major type up to its break code
+func (c cborReader) chunks(major byte) ([]byte, error) {
+	var s []byte
+	for {
+		if brk, err := c.atBreak(); err != nil || brk {
+			return s, err
+		}
+		m, info, arg, err := c.head()
+		if err != nil {
+			return nil, noEOF(err)
+		}
+		if m != major || info == cborIndefinite {
+			return nil, fmt.Errorf("%w: CBOR string chunk of major type %d", ErrMalformed, m)
+		}
+		chunk, err := readString(c.r, arg)
+		if err != nil {
+			return nil, err
+		}
+		s = append(s, chunk...)
+	}
+}
+
+// Decodes a simple value or float, given the additional information and
+// argument of its initial byte
+func (c cborReader) simple(info byte, arg uint64) (token, error) {
+	switch info {
+	case 20, 21:
+		return token{kind: tokBool, b: info == 21}, nil
+	case 22, 23:
+		// null and undefined
+		return token{kind: tokNil}, nil
+	case 25:
+		return token{kind: tokFloat, f: float16Value(uint16(arg))}, nil
+	case 26:
+		return token{kind: tokFloat, f: float64(math.Float32frombits(uint32(arg)))}, nil
+	case 27:
+		return token{kind: tokFloat, f: math.Float64frombits(arg)}, nil
+	case cborIndefinite:
+		return token{}, fmt.Errorf("%w: unexpected CBOR break code", ErrMalformed)
+	}
+	return token{}, fmt.Errorf("%w: unsupported CBOR simple value %d", ErrMalformed, arg)
+}
+
+func (c cborReader) atBreak() (bool, error) {
+	b, err := c.r.Peek(1)
+	if err != nil {
+		return false, noEOF(err)
+	}
+	if b[0] != cborSimple<<5|cborIndefinite {
+		return false, nil
+	}
+	_, err = c.r.ReadByte()
+	return true, err
+}
@@ -0,0 +1,719 @@
+// Package rhmapcodec encodes and decodes rhmap maps as CBOR (RFC 8949) and
+// MessagePack maps, for exchanging them with services that speak those
+// formats. Encode streams the map's elements as it iterates, without
+// building an intermediate built-in map, and Decode sets each element as it
+// is read.
+//
+// Keys and values are mapped by reflection much as encoding/json maps them:
+// booleans, integers, floats and strings to their counterparts, byte slices
+// and arrays to byte strings, other slices and arrays to arrays, maps to
+// maps, structs to maps from exported field names, skipping fields tagged
+// `rhmap:"-"`, and nil pointers, slices, maps and interfaces to nil.
+// Channels, functions and complex numbers can't be encoded. Decoding into
+// an empty interface yields nil, bool, int64, uint64, float64, string,
+// []byte, []any or map[any]any.
+package rhmapcodec
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"slices"
+
+	rhmap "github.com/micoo227/robin-hood-hashing"
+)
+
+// Wire format of an encoding
+type Format int
+
+const (
+	CBOR Format = iota
+	MessagePack
+)
+
+func (f Format) String() string {
+	switch f {
+	case CBOR:
+		return "CBOR"
+	case MessagePack:
+		return "MessagePack"
+	}
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+// Option configures Encode
+type Option func(*config)
+
+type config struct {
+	deterministic bool
+}
+
+// Sorts the entries of every map, including the structs encoded as maps,
+// by the bytewise order of their encoded keys, so that equal maps always
+// encode to the same bytes. For CBOR this is the core deterministic
+// encoding of RFC 8949 section 4.2.1. Sorting holds every encoded element of
+// the map in memory at once rather than streaming it.
+func Deterministic() Option {
+	return func(c *config) {
+		c.deterministic = true
+	}
+}
+
+// Returned, wrapped, for input that isn't a valid encoding or doesn't fit
+// the type decoded into
+var ErrMalformed = errors.New("rhmapcodec: malformed input")
+
+// Deepest nesting of arrays and maps Decode accepts, so that hostile input
+// can't exhaust the stack
+const maxDepth = 1000
+
+// Elements reserved up front for a decoded map or slice, whatever length
+// the input claims, so that a short hostile input can't allocate much
+const maxPrealloc = 1 << 16
+
+// Writes m to w in format as a single map from keys to values
+func Encode[K comparable, V any](w io.Writer, format Format, m *rhmap.Map[K, V], opts ...Option) error {
+	var c config
+	for _, opt := range opts {
+		opt(&c)
+	}
+	enc, err := newEncoder(format, c)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	var buf []byte
+	if c.deterministic {
+		pairs := make([]pair, 0, m.Len())
+		for k, v := range m.All() {
+			var p pair
+			if p.key, err = enc.encode(nil, reflect.ValueOf(&k).Elem()); err != nil {
+				return err
+			}
+			if p.value, err = enc.encode(nil, reflect.ValueOf(&v).Elem()); err != nil {
+				return err
+			}
+			pairs = append(pairs, p)
+		}
+		buf = enc.appendPairs(nil, pairs)
+		if _, err := bw.Write(buf); err != nil {
+			return err
+		}
+		return bw.Flush()
+	}
+
+	buf = enc.w.appendMap(buf, m.Len())
+	for k, v := range m.All() {
+		if buf, err = enc.encode(buf, reflect.ValueOf(&k).Elem()); err != nil {
+			return err
+		}
+		if buf, err = enc.encode(buf, reflect.ValueOf(&v).Elem()); err != nil {
+			return err
+		}
+		if _, err := bw.Write(buf); err != nil {
+			return err
+		}
+		buf = buf[:0]
+	}
+	if _, err := bw.Write(buf); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// Reads a single map in format from r and sets each of its elements in m.
+// A nil in place of the map sets nothing. r is read through a buffer, so
+// it may be read past the end of the map.
+func Decode[K comparable, V any](r io.Reader, format Format, m *rhmap.Map[K, V]) error {
+	dec, err := newDecoder(format, bufio.NewReader(r))
+	if err != nil {
+		return err
+	}
+	tok, err := dec.r.next()
+	if err != nil {
+		return err
+	}
+	if tok.kind == tokNil {
+		return nil
+	}
+	if tok.kind != tokMap {
+		return fmt.Errorf("%w: expected a map, got %v", ErrMalformed, tok.kind)
+	}
+	if tok.n != indefinite {
+		m.Reserve(min(tok.n, maxPrealloc))
+	}
+
+	var key K
+	var value V
+	kv, vv := reflect.ValueOf(&key).Elem(), reflect.ValueOf(&value).Elem()
+	for i := uint64(0); tok.n == indefinite || i < tok.n; i++ {
+		if done, err := dec.done(tok); err != nil || done {
+			return err
+		}
+		kv.SetZero()
+		vv.SetZero()
+		if err := dec.decode(kv, 1); err != nil {
+			return err
+		}
+		if err := comparableKey(kv); err != nil {
+			return err
+		}
+		if err := dec.decode(vv, 1); err != nil {
+			return err
+		}
+		m.Set(key, value)
+	}
+	return nil
+}
+
+// Encoded key and value of a map entry, held for sorting
+type pair struct {
+	key, value []byte
+}
+
+// Appends the primitives of one format
+type writer interface {
+	appendNil(b []byte) []byte
+	appendBool(b []byte, v bool) []byte
+	appendInt(b []byte, v int64) []byte
+	appendUint(b []byte, v uint64) []byte
+	// bits is 32 for float32 values, which are encoded as such where the
+	// format distinguishes them
+	appendFloat(b []byte, v float64, bits int) []byte
+	appendString(b []byte, s string) []byte
+	appendBytes(b []byte, p []byte) []byte
+	appendArray(b []byte, n uint64) []byte
+	appendMap(b []byte, n uint64) []byte
+}
+
+type encoder struct {
+	w writer
+	config
+}
+
+func newEncoder(format Format, c config) (*encoder, error) {
+	switch format {
+	case CBOR:
+		return &encoder{cborWriter{}, c}, nil
+	case MessagePack:
+		return &encoder{msgpackWriter{}, c}, nil
+	}
+	return nil, fmt.Errorf("rhmapcodec: unknown format %v", format)
+}
+
+// Appends the encoding of v to b
+func (e *encoder) encode(b []byte, v reflect.Value) ([]byte, error) {
+	switch v.Kind() {
+	case reflect.Bool:
+		return e.w.appendBool(b, v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return e.w.appendInt(b, v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return e.w.appendUint(b, v.Uint()), nil
+	case reflect.Float32:
+		return e.w.appendFloat(b, v.Float(), 32), nil
+	case reflect.Float64:
+		return e.w.appendFloat(b, v.Float(), 64), nil
+	case reflect.String:
+		return e.w.appendString(b, v.String()), nil
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return e.w.appendNil(b), nil
+		}
+		return e.encode(b, v.Elem())
+	case reflect.Slice:
+		if v.IsNil() {
+			return e.w.appendNil(b), nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return e.w.appendBytes(b, v.Bytes()), nil
+		}
+		return e.encodeArray(b, v)
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			p := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(p), v)
+			return e.w.appendBytes(b, p), nil
+		}
+		return e.encodeArray(b, v)
+	case reflect.Map:
+		if v.IsNil() {
+			return e.w.appendNil(b), nil
+		}
+		return e.encodeMap(b, v)
+	case reflect.Struct:
+		return e.encodeStruct(b, v)
+	}
+	return nil, fmt.Errorf("rhmapcodec: can't encode a value of type %v", v.Type())
+}
+
+func (e *encoder) encodeArray(b []byte, v reflect.Value) ([]byte, error) {
+	b = e.w.appendArray(b, uint64(v.Len()))
+	for i := range v.Len() {
+		var err error
+		if b, err = e.encode(b, v.Index(i)); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+func (e *encoder) encodeMap(b []byte, v reflect.Value) ([]byte, error) {
+	if !e.deterministic {
+		b = e.w.appendMap(b, uint64(v.Len()))
+		for iter := v.MapRange(); iter.Next(); {
+			var err error
+			if b, err = e.encode(b, iter.Key()); err != nil {
+				return nil, err
+			}
+			if b, err = e.encode(b, iter.Value()); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
+
+	pairs := make([]pair, 0, v.Len())
+	for iter := v.MapRange(); iter.Next(); {
+		p, err := e.encodePair(iter.Key(), iter.Value())
+		if err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, p)
+	}
+	return e.appendPairs(b, pairs), nil
+}
+
+func (e *encoder) encodeStruct(b []byte, v reflect.Value) ([]byte, error) {
+	fields := encodedFields(v.Type())
+	pairs := make([]pair, 0, len(fields))
+	for _, i := range fields {
+		p, err := e.encodePair(reflect.ValueOf(v.Type().Field(i).Name), v.Field(i))
+		if err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, p)
+	}
+	return e.appendPairs(b, pairs), nil
+}
+
+func (e *encoder) encodePair(key, value reflect.Value) (pair, error) {
+	var p pair
+	var err error
+	if p.key, err = e.encode(nil, key); err != nil {
+		return p, err
+	}
+	p.value, err = e.encode(nil, value)
+	return p, err
+}
+
+// Appends a map of pairs, sorted by key if the encoding is deterministic
+func (e *encoder) appendPairs(b []byte, pairs []pair) []byte {
+	if e.deterministic {
+		slices.SortFunc(pairs, func(a, b pair) int {
+			return bytes.Compare(a.key, b.key)
+		})
+	}
+	b = e.w.appendMap(b, uint64(len(pairs)))
+	for _, p := range pairs {
+		b = append(append(b, p.key...), p.value...)
+	}
+	return b
+}
+
+// Returns the indexes of the fields of struct type t that are encoded:
+// exported and not tagged `rhmap:"-"`
+func encodedFields(t reflect.Type) []int {
+	var fields []int
+	for i := range t.NumField() {
+		if f := t.Field(i); f.IsExported() && f.Tag.Get("rhmap") != "-" {
+			fields = append(fields, i)
+		}
+	}
+	return fields
+}
+
+// Kinds of token a reader returns
+type tokenKind uint8
+
+const (
+	tokNil tokenKind = iota
+	tokBool
+	tokInt
+	tokUint
+	tokFloat
+	tokString
+	tokBytes
+	tokArray
+	tokMap
+)
+
+func (k tokenKind) String() string {
+	return [...]string{"nil", "bool", "integer", "integer", "float", "string", "byte string", "array", "map"}[k]
+}
+
+// Length of a CBOR array or map whose end is marked by a break code
+const indefinite = math.MaxUint64
+
+// Primitive read from the input. Arrays and maps are read as a header
+// holding their length, followed by their elements.
+type token struct {
+	kind tokenKind
+	b    bool
+	i    int64
+	u    uint64
+	f    float64
+	s    []byte
+	n    uint64
+}
+
+// Reads the primitives of one format
+type reader interface {
+	next() (token, error)
+	// Reports whether an indefinite-length array or map ends here,
+	// consuming the break code if so
+	atBreak() (bool, error)
+}
+
+type decoder struct {
+	r reader
+}
+
+func newDecoder(format Format, r *bufio.Reader) (*decoder, error) {
+	switch format {
+	case CBOR:
+		return &decoder{cborReader{r}}, nil
+	case MessagePack:
+		return &decoder{msgpackReader{r}}, nil
+	}
+	return nil, fmt.Errorf("rhmapcodec: unknown format %v", format)
+}
+
+// Reports whether an array or map ends before its next element, which
+// only an indefinite one can without a count
+func (d *decoder) done(tok token) (bool, error) {
+	if tok.n != indefinite {
+		return false, nil
+	}
+	return d.r.atBreak()
+}
+
+// Reads the next value into v
+func (d *decoder) decode(v reflect.Value, depth int) error {
+	if depth > maxDepth {
+		return fmt.Errorf("%w: nested more than %d deep", ErrMalformed, maxDepth)
+	}
+	tok, err := d.r.next()
+	if err != nil {
+		return err
+	}
+	return d.assign(tok, v, depth)
+}
+
+func (d *decoder) assign(tok token, v reflect.Value, depth int) error {
+	if tok.kind == tokNil {
+		v.SetZero()
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return d.assign(tok, v.Elem(), depth)
+	case reflect.Interface:
+		if v.NumMethod() > 0 {
+			break
+		}
+		x, err := d.natural(tok, depth)
+		if err != nil {
+			return err
+		}
+		if x == nil {
+			v.SetZero()
+		} else {
+			v.Set(reflect.ValueOf(x))
+		}
+		return nil
+	case reflect.Bool:
+		if tok.kind == tokBool {
+			v.SetBool(tok.b)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		switch {
+		case tok.kind == tokInt:
+			i = tok.i
+		case tok.kind == tokUint && tok.u <= math.MaxInt64:
+			i = int64(tok.u)
+		default:
+			return d.mismatch(tok, v)
+		}
+		if v.OverflowInt(i) {
+			return d.mismatch(tok, v)
+		}
+		v.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var u uint64
+		switch {
+		case tok.kind == tokUint:
+			u = tok.u
+		case tok.kind == tokInt && tok.i >= 0:
+			u = uint64(tok.i)
+		default:
+			return d.mismatch(tok, v)
+		}
+		if v.OverflowUint(u) {
+			return d.mismatch(tok, v)
+		}
+		v.SetUint(u)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		switch tok.kind {
+		case tokFloat:
+			v.SetFloat(tok.f)
+		case tokInt:
+			v.SetFloat(float64(tok.i))
+		case tokUint:
+			v.SetFloat(float64(tok.u))
+		default:
+			return d.mismatch(tok, v)
+		}
+		return nil
+	case reflect.String:
+		if tok.kind == tokString || tok.kind == tokBytes {
+			v.SetString(string(tok.s))
+			return nil
+		}
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 && (tok.kind == tokBytes || tok.kind == tokString) {
+			v.SetBytes(tok.s)
+			return nil
+		}
+		if tok.kind == tokArray {
+			return d.assignSlice(tok, v, depth)
+		}
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 && (tok.kind == tokBytes || tok.kind == tokString) {
+			if len(tok.s) != v.Len() {
+				return d.mismatch(tok, v)
+			}
+			reflect.Copy(v, reflect.ValueOf(tok.s))
+			return nil
+		}
+		if tok.kind == tokArray {
+			return d.assignArray(tok, v, depth)
+		}
+	case reflect.Map:
+		if tok.kind == tokMap {
+			return d.assignMap(tok, v, depth)
+		}
+	case reflect.Struct:
+		if tok.kind == tokMap {
+			return d.assignStruct(tok, v, depth)
+		}
+	}
+	return d.mismatch(tok, v)
+}
+
+func (d *decoder) mismatch(tok token, v reflect.Value) error {
+	return fmt.Errorf("%w: can't decode a %v into a value of type %v", ErrMalformed, tok.kind, v.Type())
+}
+
+func (d *decoder) assignSlice(tok token, v reflect.Value, depth int) error {
+	s := reflect.MakeSlice(v.Type(), 0, int(min(tok.n, maxPrealloc)))
+	elem := reflect.New(v.Type().Elem()).Elem()
+	for i := uint64(0); tok.n == indefinite || i < tok.n; i++ {
+		if done, err := d.done(tok); err != nil {
+			return err
+		} else if done {
+			break
+		}
+		elem.SetZero()
+		if err := d.decode(elem, depth+1); err != nil {
+			return err
+		}
+		s = reflect.Append(s, elem)
+	}
+	v.Set(s)
+	return nil
+}
+
+// Decodes the elements of an array into the Go array v, zeroing any left
+// over and skipping any that don't fit
+func (d *decoder) assignArray(tok token, v reflect.Value, depth int) error {
+	v.SetZero()
+	for i := uint64(0); tok.n == indefinite || i < tok.n; i++ {
+		if done, err := d.done(tok); err != nil {
+			return err
+		} else if done {
+			break
+		}
+		var err error
+		if i < uint64(v.Len()) {
+			err = d.decode(v.Index(int(i)), depth+1)
+		} else {
+			err = d.skip(depth + 1)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *decoder) assignMap(tok token, v reflect.Value, depth int) error {
+	if v.IsNil() {
+		v.Set(reflect.MakeMapWithSize(v.Type(), int(min(tok.n, maxPrealloc))))
+	}
+	key := reflect.New(v.Type().Key()).Elem()
+	elem := reflect.New(v.Type().Elem()).Elem()
+	for i := uint64(0); tok.n == indefinite || i < tok.n; i++ {
+		if done, err := d.done(tok); err != nil {
+			return err
+		} else if done {
+			break
+		}
+		key.SetZero()
+		elem.SetZero()
+		if err := d.decode(key, depth+1); err != nil {
+			return err
+		}
+		if err := comparableKey(key); err != nil {
+			return err
+		}
+		if err := d.decode(elem, depth+1); err != nil {
+			return err
+		}
+		v.SetMapIndex(key, elem)
+	}
+	return nil
+}
+
+// Decodes a map into the struct v by field name, skipping entries that
+// match no encoded field
+func (d *decoder) assignStruct(tok token, v reflect.Value, depth int) error {
+	t := v.Type()
+	fields := make(map[string]int)
+	for _, i := range encodedFields(t) {
+		fields[t.Field(i).Name] = i
+	}
+	for i := uint64(0); tok.n == indefinite || i < tok.n; i++ {
+		if done, err := d.done(tok); err != nil {
+			return err
+		} else if done {
+			break
+		}
+		var name string
+		if err := d.decode(reflect.ValueOf(&name).Elem(), depth+1); err != nil {
+			return err
+		}
+		var err error
+		if f, ok := fields[name]; ok {
+			err = d.decode(v.Field(f), depth+1)
+		} else {
+			err = d.skip(depth + 1)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the value of tok, and of the elements that follow an array or
+// map header, as the types an empty interface is decoded to
+func (d *decoder) natural(tok token, depth int) (any, error) {
+	switch tok.kind {
+	case tokNil:
+		return nil, nil
+	case tokBool:
+		return tok.b, nil
+	case tokInt:
+		return tok.i, nil
+	case tokUint:
+		return tok.u, nil
+	case tokFloat:
+		return tok.f, nil
+	case tokString:
+		return string(tok.s), nil
+	case tokBytes:
+		return tok.s, nil
+	case tokArray:
+		var s []any
+		return s, d.assignSlice(tok, reflect.ValueOf(&s).Elem(), depth)
+	}
+	var m map[any]any
+	return m, d.assignMap(tok, reflect.ValueOf(&m).Elem(), depth)
+}
+
+// Checks that a key decoded into an interface can be used as a map key. Byte
+// strings, which decode to []byte, are turned into strings.
+func comparableKey(key reflect.Value) error {
+	if key.Kind() != reflect.Interface || key.Comparable() {
+		return nil
+	}
+	if b, ok := key.Interface().([]byte); ok {
+		key.Set(reflect.ValueOf(string(b)))
+		return nil
+	}
+	return fmt.Errorf("%w: map key of type %v isn't comparable", ErrMalformed, key.Elem().Type())
+}
+
+// Reads past the next value without decoding it
+func (d *decoder) skip(depth int) error {
+	if depth > maxDepth {
+		return fmt.Errorf("%w: nested more than %d deep", ErrMalformed, maxDepth)
+	}
+	tok, err := d.r.next()
+	if err != nil || (tok.kind != tokArray && tok.kind != tokMap) {
+		return err
+	}
+	for i := uint64(0); tok.n == indefinite || i < tok.n; i++ {
+		if done, err := d.done(tok); err != nil || done {
+			return err
+		}
+		if err := d.skip(depth + 1); err != nil {
+			return err
+		}
+		if tok.kind == tokMap {
+			if err := d.skip(depth + 1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Reads exactly n bytes of a string, without trusting n for the allocation
+func readString(r io.Reader, n uint64) ([]byte, error) {
+	if n > math.MaxInt32 {
+		return nil, fmt.Errorf("%w: string of %d bytes", ErrMalformed, n)
+	}
+	if n <= maxPrealloc {
+		s := make([]byte, n)
+		_, err := io.ReadFull(r, s)
+		return s, noEOF(err)
+	}
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, r, int64(n)); err != nil {
+		return nil, noEOF(err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Turns an EOF partway through a value into an error
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,215 @@
+package rhmapcodec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	rhmap "github.com/micoo227/robin-hood-hashing"
+)
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+type record struct {
+	Name   string
+	Tags   []string
+	Score  float64
+	Parent *record
+	Raw    []byte
+	Cache  int `rhmap:"-"`
+	secret int
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, format := range []Format{CBOR, MessagePack} {
+		src := must(rhmap.New[int64, record]())
+		for i := int64(-300); i < 300; i++ {
+			src.Set(i*1000, record{Name: strings.Repeat("n", int(i+300)), Tags: []string{"a", "b"}, Score: float64(i) / 3,
+				Parent: &record{Name: "p"}, Raw: []byte{byte(i)}, Cache: 9, secret: 9})
+		}
+		src.Set(math.MinInt64, record{})
+		src.Set(math.MaxInt64, record{Score: math.Inf(-1)})
+
+		var buf bytes.Buffer
+		if err := Encode(&buf, format, src); err != nil {
+			t.Fatalf("%v: Encode returned an error: %v", format, err)
+		}
+		dst := must(rhmap.New[int64, record]())
+		if err := Decode(&buf, format, dst); err != nil {
+			t.Fatalf("%v: Decode returned an error: %v", format, err)
+		}
+		if dst.Len() != src.Len() {
+			t.Fatalf("%v: Expected %d elements, Got %d", format, src.Len(), dst.Len())
+		}
+		for k, v := range src.All() {
+			v.Cache, v.secret = 0, 0
+			if got, _ := dst.Get(k); !reflect.DeepEqual(got, v) {
+				t.Fatalf("%v: Key %d should decode to %+v. Got %+v", format, k, v, got)
+			}
+		}
+	}
+}
+
+func TestDecodeIntoInterfaces(t *testing.T) {
+	for _, format := range []Format{CBOR, MessagePack} {
+		src := must(rhmap.New[string, any]())
+		src.Set("int", -5)
+		src.Set("list", []any{uint8(1), "two", nil})
+		src.Set("map", map[string]float32{"half": .5})
+
+		var buf bytes.Buffer
+		if err := Encode(&buf, format, src); err != nil {
+			t.Fatalf("%v: Encode returned an error: %v", format, err)
+		}
+		dst := must(rhmap.New[any, any]())
+		if err := Decode(&buf, format, dst); err != nil {
+			t.Fatalf("%v: Decode returned an error: %v", format, err)
+		}
+		want := map[any]any{
+			"int":  int64(-5),
+			"list": []any{uint64(1), "two", nil},
+			"map":  map[any]any{"half": .5},
+		}
+		if got := dst.ToMap(); !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: Expected %v, Got %v", format, want, got)
+		}
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	for _, format := range []Format{CBOR, MessagePack} {
+		var first []byte
+		for i := 0; i < 5; i++ {
+			m := must(rhmap.New[string, map[string]int]())
+			for j := 0; j < 50; j++ {
+				m.Set(strings.Repeat("k", j), map[string]int{"x": j, "y": -j, "zz": 0})
+			}
+			var buf bytes.Buffer
+			if err := Encode(&buf, format, m, Deterministic()); err != nil {
+				t.Fatalf("%v: Encode returned an error: %v", format, err)
+			}
+			if first == nil {
+				first = buf.Bytes()
+			} else if !bytes.Equal(buf.Bytes(), first) {
+				t.Fatalf("%v: Equal maps with different seeds should encode identically.", format)
+			}
+		}
+	}
+
+	// RFC 8949 orders keys by their encodings, so shorter strings first
+	m := must(rhmap.New[string, bool]())
+	for _, k := range []string{"aa", "b", "a"} {
+		m.Set(k, true)
+	}
+	var buf bytes.Buffer
+	Encode(&buf, CBOR, m, Deterministic())
+	if got, want := hex.EncodeToString(buf.Bytes()), "a36161f56162f5626161f5"; got != want {
+		t.Errorf("Expected keys ordered a, b, aa: %s, Got %s", want, got)
+	}
+}
+
+// Examples from RFC 8949 appendix A
+func TestCBORVectors(t *testing.T) {
+	for _, tc := range []struct {
+		value any
+		hex   string
+	}{
+		{0, "00"}, {23, "17"}, {24, "1818"}, {1000000, "1a000f4240"},
+		{uint64(18446744073709551615), "1bffffffffffffffff"}, {-1000, "3903e7"},
+		{0.0, "f90000"}, {math.Copysign(0, -1), "f98000"}, {1.5, "f93e00"}, {65504.0, "f97bff"},
+		{100000.0, "fa47c35000"}, {1.1, "fb3ff199999999999a"}, {5.960464477539063e-8, "f90001"},
+		{0.00006103515625, "f90400"}, {-4.0, "f9c400"}, {math.Inf(1), "f97c00"}, {math.NaN(), "f97e00"},
+		{false, "f4"}, {nil, "f6"}, {"IETF", "6449455446"}, {"ü", "62c3bc"},
+		{[]byte{1, 2, 3, 4}, "4401020304"}, {[]int{1, 2, 3}, "83010203"},
+	} {
+		enc := must(newEncoder(CBOR, config{}))
+		got := must(enc.encode(nil, reflect.ValueOf(&tc.value).Elem()))
+		if hex.EncodeToString(got) != tc.hex {
+			t.Errorf("%v should encode as %s. Got %x", tc.value, tc.hex, got)
+		}
+	}
+}
+
+func TestCBORIndefiniteLengths(t *testing.T) {
+	// {"a": [1, [2, 3]], "b": "streaming"}, with every length indefinite
+	data := must(hex.DecodeString("bf" + "6161" + "9f01820203ff" + "6162" + "7f657374726561646d696e67ff" + "ff"))
+	m := must(rhmap.New[string, any]())
+	if err := Decode(bytes.NewReader(data), CBOR, m); err != nil {
+		t.Fatalf("Decode returned an error: %v", err)
+	}
+	want := map[string]any{"a": []any{uint64(1), []any{uint64(2), uint64(3)}}, "b": "streaming"}
+	if got := m.ToMap(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, Got %v", want, got)
+	}
+}
+
+func TestMessagePackVectors(t *testing.T) {
+	m := must(rhmap.New[string, any]())
+	m.Set("compact", true)
+	m.Set("schema", 0)
+	var buf bytes.Buffer
+	Encode(&buf, MessagePack, m, Deterministic())
+	// Keys sort by their encodings, and the shorter fixstr header sorts first
+	if got, want := hex.EncodeToString(buf.Bytes()), "82a6736368656d6100a7636f6d70616374c3"; got != want {
+		t.Errorf("Expected %s, Got %s", want, got)
+	}
+
+	for _, tc := range []struct {
+		value any
+		hex   string
+	}{
+		{-1, "ff"}, {-33, "d0df"}, {128, "cc80"}, {-129, "d1ff7f"}, {1 << 32, "cf0000000100000000"},
+		{float32(1.5), "ca3fc00000"}, {strings.Repeat("x", 32), "d920" + strings.Repeat("78", 32)},
+		{[]byte{1}, "c40101"},
+	} {
+		enc := must(newEncoder(MessagePack, config{}))
+		got := must(enc.encode(nil, reflect.ValueOf(&tc.value).Elem()))
+		if hex.EncodeToString(got) != tc.hex {
+			t.Errorf("%v should encode as %s. Got %x", tc.value, tc.hex, got)
+		}
+		var back any
+		dec := must(newDecoder(MessagePack, bufio.NewReader(bytes.NewReader(got))))
+		if err := dec.decode(reflect.ValueOf(&back).Elem(), 0); err != nil {
+			t.Errorf("%s should decode. Got %v", tc.hex, err)
+		}
+	}
+}
+
+func TestDecodeRejectsMalformedInput(t *testing.T) {
+	for _, tc := range []struct {
+		format Format
+		hex    string
+	}{
+		{CBOR, "a1"},                  // truncated map
+		{CBOR, "a1617a"},              // missing value
+		{CBOR, "a1617a7a7fffffff"},    // string longer than the input
+		{CBOR, "a161611c"},            // reserved additional information
+		{CBOR, "a16161ff"},            // stray break code
+		{CBOR, "a16161820102"},        // array into an int
+		{MessagePack, "81a161d4"},     // extension type
+		{MessagePack, "81a161dc0005"}, // short array
+		{CBOR, "83010203"},            // not a map
+	} {
+		m := must(rhmap.New[string, int]())
+		err := Decode(bytes.NewReader(must(hex.DecodeString(tc.hex))), tc.format, m)
+		if err == nil {
+			t.Errorf("%v input %s should be rejected.", tc.format, tc.hex)
+		}
+	}
+
+	deep := must(hex.DecodeString("a16161" + strings.Repeat("81", 2*maxDepth) + "00"))
+	m := must(rhmap.New[string, any]())
+	if err := Decode(bytes.NewReader(deep), CBOR, m); !errors.Is(err, ErrMalformed) {
+		t.Errorf("Nesting past the depth limit should be malformed. Got %v", err)
+	}
+}
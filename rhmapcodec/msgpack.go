@@ -0,0 +1,172 @@
+package rhmapcodec
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Writes MessagePack with every integer and length in its shortest form.
+// Floats keep their precision, as MessagePack tells float32 from float64.
+type msgpackWriter struct{}
+
+func (msgpackWriter) appendNil(b []byte) []byte {
+	return append(b, 0xc0)
+}
+
+func (msgpackWriter) appendBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, 0xc3)
+	}
+	return append(b, 0xc2)
+}
+
+func (w msgpackWriter) appendInt(b []byte, v int64) []byte {
+	switch {
+	case v >= 0:
+		return w.appendUint(b, uint64(v))
+	case v >= -32:
+		return append(b, byte(v))
+	case v >= math.MinInt8:
+		return append(b, 0xd0, byte(v))
+	case v >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(b, 0xd1), uint16(v))
+	case v >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(b, 0xd2), uint32(v))
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(v))
+}
+
+func (msgpackWriter) appendUint(b []byte, v uint64) []byte {
+	switch {
+	case v <= math.MaxInt8:
+		return append(b, byte(v))
+	case v <= math.MaxUint8:
+		return append(b, 0xcc, byte(v))
+	case v <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xcd), uint16(v))
+	case v <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, 0xce), uint32(v))
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0xcf), v)
+}
+
+func (msgpackWriter) appendFloat(b []byte, v float64, bits int) []byte {
+	if bits == 32 {
+		return binary.BigEndian.AppendUint32(append(b, 0xca), math.Float32bits(float32(v)))
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0xcb), math.Float64bits(v))
+}
+
+// Appends a header for a string, byte string, array or map of n elements,
+// given the fix type's prefix and limit and the prefixes of its 8, 16 and
+// 32-bit length forms, 0 where there is none
+func appendMsgpackHeader(b []byte, n uint64, fix byte, fixMax uint64, p8, p16, p32 byte) []byte {
+	switch {
+	case fix != 0 && n <= fixMax:
+		return append(b, fix|byte(n))
+	case p8 != 0 && n <= math.MaxUint8:
+		return append(b, p8, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, p16), uint16(n))
+	}
+	return binary.BigEndian.AppendUint32(append(b, p32), uint32(n))
+}
+
+func (msgpackWriter) appendString(b []byte, s string) []byte {
+	return append(appendMsgpackHeader(b, uint64(len(s)), 0xa0, 31, 0xd9, 0xda, 0xdb), s...)
+}
+
+func (msgpackWriter) appendBytes(b []byte, p []byte) []byte {
+	return append(appendMsgpackHeader(b, uint64(len(p)), 0, 0, 0xc4, 0xc5, 0xc6), p...)
+}
+
+func (msgpackWriter) appendArray(b []byte, n uint64) []byte {
+	return appendMsgpackHeader(b, n, 0x90, 15, 0, 0xdc, 0xdd)
+}
+
+func (msgpackWriter) appendMap(b []byte, n uint64) []byte {
+	return appendMsgpackHeader(b, n, 0x80, 15, 0, 0xde, 0xdf)
+}
+
+// Reads MessagePack. Extension types are rejected.
+type msgpackReader struct {
+	r *bufio.Reader
+}
+
+// Reads an n-byte big-endian unsigned integer
+func (m msgpackReader) uint(n int) (uint64, error) {
+	var buf [8]byte
+	if _, err := io.ReadFull(m.r, buf[8-n:]); err != nil {
+		return 0, noEOF(err)
+	}
+	return binary.BigEndian.Uint64(buf[:]), nil
+}
+
+func (m msgpackReader) next() (token, error) {
+	b, err := m.r.ReadByte()
+	if err != nil {
+		return token{}, err
+	}
+
+	switch {
+	case b <= 0x7f:
+		return token{kind: tokUint, u: uint64(b)}, nil
+	case b >= 0xe0:
+		return token{kind: tokInt, i: int64(int8(b))}, nil
+	case b&0xf0 == 0x80:
+		return token{kind: tokMap, n: uint64(b & 0x0f)}, nil
+	case b&0xf0 == 0x90:
+		return token{kind: tokArray, n: uint64(b & 0x0f)}, nil
+	case b&0xe0 == 0xa0:
+		s, err := readString(m.r, uint64(b&0x1f))
+		return token{kind: tokString, s: s}, err
+	}
+
+	switch b {
+	case 0xc0:
+		return token{kind: tokNil}, nil
+	case 0xc2, 0xc3:
+		return token{kind: tokBool, b: b == 0xc3}, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, err := m.uint(1 << (b - 0xcc))
+		return token{kind: tokUint, u: u}, err
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		n := 1 << (b - 0xd0)
+		u, err := m.uint(n)
+		// Sign-extend from the top bit of the n bytes read
+		shift := 64 - 8*n
+		return token{kind: tokInt, i: int64(u<<shift) >> shift}, err
+	case 0xca:
+		u, err := m.uint(4)
+		return token{kind: tokFloat, f: float64(math.Float32frombits(uint32(u)))}, err
+	case 0xcb:
+		u, err := m.uint(8)
+		return token{kind: tokFloat, f: math.Float64frombits(u)}, err
+	case 0xd9, 0xda, 0xdb, 0xc4, 0xc5, 0xc6:
+		kind, width := tokString, 1<<(b-0xd9)
+		if b <= 0xc6 {
+			kind, width = tokBytes, 1<<(b-0xc4)
+		}
+		n, err := m.uint(width)
+		if err != nil {
+			return token{}, err
+		}
+		s, err := readString(m.r, n)
+		return token{kind: kind, s: s}, err
+	case 0xdc, 0xdd:
+		n, err := m.uint(2 << (b - 0xdc))
+		return token{kind: tokArray, n: n}, err
+	case 0xde, 0xdf:
+		n, err := m.uint(2 << (b - 0xde))
+		return token{kind: tokMap, n: n}, err
+	}
+	return token{}, fmt.Errorf("%w: unsupported MessagePack type %#x", ErrMalformed, b)
+}
+
+// MessagePack has no indefinite lengths
+func (msgpackReader) atBreak() (bool, error) {
+	return false, nil
+}
@@ -0,0 +1,282 @@
+// Package rhmaptest checks map implementations against Go's built-in map.
+// Check drives an implementation through random sequences of operations,
+// comparing every result with a built-in map given the same operations,
+// and shrinks any sequence that tells them apart to a short one that still
+// does. Implementations that validate their own invariants, as rhmap.Map
+// does, are validated after every operation, and CheckInvariants checks
+// those of an rhmap.Map on its own.
+package rhmaptest
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"iter"
+	"math/rand/v2"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	rhmap "github.com/micoo227/robin-hood-hashing"
+)
+
+// Map-like implementation under test. It is also checked through the
+// methods it has of Clear(), All() iter.Seq2[K, V] and Validate() error.
+type Map[K comparable, V any] interface {
+	Set(key K, value V)
+	Get(key K) (V, bool)
+	Delete(key K)
+	Len() uint64
+}
+
+type clearer interface {
+	Clear()
+}
+
+type validator interface {
+	Validate() error
+}
+
+type ranger[K comparable, V any] interface {
+	All() iter.Seq2[K, V]
+}
+
+// Kind of an operation in a checked sequence
+type OpKind uint8
+
+const (
+	OpSet OpKind = iota
+	OpGet
+	OpDelete
+	// Generated only for implementations with a Clear method
+	OpClear
+)
+
+// Operation in a checked sequence. Value is only used by OpSet.
+type Op[K comparable, V any] struct {
+	Kind  OpKind
+	Key   K
+	Value V
+}
+
+func (o Op[K, V]) String() string {
+	switch o.Kind {
+	case OpSet:
+		return fmt.Sprintf("Set(%#v, %#v)", o.Key, o.Value)
+	case OpGet:
+		return fmt.Sprintf("Get(%#v)", o.Key)
+	case OpDelete:
+		return fmt.Sprintf("Delete(%#v)", o.Key)
+	case OpClear:
+		return "Clear()"
+	}
+	return "Op(" + strconv.Itoa(int(o.Kind)) + ")"
+}
+
+// Settings of Check. Keys and Values are required.
+type Config[K comparable, V any] struct {
+	// Generate the keys and values of operations. Drawing keys from a small
+	// set makes the sequences update and delete present keys often.
+	Keys   func(r *rand.Rand) K
+	Values func(r *rand.Rand) V
+	// Compares values, reflect.DeepEqual if nil
+	Equal func(a, b V) bool
+	// Sequences to run, 50 if 0, and operations in each, 500 if 0
+	Runs int
+	Ops  int
+	// Seeds the random sequences, so that a seed reproduces a failure
+	Seed uint64
+}
+
+// Sequence of operations that told an implementation apart from the
+// built-in map, shrunk as far as Check could while it kept failing
+type Failure[K comparable, V any] struct {
+	// Seed of Check's random source and the sequence that failed
+	Seed uint64
+	Run  int
+	// The shrunk sequence, whose last operation went wrong
+	Ops []Op[K, V]
+	// What went wrong
+	Problem string
+}
+
+func (f *Failure[K, V]) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "rhmaptest: run %d of seed %d: %s after", f.Run, f.Seed, f.Problem)
+	for i, op := range f.Ops {
+		fmt.Fprintf(&b, "\n\t%d: %v", i, op)
+	}
+	return b.String()
+}
+
+// Runs random operation sequences against maps created by newMap and
+// built-in maps side by side, returning nil if every result agreed, or a
+// *Failure holding the shortest failing sequence found. Each sequence gets
+// a fresh map.
+func Check[K comparable, V any](newMap func() Map[K, V], cfg Config[K, V]) error {
+	if cfg.Keys == nil || cfg.Values == nil {
+		return errors.New("rhmaptest: Config needs Keys and Values")
+	}
+	equal := cfg.Equal
+	if equal == nil {
+		equal = func(a, b V) bool { return reflect.DeepEqual(a, b) }
+	}
+	runs, n := cmp.Or(cfg.Runs, 50), cmp.Or(cfg.Ops, 500)
+
+	for run := range runs {
+		r := rand.New(rand.NewPCG(cfg.Seed, uint64(run)))
+		_, clears := newMap().(clearer)
+		ops := make([]Op[K, V], n)
+		for i := range ops {
+			ops[i] = randomOp(r, cfg, clears)
+		}
+
+		at, problem := replay(newMap(), ops, equal)
+		if at < 0 {
+			continue
+		}
+		ops = shrink(ops[:at+1], func(ops []Op[K, V]) bool {
+			at, _ := replay(newMap(), ops, equal)
+			return at >= 0
+		})
+		_, problem = replay(newMap(), ops, equal)
+		return &Failure[K, V]{Seed: cfg.Seed, Run: run, Ops: ops, Problem: problem}
+	}
+	return nil
+}
+
+// Applies ops to m and to a built-in map, returning nil if every result
+// agreed, or an error naming the first operation that went wrong. Replaying
+// a Failure's operations this way makes a regression test of it.
+func Replay[K comparable, V any](m Map[K, V], ops []Op[K, V], equal func(a, b V) bool) error {
+	if equal == nil {
+		equal = func(a, b V) bool { return reflect.DeepEqual(a, b) }
+	}
+	if at, problem := replay(m, ops, equal); at >= 0 {
+		return fmt.Errorf("rhmaptest: operation %d, %v: %s", at, ops[at], problem)
+	}
+	return nil
+}
+
+// Checks the invariants of m that hold whatever its contents: those
+// Validate checks, with every element PSL slots from its home, no element
+// passing a richer one and the PSL statistics agreeing with the slots, and
+// iteration yielding Len distinct keys that Get finds with the values
+// yielded
+func CheckInvariants[K comparable, V any](m *rhmap.Map[K, V]) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	seen := make(map[K]struct{}, m.Len())
+	for k, v := range m.All() {
+		if _, ok := seen[k]; ok {
+			return fmt.Errorf("rhmaptest: All yields %#v twice", k)
+		}
+		seen[k] = struct{}{}
+		if got, ok := m.Get(k); k == k && (!ok || !reflect.DeepEqual(got, v)) {
+			return fmt.Errorf("rhmaptest: All yields %#v with %#v but Get returns %#v, %v", k, v, got, ok)
+		}
+	}
+	if uint64(len(seen)) != m.Len() {
+		return fmt.Errorf("rhmaptest: All yields %d keys but Len is %d", len(seen), m.Len())
+	}
+	return nil
+}
+
+func randomOp[K comparable, V any](r *rand.Rand, cfg Config[K, V], clears bool) Op[K, V] {
+	switch n := r.IntN(100); {
+	case n < 45:
+		return Op[K, V]{Kind: OpSet, Key: cfg.Keys(r), Value: cfg.Values(r)}
+	case n < 75:
+		return Op[K, V]{Kind: OpGet, Key: cfg.Keys(r)}
+	case n < 99 || !clears:
+		return Op[K, V]{Kind: OpDelete, Key: cfg.Keys(r)}
+	}
+	return Op[K, V]{Kind: OpClear}
+}
+
+// Applies ops to m and to a built-in map, returning the index of the first
+// operation after which they disagree and how, or -1. Panics count as
+// disagreements.
+func replay[K comparable, V any](m Map[K, V], ops []Op[K, V], equal func(a, b V) bool) (at int, problem string) {
+	model := make(map[K]V)
+	defer func() {
+		if r := recover(); r != nil {
+			problem = fmt.Sprintf("panic: %v", r)
+		}
+	}()
+
+	for at = range ops {
+		op := ops[at]
+		switch op.Kind {
+		case OpSet:
+			m.Set(op.Key, op.Value)
+			model[op.Key] = op.Value
+		case OpGet:
+			got, ok := m.Get(op.Key)
+			want, wantOk := model[op.Key]
+			if ok != wantOk || (ok && !equal(got, want)) {
+				return at, fmt.Sprintf("Get(%#v) = %#v, %v; want %#v, %v", op.Key, got, ok, want, wantOk)
+			}
+		case OpDelete:
+			m.Delete(op.Key)
+			delete(model, op.Key)
+		case OpClear:
+			m.(clearer).Clear()
+			clear(model)
+		}
+
+		if got := m.Len(); got != uint64(len(model)) {
+			return at, fmt.Sprintf("Len() = %d; want %d", got, len(model))
+		}
+		if v, ok := m.(validator); ok {
+			if err := v.Validate(); err != nil {
+				return at, err.Error()
+			}
+		}
+		if op.Kind == OpClear || at == len(ops)-1 {
+			if problem := compareAll(m, model, equal); problem != "" {
+				return at, problem
+			}
+		}
+	}
+	return -1, ""
+}
+
+// Compares the elements m iterates over, if it can, with model's
+func compareAll[K comparable, V any](m Map[K, V], model map[K]V, equal func(a, b V) bool) string {
+	r, ok := m.(ranger[K, V])
+	if !ok {
+		return ""
+	}
+	seen := make(map[K]struct{}, len(model))
+	for k, v := range r.All() {
+		want, ok := model[k]
+		if _, dup := seen[k]; dup || !ok || !equal(v, want) {
+			return fmt.Sprintf("All() yields %#v, %#v, which the model doesn't hold once", k, v)
+		}
+		seen[k] = struct{}{}
+	}
+	if len(seen) != len(model) {
+		return fmt.Sprintf("All() yields %d elements; want %d", len(seen), len(model))
+	}
+	return ""
+}
+
+// Removes operations from ops while fails still reports the shorter
+// sequence failing, first in halves and then in ever smaller chunks down to
+// single operations
+func shrink[K comparable, V any](ops []Op[K, V], fails func([]Op[K, V]) bool) []Op[K, V] {
+	for chunk := len(ops) / 2; chunk >= 1; chunk /= 2 {
+		for i := 0; i+chunk <= len(ops); {
+			shorter := slices.Concat(ops[:i], ops[i+chunk:])
+			if len(shorter) > 0 && fails(shorter) {
+				ops = shorter
+			} else {
+				i += chunk
+			}
+		}
+	}
+	return ops
+}
@@ -0,0 +1,121 @@
+package rhmaptest
+
+import (
+	"errors"
+	"math/rand/v2"
+	"strings"
+	"testing"
+
+	rhmap "github.com/micoo227/robin-hood-hashing"
+)
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+var intConfig = Config[int, int]{
+	Keys:   func(r *rand.Rand) int { return r.IntN(64) },
+	Values: func(r *rand.Rand) int { return r.IntN(1000) },
+	Runs:   20,
+	Seed:   1,
+}
+
+func TestCheckPassesRhmap(t *testing.T) {
+	for name, newMap := range map[string]func() Map[int, int]{
+		"default":     func() Map[int, int] { return must(rhmap.New[int, int]()) },
+		"incremental": func() Map[int, int] { return must(rhmap.New[int, int](rhmap.WithIncrementalRehash(2))) },
+		"shrinking":   func() Map[int, int] { return must(rhmap.New[int, int](rhmap.WithShrink(.3))) },
+		"grouped":     func() Map[int, int] { return must(rhmap.New[int, int](rhmap.WithGroupProbing())) },
+		"growth":      func() Map[int, int] { return must(rhmap.New[int, int](rhmap.WithGrowthFactor(1.5))) },
+		"concurrent":  func() Map[int, int] { return must(rhmap.NewConcurrent[int, int](4)) },
+		"ordered":     func() Map[int, int] { return must(rhmap.NewOrdered[int, int]()) },
+		"sorted":      func() Map[int, int] { return rhmap.NewSorted[int, int]() },
+		"compact":     func() Map[int, int] { return must(rhmap.NewCompactMap[int, int]()) },
+	} {
+		if err := Check(newMap, intConfig); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+// Built-in map that forgets to delete keys once it holds three or more
+type forgetful map[int]int
+
+func (f forgetful) Set(k, v int)          { f[k] = v }
+func (f forgetful) Get(k int) (int, bool) { v, ok := f[k]; return v, ok }
+func (f forgetful) Len() uint64           { return uint64(len(f)) }
+func (f forgetful) Delete(k int) {
+	if len(f) < 3 {
+		delete(f, k)
+	}
+}
+
+func TestCheckShrinksFailures(t *testing.T) {
+	err := Check(func() Map[int, int] { return forgetful{} }, intConfig)
+	var failure *Failure[int, int]
+	if !errors.As(err, &failure) {
+		t.Fatalf("Expected a *Failure, Got %v", err)
+	}
+	// Three Sets of distinct keys and a Delete of one of them
+	if len(failure.Ops) != 4 {
+		t.Errorf("Expected the failure shrunk to 4 operations, Got %v", failure)
+	}
+	if !strings.Contains(failure.Problem, "Len() = 3; want 2") {
+		t.Errorf("Expected a Len mismatch, Got %q", failure.Problem)
+	}
+	if Replay(forgetful{}, failure.Ops, nil) == nil {
+		t.Errorf("Replaying a failure should fail again.")
+	}
+	if err := Replay(must(rhmap.New[int, int]()), failure.Ops, nil); err != nil {
+		t.Errorf("Replaying a failure against a correct map should pass. Got %v", err)
+	}
+}
+
+// Built-in map that panics on key 7
+type fragile map[int]int
+
+func (f fragile) Set(k, v int) {
+	if k == 7 {
+		panic("key 7")
+	}
+	f[k] = v
+}
+func (f fragile) Get(k int) (int, bool) { v, ok := f[k]; return v, ok }
+func (f fragile) Len() uint64           { return uint64(len(f)) }
+func (f fragile) Delete(k int)          { delete(f, k) }
+
+func TestCheckCatchesPanics(t *testing.T) {
+	err := Check(func() Map[int, int] { return fragile{} }, intConfig)
+	var failure *Failure[int, int]
+	if !errors.As(err, &failure) {
+		t.Fatalf("Expected a *Failure, Got %v", err)
+	}
+	if len(failure.Ops) != 1 || failure.Ops[0] != (Op[int, int]{Kind: OpSet, Key: 7, Value: failure.Ops[0].Value}) {
+		t.Errorf("Expected the failure shrunk to a Set of key 7, Got %v", failure)
+	}
+	if !strings.Contains(failure.Problem, "panic: key 7") {
+		t.Errorf("Expected the panic reported, Got %q", failure.Problem)
+	}
+}
+
+func TestCheckNeedsGenerators(t *testing.T) {
+	if err := Check(func() Map[int, int] { return forgetful{} }, Config[int, int]{}); err == nil {
+		t.Errorf("A Config without Keys and Values should be rejected.")
+	}
+}
+
+func TestCheckInvariants(t *testing.T) {
+	m := must(rhmap.New[string, []byte](rhmap.WithIncrementalRehash(1)))
+	for i := 0; i < 1000; i++ {
+		m.Set(strings.Repeat("k", i%97), []byte{byte(i)})
+		if i%3 == 0 {
+			m.Delete(strings.Repeat("k", i%89))
+		}
+		if err := CheckInvariants(m); err != nil {
+			t.Fatalf("After %d operations: %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package rhmap
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+	"strconv"
+)
+
+// Point on a Ring: one virtual node of a real node
+type ringPoint struct {
+	hash uint64
+	node string
+}
+
+// Consistent hash ring assigning keys to named nodes, each placed at several
+// virtual points to even out the load. Adding or removing a node only moves
+// the keys adjacent to its points. Keys are encoded and hashed as map keys
+// are, so processes sharing seeds through WithSeedsFrom or WithDeterministic
+// agree on every key's owner.
+type Ring[K comparable] struct {
+	points []ringPoint
+	vnodes int
+	hasher Hasher
+	enc    keyEncoder[K]
+	k0     uint64
+	k1     uint64
+}
+
+// Creates an empty ring placing each node at vnodes points. WithHasher,
+// WithSeedsFrom and WithDeterministic choose how keys and nodes are hashed;
+// other options are ignored. It returns an error if vnodes is not positive
+// or K can't be encoded.
+func NewRing[K comparable](vnodes int, opts ...Option) (*Ring[K], error) {
+	if vnodes <= 0 {
+		return nil, errors.New("rhmap: ring needs at least one virtual node per node")
+	}
+	enc, err := newKeyEncoder[K]()
+	if err != nil {
+		return nil, err
+	}
+
+	hasher, k0, k1 := resolveOptions(opts).hashing()
+	return &Ring[K]{vnodes: vnodes, hasher: hasher, enc: enc, k0: k0, k1: k1}, nil
+}
+
+// Adds node to the ring. Adding a node already present does nothing.
+func (r *Ring[K]) AddNode(node string) {
+	if slices.Contains(r.Nodes(), node) {
+		return
+	}
+	for i := 0; i < r.vnodes; i++ {
+		r.points = append(r.points, ringPoint{r.pointHash(node, i), node})
+	}
+	slices.SortFunc(r.points, compareRingPoints)
+}
+
+// Removes node and hands its keys to the nodes following its points
+func (r *Ring[K]) RemoveNode(node string) {
+	r.points = slices.DeleteFunc(r.points, func(p ringPoint) bool { return p.node == node })
+}
+
+// Returns the node owning key, or false if the ring is empty
+func (r *Ring[K]) Owner(key K) (string, bool) {
+	if len(r.points) == 0 {
+		return "", false
+	}
+
+	hash := r.enc.hash(r.hasher, r.k0, r.k1, key)
+	i, _ := slices.BinarySearchFunc(r.points, hash, func(p ringPoint, h uint64) int {
+		return cmp.Compare(p.hash, h)
+	})
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].node, true
+}
+
+// Returns the nodes on the ring in sorted order
+func (r *Ring[K]) Nodes() []string {
+	var nodes []string
+	for _, p := range r.points {
+		nodes = append(nodes, p.node)
+	}
+	slices.Sort(nodes)
+	return slices.Compact(nodes)
+}
+
+// Hash of a node's i-th virtual point, from its name and index
+func (r *Ring[K]) pointHash(node string, i int) uint64 {
+	var scratch [keyScratchSize]byte
+	p := strconv.AppendInt(append(append(scratch[:0], node...), '#'), int64(i), 10)
+	return hashBytes(r.hasher, r.k0, r.k1, p)
+}
+
+// Orders points by hash, breaking ties by node name so that every process
+// builds the same ring
+func compareRingPoints(a, b ringPoint) int {
+	return cmp.Or(cmp.Compare(a.hash, b.hash), cmp.Compare(a.node, b.node))
+}
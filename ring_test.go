@@ -0,0 +1,64 @@
+package rhmap
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRing(t *testing.T) {
+	r := must(NewRing[int](100, WithDeterministic(1)))
+	if _, ok := r.Owner(1); ok {
+		t.Errorf("An empty ring should own nothing.")
+	}
+
+	nodes := []string{"a", "b", "c", "d"}
+	for _, node := range nodes {
+		r.AddNode(node)
+	}
+	r.AddNode("a")
+	if !slices.Equal(r.Nodes(), nodes) || len(r.points) != 400 {
+		t.Errorf("Ring should hold 4 nodes at 100 points each. Got %v, %d points", r.Nodes(), len(r.points))
+	}
+
+	owners := make(map[int]string)
+	load := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		owners[i], _ = r.Owner(i)
+		load[owners[i]]++
+	}
+	for _, node := range nodes {
+		if load[node] < 1500 || load[node] > 3500 {
+			t.Errorf("Node %s should own about a quarter of the keys. Owns %d", node, load[node])
+		}
+	}
+
+	r.RemoveNode("b")
+	for i := 0; i < 10000; i++ {
+		owner, _ := r.Owner(i)
+		if owners[i] != "b" && owner != owners[i] {
+			t.Errorf("Key %d should stay on %s when another node leaves. Moved to %s", i, owners[i], owner)
+		}
+		if owner == "b" {
+			t.Errorf("Key %d should not be owned by a removed node.", i)
+		}
+	}
+}
+
+func TestRingAgreesAcrossProcesses(t *testing.T) {
+	r1 := must(NewRing[string](50, WithDeterministic(7)))
+	r2 := must(NewRing[string](50, WithDeterministic(7)))
+	for _, node := range []string{"x", "y", "z"} {
+		r1.AddNode(node)
+	}
+	for _, node := range []string{"z", "x", "y"} {
+		r2.AddNode(node)
+	}
+
+	for _, key := range []string{"alpha", "beta", "gamma", "delta"} {
+		o1, _ := r1.Owner(key)
+		o2, _ := r2.Owner(key)
+		if o1 != o2 {
+			t.Errorf("Rings with the same seeds and nodes should agree on %s. Got %s and %s", key, o1, o2)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package rhmap
+
+import "math/rand/v2"
+
+// Random slots Random and Sample draw in a row without finding an element
+// before they fall back to a pass over the table
+const maxRandomDraws = 64
+
+// Returns an element chosen uniformly at random, and false if the map is
+// empty. Random slots are drawn until one holds an element, which takes
+// 1/load draws on average, so that cache probing or randomized eviction
+// costs O(1) per draw; a map left sparse by deletes falls back to counting
+// its way to a random element.
+func (m *Map[K, V]) Random() (K, V, bool) {
+	if m.numElements == 0 {
+		var zeroKey K
+		var zeroVal V
+		return zeroKey, zeroVal, false
+	}
+	tables := m.tables()
+	slots := uint64(len(tables[0]) + len(tables[1]))
+	for range maxRandomDraws {
+		if e := slotOf(tables, rand.Uint64N(slots)); e.set {
+			return e.key, e.value, true
+		}
+	}
+
+	n := rand.Uint64N(m.numElements)
+	for _, elements := range tables {
+		for i := range elements {
+			if !elements[i].set {
+				continue
+			}
+			if n == 0 {
+				return elements[i].key, elements[i].value, true
+			}
+			n--
+		}
+	}
+	panic("rhmap: element count exceeds the elements in the table")
+}
+
+// Returns n distinct elements chosen uniformly at random, in no particular
+// order, or every element if the map holds no more than n. A sample under a
+// quarter of the map draws random slots as Random does, skipping those
+// already drawn; a larger one, or one from a sparse table, is a reservoir
+// sample taken in one pass over the table.
+func (m *Map[K, V]) Sample(n int) []Entry[K, V] {
+	if n <= 0 || m.numElements == 0 {
+		return nil
+	}
+	tables := m.tables()
+	if uint64(n) >= m.numElements {
+		sample := make([]Entry[K, V], 0, m.numElements)
+		for _, elements := range tables {
+			for i := range elements {
+				if elements[i].set {
+					sample = append(sample, Entry[K, V]{elements[i].key, elements[i].value})
+				}
+			}
+		}
+		return sample
+	}
+
+	sample := make([]Entry[K, V], 0, n)
+	if uint64(n) < m.numElements/4 {
+		slots := uint64(len(tables[0]) + len(tables[1]))
+		drawn := make(map[uint64]struct{}, n)
+		for misses := 0; len(sample) < n && misses < maxRandomDraws; {
+			i := rand.Uint64N(slots)
+			e := slotOf(tables, i)
+			if _, ok := drawn[i]; ok || !e.set {
+				misses++
+				continue
+			}
+			drawn[i] = struct{}{}
+			sample = append(sample, Entry[K, V]{e.key, e.value})
+			misses = 0
+		}
+		if len(sample) == n {
+			return sample
+		}
+		sample = sample[:0]
+	}
+
+	// Algorithm R: the element seen c-th replaces a random member of the
+	// sample with probability n/c
+	var seen uint64
+	for _, elements := range tables {
+		for i := range elements {
+			if !elements[i].set {
+				continue
+			}
+			seen++
+			if len(sample) < n {
+				sample = append(sample, Entry[K, V]{elements[i].key, elements[i].value})
+			} else if j := rand.Uint64N(seen); j < uint64(n) {
+				sample[j] = Entry[K, V]{elements[i].key, elements[i].value}
+			}
+		}
+	}
+	return sample
+}
+
+// Returns slot i of the tables taken end to end
+func slotOf[K comparable, V any](tables [2][]element[K, V], i uint64) *element[K, V] {
+	if i < uint64(len(tables[0])) {
+		return &tables[0][i]
+	}
+	return &tables[1][i-uint64(len(tables[0]))]
+}
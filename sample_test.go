@@ -0,0 +1,89 @@
+package rhmap
+
+import (
+	"math"
+	"testing"
+)
+
+// Reports whether counts, which should each be near expected, pass a
+// chi-squared test at a generous threshold
+func looksUniform(counts map[int]int, keys int, expected float64) bool {
+	var chi2 float64
+	for k := 0; k < keys; k++ {
+		d := float64(counts[k]) - expected
+		chi2 += d * d / expected
+	}
+	// Far beyond the 99.9th percentile for the degrees of freedom used here
+	return chi2 < float64(keys)+6*math.Sqrt(2*float64(keys))
+}
+
+func TestRandom(t *testing.T) {
+	m := must(New[int, int]())
+	if _, _, ok := m.Random(); ok {
+		t.Errorf("An empty map should have no random element.")
+	}
+
+	// Clusters make the first element after a random slot far from uniform
+	for i := 0; i < 50; i++ {
+		m.Set(i, i*2)
+	}
+	counts := make(map[int]int)
+	for i := 0; i < 50000; i++ {
+		k, v, ok := m.Random()
+		if !ok || v != k*2 {
+			t.Fatalf("Expected a present element, Got %d %d %v", k, v, ok)
+		}
+		counts[k]++
+	}
+	if !looksUniform(counts, 50, 1000) {
+		t.Errorf("Random elements should be uniform. Got counts %v", counts)
+	}
+
+	// A sparse table falls back to counting
+	sparse := must(New[int, int](WithSize(1 << 16)))
+	sparse.Set(1, 1)
+	sparse.Set(2, 2)
+	counts = make(map[int]int)
+	for i := 0; i < 2000; i++ {
+		k, _, _ := sparse.Random()
+		counts[k]++
+	}
+	if len(counts) != 2 || counts[1] < 800 || counts[2] < 800 {
+		t.Errorf("Both elements of a sparse map should come up evenly. Got counts %v", counts)
+	}
+}
+
+func TestSample(t *testing.T) {
+	m := must(New[int, int](WithIncrementalRehash(1)))
+	if s := m.Sample(3); s != nil {
+		t.Errorf("An empty map should sample nothing. Got %v", s)
+	}
+	for i := 0; i < 100; i++ {
+		m.Set(i, -i)
+	}
+
+	if s := m.Sample(1000); len(s) != 100 {
+		t.Errorf("A sample of more than the map should hold every element. Got %d", len(s))
+	}
+	// Slot draws below a quarter of the map, a reservoir above
+	for _, n := range []int{5, 60} {
+		counts := make(map[int]int)
+		for i := 0; i < 5000; i++ {
+			s := m.Sample(n)
+			if len(s) != n {
+				t.Fatalf("Expected %d elements, Got %d", n, len(s))
+			}
+			seen := make(map[int]bool)
+			for _, e := range s {
+				if seen[e.Key] || e.Value != -e.Key {
+					t.Fatalf("Expected distinct present elements, Got %v", s)
+				}
+				seen[e.Key] = true
+				counts[e.Key]++
+			}
+		}
+		if !looksUniform(counts, 100, float64(5000*n)/100) {
+			t.Errorf("Samples of %d should be uniform. Got counts %v", n, counts)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package rhmap
+
+import "slices"
+
+// Default number of slots in each segment of a SegmentedMap
+const defaultSegmentSize uint64 = 1 << 16
+
+// Fixed-size robin hood table owning the keys whose hashes share its
+// leading depth bits. A segment whose keys all agree on the next bit can't be
+// split usefully and overflows instead, growing by rehashing like a Map.
+type segment[K comparable, V any] struct {
+	table    *Map[K, V]
+	depth    uint
+	overflow bool
+}
+
+// Robin hood hashmap split into fixed-size segments behind an extendible
+// hashing directory. Growth splits only the segment that filled up, so the
+// memory allocated by a single grow is bounded by the segment size rather
+// than by the size of the whole table.
+type SegmentedMap[K comparable, V any] struct {
+	dir         []*segment[K, V]
+	depth       uint
+	segmentSize uint64
+	numElements uint64
+	opts        []Option
+}
+
+// Creates a segmented map whose segments each hold segmentSize slots, or the
+// default segment size if segmentSize is 0. Options apply to every segment;
+// WithSize is ignored. It returns an error if K can't be encoded, as New does.
+func NewSegmented[K comparable, V any](segmentSize uint64, opts ...Option) (*SegmentedMap[K, V], error) {
+	if segmentSize == 0 {
+		segmentSize = defaultSegmentSize
+	}
+
+	s := &SegmentedMap[K, V]{
+		segmentSize: segmentSize,
+		opts:        append(slices.Clip(opts), WithSize(segmentSize)),
+	}
+	first, err := New[K, V](s.opts...)
+	if err != nil {
+		return nil, err
+	}
+	// Every segment must hash identically for the directory to route keys,
+	// so none may reseed itself
+	first.autoReseed = false
+	s.opts = append(s.opts, WithSeedsFrom(first))
+	s.dir = []*segment[K, V]{{table: first}}
+
+	return s, nil
+}
+
+func (s *SegmentedMap[K, V]) Set(key K, value V) {
+	hash := s.dir[0].table.hashKey(key)
+
+	for {
+		seg := s.dir[s.dirIndex(hash)]
+		t := seg.table
+
+		if _, ok, i := t.getForUpdate(key, hash); ok {
+			t.unshare()
+			t.elements[i].value = value
+			return
+		}
+
+		if seg.overflow {
+			t.Set(key, value)
+			s.numElements++
+			return
+		}
+		if float32(float64(t.numElements)/float64(t.size)) < t.loadFactor {
+			t.unshare()
+			t.insertWithHash(key, value, hash)
+			s.numElements++
+			return
+		}
+
+		s.split(seg)
+	}
+}
+
+func (s *SegmentedMap[K, V]) Get(key K) (V, bool) {
+	hash := s.dir[0].table.hashKey(key)
+	val, ok, _ := s.dir[s.dirIndex(hash)].table.getWithHash(key, hash)
+	return val, ok
+}
+
+func (s *SegmentedMap[K, V]) Delete(key K) {
+	hash := s.dir[0].table.hashKey(key)
+	if s.dir[s.dirIndex(hash)].table.removeWithHash(key, hash) {
+		s.numElements--
+	}
+}
+
+func (s *SegmentedMap[K, V]) Len() uint64 {
+	return s.numElements
+}
+
+// Returns the number of distinct segments backing the map
+func (s *SegmentedMap[K, V]) Segments() int {
+	n := 0
+	for i, seg := range s.dir {
+		if i == 0 || seg != s.dir[i-1] {
+			n++
+		}
+	}
+	return n
+}
+
+// Directory slot for a hash: its leading depth bits
+func (s *SegmentedMap[K, V]) dirIndex(hash uint64) uint64 {
+	return hash >> (64 - s.depth)
+}
+
+// Moves the elements of seg whose next hash bit is set into a new segment,
+// doubling the directory first if seg already uses every directory bit. Only
+// the new segment is allocated; seg is compacted in place. If every element
+// agrees on the next bit, seg is marked as overflowing instead.
+func (s *SegmentedMap[K, V]) split(seg *segment[K, V]) {
+	bit := uint64(1) << (63 - seg.depth)
+	seg.table.finishRehash()
+
+	var moving uint64
+	for _, elem := range seg.table.elements {
+		if elem.set && elem.hash&bit != 0 {
+			moving++
+		}
+	}
+	if moving == 0 || moving == seg.table.numElements || seg.depth == 63 {
+		seg.overflow = true
+		return
+	}
+
+	if seg.depth == s.depth {
+		dir := make([]*segment[K, V], 2*len(s.dir))
+		for i := range dir {
+			dir[i] = s.dir[i>>1]
+		}
+		s.dir = dir
+		s.depth++
+	}
+
+	sibling := &segment[K, V]{table: newMap[K, V](seg.table.enc, s.opts...)}
+
+	var moved []K
+	for _, elem := range seg.table.elements {
+		if !elem.set {
+			continue
+		}
+		if elem.hash&bit != 0 {
+			sibling.table.insertWithHash(elem.key, elem.value, elem.hash)
+			moved = append(moved, elem.key)
+		}
+	}
+	seg.table.deleteAll(moved, nil)
+
+	seg.depth++
+	sibling.depth = seg.depth
+
+	// Directory slots pointing at seg whose bit for the new depth is set
+	// now belong to the sibling
+	shift := s.depth - seg.depth
+	for i := range s.dir {
+		if s.dir[i] == seg && (i>>shift)&1 == 1 {
+			s.dir[i] = sibling
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package rhmap
+
+import "testing"
+
+func TestSegmentedMap(t *testing.T) {
+	m := must(NewSegmented[int, int](64))
+
+	for i := 0; i < 10000; i++ {
+		m.Set(i, i)
+	}
+	m.Set(5, 50)
+
+	if m.Len() != 10000 {
+		t.Errorf("Map should contain 10000 elements. Found %d", m.Len())
+	}
+	if m.Segments() < 10000/64 {
+		t.Errorf("10000 elements should need at least %d segments of 64 slots. Found %d", 10000/64, m.Segments())
+	}
+	for i := 0; i < 10000; i++ {
+		want := i
+		if i == 5 {
+			want = 50
+		}
+		if val, ok := m.Get(i); !ok || val != want {
+			t.Errorf("Key %d should map to %d. Got %d, %t", i, want, val, ok)
+		}
+	}
+
+	for _, seg := range m.dir {
+		if seg.table.size != 64 {
+			t.Fatalf("Segments should never grow past their fixed size. Found one with %d slots", seg.table.size)
+		}
+	}
+
+	for i := 0; i < 10000; i += 2 {
+		m.Delete(i)
+	}
+	if m.Len() != 5000 {
+		t.Errorf("Map should contain 5000 elements after deleting evens. Found %d", m.Len())
+	}
+	for i := 0; i < 10000; i++ {
+		if _, ok := m.Get(i); ok != (i%2 == 1) {
+			t.Errorf("Key %d should be present: %t", i, i%2 == 1)
+		}
+	}
+}
+
+func TestSegmentedMapCollidingKeys(t *testing.T) {
+	m := must(NewSegmented[int, int](16))
+	for _, seg := range m.dir {
+		seg.table.hasher = HasherFunc(func(k0, k1 uint64, p []byte) uint64 { return 0 })
+	}
+
+	for i := 0; i < 100; i++ {
+		m.Set(i, i)
+	}
+	for i := 0; i < 100; i++ {
+		if val, ok := m.Get(i); !ok || val != i {
+			t.Errorf("Key %d should map to %d when every key collides. Got %d, %t", i, i, val, ok)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package rhmap
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+)
+
+// Callbacks through which a SessionStore reports changes, for example to
+// persist sessions or audit them. Any of them may be nil. They run outside
+// the store's locks and may use the store.
+type SessionHooks[V any] struct {
+	// Called with a new session and its expiry time
+	OnCreate func(id string, value V, expires time.Time)
+	// Called when a session's expiry is extended
+	OnRefresh func(id string, expires time.Time)
+	// Called when a session is revoked explicitly
+	OnRevoke func(id string)
+	// Called when an expired session is reclaimed
+	OnExpire func(id string, value V)
+}
+
+// Store of sessions identified by random IDs, each expiring a fixed time
+// after it was created or last refreshed. It is an ExpiringMap keyed by
+// session ID, and is safe for concurrent use.
+type SessionStore[V any] struct {
+	sessions *ExpiringMap[string, V]
+	hooks    SessionHooks[V]
+}
+
+// Creates a session store whose sessions expire ttl after creation or their
+// last refresh. Options configure the underlying map, including WithClock.
+func NewSessionStore[V any](ttl time.Duration, hooks SessionHooks[V], opts ...Option) (*SessionStore[V], error) {
+	sessions, err := NewExpiring[string, V](ttl, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if hooks.OnExpire != nil {
+		sessions.OnExpire(hooks.OnExpire)
+	}
+	return &SessionStore[V]{sessions: sessions, hooks: hooks}, nil
+}
+
+// Starts a session holding value and returns its ID, 256 random bits
+// encoded as URL-safe base64
+func (s *SessionStore[V]) Create(value V) (string, error) {
+	var raw [32]byte
+	if _, err := rand.Read(raw[:]); err != nil {
+		return "", err
+	}
+	id := base64.RawURLEncoding.EncodeToString(raw[:])
+
+	s.sessions.Set(id, value)
+	if s.hooks.OnCreate != nil {
+		s.hooks.OnCreate(id, value, s.expiry())
+	}
+	return id, nil
+}
+
+// Returns the value of a live session
+func (s *SessionStore[V]) Get(id string) (V, bool) {
+	return s.sessions.Get(id)
+}
+
+// Extends a live session to expire a full TTL from now and reports whether
+// it was live
+func (s *SessionStore[V]) Refresh(id string) bool {
+	if !s.sessions.Touch(id) {
+		return false
+	}
+	if s.hooks.OnRefresh != nil {
+		s.hooks.OnRefresh(id, s.expiry())
+	}
+	return true
+}
+
+// Ends a session immediately
+func (s *SessionStore[V]) Revoke(id string) {
+	s.sessions.Delete(id)
+	if s.hooks.OnRevoke != nil {
+		s.hooks.OnRevoke(id)
+	}
+}
+
+// Returns the number of sessions, including expired ones not yet reclaimed
+func (s *SessionStore[V]) Len() uint64 {
+	return s.sessions.Len()
+}
+
+// Reclaims expired sessions, calling OnExpire for each, and returns how
+// many there were
+func (s *SessionStore[V]) Sweep() int {
+	return s.sessions.Sweep()
+}
+
+// Starts a goroutine that calls Sweep every interval until the returned
+// function is called
+func (s *SessionStore[V]) StartSweeper(interval time.Duration) (stop func()) {
+	return s.sessions.StartSweeper(interval)
+}
+
+// Expiry time of a session created or refreshed now
+func (s *SessionStore[V]) expiry() time.Time {
+	return s.sessions.clock.Now().Add(s.sessions.ttl)
+}
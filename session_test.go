@@ -0,0 +1,51 @@
+package rhmap
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSessionStore(t *testing.T) {
+	clock := newFakeClock()
+	var created, refreshed, revoked, expired []string
+	s := must(NewSessionStore(time.Hour, SessionHooks[string]{
+		OnCreate:  func(id, user string, expires time.Time) { created = append(created, user) },
+		OnRefresh: func(id string, expires time.Time) { refreshed = append(refreshed, id) },
+		OnRevoke:  func(id string) { revoked = append(revoked, id) },
+		OnExpire:  func(id, user string) { expired = append(expired, user) },
+	}, WithClock(clock)))
+
+	ann := must(s.Create("ann"))
+	bob := must(s.Create("bob"))
+	eve := must(s.Create("eve"))
+	if len(ann) != 43 || ann == bob {
+		t.Errorf("Session IDs should be distinct 256-bit base64 strings. Got %q, %q", ann, bob)
+	}
+	if user, ok := s.Get(ann); !ok || user != "ann" {
+		t.Errorf("ann's session should be live. Got %q, %t", user, ok)
+	}
+
+	clock.Advance(50 * time.Minute)
+	if !s.Refresh(ann) {
+		t.Errorf("Refreshing a live session should succeed.")
+	}
+	s.Revoke(eve)
+
+	clock.Advance(20 * time.Minute)
+	if _, ok := s.Get(bob); ok {
+		t.Errorf("bob's session should have expired.")
+	}
+	if _, ok := s.Get(ann); !ok {
+		t.Errorf("Refreshing should have extended ann's session.")
+	}
+	if s.Refresh(bob) {
+		t.Errorf("An expired session can't be refreshed.")
+	}
+
+	if n := s.Sweep(); n != 1 || s.Len() != 1 {
+		t.Errorf("Sweep should reclaim bob only. Reclaimed %d, %d left", n, s.Len())
+	}
+	if len(created) != 3 || len(refreshed) != 1 || len(revoked) != 1 || len(expired) != 1 || expired[0] != "bob" {
+		t.Errorf("Hooks should see 3 creates, 1 refresh, 1 revoke and bob expiring. Got %v %v %v %v", created, refreshed, revoked, expired)
+	}
+}
@@ -0,0 +1,98 @@
+package rhmap
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Write held by a SessionView until it commits
+type sessionWrite[V any] struct {
+	value   V
+	hash    uint64
+	deleted bool
+}
+
+// Read-your-writes view of a ConcurrentMap for one request or transaction.
+// Sets and deletes go to a private overlay that the view's own reads see
+// first and nobody else sees, until Commit applies them to the map at once
+// or Discard drops them. A SessionView must not be used concurrently.
+type SessionView[K comparable, V any] struct {
+	c       *ConcurrentMap[K, V]
+	overlay *Map[K, sessionWrite[V]]
+}
+
+// Returns a view of the map with an empty overlay
+func (c *ConcurrentMap[K, V]) Session() *SessionView[K, V] {
+	return &SessionView[K, V]{c: c, overlay: newMap[K, sessionWrite[V]](c.shards[0].table.enc)}
+}
+
+// Returns the value under key as of the view's own writes, falling back to
+// the shared map for keys it hasn't written
+func (s *SessionView[K, V]) Get(key K) (V, bool) {
+	if w, ok := s.overlay.Get(key); ok {
+		return w.value, !w.deleted
+	}
+	return s.c.Get(key)
+}
+
+func (s *SessionView[K, V]) Set(key K, value V) {
+	s.overlay.Set(key, sessionWrite[V]{value: value, hash: s.c.shards[0].table.hashKey(key)})
+}
+
+func (s *SessionView[K, V]) Delete(key K) {
+	s.overlay.Set(key, sessionWrite[V]{hash: s.c.shards[0].table.hashKey(key), deleted: true})
+}
+
+// Returns the number of keys the view has written and not yet committed
+func (s *SessionView[K, V]) Pending() uint64 {
+	return s.overlay.Len()
+}
+
+// Applies the view's writes to the map as one atomic step, holding the
+// locks of every shard they touch until all are applied, and empties the
+// overlay. Readers of the map see either none of the writes or all of them.
+func (s *SessionView[K, V]) Commit() {
+	if s.overlay.Len() == 0 {
+		return
+	}
+	writes := make([]bufferedWrite[K, V], 0, s.overlay.Len())
+	for k, w := range s.overlay.All() {
+		writes = append(writes, bufferedWrite[K, V]{
+			key: k, value: w.value, hash: w.hash, shard: s.c.shardIndex(w.hash), deleted: w.deleted,
+		})
+	}
+	// Shards are locked in index order, so that concurrent commits can't
+	// deadlock
+	slices.SortFunc(writes, func(x, y bufferedWrite[K, V]) int {
+		return cmp.Compare(x.shard, y.shard)
+	})
+	var locked []*shard[K, V]
+	for i := range writes {
+		if i == 0 || writes[i].shard != writes[i-1].shard {
+			sh := &s.c.shards[writes[i].shard]
+			sh.mu.Lock()
+			locked = append(locked, sh)
+		}
+	}
+	for i := range writes {
+		w := &writes[i]
+		table := s.c.shards[w.shard].table
+		if !w.deleted {
+			table.setWithHash(w.key, w.value, w.hash)
+		} else if table.numElements > 0 {
+			table.removeWithHash(w.key, w.hash)
+		}
+	}
+	for _, sh := range locked {
+		sh.mu.Unlock()
+	}
+	for range writes {
+		s.c.countOp()
+	}
+	s.overlay.Clear()
+}
+
+// Drops the view's uncommitted writes
+func (s *SessionView[K, V]) Discard() {
+	s.overlay.Clear()
+}
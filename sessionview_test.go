@@ -0,0 +1,79 @@
+package rhmap
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestSessionView(t *testing.T) {
+	m := must(NewConcurrent[int, int](4))
+	m.Set(1, 1)
+	m.Set(2, 2)
+
+	s := m.Session()
+	s.Set(1, 10)
+	s.Delete(2)
+	s.Set(3, 30)
+	if val, ok := s.Get(1); !ok || val != 10 {
+		t.Errorf("The view should read its own write to 1. Got %d, %t", val, ok)
+	}
+	if _, ok := s.Get(2); ok {
+		t.Errorf("The view should read its own delete of 2.")
+	}
+	if val, ok := m.Get(1); !ok || val != 1 {
+		t.Errorf("The map should not see uncommitted writes. Got %d, %t", val, ok)
+	}
+	if s.Pending() != 3 {
+		t.Errorf("Expected 3 pending writes. Got %d", s.Pending())
+	}
+
+	s.Discard()
+	if val, ok := s.Get(2); !ok || val != 2 || s.Pending() != 0 {
+		t.Errorf("Discard should drop the view's writes. Got %d, %t with %d pending", val, ok, s.Pending())
+	}
+
+	s.Set(1, 10)
+	s.Delete(2)
+	s.Set(3, 30)
+	s.Commit()
+	for key, want := range map[int]int{1: 10, 3: 30} {
+		if val, ok := m.Get(key); !ok || val != want {
+			t.Errorf("Commit should apply the write to %d. Got %d, %t", key, val, ok)
+		}
+	}
+	if _, ok := m.Get(2); ok || m.Len() != 2 || s.Pending() != 0 {
+		t.Errorf("Commit should apply the delete and empty the view. Found %d elements, %d pending", m.Len(), s.Pending())
+	}
+}
+
+func TestSessionViewAtomic(t *testing.T) {
+	m := must(NewConcurrent[int, int](8))
+	for i := 0; i < 64; i++ {
+		m.Set(i, 0)
+	}
+
+	var wg sync.WaitGroup
+	for g := 1; g <= 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s := m.Session()
+			for range 100 {
+				for i := 0; i < 64; i++ {
+					s.Set(i, g)
+				}
+				s.Commit()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Commits touching the same shards apply one after another, so every key
+	// holds the value of the last of them
+	first, _ := m.Get(0)
+	for i := 1; i < 64; i++ {
+		if val, _ := m.Get(i); val != first {
+			t.Errorf("Commits should apply as a whole. Key %d holds %d, key 0 holds %d", i, val, first)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package rhmap
+
+import "iter"
+
+// Robin hood hash set, a Map without values
+type Set[K comparable] struct {
+	table *Map[K, struct{}]
+}
+
+// Creates a set configured by opts. It returns an error if K can't be
+// encoded, as New does.
+func NewSet[K comparable](opts ...Option) (*Set[K], error) {
+	table, err := New[K, struct{}](opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Set[K]{table: table}, nil
+}
+
+func (s *Set[K]) Add(key K) {
+	s.table.Set(key, struct{}{})
+}
+
+func (s *Set[K]) Remove(key K) {
+	s.table.Delete(key)
+}
+
+func (s *Set[K]) Contains(key K) bool {
+	_, ok := s.table.Get(key)
+	return ok
+}
+
+func (s *Set[K]) Len() uint64 {
+	return s.table.Len()
+}
+
+// Returns an iterator over every key in the set. The set must not be
+// modified while the iteration is in progress.
+func (s *Set[K]) All() iter.Seq[K] {
+	return s.table.Keys()
+}
+
+// Returns a new set of the keys in s, other or both
+func (s *Set[K]) Union(other *Set[K]) *Set[K] {
+	result := s.derive(s.Len() + other.Len())
+	for key := range s.All() {
+		result.Add(key)
+	}
+	for key := range other.All() {
+		result.Add(key)
+	}
+	return result
+}
+
+// Returns a new set of the keys in both s and other
+func (s *Set[K]) Intersection(other *Set[K]) *Set[K] {
+	small, large := s, other
+	if small.Len() > large.Len() {
+		small, large = large, small
+	}
+
+	result := s.derive(small.Len())
+	for key := range small.All() {
+		if large.Contains(key) {
+			result.Add(key)
+		}
+	}
+	return result
+}
+
+// Returns a new set of the keys in s but not in other
+func (s *Set[K]) Difference(other *Set[K]) *Set[K] {
+	result := s.derive(s.Len())
+	for key := range s.All() {
+		if !other.Contains(key) {
+			result.Add(key)
+		}
+	}
+	return result
+}
+
+// Creates an empty set with s's hasher, sized to hold n keys without growing
+func (s *Set[K]) derive(n uint64) *Set[K] {
+	table := newMap[K, struct{}](s.table.enc, WithHasher(s.table.hasher))
+	table.growFor(n)
+	return &Set[K]{table: table}
+}
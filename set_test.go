@@ -0,0 +1,56 @@
+package rhmap
+
+import (
+	"slices"
+	"testing"
+)
+
+func setOf(keys ...int) *Set[int] {
+	s := must(NewSet[int]())
+	for _, key := range keys {
+		s.Add(key)
+	}
+	return s
+}
+
+func sortedKeys(s *Set[int]) []int {
+	return slices.Sorted(s.All())
+}
+
+func TestSetAddRemove(t *testing.T) {
+	s := setOf(1, 2, 3, 2)
+	if s.Len() != 3 {
+		t.Errorf("Set should contain 3 keys. Found %d", s.Len())
+	}
+	if !s.Contains(2) || s.Contains(4) {
+		t.Errorf("Set should contain 2 and not 4.")
+	}
+
+	s.Remove(2)
+	s.Remove(4)
+	if s.Contains(2) || s.Len() != 2 {
+		t.Errorf("Removed key should be gone. Found %v", sortedKeys(s))
+	}
+}
+
+func TestSetAlgebra(t *testing.T) {
+	a := setOf(1, 2, 3, 4)
+	b := setOf(3, 4, 5)
+
+	for name, test := range map[string]struct {
+		got  *Set[int]
+		want []int
+	}{
+		"Union":        {a.Union(b), []int{1, 2, 3, 4, 5}},
+		"Intersection": {a.Intersection(b), []int{3, 4}},
+		"Difference":   {a.Difference(b), []int{1, 2}},
+	} {
+		if got := sortedKeys(test.got); !slices.Equal(got, test.want) {
+			t.Errorf("%s: Expected %v, Got %v", name, test.want, got)
+		}
+	}
+
+	if got := sortedKeys(a); !slices.Equal(got, []int{1, 2, 3, 4}) {
+		t.Errorf("Set operations should not modify their operands. Got %v", got)
+	}
+}
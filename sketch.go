@@ -0,0 +1,102 @@
+package rhmap
+
+import (
+	"errors"
+	"math"
+)
+
+// Count-Min sketch estimating how often each key was added in fixed memory.
+// Estimates never undercount, and overcount by at most a small fraction of
+// the total added with high probability. Keys are encoded and hashed once
+// per call like map keys, and every row's counter is derived from that one
+// hash. It can serve standalone or as the frequency filter of a TinyLFU
+// admission policy.
+type CountMinSketch[K comparable] struct {
+	counters []uint32
+	width    uint64
+	depth    uint64
+	hasher   Hasher
+	enc      keyEncoder[K]
+	k0       uint64
+	k1       uint64
+}
+
+// Creates a sketch of depth rows of width counters each. Overcounts stay
+// below e/width of the total added with probability 1 - e^-depth. WithHasher,
+// WithSeedsFrom and WithDeterministic choose how keys are hashed; other
+// options are ignored. It returns an error if width or depth is not
+// positive or K can't be encoded.
+func NewCountMinSketch[K comparable](width, depth int, opts ...Option) (*CountMinSketch[K], error) {
+	if width <= 0 || depth <= 0 {
+		return nil, errors.New("rhmap: sketch width and depth must be positive")
+	}
+	enc, err := newKeyEncoder[K]()
+	if err != nil {
+		return nil, err
+	}
+
+	hasher, k0, k1 := resolveOptions(opts).hashing()
+
+	return &CountMinSketch[K]{
+		counters: make([]uint32, width*depth),
+		width:    uint64(width),
+		depth:    uint64(depth),
+		hasher:   hasher,
+		enc:      enc,
+		k0:       k0,
+		k1:       k1,
+	}, nil
+}
+
+// Counts one occurrence of key
+func (s *CountMinSketch[K]) Add(key K) {
+	s.AddN(key, 1)
+}
+
+// Counts n occurrences of key. Counters saturate instead of wrapping.
+func (s *CountMinSketch[K]) AddN(key K, n uint32) {
+	s.addHash(s.hashKey(key), n)
+}
+
+// Returns an upper bound on the number of times key was added
+func (s *CountMinSketch[K]) Estimate(key K) uint32 {
+	return s.estimateHash(s.hashKey(key))
+}
+
+// Halves every counter, so that old occurrences fade and the sketch tracks
+// recent frequency, as TinyLFU does after each sample period
+func (s *CountMinSketch[K]) Halve() {
+	for i := range s.counters {
+		s.counters[i] >>= 1
+	}
+}
+
+// Zeroes every counter
+func (s *CountMinSketch[K]) Reset() {
+	clear(s.counters)
+}
+
+func (s *CountMinSketch[K]) hashKey(key K) uint64 {
+	return s.enc.hash(s.hasher, s.k0, s.k1, key)
+}
+
+func (s *CountMinSketch[K]) addHash(hash uint64, n uint32) {
+	h1, h2 := hash&math.MaxUint32, hash>>32|1
+	for row := uint64(0); row < s.depth; row++ {
+		i := row*s.width + (h1+row*h2)%s.width
+		if c := s.counters[i]; c > math.MaxUint32-n {
+			s.counters[i] = math.MaxUint32
+		} else {
+			s.counters[i] = c + n
+		}
+	}
+}
+
+func (s *CountMinSketch[K]) estimateHash(hash uint64) uint32 {
+	h1, h2 := hash&math.MaxUint32, hash>>32|1
+	estimate := uint32(math.MaxUint32)
+	for row := uint64(0); row < s.depth; row++ {
+		estimate = min(estimate, s.counters[row*s.width+(h1+row*h2)%s.width])
+	}
+	return estimate
+}
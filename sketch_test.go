@@ -0,0 +1,54 @@
+package rhmap
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCountMinSketch(t *testing.T) {
+	s := must(NewCountMinSketch[int](1024, 4, WithDeterministic(1)))
+
+	// Key i is added i times, 50000 additions in total
+	total := 0
+	for i := 0; i < 316; i++ {
+		s.AddN(i, uint32(i))
+		total += i
+	}
+
+	for i := 0; i < 316; i++ {
+		est := s.Estimate(i)
+		if est < uint32(i) {
+			t.Errorf("Estimate should never undercount. Key %d: %d < %d", i, est, i)
+		}
+		if bound := uint32(i) + uint32(math.E/1024*float64(total)); est > bound {
+			t.Errorf("Key %d overestimated past the error bound: %d > %d", i, est, bound)
+		}
+	}
+	if s.Estimate(-1) > uint32(math.E/1024*float64(total)) {
+		t.Errorf("An absent key should estimate near zero. Got %d", s.Estimate(-1))
+	}
+
+	before := s.Estimate(300)
+	s.Halve()
+	if got := s.Estimate(300); got != before/2 {
+		t.Errorf("Halve should halve the counts. Got %d from %d", got, before)
+	}
+	s.Reset()
+	if s.Estimate(300) != 0 {
+		t.Errorf("Reset should clear every count.")
+	}
+
+	s.AddN(1, math.MaxUint32)
+	s.Add(1)
+	if s.Estimate(1) != math.MaxUint32 {
+		t.Errorf("Counters should saturate rather than wrap. Got %d", s.Estimate(1))
+	}
+}
+
+func TestCountMinSketchSharesMapHashing(t *testing.T) {
+	m := must(New[string, int]())
+	s := must(NewCountMinSketch[string](64, 2, WithSeedsFrom(m)))
+	if s.hashKey("key") != m.hashKey("key") {
+		t.Errorf("A sketch seeded from a map should hash keys as the map does.")
+	}
+}
@@ -0,0 +1,164 @@
+package rhmap
+
+import (
+	"slices"
+	"time"
+)
+
+// Keeps the values of the last capacity keys removed by Delete for window
+// after their deletion, so that Restore can undo the delete, as editors and
+// other interactive applications built on the map need. Older deletes are
+// dropped as newer ones arrive. Only Delete, directly or through an Entry
+// handle, is undoable; DeleteFunc, Clear and evictions remove for good. The
+// window is measured by the clock WithClock sets. A window or capacity that
+// isn't positive leaves deletes final.
+//
+// The WithOnEvict callback is told of a soft-deleted value once it can no
+// longer be restored: when it is dropped for a newer delete, or found past
+// its window by Restore or by a later delete.
+func WithSoftDelete(window time.Duration, capacity int) Option {
+	return func(o *options) {
+		o.softWindow, o.softCapacity = window, capacity
+	}
+}
+
+// Puts back the value key held when Delete last removed it, if that was
+// within the WithSoftDelete window and key hasn't been set since. It reports
+// whether key was restored.
+func (m *Map[K, V]) Restore(key K) bool {
+	if m.deleted == nil || m.rejectsWrites() {
+		return false
+	}
+	d, ok := m.deleted.take(key)
+	if !ok {
+		return false
+	}
+	if m.deleted.expired(d, m.deleted.clock.Now().UnixNano()) {
+		m.deleted.evict(d)
+		return false
+	}
+	hash := m.hashKey(key)
+	if _, ok, _ := m.getWithHash(key, hash); ok {
+		m.deleted.evict(d)
+		return false
+	}
+	m.insertAbsent(key, d.value, hash)
+	return true
+}
+
+// Deletes key as Delete does, keeping its value for Restore under
+// WithSoftDelete
+func (m *Map[K, V]) softRemove(key K, hash uint64) bool {
+	if m.deleted == nil {
+		return m.removeWithHash(key, hash)
+	}
+	old, ok := m.takeWithHash(key, hash)
+	if !ok {
+		return false
+	}
+	m.deleted.add(key, old)
+	return true
+}
+
+// Recently deleted values of a WithSoftDelete map, oldest first
+type softDeletes[K comparable, V any] struct {
+	window   time.Duration
+	capacity int
+	clock    Clock
+	onEvict  func(K, V)
+	// Sequence number of each key's latest delete. A delete is the entry of
+	// deletes at its sequence number less that of the first entry; entries
+	// whose keys were since deleted again or restored are stale.
+	latest  *Map[K, uint64]
+	deletes []deletedValue[K, V]
+	seq     uint64
+}
+
+// Value of a deleted key kept for Restore, with the Unix nanosecond time it
+// was deleted at
+type deletedValue[K comparable, V any] struct {
+	key     K
+	value   V
+	deleted int64
+	seq     uint64
+}
+
+func newSoftDeletes[K comparable, V any](enc keyEncoder[K], o options, onEvict func(K, V)) *softDeletes[K, V] {
+	clock := o.clock
+	if clock == nil {
+		clock = realClock{}
+	}
+	return &softDeletes[K, V]{
+		window:   o.softWindow,
+		capacity: o.softCapacity,
+		clock:    clock,
+		onEvict:  onEvict,
+		latest:   newMap[K, uint64](enc),
+	}
+}
+
+// Records the delete of key, dropping the deletes that have expired or no
+// longer fit, and any earlier delete of key
+func (s *softDeletes[K, V]) add(key K, value V) {
+	if d, ok := s.take(key); ok {
+		s.evict(d)
+	}
+	now := s.clock.Now().UnixNano()
+	s.seq++
+	s.latest.Set(key, s.seq)
+	s.deletes = append(s.deletes, deletedValue[K, V]{key, value, now, s.seq})
+
+	for len(s.deletes) > 0 {
+		oldest := s.deletes[0]
+		live := s.live(oldest)
+		if live && s.latest.Len() <= uint64(s.capacity) && !s.expired(oldest, now) {
+			break
+		}
+		if live {
+			s.latest.Delete(oldest.key)
+			s.evict(oldest)
+		}
+		s.deletes[0] = deletedValue[K, V]{}
+		s.deletes = s.deletes[1:]
+	}
+}
+
+// Removes and returns the latest delete of key
+func (s *softDeletes[K, V]) take(key K) (deletedValue[K, V], bool) {
+	seq, ok := s.latest.Get(key)
+	if !ok {
+		return deletedValue[K, V]{}, false
+	}
+	s.latest.Delete(key)
+	i := seq - s.deletes[0].seq
+	d := s.deletes[i]
+	// Leave the key for add to tell the entry is stale, but not the value
+	var zeroVal V
+	s.deletes[i].value = zeroVal
+	return d, true
+}
+
+// Reports whether d is the latest delete of its key
+func (s *softDeletes[K, V]) live(d deletedValue[K, V]) bool {
+	seq, ok := s.latest.Get(d.key)
+	return ok && seq == d.seq
+}
+
+// Reports whether d is past the window at now
+func (s *softDeletes[K, V]) expired(d deletedValue[K, V], now int64) bool {
+	return now-d.deleted > int64(s.window)
+}
+
+// Tells the eviction callback that d can no longer be restored
+func (s *softDeletes[K, V]) evict(d deletedValue[K, V]) {
+	if s.onEvict != nil {
+		s.onEvict(d.key, d.value)
+	}
+}
+
+func (s *softDeletes[K, V]) clone() *softDeletes[K, V] {
+	c := *s
+	c.latest = s.latest.Clone()
+	c.deletes = slices.Clone(s.deletes)
+	return &c
+}
@@ -0,0 +1,112 @@
+package rhmap
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSoftDelete(t *testing.T) {
+	m := must(New[string, int](WithSoftDelete(time.Hour, 2)))
+	m.Set("a", 1)
+	m.Set("b", 2)
+	m.Set("c", 3)
+
+	m.Delete("a")
+	if _, ok := m.Get("a"); ok {
+		t.Errorf("A soft-deleted key should read as absent.")
+	}
+	if !m.Restore("a") {
+		t.Errorf("A key deleted within the window should be restored.")
+	}
+	if v, ok := m.Get("a"); !ok || v != 1 || m.Len() != 3 {
+		t.Errorf("Expected a restored to 1, Got %d, %t", v, ok)
+	}
+	if m.Restore("a") || m.Restore("missing") {
+		t.Errorf("Only deleted keys should be restored, and once.")
+	}
+
+	// The oldest of three deletes is dropped from a list of two
+	m.Delete("a")
+	m.Delete("b")
+	m.Delete("c")
+	if m.Restore("a") {
+		t.Errorf("The oldest delete should have been dropped.")
+	}
+
+	m.Set("b", 20)
+	if m.Restore("b") {
+		t.Errorf("A key set since its delete should not be restored.")
+	}
+	if v, _ := m.Get("b"); v != 20 {
+		t.Errorf("Expected 20, Got %d", v)
+	}
+	if !m.Restore("c") {
+		t.Errorf("The latest delete should still be restorable.")
+	}
+
+	// Restoring leaves stale entries behind, which must not count against
+	// later deletes
+	for i := 0; i < 10; i++ {
+		m.Delete("c")
+		m.Restore("c")
+	}
+	m.Set("a", 1)
+	m.Delete("a")
+	m.Delete("c")
+	if !m.Restore("a") || !m.Restore("c") {
+		t.Errorf("Two deletes should fit a list of two.")
+	}
+}
+
+func TestSoftDeleteWindow(t *testing.T) {
+	clock := newFakeClock()
+	var evicted []int
+	m := must(New[int, int](WithSoftDelete(time.Minute, 10), WithClock(clock),
+		WithOnEvict(func(k, v int) { evicted = append(evicted, k) })))
+	for i := 1; i <= 3; i++ {
+		m.Set(i, i)
+	}
+	m.Delete(1)
+	e := m.Entry(2)
+	e.Delete()
+	if len(evicted) != 0 {
+		t.Errorf("Restorable deletes should not be evicted. Got %v", evicted)
+	}
+	if !m.Restore(2) {
+		t.Errorf("A delete through an Entry handle should be restorable.")
+	}
+
+	// Deleting once the window has passed drops the expired delete
+	clock.Advance(2 * time.Minute)
+	m.Delete(3)
+	if len(evicted) != 1 || evicted[0] != 1 {
+		t.Errorf("Expected 1 evicted once expired, Got %v", evicted)
+	}
+	clock.Advance(2 * time.Minute)
+	if m.Restore(3) {
+		t.Errorf("A delete older than the window should be final.")
+	}
+	if len(evicted) != 2 || evicted[1] != 3 {
+		t.Errorf("Expected 3 evicted by Restore, Got %v", evicted)
+	}
+	if err := m.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSoftDeleteClone(t *testing.T) {
+	m := must(New[int, int](WithSoftDelete(time.Hour, 10)))
+	m.Set(1, 1)
+	m.Delete(1)
+	c := m.Clone()
+	if !c.Restore(1) || !m.Restore(1) {
+		t.Errorf("A clone should have its own copy of the recently deleted list.")
+	}
+
+	plain := must(New[int, int]())
+	plain.Set(1, 1)
+	plain.Delete(1)
+	if plain.Restore(1) {
+		t.Errorf("Deletes should be final without WithSoftDelete.")
+	}
+}
@@ -0,0 +1,108 @@
+package rhmap
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// Robin hood hashmap with an auxiliary sorted index of its keys. Point
+// lookups stay O(1) in the hash table, while the index serves ordered
+// iteration and Ceiling/Floor queries. Inserting or deleting a key costs an
+// extra O(n) shift of the index, so it suits read-mostly ordered workloads.
+type SortedMap[K cmp.Ordered, V any] struct {
+	table *Map[K, V]
+	keys  []K
+}
+
+func NewSorted[K cmp.Ordered, V any](opts ...Option) *SortedMap[K, V] {
+	// Ordered keys are numbers and strings, which always encode
+	enc, _ := newKeyEncoder[K]()
+	return &SortedMap[K, V]{table: newMap[K, V](enc, opts...)}
+}
+
+func (s *SortedMap[K, V]) Set(key K, value V) {
+	if s.table.zeroDeletes && isZero(value) {
+		s.Delete(key)
+		return
+	}
+
+	hash := s.table.hashKey(key)
+	if _, ok, i := s.table.getForUpdate(key, hash); ok {
+		s.table.unshare()
+		s.table.elements[i].value = value
+		return
+	}
+
+	s.table.Set(key, value)
+	i, _ := slices.BinarySearch(s.keys, key)
+	s.keys = slices.Insert(s.keys, i, key)
+}
+
+func (s *SortedMap[K, V]) Get(key K) (V, bool) {
+	return s.table.Get(key)
+}
+
+func (s *SortedMap[K, V]) Delete(key K) {
+	if !s.table.removeWithHash(key, s.table.hashKey(key)) {
+		return
+	}
+	if i, ok := slices.BinarySearch(s.keys, key); ok {
+		s.keys = slices.Delete(s.keys, i, i+1)
+	}
+}
+
+func (s *SortedMap[K, V]) Len() uint64 {
+	return s.table.Len()
+}
+
+// Calls fn for every element in ascending key order, stopping early if fn
+// returns false
+func (s *SortedMap[K, V]) RangeSorted(fn func(K, V) bool) {
+	for _, key := range s.keys {
+		val, _ := s.table.Get(key)
+		if !fn(key, val) {
+			return
+		}
+	}
+}
+
+// Returns the element with the smallest key greater than or equal to key
+func (s *SortedMap[K, V]) Ceiling(key K) (K, V, bool) {
+	i, _ := slices.BinarySearch(s.keys, key)
+	if i == len(s.keys) {
+		var zeroKey K
+		var zeroVal V
+		return zeroKey, zeroVal, false
+	}
+	val, _ := s.table.Get(s.keys[i])
+	return s.keys[i], val, true
+}
+
+// Returns the element with the largest key less than or equal to key
+func (s *SortedMap[K, V]) Floor(key K) (K, V, bool) {
+	i, found := slices.BinarySearch(s.keys, key)
+	if !found {
+		i--
+	}
+	if i < 0 {
+		var zeroKey K
+		var zeroVal V
+		return zeroKey, zeroVal, false
+	}
+	val, _ := s.table.Get(s.keys[i])
+	return s.keys[i], val, true
+}
+
+// Calls fn for every element of s whose key starts with prefix, in ascending
+// key order, stopping early if fn returns false. Matching keys are contiguous
+// in the sorted index, so only they are visited rather than the whole table.
+func RangePrefix[V any](s *SortedMap[string, V], prefix string, fn func(string, V) bool) {
+	i, _ := slices.BinarySearch(s.keys, prefix)
+	for ; i < len(s.keys) && strings.HasPrefix(s.keys[i], prefix); i++ {
+		val, _ := s.table.Get(s.keys[i])
+		if !fn(s.keys[i], val) {
+			return
+		}
+	}
+}
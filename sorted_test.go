@@ -0,0 +1,81 @@
+package rhmap
+
+import "testing"
+
+func TestSortedMapRangeSorted(t *testing.T) {
+	m := NewSorted[int, string]()
+
+	for _, k := range []int{50, 10, 40, 20, 30} {
+		m.Set(k, "v")
+	}
+	m.Set(20, "updated")
+	m.Delete(40)
+
+	var keys []int
+	m.RangeSorted(func(k int, v string) bool {
+		keys = append(keys, k)
+		return true
+	})
+
+	want := []int{10, 20, 30, 50}
+	if len(keys) != len(want) {
+		t.Fatalf("RangeSorted should visit %v. Got %v", want, keys)
+	}
+	for i := range want {
+		if keys[i] != want[i] {
+			t.Errorf("RangeSorted should visit %v. Got %v", want, keys)
+			break
+		}
+	}
+	if val, _ := m.Get(20); val != "updated" {
+		t.Errorf("Key 20 should map to 'updated'. Got %s", val)
+	}
+}
+
+func TestSortedMapCeilingFloor(t *testing.T) {
+	m := NewSorted[int, int]()
+
+	for _, k := range []int{10, 20, 30} {
+		m.Set(k, k*2)
+	}
+
+	tests := []struct {
+		key         int
+		ceil, floor int
+		hasC, hasF  bool
+	}{
+		{5, 10, 0, true, false},
+		{10, 10, 10, true, true},
+		{15, 20, 10, true, true},
+		{30, 30, 30, true, true},
+		{35, 0, 30, false, true},
+	}
+	for _, tt := range tests {
+		k, v, ok := m.Ceiling(tt.key)
+		if ok != tt.hasC || (ok && (k != tt.ceil || v != tt.ceil*2)) {
+			t.Errorf("Ceiling(%d) should be %d, %t. Got %d, %t", tt.key, tt.ceil, tt.hasC, k, ok)
+		}
+		k, v, ok = m.Floor(tt.key)
+		if ok != tt.hasF || (ok && (k != tt.floor || v != tt.floor*2)) {
+			t.Errorf("Floor(%d) should be %d, %t. Got %d, %t", tt.key, tt.floor, tt.hasF, k, ok)
+		}
+	}
+}
+
+func TestRangePrefix(t *testing.T) {
+	m := NewSorted[string, int]()
+
+	for i, k := range []string{"user:2", "session:1", "user:1", "user", "users:1", "admin:1"} {
+		m.Set(k, i)
+	}
+
+	var keys []string
+	RangePrefix(m, "user:", func(k string, v int) bool {
+		keys = append(keys, k)
+		return true
+	})
+
+	if len(keys) != 2 || keys[0] != "user:1" || keys[1] != "user:2" {
+		t.Errorf("RangePrefix(\"user:\") should visit [user:1 user:2]. Got %v", keys)
+	}
+}
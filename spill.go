@@ -0,0 +1,291 @@
+package rhmap
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// Shard of a SpillMap, resident while table is set
+type spillShard[K comparable, V any] struct {
+	table *Map[K, V]
+	// Whether the shard has a file, and whether the table has changed since
+	// it was written
+	onDisk bool
+	dirty  bool
+	// Elements of the shard while it is spilled
+	len uint64
+	// Tick of the shard's last use, which picks the shard to spill
+	used uint64
+}
+
+// Robin hood hashmap for batch jobs whose working set outgrows memory, used
+// as a lightweight embedded key-value store. Keys are split among shards by
+// the top bits of their hash, and at most a fixed number of shards stay in
+// memory: touching any other loads it back from disk, first spilling the
+// least recently used resident shard to a file in the stream format of
+// WriteTo. Shards that haven't changed since they were last written are
+// dropped without writing. Operations return the I/O errors loading and
+// spilling run into. A SpillMap is not safe for concurrent use.
+type SpillMap[K comparable, V any] struct {
+	dir    string
+	opts   []Option
+	enc    keyEncoder[K]
+	hasher Hasher
+	k0     uint64
+	k1     uint64
+	shards []spillShard[K, V]
+	// Shift taking a hash to its shard's index
+	shift       uint
+	resident    int
+	maxResident int
+	tick        uint64
+}
+
+// Creates a map of shards shards, rounded up to a power of two, keeping at
+// most resident of them in memory and spilling the rest to files in dir,
+// which it creates if needed. dir is scratch space: Close removes the files.
+// Options configure each shard's map. It returns an error if K can't be
+// encoded, the map is given a custom hasher, which streams can't record, or
+// dir can't be created.
+func NewSpillMap[K comparable, V any](dir string, shards, resident int, opts ...Option) (*SpillMap[K, V], error) {
+	if shards < 1 || resident < 1 {
+		return nil, errors.New("rhmap: a SpillMap needs at least one shard in memory")
+	}
+	enc, err := newKeyEncoder[K]()
+	if err != nil {
+		return nil, err
+	}
+	hasher, k0, k1 := resolveOptions(opts).hashing()
+	if builtinHasherName(hasher) == "" {
+		return nil, errors.New("rhmap: can't spill a map with a custom hasher")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	n := roundSize(uint64(shards))
+	return &SpillMap[K, V]{
+		dir: dir,
+		// Every shard hashes as the SpillMap does, so that hashes are
+		// computed once, and never reseeds itself
+		opts:        append(slices.Clip(opts), WithHasher(hasher), WithSeed(k0, k1)),
+		enc:         enc,
+		hasher:      hasher,
+		k0:          k0,
+		k1:          k1,
+		shards:      make([]spillShard[K, V], n),
+		shift:       uint(64 - bits.TrailingZeros64(n)),
+		maxResident: resident,
+	}, nil
+}
+
+func (s *SpillMap[K, V]) Set(key K, value V) error {
+	hash := s.hash(key)
+	sh, err := s.load(hash)
+	if err != nil {
+		return err
+	}
+	sh.table.setWithHash(key, value, hash)
+	sh.dirty = true
+	return nil
+}
+
+func (s *SpillMap[K, V]) Get(key K) (V, bool, error) {
+	hash := s.hash(key)
+	sh, err := s.load(hash)
+	if err != nil {
+		var zeroVal V
+		return zeroVal, false, err
+	}
+	val, ok, _ := sh.table.getWithHash(key, hash)
+	return val, ok, nil
+}
+
+func (s *SpillMap[K, V]) Delete(key K) error {
+	hash := s.hash(key)
+	sh, err := s.load(hash)
+	if err != nil {
+		return err
+	}
+	if t := sh.table; t.numElements > 0 && t.removeWithHash(key, hash) {
+		t.maybeShrink()
+		sh.dirty = true
+	}
+	return nil
+}
+
+// Returns the number of elements, resident or spilled
+func (s *SpillMap[K, V]) Len() uint64 {
+	var n uint64
+	for i := range s.shards {
+		if sh := &s.shards[i]; sh.table != nil {
+			n += sh.table.Len()
+		} else {
+			n += sh.len
+		}
+	}
+	return n
+}
+
+// Calls fn with every element until it returns false, a shard at a time,
+// loading spilled shards as it goes. The map must not be modified while the
+// iteration is in progress.
+func (s *SpillMap[K, V]) Range(fn func(K, V) bool) error {
+	for i := range s.shards {
+		sh, err := s.use(i)
+		if err != nil {
+			return err
+		}
+		for k, v := range sh.table.All() {
+			if !fn(k, v) {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// Writes every resident shard changed since it was last written, keeping
+// it in memory, so that the files hold the whole map
+func (s *SpillMap[K, V]) Flush() error {
+	for i := range s.shards {
+		if sh := &s.shards[i]; sh.table != nil && sh.dirty {
+			if err := s.write(i); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Drops every shard and removes the files of spilled ones. The map must not
+// be used afterwards.
+func (s *SpillMap[K, V]) Close() error {
+	var errs []error
+	for i := range s.shards {
+		sh := &s.shards[i]
+		if sh.table != nil {
+			sh.table.Free()
+		}
+		if sh.onDisk {
+			errs = append(errs, os.Remove(s.path(i)))
+		}
+		*sh = spillShard[K, V]{}
+	}
+	s.resident = 0
+	return errors.Join(errs...)
+}
+
+func (s *SpillMap[K, V]) hash(key K) uint64 {
+	return s.enc.hash(s.hasher, s.k0, s.k1, key)
+}
+
+// Returns the shard hash belongs to, made resident
+func (s *SpillMap[K, V]) load(hash uint64) (*spillShard[K, V], error) {
+	return s.use(int(hash >> s.shift))
+}
+
+// Returns shard i, reading it back from its file if it was spilled, after
+// spilling the least recently used resident shard if too many are resident
+func (s *SpillMap[K, V]) use(i int) (*spillShard[K, V], error) {
+	s.tick++
+	sh := &s.shards[i]
+	sh.used = s.tick
+	if sh.table != nil {
+		return sh, nil
+	}
+
+	if s.resident >= s.maxResident {
+		if err := s.spill(); err != nil {
+			return nil, err
+		}
+	}
+	t := newMap[K, V](s.enc, s.opts...)
+	if sh.onDisk {
+		if err := s.read(i, t); err != nil {
+			return nil, fmt.Errorf("rhmap: loading spilled shard %d: %w", i, err)
+		}
+	}
+	sh.table = t
+	s.resident++
+	return sh, nil
+}
+
+// Spills the least recently used resident shard, writing it first if it
+// changed since it was last written
+func (s *SpillMap[K, V]) spill() error {
+	coldest := -1
+	for i := range s.shards {
+		if sh := &s.shards[i]; sh.table != nil && (coldest < 0 || sh.used < s.shards[coldest].used) {
+			coldest = i
+		}
+	}
+	sh := &s.shards[coldest]
+	if sh.dirty {
+		if err := s.write(coldest); err != nil {
+			return err
+		}
+	}
+	sh.len = sh.table.Len()
+	sh.table.Free()
+	sh.table = nil
+	s.resident--
+	return nil
+}
+
+// Writes resident shard i to its file, replacing the file only once the
+// whole shard is written. An empty shard's file is removed instead.
+func (s *SpillMap[K, V]) write(i int) error {
+	sh := &s.shards[i]
+	path := s.path(i)
+	if sh.table.Len() == 0 {
+		if sh.onDisk {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		}
+		sh.onDisk, sh.dirty = false, false
+		return nil
+	}
+
+	f, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	_, err = sh.table.WriteTo(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(path+".tmp", path)
+	}
+	if err != nil {
+		os.Remove(path + ".tmp")
+		return fmt.Errorf("rhmap: spilling shard %d: %w", i, err)
+	}
+	sh.onDisk, sh.dirty = true, false
+	return nil
+}
+
+func (s *SpillMap[K, V]) read(i int, t *Map[K, V]) error {
+	f, err := os.Open(s.path(i))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = t.ReadFrom(bufio.NewReader(f))
+	return err
+}
+
+func (s *SpillMap[K, V]) path(i int) string {
+	return filepath.Join(s.dir, fmt.Sprintf("shard-%d.rhm", i))
+}
@@ -0,0 +1,122 @@
+package rhmap
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestSpillMap(t *testing.T) {
+	dir := t.TempDir()
+	s := must(NewSpillMap[string, int](dir, 16, 2))
+	for i := 0; i < 2000; i++ {
+		if err := s.Set(strconv.Itoa(i), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s.resident != 2 {
+		t.Errorf("Expected 2 resident shards, Got %d", s.resident)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "shard-*.rhm"))
+	if len(files) < 14 {
+		t.Errorf("Cold shards should be spilled to disk. Found %d files", len(files))
+	}
+
+	for i := 0; i < 2000; i += 2 {
+		if err := s.Delete(strconv.Itoa(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s.Len() != 1000 {
+		t.Errorf("Map should contain 1000 elements. Found %d", s.Len())
+	}
+	for i := 0; i < 2000; i++ {
+		v, ok, err := s.Get(strconv.Itoa(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != (i%2 == 1) || (ok && v != i) {
+			t.Fatalf("Get of key %d returned %d, %t", i, v, ok)
+		}
+	}
+
+	var n int
+	if err := s.Range(func(k string, v int) bool {
+		if k != strconv.Itoa(v) {
+			t.Fatalf("Range yielded %q with %d", k, v)
+		}
+		n++
+		return true
+	}); err != nil || n != 1000 {
+		t.Errorf("Range should yield every element. Got %d, %v", n, err)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Close should remove the spill files. Found %d", len(entries))
+	}
+}
+
+func TestSpillMapCleanShards(t *testing.T) {
+	dir := t.TempDir()
+	s := must(NewSpillMap[int, int](dir, 4, 1))
+	for i := 0; i < 1000; i++ {
+		s.Set(i, i)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	stat := func() map[string]int64 {
+		mtimes := make(map[string]int64)
+		files, _ := filepath.Glob(filepath.Join(dir, "shard-*.rhm"))
+		for _, f := range files {
+			fi, _ := os.Stat(f)
+			mtimes[f] = fi.ModTime().UnixNano()
+		}
+		return mtimes
+	}
+	before := stat()
+	if len(before) != 4 {
+		t.Fatalf("Flush should leave every shard on disk. Found %d files", len(before))
+	}
+	// Reading swaps shards in and out without writing any
+	for i := 0; i < 1000; i++ {
+		if v, ok, err := s.Get(i); err != nil || !ok || v != i {
+			t.Fatalf("Expected %d, Got %d, %t, %v", i, v, ok, err)
+		}
+	}
+	after := stat()
+	for f, mtime := range before {
+		if after[f] != mtime {
+			t.Errorf("Clean shard %s should not be written again.", f)
+		}
+	}
+}
+
+func TestSpillMapCorruptShard(t *testing.T) {
+	dir := t.TempDir()
+	s := must(NewSpillMap[int, int](dir, 2, 1))
+	for i := 0; i < 100; i++ {
+		s.Set(i, i)
+	}
+	s.Flush()
+	files, _ := filepath.Glob(filepath.Join(dir, "shard-*.rhm"))
+	for _, f := range files {
+		os.WriteFile(f, []byte("garbage"), 0o644)
+	}
+	var failed bool
+	for i := 0; i < 100 && !failed; i++ {
+		_, _, err := s.Get(i)
+		failed = err != nil
+	}
+	if !failed {
+		t.Errorf("Loading a corrupt shard should fail.")
+	}
+
+	if _, err := NewSpillMap[int, int](dir, 2, 1, WithHasher(HasherFunc(func(_, _ uint64, p []byte) uint64 { return 0 }))); err == nil {
+		t.Errorf("A custom hasher should be refused.")
+	}
+}
@@ -0,0 +1,205 @@
+package rhmap
+
+import "fmt"
+
+// Snapshot of a map's probe statistics, for tuning hashers and load factors.
+// PSL statistics cover every element, including those an incremental rehash
+// has yet to migrate.
+type Stats struct {
+	Len uint64
+	// Slots in the current table
+	Capacity uint64
+	// Len divided by Capacity
+	Load float64
+	// Mean, variance and maximum of the elements' probe sequence lengths
+	MeanPsl     float64
+	PslVariance float64
+	MaxPsl      uint
+	// PslHistogram[p] is the number of elements p slots past their home slot
+	PslHistogram []uint64
+	// Number of times the table has grown or shrunk
+	Resizes uint64
+	// Number of times the map reseeded itself after detecting a flood, and
+	// suspected one at all
+	Reseeds         uint64
+	SuspectedFloods uint64
+	// Estimated number of distinct keys ever inserted, within about 2%,
+	// under WithKeyspaceStats
+	DistinctKeys uint64
+	// Get calls that missed, and the keys they missed most often, most
+	// missed first, under WithMissStats
+	Misses    uint64
+	TopMisses []MissCount
+	// Elements evicted, and new keys rejected, to keep within WithMaxEntries
+	Evictions uint64
+	Rejected  uint64
+	// Latency histograms keyed by the Latency constants, under
+	// WithLatencyStats
+	Latency map[string]LatencyHistogram
+	// Get calls that hit and missed, decayed by age, and the share of them
+	// that hit, under WithHitRatio
+	RecentHits   float64
+	RecentMisses float64
+	HitRatio     float64
+}
+
+// Returns the map's current statistics. It scans the whole table, so it is
+// meant for diagnostics rather than hot paths.
+func (m *Map[K, V]) Stats() Stats {
+	s := Stats{Len: m.numElements, Capacity: m.size, Resizes: m.resizes, Reseeds: m.reseeds, SuspectedFloods: m.suspectedFloods,
+		Evictions: m.evictions, Rejected: m.rejected}
+	if m.size > 0 {
+		s.Load = float64(m.numElements) / float64(m.size)
+	}
+	if m.keyspace != nil {
+		s.DistinctKeys = m.keyspace.estimate()
+	}
+	if m.misses != nil {
+		s.Misses, s.TopMisses = m.misses.top()
+	}
+	if m.latency != nil {
+		s.Latency = m.latency.histograms()
+	}
+	if m.hits != nil {
+		s.RecentHits, s.RecentMisses = m.hits.counts()
+		s.HitRatio = ratioOf(s.RecentHits, s.RecentMisses)
+	}
+
+	var sum, sumSquares float64
+	for _, elems := range m.tables() {
+		for _, elem := range elems {
+			if !elem.set {
+				continue
+			}
+			for uint(len(s.PslHistogram)) <= elem.psl {
+				s.PslHistogram = append(s.PslHistogram, 0)
+			}
+			s.PslHistogram[elem.psl]++
+			s.MaxPsl = max(s.MaxPsl, elem.psl)
+			sum += float64(elem.psl)
+			sumSquares += float64(elem.psl) * float64(elem.psl)
+		}
+	}
+	if m.numElements > 0 {
+		n := float64(m.numElements)
+		s.MeanPsl = sum / n
+		s.PslVariance = max(sumSquares/n-s.MeanPsl*s.MeanPsl, 0)
+	}
+	return s
+}
+
+// Returns the map's current load, its element count over the slots of its
+// current table, which it grows at once it reaches the load factor
+func (m *Map[K, V]) LoadFactor() float64 {
+	if m.size == 0 {
+		return 0
+	}
+	return float64(m.numElements) / float64(m.size)
+}
+
+// Returns the mean probe sequence length of the map's elements. Unlike
+// Stats, it reads the running totals the table keeps rather than scanning.
+func (m *Map[K, V]) MeanPSL() float64 {
+	if m.numElements == 0 {
+		return 0
+	}
+	total := m.totalPsl
+	if m.draining != nil {
+		total += m.draining.totalPsl
+	}
+	return float64(total) / float64(m.numElements)
+}
+
+// Returns the longest probe sequence length of the map's elements, the
+// number of slots a miss probes. After deletes it may be an upper bound
+// until the table is rebuilt.
+func (m *Map[K, V]) MaxPSL() uint {
+	if m.draining != nil {
+		return max(m.maxPsl, m.draining.maxPsl)
+	}
+	return m.maxPsl
+}
+
+// Reports whether an incremental rehash is underway and, if so, the fraction
+// of the old table's slots migrated so far. Until it completes, the map
+// holds both tables.
+func (m *Map[K, V]) Migrating() (bool, float64) {
+	if m.draining == nil {
+		return false, 0
+	}
+	return true, float64(m.drainCursor) / float64(m.draining.size)
+}
+
+// Checks the table's invariants and returns an error describing the first
+// violation found: every element sits psl slots past its home slot, no
+// element has passed one closer to its home, and the element count, PSL
+// statistics and control bytes agree with the slots. A healthy map always
+// returns nil, so tests can call it after every operation. Keys mutated
+// since they were set aren't detected; see Audit.
+func (m *Map[K, V]) Validate() error {
+	// The map's element count covers both tables during an incremental rehash
+	own := m.numElements
+	if m.draining != nil {
+		own -= m.draining.numElements
+		if err := m.draining.validateTable(m.draining.numElements, true); err != nil {
+			return fmt.Errorf("%w in the table being drained", err)
+		}
+	}
+	return m.validateTable(own, false)
+}
+
+// Validates a single table expected to hold numElements elements. Migration
+// clears the slots of a table being drained without shifting its clusters
+// back, so such a table may have holes.
+func (m *Map[K, V]) validateTable(numElements uint64, draining bool) error {
+	if uint64(len(m.elements)) != m.size || (!m.fastRange && m.size&(m.size-1) != 0) {
+		return fmt.Errorf("rhmap: table of %d slots with size %d", len(m.elements), m.size)
+	}
+
+	var count, totalPsl, atMax uint64
+	var maxPsl uint
+	for i := range m.elements {
+		elem := &m.elements[i]
+		if m.ctrl != nil {
+			var c byte
+			if elem.set {
+				c = ctrlTag(elem.hash)
+			}
+			if m.ctrl[i] != c || (i < groupSize && m.ctrl[m.size+uint64(i)] != c) {
+				return fmt.Errorf("rhmap: slot %d has a stale control byte", i)
+			}
+		}
+		if !elem.set {
+			continue
+		}
+
+		if m.indexAtPsl(elem.hash, elem.psl) != uint64(i) {
+			return fmt.Errorf("rhmap: slot %d holds an element whose PSL %d doesn't lead to it", i, elem.psl)
+		}
+		// The slot before must hold an element at least as far from home,
+		// less the one step between them, or this element passed it
+		prev := &m.elements[(uint64(i)+m.size-1)%m.size]
+		if elem.psl > 0 && !draining && (!prev.set || prev.psl+1 < elem.psl) {
+			return fmt.Errorf("rhmap: slot %d with PSL %d follows a richer slot", i, elem.psl)
+		}
+
+		count++
+		totalPsl += uint64(elem.psl)
+		if elem.psl > maxPsl {
+			maxPsl, atMax = elem.psl, 0
+		}
+		if elem.psl == maxPsl {
+			atMax++
+		}
+	}
+
+	switch {
+	case count != numElements:
+		return fmt.Errorf("rhmap: %d elements counted, %d recorded", count, numElements)
+	case totalPsl != m.totalPsl:
+		return fmt.Errorf("rhmap: total PSL of %d, %d recorded", totalPsl, m.totalPsl)
+	case maxPsl > m.maxPsl || (maxPsl == m.maxPsl && atMax > uint64(m.maxFreq)):
+		return fmt.Errorf("rhmap: %d elements at PSL %d exceed the recorded maximum", atMax, maxPsl)
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package rhmap
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	m := must(New[int, int]())
+	if s := m.Stats(); s.Len != 0 || s.MeanPsl != 0 || len(s.PslHistogram) != 0 || s.Resizes != 0 {
+		t.Errorf("An empty map should have empty stats. Got %+v", s)
+	}
+
+	for i := 0; i < 1000; i++ {
+		m.Set(i, i)
+	}
+	s := m.Stats()
+	if s.Len != 1000 || s.Capacity != m.size || s.Load != 1000/float64(m.size) {
+		t.Errorf("Stats should report the length, capacity and load. Got %d, %d, %f", s.Len, s.Capacity, s.Load)
+	}
+	// 8 slots doubled to 2048
+	if s.Resizes != 8 {
+		t.Errorf("Growing from 8 to 2048 slots should take 8 resizes. Got %d", s.Resizes)
+	}
+
+	var n, sum uint64
+	for psl, count := range s.PslHistogram {
+		n += count
+		sum += uint64(psl) * count
+	}
+	if n != 1000 || sum != m.totalPsl {
+		t.Errorf("The PSL histogram should cover every element. Counted %d with total PSL %d", n, sum)
+	}
+	if s.MaxPsl != uint(len(s.PslHistogram)-1) || s.MeanPsl != float64(m.totalPsl)/1000 {
+		t.Errorf("Max and mean PSL should match the histogram. Got %d, %f", s.MaxPsl, s.MeanPsl)
+	}
+	if s.PslVariance < 0 || (s.MaxPsl > 0 && s.PslVariance == 0) {
+		t.Errorf("PSL variance should be positive when PSLs differ. Got %f", s.PslVariance)
+	}
+
+	m.SetHasher(XXHasher{})
+	if got := m.Stats().Resizes; got != 8 {
+		t.Errorf("Rebuilding at the same size should not count as a resize. Got %d", got)
+	}
+}
+
+func TestProbeGetters(t *testing.T) {
+	m := must(New[int, int](WithIncrementalRehash(1)))
+	if m.LoadFactor() != 0 || m.MeanPSL() != 0 || m.MaxPSL() != 0 {
+		t.Errorf("An empty map should have no load or PSLs.")
+	}
+	// Checked mid-rehash too, while elements sit in both tables
+	for i := 0; i < 1000; i++ {
+		m.Set(i, i)
+		s := m.Stats()
+		if m.LoadFactor() != s.Load || m.MeanPSL() != s.MeanPsl || m.MaxPSL() < s.MaxPsl {
+			t.Fatalf("Getters should agree with Stats. Got %f, %f, %d; Stats %+v", m.LoadFactor(), m.MeanPSL(), m.MaxPSL(), s)
+		}
+	}
+}
+
+func TestMigrating(t *testing.T) {
+	m := must(New[int, int](WithIncrementalRehash(1)))
+	if migrating, _ := m.Migrating(); migrating {
+		t.Errorf("An empty map should not be migrating.")
+	}
+	// A write can finish one rehash and start the next, so progress is
+	// compared within one draining table only
+	var draining *Map[int, int]
+	last := 0.0
+	for i := 0; i < 1000; i++ {
+		m.Set(i, i)
+		migrating, progress := m.Migrating()
+		if migrating != (m.draining != nil) {
+			t.Fatalf("Migrating should report the rehash underway. Got %t", migrating)
+		}
+		if !migrating {
+			continue
+		}
+		if progress < 0 || progress >= 1 || (m.draining == draining && progress < last) {
+			t.Fatalf("Progress should grow within [0, 1) during a rehash. Got %f after %f", progress, last)
+		}
+		draining, last = m.draining, progress
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for name, opts := range map[string][]Option{
+		"default":     nil,
+		"incremental": {WithIncrementalRehash(2)},
+		"grouped":     {WithGroupProbing()},
+		"fastRange":   {WithFastRange(), WithShrink(.2)},
+	} {
+		m := must(New[int, int](opts...))
+		for i := 0; i < 5000; i++ {
+			k := rand.Intn(1000)
+			switch i % 4 {
+			case 0, 1:
+				m.Set(k, i)
+			case 2:
+				m.Delete(k)
+			case 3:
+				m.DeleteAll([]int{k, k + 1, k + 2})
+			}
+			if err := m.Validate(); err != nil {
+				t.Fatalf("%s: a healthy map should validate after operation %d. Got %v", name, i, err)
+			}
+		}
+	}
+
+	m := must(New[int, int]())
+	for i := 0; i < 100; i++ {
+		m.Set(i, i)
+	}
+	corrupt := func(what string, change func()) {
+		saved := *m
+		saved.elements = append([]element[int, int](nil), m.elements...)
+		change()
+		if m.Validate() == nil {
+			t.Errorf("Validate should catch %s.", what)
+		}
+		*m = saved
+	}
+	corrupt("a wrong element count", func() { m.numElements++ })
+	corrupt("a wrong total PSL", func() { m.totalPsl++ })
+	corrupt("an understated max PSL", func() { m.maxPsl, m.maxFreq = 0, 0 })
+	corrupt("an element at the wrong PSL", func() {
+		for i := range m.elements {
+			if m.elements[i].set {
+				m.elements[i].psl++
+				return
+			}
+		}
+	})
+	corrupt("an element that passed a richer one", func() {
+		for i := range m.elements {
+			j := (i + 1) % len(m.elements)
+			if m.elements[i].set && m.elements[j].set && m.elements[j].psl > 0 {
+				m.elements[i].set = false
+				return
+			}
+		}
+	})
+}
+
+func TestKeyspaceStats(t *testing.T) {
+	m := must(New[int, int](WithKeyspaceStats(), WithSeed(1, 2)))
+	// Churn over a small keyspace
+	for round := 0; round < 50; round++ {
+		for i := 0; i < 1000; i++ {
+			m.Set(i, round)
+		}
+		for i := 0; i < 1000; i++ {
+			m.Delete(i)
+		}
+	}
+	if got := m.Stats().DistinctKeys; got < 950 || got > 1050 {
+		t.Errorf("Churn over 1000 keys should estimate about 1000. Got %d", got)
+	}
+
+	// A growing keyspace
+	for i := 0; i < 100000; i++ {
+		m.GetOrSet(i, i)
+		m.Delete(i)
+	}
+	if got := m.Stats().DistinctKeys; got < 95000 || got > 105000 {
+		t.Errorf("100000 distinct keys should estimate about 100000. Got %d", got)
+	}
+
+	c := m.Clone()
+	c.Set(-1, 0)
+	m.Reset()
+	if m.Stats().DistinctKeys != 0 || c.Stats().DistinctKeys == 0 {
+		t.Errorf("Reset should restart the history without reaching the clone.")
+	}
+	if must(New[int, int]()).Stats().DistinctKeys != 0 {
+		t.Errorf("Keyspace stats should be off by default.")
+	}
+}
+
+func TestMissStats(t *testing.T) {
+	m := must(New[int, int](WithMissStats(3)))
+	for i := 0; i < 100; i++ {
+		m.Set(i, i)
+	}
+	for round := 0; round < 50; round++ {
+		m.Get(round)
+		m.Get(-1)
+		if round%2 == 0 {
+			m.Get(-2)
+		}
+		if round%5 == 0 {
+			m.Get(-3)
+		}
+		// Keys missed once each
+		m.Get(1000 + round)
+	}
+
+	s := m.Stats()
+	if s.Misses != 50+25+10+50 {
+		t.Errorf("Every missed Get should be counted. Expected 135, Got %d", s.Misses)
+	}
+	if len(s.TopMisses) != 3 {
+		t.Fatalf("Three keys should be tracked. Got %v", s.TopMisses)
+	}
+	for i, want := range []MissCount{{-1, 50}, {-2, 25}, {-3, 10}} {
+		if got := s.TopMisses[i]; got.Key != want.Key || got.Count < want.Count || got.Count > want.Count+2 {
+			t.Errorf("Miss %d should be about %v. Got %v", i, want, got)
+		}
+	}
+
+	c := m.Clone()
+	m.Reset()
+	if s := m.Stats(); s.Misses != 0 || len(s.TopMisses) != 0 {
+		t.Errorf("Reset should restart miss tracking. Got %d, %v", s.Misses, s.TopMisses)
+	}
+	if c.Stats().Misses != 135 {
+		t.Errorf("A clone should keep its own miss history.")
+	}
+	if s := must(New[int, int]()).Stats(); s.TopMisses != nil {
+		t.Errorf("Miss stats should be off by default.")
+	}
+}
@@ -0,0 +1,343 @@
+package rhmap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Leads every stream written by WriteTo, followed by the format version
+const streamMagic = "RHMP"
+
+// Version WriteTo writes. Version 1 streams, which carry no type layouts or
+// checksums, are still read.
+const streamVersion = 2
+
+var (
+	// Returned by ReadFrom for a stream that is malformed or fails a checksum
+	ErrCorruptStream = errors.New("rhmap: corrupt map stream")
+	// Returned by ReadFrom for a stream of keys or values laid out unlike the
+	// map's
+	ErrIncompatibleStream = errors.New("rhmap: map stream of incompatible types")
+)
+
+// Checksums of version 2 streams
+var streamCRC = crc32.MakeTable(crc32.Castagnoli)
+
+// Elements per chunk of a stream, and the largest encoded chunk ReadFrom
+// accepts, which bounds what a corrupt length can make it allocate
+const (
+	streamChunkLen = 4096
+	maxStreamChunk = 1 << 30
+)
+
+// Bytes of the header after the hasher name: seeds, load factor, size,
+// flags and element count
+const streamHeaderLen = 8 + 8 + 4 + 8 + 1 + 8
+
+// Header flags for the options a stream records
+const (
+	streamFastRange = 1 << iota
+	streamZeroDeletes
+)
+
+// Elements of one chunk of a stream, gob-encoded
+type streamChunk[K comparable, V any] struct {
+	Keys   []K
+	Values []V
+}
+
+// Writes the map to w in a versioned binary format that ReadFrom restores,
+// streaming elements in chunks so that checkpointing a huge map needs memory
+// for one chunk rather than for the whole encoding, as GobEncode does. The
+// stream starts with a header holding the map's seeds, configuration,
+// element count and the layouts of its key and value types, and each chunk
+// is gob-encoded behind its length, ending with an empty one. The header
+// and every chunk are followed by a CRC-32C checksum. Like GobEncode, it
+// can't encode maps using a custom hasher and its output holds the seeds.
+// It returns the number of bytes written.
+func (m *Map[K, V]) WriteTo(w io.Writer) (int64, error) {
+	name := builtinHasherName(m.hasher)
+	if name == "" {
+		return 0, errors.New("rhmap: can't encode a map with a custom hasher")
+	}
+
+	header := append([]byte(streamMagic), streamVersion, byte(len(name)))
+	header = append(header, name...)
+	header = binary.LittleEndian.AppendUint64(header, m.k0)
+	header = binary.LittleEndian.AppendUint64(header, m.k1)
+	header = binary.LittleEndian.AppendUint32(header, math.Float32bits(m.loadFactor))
+	header = binary.LittleEndian.AppendUint64(header, m.size)
+	var flags byte
+	if m.fastRange {
+		flags |= streamFastRange
+	}
+	if m.zeroDeletes {
+		flags |= streamZeroDeletes
+	}
+	header = append(header, flags)
+	header = binary.LittleEndian.AppendUint64(header, m.numElements)
+	for _, layout := range [...]string{typeLayout(reflect.TypeFor[K]()), typeLayout(reflect.TypeFor[V]())} {
+		header = binary.LittleEndian.AppendUint16(header, uint16(len(layout)))
+		header = append(header, layout...)
+	}
+	header = binary.LittleEndian.AppendUint32(header, crc32.Checksum(header, streamCRC))
+	n, err := w.Write(header)
+	written := int64(n)
+	if err != nil {
+		return written, err
+	}
+
+	var chunk streamChunk[K, V]
+	var buffer bytes.Buffer
+	flush := func() error {
+		buffer.Reset()
+		buffer.Write(make([]byte, 4))
+		if len(chunk.Keys) > 0 {
+			if err := gob.NewEncoder(&buffer).Encode(chunk); err != nil {
+				return err
+			}
+		}
+		binary.LittleEndian.PutUint32(buffer.Bytes(), uint32(buffer.Len()-4))
+		buffer.Write(binary.LittleEndian.AppendUint32(nil, crc32.Checksum(buffer.Bytes(), streamCRC)))
+		n, err := w.Write(buffer.Bytes())
+		written += int64(n)
+		chunk.Keys, chunk.Values = chunk.Keys[:0], chunk.Values[:0]
+		return err
+	}
+	for key, value := range m.All() {
+		chunk.Keys = append(chunk.Keys, key)
+		chunk.Values = append(chunk.Values, value)
+		if len(chunk.Keys) == streamChunkLen {
+			if err := flush(); err != nil {
+				return written, err
+			}
+		}
+	}
+	if len(chunk.Keys) > 0 {
+		if err := flush(); err != nil {
+			return written, err
+		}
+	}
+	return written, flush()
+}
+
+// Replaces the map's contents and configuration with a map written by
+// WriteTo, reading one chunk at a time. The zero Map is a valid target.
+// A stream whose key or value layout differs from K's or V's is refused
+// with ErrIncompatibleStream before the map is touched. If the stream is
+// cut short, the error is returned, and if it is malformed or fails a
+// checksum, ErrCorruptStream is; either way the map then holds the
+// elements of the chunks verified so far. It returns the number of bytes
+// read.
+func (m *Map[K, V]) ReadFrom(r io.Reader) (int64, error) {
+	if m.readOnly {
+		return 0, ErrReadOnly
+	}
+	cr := &countingReader{r: r}
+
+	prefix := make([]byte, len(streamMagic)+2)
+	if _, err := io.ReadFull(cr, prefix); err != nil {
+		return cr.n, err
+	}
+	if string(prefix[:len(streamMagic)]) != streamMagic {
+		return cr.n, ErrCorruptStream
+	}
+	version := prefix[len(streamMagic)]
+	if version != 1 && version != streamVersion {
+		return cr.n, fmt.Errorf("%w: unsupported map stream version %d", ErrIncompatibleStream, version)
+	}
+	header := make([]byte, int(prefix[len(streamMagic)+1])+streamHeaderLen)
+	if _, err := io.ReadFull(cr, header); err != nil {
+		return cr.n, noEOF(err)
+	}
+	nameLen := len(header) - streamHeaderLen
+	if version > 1 {
+		if err := readLayouts[K, V](cr, append(prefix, header...)); err != nil {
+			return cr.n, err
+		}
+	}
+	state := mapState[K, V]{
+		Hasher:     string(header[:nameLen]),
+		K0:         binary.LittleEndian.Uint64(header[nameLen:]),
+		K1:         binary.LittleEndian.Uint64(header[nameLen+8:]),
+		LoadFactor: math.Float32frombits(binary.LittleEndian.Uint32(header[nameLen+16:])),
+		Size:       binary.LittleEndian.Uint64(header[nameLen+20:]),
+	}
+	flags := header[nameLen+28]
+	state.FastRange, state.ZeroDeletes = flags&streamFastRange != 0, flags&streamZeroDeletes != 0
+	count := binary.LittleEndian.Uint64(header[nameLen+29:])
+	// The table is grown chunk by chunk, so a corrupt count can't make it
+	// allocate more than the stream holds
+	if err := m.restore(&state, 0); err != nil {
+		return cr.n, err
+	}
+	defer m.publish()
+
+	// Each chunk is read whole, with its length and checksum, before any of
+	// it is decoded
+	var lenBuf [4]byte
+	var data []byte
+	for {
+		if _, err := io.ReadFull(cr, lenBuf[:]); err != nil {
+			return cr.n, noEOF(err)
+		}
+		size := binary.LittleEndian.Uint32(lenBuf[:])
+		if size > maxStreamChunk {
+			return cr.n, ErrCorruptStream
+		}
+		checked := 0
+		if version > 1 {
+			checked = 4
+		}
+		if cap(data) < 4+int(size)+checked {
+			data = make([]byte, 4+int(size)+checked)
+		}
+		data = data[:4+int(size)+checked]
+		copy(data, lenBuf[:])
+		if _, err := io.ReadFull(cr, data[4:]); err != nil {
+			return cr.n, noEOF(err)
+		}
+		if version > 1 {
+			body := data[:4+size]
+			if binary.LittleEndian.Uint32(data[4+size:]) != crc32.Checksum(body, streamCRC) {
+				return cr.n, fmt.Errorf("%w: chunk checksum mismatch", ErrCorruptStream)
+			}
+		}
+		if size == 0 {
+			break
+		}
+		var chunk streamChunk[K, V]
+		if err := gob.NewDecoder(bytes.NewReader(data[4 : 4+size])).Decode(&chunk); err != nil {
+			return cr.n, fmt.Errorf("%w: %v", ErrCorruptStream, err)
+		}
+		if len(chunk.Keys) != len(chunk.Values) {
+			return cr.n, ErrCorruptStream
+		}
+		m.growFor(m.numElements + uint64(len(chunk.Keys)))
+		for i, key := range chunk.Keys {
+			m.Set(key, chunk.Values[i])
+		}
+	}
+	if m.numElements != count {
+		return cr.n, fmt.Errorf("%w: %d elements where the header counts %d", ErrCorruptStream, m.numElements, count)
+	}
+	return cr.n, nil
+}
+
+// Reads the key and value layouts and checksum ending a version 2 header,
+// whose earlier bytes were read, and checks both
+func readLayouts[K comparable, V any](r io.Reader, header []byte) error {
+	var layouts [2]string
+	for i := range layouts {
+		var lenBuf [2]byte
+		if _, err := io.ReadFull(r, lenBuf[:]); err != nil {
+			return noEOF(err)
+		}
+		layout := make([]byte, binary.LittleEndian.Uint16(lenBuf[:]))
+		if _, err := io.ReadFull(r, layout); err != nil {
+			return noEOF(err)
+		}
+		header = append(append(header, lenBuf[:]...), layout...)
+		layouts[i] = string(layout)
+	}
+	var sum [4]byte
+	if _, err := io.ReadFull(r, sum[:]); err != nil {
+		return noEOF(err)
+	}
+	if binary.LittleEndian.Uint32(sum[:]) != crc32.Checksum(header, streamCRC) {
+		return fmt.Errorf("%w: header checksum mismatch", ErrCorruptStream)
+	}
+
+	for i, want := range [...]string{typeLayout(reflect.TypeFor[K]()), typeLayout(reflect.TypeFor[V]())} {
+		if layouts[i] != want {
+			return fmt.Errorf("%w: stream has %s %s, map has %s", ErrIncompatibleStream,
+				[...]string{"keys", "values"}[i], layouts[i], want)
+		}
+	}
+	return nil
+}
+
+// Describes the layout of t as gob encodes it: kinds and sizes, and for
+// structs the names and layouts of exported fields, regardless of the
+// names of the types involved. Types recurring within their own layout are
+// described by name.
+func typeLayout(t reflect.Type) string {
+	var b strings.Builder
+	writeTypeLayout(&b, t, nil)
+	return b.String()
+}
+
+func writeTypeLayout(b *strings.Builder, t reflect.Type, enclosing []reflect.Type) {
+	for _, e := range enclosing {
+		if e == t {
+			b.WriteString(t.String())
+			return
+		}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		b.WriteByte('*')
+		writeTypeLayout(b, t.Elem(), append(enclosing, t))
+	case reflect.Slice:
+		b.WriteString("[]")
+		writeTypeLayout(b, t.Elem(), append(enclosing, t))
+	case reflect.Array:
+		b.WriteString("[" + strconv.Itoa(t.Len()) + "]")
+		writeTypeLayout(b, t.Elem(), append(enclosing, t))
+	case reflect.Map:
+		b.WriteString("map[")
+		writeTypeLayout(b, t.Key(), append(enclosing, t))
+		b.WriteByte(']')
+		writeTypeLayout(b, t.Elem(), append(enclosing, t))
+	case reflect.Struct:
+		b.WriteString("struct{")
+		first := true
+		for i := range t.NumField() {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			if !first {
+				b.WriteString("; ")
+			}
+			first = false
+			b.WriteString(f.Name + " ")
+			writeTypeLayout(b, f.Type, append(enclosing, t))
+		}
+		b.WriteByte('}')
+	case reflect.Interface:
+		b.WriteString("interface")
+	default:
+		b.WriteString(t.Kind().String())
+	}
+}
+
+// Reports a stream ending before its final chunk as truncated rather than
+// as a clean end
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Reader counting the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,185 @@
+package rhmap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestStreamRoundTrip(t *testing.T) {
+	m := must(New[string, int](WithHasher(XXHasher{}), WithFastRange()))
+	n := 3*streamChunkLen + 10
+	for i := 0; i < n; i++ {
+		m.Set(strconv.Itoa(i), i)
+	}
+
+	var buf bytes.Buffer
+	written, err := m.WriteTo(&buf)
+	if err != nil {
+		t.Fatalf("WriteTo returned an error: %v", err)
+	}
+	if written != int64(buf.Len()) {
+		t.Errorf("WriteTo should count the bytes written. Expected %d, Got %d", buf.Len(), written)
+	}
+	data := bytes.Clone(buf.Bytes())
+
+	var d Map[string, int]
+	read, err := d.ReadFrom(&buf)
+	if err != nil {
+		t.Fatalf("ReadFrom returned an error: %v", err)
+	}
+	if read != written {
+		t.Errorf("ReadFrom should count the bytes read. Expected %d, Got %d", written, read)
+	}
+	if d.Len() != uint64(n) {
+		t.Errorf("Restored map should contain %d elements. Found %d", n, d.Len())
+	}
+	for i := 0; i < n; i++ {
+		if val, ok := d.Get(strconv.Itoa(i)); !ok || val != i {
+			t.Errorf("Key %d should map to %d after restoring. Got %d, %t", i, i, val, ok)
+		}
+	}
+	if d.hashKey("key") != m.hashKey("key") || !d.fastRange {
+		t.Errorf("Restored map should keep its hasher, seeds and options.")
+	}
+
+	if _, err := d.ReadFrom(bytes.NewReader(data[:len(data)/2])); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("A truncated stream should fail with io.ErrUnexpectedEOF. Got %v", err)
+	}
+	data[len(streamMagic)] = streamVersion + 1
+	if _, err := d.ReadFrom(bytes.NewReader(data)); err == nil {
+		t.Errorf("A stream of an unknown version should be rejected.")
+	}
+	if _, err := d.ReadFrom(bytes.NewReader([]byte("not a map stream"))); err == nil {
+		t.Errorf("Data without the stream header should be rejected.")
+	}
+
+	if _, err := m.WriteTo(failingWriter{}); !errors.Is(err, errWrite) {
+		t.Errorf("WriteTo should return the writer's error. Got %v", err)
+	}
+	custom := must(New[int, int](WithHasher(NewMapHasher())))
+	if _, err := custom.WriteTo(io.Discard); err == nil {
+		t.Errorf("Maps with a custom hasher can't be streamed.")
+	}
+}
+
+func TestStreamEmptyMap(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := must(New[int, int]()).WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo returned an error: %v", err)
+	}
+	d := must(New[int, int]())
+	d.Set(1, 1)
+	if _, err := d.ReadFrom(&buf); err != nil || d.Len() != 0 {
+		t.Errorf("Restoring an empty map should empty the target. Got %d elements, %v", d.Len(), err)
+	}
+}
+
+func TestStreamIntegrity(t *testing.T) {
+	m := must(New[string, int]())
+	for i := 0; i < 2*streamChunkLen; i++ {
+		m.Set(strconv.Itoa(i), i)
+	}
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo returned an error: %v", err)
+	}
+	data := buf.Bytes()
+
+	for _, at := range []int{len(streamMagic) + 10, len(data) / 2, len(data) - 3} {
+		corrupt := bytes.Clone(data)
+		corrupt[at] ^= 0x20
+		d := must(New[string, int]())
+		if _, err := d.ReadFrom(bytes.NewReader(corrupt)); !errors.Is(err, ErrCorruptStream) {
+			t.Errorf("A stream corrupted at byte %d should fail with ErrCorruptStream. Got %v", at, err)
+		}
+	}
+
+	d := must(New[string, string]())
+	d.Set("kept", "")
+	if _, err := d.ReadFrom(bytes.NewReader(data)); !errors.Is(err, ErrIncompatibleStream) {
+		t.Errorf("A stream of other value types should fail with ErrIncompatibleStream. Got %v", err)
+	}
+	if _, ok := d.Get("kept"); !ok {
+		t.Errorf("A stream refused for its types should leave the map as it was.")
+	}
+}
+
+type layoutA struct {
+	X    int
+	Tags []string
+	next *layoutA
+}
+
+type layoutB struct {
+	X    int
+	Tags []string
+}
+
+type layoutC struct {
+	X    int64
+	Tags []string
+}
+
+type layoutList struct {
+	Value int
+	Next  *layoutList
+}
+
+func TestTypeLayout(t *testing.T) {
+	a, b, c := typeLayout(reflect.TypeFor[layoutA]()), typeLayout(reflect.TypeFor[layoutB]()), typeLayout(reflect.TypeFor[layoutC]())
+	if a != b {
+		t.Errorf("Layouts should ignore type names and unexported fields. Got %q and %q", a, b)
+	}
+	if a == c {
+		t.Errorf("Layouts should tell int from int64. Got %q for both", a)
+	}
+	if got, want := typeLayout(reflect.TypeFor[map[string]layoutList]()),
+		"map[string]struct{Value int; Next *rhmap.layoutList}"; got != want {
+		t.Errorf("Expected %q, Got %q", want, got)
+	}
+}
+
+// Rewrites a version 2 stream of a Map[int, int] as version 1, without type
+// layouts or checksums
+func streamVersion1(data []byte) []byte {
+	nameLen := int(data[len(streamMagic)+1])
+	headerLen := len(streamMagic) + 2 + nameLen + streamHeaderLen
+	out := append([]byte(nil), data[:headerLen]...)
+	out[len(streamMagic)] = 1
+	rest := data[headerLen:]
+	for range 2 {
+		n := int(binary.LittleEndian.Uint16(rest))
+		rest = rest[2+n:]
+	}
+	rest = rest[4:]
+	for {
+		size := int(binary.LittleEndian.Uint32(rest))
+		out = append(out, rest[:4+size]...)
+		rest = rest[4+size+4:]
+		if size == 0 {
+			return out
+		}
+	}
+}
+
+func TestStreamReadsVersion1(t *testing.T) {
+	m := must(New[int, int]())
+	for i := 0; i < streamChunkLen+5; i++ {
+		m.Set(i, -i)
+	}
+	var buf bytes.Buffer
+	m.WriteTo(&buf)
+
+	d := must(New[int, int]())
+	if _, err := d.ReadFrom(bytes.NewReader(streamVersion1(buf.Bytes()))); err != nil {
+		t.Fatalf("A version 1 stream should still be read. Got %v", err)
+	}
+	if v, _ := d.Get(7); d.Len() != m.Len() || v != -7 {
+		t.Errorf("Expected %d elements with 7 = -7, Got %d with 7 = %d", m.Len(), d.Len(), v)
+	}
+}
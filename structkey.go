@@ -0,0 +1,154 @@
+package rhmap
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Struct tag name for key fields. A field tagged `rhmap:"-"` is left out of
+// hashing and equality, for fields such as timestamps or caches that don't
+// contribute to a key's identity.
+const keyTagName = "rhmap"
+
+// Encodes and compares values of one type by the fields that identify them
+type valueCodec struct {
+	append func(buf []byte, v reflect.Value) []byte
+	equal  func(a, b reflect.Value) bool
+}
+
+// Reports whether t is, or contains, a struct with a field tagged
+// `rhmap:"-"`
+func hasIgnoredFields(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Array:
+		return hasIgnoredFields(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.Tag.Get(keyTagName) == "-" || hasIgnoredFields(f.Type) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Builds the codec for t. Structs are encoded and compared by their exported
+// fields not tagged `rhmap:"-"`; unexported fields are ignored, as gob
+// ignores them. It returns an error for a struct left with no fields.
+func newValueCodec(t reflect.Type) (valueCodec, error) {
+	switch t.Kind() {
+	case reflect.Struct:
+		var fields []int
+		var codecs []valueCodec
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() || f.Tag.Get(keyTagName) == "-" {
+				continue
+			}
+			c, err := newValueCodec(f.Type)
+			if err != nil {
+				return valueCodec{}, err
+			}
+			fields = append(fields, i)
+			codecs = append(codecs, c)
+		}
+		if len(fields) == 0 {
+			return valueCodec{}, fmt.Errorf("rhmap: %v has no fields to hash", t)
+		}
+		return valueCodec{
+			append: func(buf []byte, v reflect.Value) []byte {
+				for i, f := range fields {
+					buf = codecs[i].append(buf, v.Field(f))
+				}
+				return buf
+			},
+			equal: func(a, b reflect.Value) bool {
+				for i, f := range fields {
+					if !codecs[i].equal(a.Field(f), b.Field(f)) {
+						return false
+					}
+				}
+				return true
+			},
+		}, nil
+
+	case reflect.Array:
+		elem, err := newValueCodec(t.Elem())
+		if err != nil {
+			return valueCodec{}, err
+		}
+		return valueCodec{
+			append: func(buf []byte, v reflect.Value) []byte {
+				for i := 0; i < v.Len(); i++ {
+					buf = elem.append(buf, v.Index(i))
+				}
+				return buf
+			},
+			equal: func(a, b reflect.Value) bool {
+				for i := 0; i < a.Len(); i++ {
+					if !elem.equal(a.Index(i), b.Index(i)) {
+						return false
+					}
+				}
+				return true
+			},
+		}, nil
+	}
+
+	leaf := func(append func([]byte, reflect.Value) []byte) (valueCodec, error) {
+		return valueCodec{append: append, equal: reflect.Value.Equal}, nil
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return leaf(func(buf []byte, v reflect.Value) []byte {
+			if v.Bool() {
+				return append(buf, 1)
+			}
+			return append(buf, 0)
+		})
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return leaf(func(buf []byte, v reflect.Value) []byte {
+			return binary.LittleEndian.AppendUint64(buf, uint64(v.Int()))
+		})
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return leaf(func(buf []byte, v reflect.Value) []byte {
+			return binary.LittleEndian.AppendUint64(buf, v.Uint())
+		})
+	case reflect.Pointer, reflect.Chan, reflect.UnsafePointer:
+		return leaf(func(buf []byte, v reflect.Value) []byte {
+			return binary.LittleEndian.AppendUint64(buf, uint64(v.Pointer()))
+		})
+	case reflect.Float32, reflect.Float64:
+		return leaf(func(buf []byte, v reflect.Value) []byte {
+			return binary.LittleEndian.AppendUint64(buf, float64Bits(v.Float()))
+		})
+	case reflect.Complex64, reflect.Complex128:
+		return leaf(func(buf []byte, v reflect.Value) []byte {
+			c := v.Complex()
+			buf = binary.LittleEndian.AppendUint64(buf, float64Bits(real(c)))
+			return binary.LittleEndian.AppendUint64(buf, float64Bits(imag(c)))
+		})
+	case reflect.String:
+		// Length-prefixed, so adjacent string fields can't run together
+		return leaf(func(buf []byte, v reflect.Value) []byte {
+			buf = binary.AppendUvarint(buf, uint64(v.Len()))
+			return append(buf, v.String()...)
+		})
+	case reflect.Interface:
+		return leaf(func(buf []byte, v reflect.Value) []byte {
+			if v.IsNil() {
+				return append(buf, 0)
+			}
+			enc, err := gobEncode(v.Interface())
+			if err != nil {
+				panic(fmt.Sprintf("rhmap: can't encode key field of type %v: %v", v.Elem().Type(), err))
+			}
+			buf = binary.AppendUvarint(append(buf, 1), uint64(len(enc)))
+			return append(buf, enc...)
+		})
+	}
+	return valueCodec{}, errors.New("rhmap: unsupported key field type " + t.String())
+}
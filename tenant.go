@@ -0,0 +1,275 @@
+package rhmap
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+
+	"github.com/micoo227/robin-hood-hashing/internal/slab"
+)
+
+// Matches, with errors.Is, every QuotaError
+var ErrQuotaExceeded = errors.New("rhmap: tenant quota exceeded")
+
+// Returned by TenantMap.Set when an element would take a tenant past its
+// quota and evicting the tenant's own entries can't make room
+type QuotaError struct {
+	Tenant string
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("rhmap: quota exceeded for tenant %q", e.Tenant)
+}
+
+func (e *QuotaError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+// Elements and memory attributed to one tenant of a TenantMap
+type TenantUsage struct {
+	Count uint64
+	// Estimated bytes: a table slot per element, plus what the map's size
+	// function reports for each
+	Bytes uint64
+}
+
+// Slot of a TenantMap's entry slab, linked into its tenant's recency list by
+// index. Each tenant's list runs through a sentinel slot of its own.
+type tenantEntry[K comparable, V any] struct {
+	key    K
+	value  V
+	hash   uint64
+	tenant *tenantState
+	bytes  uint64
+}
+
+type tenantState struct {
+	name  string
+	usage TenantUsage
+	// Slab index of the sentinel of the tenant's recency list, whose front
+	// is its most recently used entry
+	list uint32
+}
+
+// Robin hood hashmap shared by several tenants, which charges every element
+// to the tenant that set it so services can attribute memory to tenants and
+// cap it with per-tenant quotas. Untagged elements are charged to the
+// tenant "". The table maps each key to its entry's index in a slab, as in
+// LRU, and each tenant's entries are linked by recency so that an over-quota
+// tenant can evict its own coldest entries rather than anyone else's.
+type TenantMap[K comparable, V any] struct {
+	table       *Map[K, uint32]
+	entries     slab.Slab[tenantEntry[K, V]]
+	tenants     *Map[string, *tenantState]
+	sizeOf      func(K, V) uint64
+	byteQuotas  map[string]uint64
+	entryQuotas map[string]uint64
+	evict       bool
+	onEvict     func(K, V)
+}
+
+// Creates a tenant map. sizeOf, if non-nil, estimates the memory an element
+// points to beyond its table slot, such as string contents. Options apply
+// to the underlying map, along with WithTenantQuota and WithTenantEviction;
+// WithZeroDeletes has no effect. It returns an error if K can't be encoded,
+// as New does.
+func NewTenantMap[K comparable, V any](sizeOf func(K, V) uint64, opts ...Option) (*TenantMap[K, V], error) {
+	table, err := New[K, uint32](opts...)
+	if err != nil {
+		return nil, err
+	}
+	table.zeroDeletes = false
+	table.onEvict = nil
+	tenants, err := New[string, *tenantState]()
+	if err != nil {
+		return nil, err
+	}
+
+	o := resolveOptions(opts)
+	return &TenantMap[K, V]{
+		table:       table,
+		tenants:     tenants,
+		sizeOf:      sizeOf,
+		byteQuotas:  make(map[string]uint64),
+		entryQuotas: o.tenantQuotas,
+		evict:       o.tenantEvict,
+		onEvict:     evictHook[K, V](o),
+	}, nil
+}
+
+// Stores value under key, charges it to tenant and marks it the tenant's
+// most recently used entry, moving the charge if key belonged to another
+// tenant. If the element would take tenant past a quota, the tenant's least
+// recently used entries are evicted to make room under WithTenantEviction;
+// otherwise, or if evicting can't make room, nothing changes and a
+// *QuotaError is returned.
+func (t *TenantMap[K, V]) Set(tenant string, key K, value V) error {
+	bytes := uint64(unsafe.Sizeof(element[K, uint32]{})) + slab.SlotSize[tenantEntry[K, V]]()
+	if t.sizeOf != nil {
+		bytes += t.sizeOf(key, value)
+	}
+
+	hash := t.table.hashKey(key)
+	i, ok, _ := t.table.getWithHash(key, hash)
+	if !ok {
+		i = noEntry
+	}
+	if err := t.makeRoom(tenant, bytes, i); err != nil {
+		return err
+	}
+
+	if ok {
+		t.release(i)
+	} else {
+		i = t.entries.Alloc()
+		t.table.setWithHash(key, i, hash)
+	}
+	*t.entries.At(i) = tenantEntry[K, V]{key: key, value: value, hash: hash, bytes: bytes}
+	t.charge(tenant, i)
+	return nil
+}
+
+// Slab index standing for no entry
+const noEntry = ^uint32(0)
+
+// Checks that tenant can take an element of the given bytes in place of
+// entry replacing, if any, evicting its coldest other entries if allowed
+func (t *TenantMap[K, V]) makeRoom(tenant string, bytes uint64, replacing uint32) error {
+	maxEntries, entryLimited := t.entryQuotas[tenant]
+	maxBytes, byteLimited := t.byteQuotas[tenant]
+	if !entryLimited && !byteLimited {
+		return nil
+	}
+
+	for {
+		st, _ := t.tenants.Get(tenant)
+		var usage TenantUsage
+		if st != nil {
+			usage = st.usage
+		}
+		if replacing != noEntry && t.entries.At(replacing).tenant == st {
+			usage.Count--
+			usage.Bytes -= t.entries.At(replacing).bytes
+		}
+		if (!entryLimited || usage.Count+1 <= maxEntries) && (!byteLimited || usage.Bytes+bytes <= maxBytes) {
+			return nil
+		}
+
+		if !t.evict || st == nil {
+			return &QuotaError{Tenant: tenant}
+		}
+		victim := t.entries.Prev(st.list)
+		if victim == replacing {
+			victim = t.entries.Prev(victim)
+		}
+		if victim == st.list {
+			return &QuotaError{Tenant: tenant}
+		}
+		k, v := t.entries.At(victim).key, t.entries.At(victim).value
+		t.remove(victim)
+		if t.onEvict != nil {
+			t.onEvict(k, v)
+		}
+	}
+}
+
+// Returns the value under key and marks it its tenant's most recently used
+// entry
+func (t *TenantMap[K, V]) Get(key K) (V, bool) {
+	i, ok := t.table.Get(key)
+	if !ok {
+		var zeroVal V
+		return zeroVal, false
+	}
+	t.entries.Unlink(i)
+	t.entries.PushFront(t.entries.At(i).tenant.list, i)
+	return t.entries.At(i).value, true
+}
+
+// Returns the tenant key is charged to, if key is present
+func (t *TenantMap[K, V]) Tenant(key K) (string, bool) {
+	i, ok := t.table.Get(key)
+	if !ok {
+		return "", false
+	}
+	return t.entries.At(i).tenant.name, true
+}
+
+func (t *TenantMap[K, V]) Delete(key K) {
+	if i, ok := t.table.Get(key); ok {
+		v := t.entries.At(i).value
+		t.remove(i)
+		if t.onEvict != nil {
+			t.onEvict(key, v)
+		}
+	}
+}
+
+func (t *TenantMap[K, V]) Len() uint64 {
+	return t.table.Len()
+}
+
+// Caps the estimated bytes charged to tenant at maxBytes. Elements already
+// stored are kept even if they exceed it; only later Sets are refused or
+// evict. A maxBytes of 0 removes the quota.
+func (t *TenantMap[K, V]) SetQuota(tenant string, maxBytes uint64) {
+	if maxBytes == 0 {
+		delete(t.byteQuotas, tenant)
+		return
+	}
+	t.byteQuotas[tenant] = maxBytes
+}
+
+// Returns what is currently charged to tenant
+func (t *TenantMap[K, V]) Usage(tenant string) TenantUsage {
+	if st, ok := t.tenants.Get(tenant); ok {
+		return st.usage
+	}
+	return TenantUsage{}
+}
+
+// Returns what is currently charged to every tenant holding at least one
+// element
+func (t *TenantMap[K, V]) UsageByTag() map[string]TenantUsage {
+	byTag := make(map[string]TenantUsage, t.tenants.Len())
+	for tenant, st := range t.tenants.All() {
+		byTag[tenant] = st.usage
+	}
+	return byTag
+}
+
+// Deletes entry i from the table, releases its charge and frees its slot
+func (t *TenantMap[K, V]) remove(i uint32) {
+	e := t.entries.At(i)
+	t.table.deleteWithHash(e.key, e.hash)
+	t.release(i)
+	t.entries.Free(i)
+}
+
+// Charges entry i to tenant as its most recently used entry, creating the
+// tenant's list on its first entry
+func (t *TenantMap[K, V]) charge(tenant string, i uint32) {
+	st, ok := t.tenants.Get(tenant)
+	if !ok {
+		st = &tenantState{name: tenant, list: t.entries.NewList()}
+		t.tenants.Set(tenant, st)
+	}
+	e := t.entries.At(i)
+	e.tenant = st
+	st.usage.Count++
+	st.usage.Bytes += e.bytes
+	t.entries.PushFront(st.list, i)
+}
+
+// Releases entry i's charge, dropping its tenant once it holds nothing
+func (t *TenantMap[K, V]) release(i uint32) {
+	e := t.entries.At(i)
+	st := e.tenant
+	t.entries.Unlink(i)
+	st.usage.Count--
+	st.usage.Bytes -= e.bytes
+	if st.usage.Count == 0 {
+		t.tenants.Delete(st.name)
+		t.entries.Free(st.list)
+	}
+}
@@ -0,0 +1,141 @@
+package rhmap
+
+import (
+	"errors"
+	"testing"
+	"unsafe"
+
+	"github.com/micoo227/robin-hood-hashing/internal/slab"
+)
+
+func TestTenantMap(t *testing.T) {
+	m := must(NewTenantMap[int, string](func(k int, v string) uint64 { return uint64(len(v)) }))
+	slot := uint64(unsafe.Sizeof(element[int, uint32]{})) + slab.SlotSize[tenantEntry[int, string]]()
+	set := func(tenant string, key int, value string) {
+		if err := m.Set(tenant, key, value); err != nil {
+			t.Fatalf("Setting key %d for tenant %q should succeed. Got %v", key, tenant, err)
+		}
+	}
+
+	for i := 0; i < 10; i++ {
+		set("a", i, "xxxx")
+	}
+	set("b", 100, "yy")
+	set("", 200, "")
+
+	if got := m.Usage("a"); got.Count != 10 || got.Bytes != 10*(slot+4) {
+		t.Errorf("Tenant a should be charged 10 elements of %d bytes. Got %+v", slot+4, got)
+	}
+	if got := len(m.UsageByTag()); got != 3 {
+		t.Errorf("Untagged elements should be charged to the empty tenant. Expected 3 tenants, Got %d", got)
+	}
+
+	// Moving a key moves its charge
+	set("b", 0, "zzzzzz")
+	if a, b := m.Usage("a"), m.Usage("b"); a.Count != 9 || b.Count != 2 || b.Bytes != 2*slot+8 {
+		t.Errorf("Resetting a key under another tenant should move its charge. Got %+v, %+v", a, b)
+	}
+	if tenant, ok := m.Tenant(0); !ok || tenant != "b" {
+		t.Errorf("Key 0 should now belong to b. Got %q, %t", tenant, ok)
+	}
+
+	m.Delete(100)
+	m.Delete(0)
+	if _, ok := m.UsageByTag()["b"]; ok {
+		t.Errorf("A tenant with no elements left should be dropped.")
+	}
+
+	m.SetQuota("a", 10*(slot+4))
+	if err := m.Set("a", 50, "xxxx"); err != nil {
+		t.Errorf("A tenant under quota should be able to add an element. Got %v", err)
+	}
+	if err := m.Set("a", 51, "x"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("A tenant at quota should be refused. Got %v", err)
+	}
+	if _, ok := m.Get(51); ok || m.Usage("a").Count != 10 {
+		t.Errorf("A refused Set should change nothing.")
+	}
+	if err := m.Set("a", 50, "xx"); err != nil {
+		t.Errorf("Replacing an element with a smaller one should fit the quota. Got %v", err)
+	}
+	if val, ok := m.Get(50); !ok || val != "xx" {
+		t.Errorf("Key 50 should map to xx. Got %q, %t", val, ok)
+	}
+
+	m.SetQuota("a", 0)
+	if err := m.Set("a", 51, "x"); err != nil {
+		t.Errorf("Removing the quota should lift it. Got %v", err)
+	}
+	if m.Len() != 12 {
+		t.Errorf("Map should contain 12 elements. Found %d", m.Len())
+	}
+}
+
+func TestTenantQuota(t *testing.T) {
+	m := must(NewTenantMap[int, int](nil, WithTenantQuota("a", 3)))
+	for i := 0; i < 3; i++ {
+		if err := m.Set("a", i, i); err != nil {
+			t.Fatalf("Tenant a should fit 3 elements. Got %v", err)
+		}
+	}
+	if err := m.Set("a", 1, 10); err != nil {
+		t.Errorf("Replacing an element at quota should succeed. Got %v", err)
+	}
+	var qe *QuotaError
+	if err := m.Set("a", 3, 3); !errors.As(err, &qe) || qe.Tenant != "a" || !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("A fourth element should fail with a QuotaError for a. Got %v", err)
+	}
+	if err := m.Set("b", 3, 3); err != nil {
+		t.Errorf("Tenants without a quota should be unaffected. Got %v", err)
+	}
+	if m.Len() != 4 {
+		t.Errorf("Map should contain 4 elements. Found %d", m.Len())
+	}
+}
+
+func TestTenantEviction(t *testing.T) {
+	m := must(NewTenantMap[int, int](nil, WithTenantQuota("noisy", 3), WithTenantEviction()))
+	for i := 1; i <= 10; i++ {
+		m.Set("quiet", -i, i)
+	}
+	for i := 0; i < 3; i++ {
+		m.Set("noisy", i, i)
+	}
+	// Key 0 becomes the most recently used, leaving 1 the coldest
+	m.Get(0)
+	if err := m.Set("noisy", 3, 3); err != nil {
+		t.Fatalf("An evicting tenant should make room. Got %v", err)
+	}
+	if _, ok := m.Get(1); ok {
+		t.Errorf("The tenant's coldest entry should have been evicted.")
+	}
+	for _, k := range []int{0, 2, 3} {
+		if _, ok := m.Get(k); !ok {
+			t.Errorf("Key %d should survive the eviction.", k)
+		}
+	}
+
+	for i := 4; i < 1000; i++ {
+		m.Set("noisy", i, i)
+	}
+	if got := m.Usage("noisy").Count; got != 3 {
+		t.Errorf("A noisy tenant should stay at its quota. Got %d elements", got)
+	}
+	if got := m.Usage("quiet").Count; got != 10 {
+		t.Errorf("A noisy tenant should not squeeze out others. Quiet tenant holds %d", got)
+	}
+	if m.Len() != 13 || m.entries.Len() > 13+2 {
+		t.Errorf("Evicted slots should be reused. Found %d elements in %d slots", m.Len(), m.entries.Len())
+	}
+
+	// An element too large for the byte quota on its own can't be made room for
+	b := must(NewTenantMap[int, string](func(k int, v string) uint64 { return uint64(len(v)) }, WithTenantEviction()))
+	b.SetQuota("a", 1000)
+	b.Set("a", 1, "x")
+	if err := b.Set("a", 2, string(make([]byte, 2000))); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("An element larger than the quota should be refused. Got %v", err)
+	}
+	if b.Len() != 0 || len(b.UsageByTag()) != 0 {
+		t.Errorf("Evicting for an element that still doesn't fit empties the tenant. Found %d elements", b.Len())
+	}
+}
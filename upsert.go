@@ -0,0 +1,86 @@
+package rhmap
+
+// Returns the value under key and true if present. Otherwise sets key to
+// value and returns value and false. The key is hashed once.
+func (m *Map[K, V]) GetOrSet(key K, value V) (V, bool) {
+	hash := m.hashKey(key)
+	if val, ok, _ := m.getWithHash(key, hash); ok {
+		return val, true
+	}
+	if !m.rejectsWrites() {
+		m.insertAbsent(key, value, hash)
+	}
+	return value, false
+}
+
+// Returns the value under key, first setting it to fn() if key is absent.
+// fn is only called for absent keys, which suits lazy initialization.
+func (m *Map[K, V]) GetOrCompute(key K, fn func() V) V {
+	hash := m.hashKey(key)
+	if val, ok, _ := m.getWithHash(key, hash); ok {
+		return val
+	}
+	value := fn()
+	if !m.rejectsWrites() {
+		m.insertAbsent(key, value, hash)
+	}
+	return value
+}
+
+// Calls fn with the value under key, or the zero value and false if absent,
+// and stores the value fn returns. If fn returns false, key is deleted
+// instead. Returns the value now under key and whether it is present. The
+// key is hashed and probed once however the element changes.
+func (m *Map[K, V]) Compute(key K, fn func(old V, ok bool) (V, bool)) (V, bool) {
+	hash := m.hashKey(key)
+	if m.readOnly {
+		old, ok, _ := m.getWithHash(key, hash)
+		m.rejectsWrites()
+		return old, ok
+	}
+	old, ok, i := m.getForUpdate(key, hash)
+	value, keep := fn(old, ok)
+	if keep && m.zeroDeletes && isZero(value) {
+		keep = false
+	}
+
+	switch {
+	case ok && keep:
+		m.unshare()
+		m.elements[i].value = value
+		m.accessed(key)
+	case ok:
+		m.deleteWithHash(key, hash)
+		m.maybeShrink()
+		if m.onEvict != nil {
+			m.onEvict(key, old)
+		}
+	case keep:
+		m.insertAbsent(key, value, hash)
+	}
+
+	if !keep {
+		var zeroVal V
+		return zeroVal, false
+	}
+	return value, true
+}
+
+// Inserts a key known to be absent, growing the table first if needed
+func (m *Map[K, V]) insertAbsent(key K, value V, hash uint64) {
+	if m.zeroDeletes && isZero(value) {
+		return
+	}
+	if m.maxEntries > 0 && !m.makeRoom(hash) {
+		return
+	}
+	if m.overloaded() {
+		m.rehashTable()
+	}
+	m.unshare()
+	m.stepRehash()
+	m.noteKey(hash)
+	m.insertWithHash(key, value, hash)
+	m.inserted(key)
+	m.checkFlooding()
+}
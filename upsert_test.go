@@ -0,0 +1,68 @@
+package rhmap
+
+import "testing"
+
+func TestGetOrSet(t *testing.T) {
+	m := must(New[string, int]())
+
+	if val, ok := m.GetOrSet("a", 1); ok || val != 1 {
+		t.Errorf("Absent key should be set. Got %d, %t", val, ok)
+	}
+	if val, ok := m.GetOrSet("a", 2); !ok || val != 1 {
+		t.Errorf("Present key should keep its value. Got %d, %t", val, ok)
+	}
+
+	calls := 0
+	compute := func() int { calls++; return 10 }
+	if m.GetOrCompute("b", compute) != 10 || m.GetOrCompute("b", compute) != 10 || calls != 1 {
+		t.Errorf("GetOrCompute should call fn once, for the absent key. Called %d times", calls)
+	}
+	if m.Len() != 2 {
+		t.Errorf("Map should contain 2 elements. Found %d", m.Len())
+	}
+}
+
+func TestCompute(t *testing.T) {
+	m := must(New[int, int]())
+	incr := func(old int, ok bool) (int, bool) { return old + 1, true }
+
+	for i := 0; i < 1000; i++ {
+		m.Compute(i%100, incr)
+	}
+	for i := 0; i < 100; i++ {
+		if val, ok := m.Get(i); !ok || val != 10 {
+			t.Errorf("Key %d should have been incremented 10 times. Got %d, %t", i, val, ok)
+		}
+	}
+
+	val, ok := m.Compute(5, func(old int, ok bool) (int, bool) { return 0, false })
+	if ok || val != 0 {
+		t.Errorf("Returning false should delete. Got %d, %t", val, ok)
+	}
+	if _, ok := m.Get(5); ok || m.Len() != 99 {
+		t.Errorf("Key 5 should be deleted, leaving 99 elements. Found %d", m.Len())
+	}
+	m.Compute(-1, func(old int, ok bool) (int, bool) {
+		if ok {
+			t.Errorf("fn should see an absent key as absent.")
+		}
+		return 0, false
+	})
+	if m.Len() != 99 {
+		t.Errorf("Declining to set an absent key should change nothing. Found %d", m.Len())
+	}
+}
+
+func TestComputeZeroDeletes(t *testing.T) {
+	m := must(New[string, int](WithZeroDeletes()))
+	decr := func(old int, ok bool) (int, bool) { return old - 1, true }
+
+	m.Set("n", 2)
+	m.Compute("n", decr)
+	if val, ok := m.Compute("n", decr); ok || val != 0 || m.Len() != 0 {
+		t.Errorf("Counting down to zero should delete the counter. Got %d, %t, %d elements", val, ok, m.Len())
+	}
+	if _, ok := m.GetOrSet("z", 0); ok || m.Len() != 0 {
+		t.Errorf("GetOrSet should not store a zero value. Found %d elements", m.Len())
+	}
+}
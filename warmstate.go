@@ -0,0 +1,96 @@
+package rhmap
+
+import (
+	"encoding/gob"
+	"errors"
+	"io"
+)
+
+// Returned by SaveWarmState for a map whose eviction policy keeps no ranking
+var ErrNoRanking = errors.New("rhmap: eviction policy keeps no ranking to save")
+
+// Element of a warm state stream, with the uses an LFU policy counted
+type warmEntry[K comparable, V any] struct {
+	Key   K
+	Value V
+	Uses  uint64
+}
+
+// Evictor whose per-key metadata goes beyond a ranking, for warm state
+type countingEvictor[K comparable] interface {
+	uses(key K) uint64
+	setUses(key K, n uint64)
+}
+
+// Writes the elements of a bounded map to w, coldest first as Coldest
+// returns them, with the use counts of EvictLFU, so that LoadWarmState can
+// rebuild a cache as warm as this one after a restart rather than one that
+// misses on every key. Keys and values are gob-encoded, one element at a
+// time. It returns ErrNoRanking unless the policy ranks its elements.
+func (m *Map[K, V]) SaveWarmState(w io.Writer) error {
+	if _, ok := m.evictor.(rankingEvictor[K]); !ok {
+		return ErrNoRanking
+	}
+	counts, _ := m.evictor.(countingEvictor[K])
+	enc := gob.NewEncoder(w)
+	for _, e := range m.Coldest(int(m.numElements)) {
+		entry := warmEntry[K, V]{Key: e.Key, Value: e.Value}
+		if counts != nil {
+			entry.Uses = counts.uses(e.Key)
+		}
+		if err := enc.Encode(&entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sets the elements written by SaveWarmState, coldest first, so that the
+// map's policy ranks them as the saved map's did, and restores their use
+// counts under EvictLFU. Elements already in the map rank colder than
+// those loaded, and a map smaller than the saved one keeps the hottest.
+// Elements up to a failed read stay loaded.
+func (m *Map[K, V]) LoadWarmState(r io.Reader) error {
+	counts, _ := m.evictor.(countingEvictor[K])
+	dec := gob.NewDecoder(r)
+	for {
+		var entry warmEntry[K, V]
+		if err := dec.Decode(&entry); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		m.Set(entry.Key, entry.Value)
+		if counts != nil && entry.Uses > 0 {
+			counts.setUses(entry.Key, entry.Uses)
+		}
+	}
+}
+
+// Writes the cache's elements to w, least recently used first, for
+// LoadWarmState to restore
+func (c *LRU[K, V]) SaveWarmState(w io.Writer) error {
+	enc := gob.NewEncoder(w)
+	for _, e := range c.Coldest(int(c.Len())) {
+		if err := enc.Encode(&warmEntry[K, V]{Key: e.Key, Value: e.Value}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Adds the elements written by SaveWarmState, least recently used first,
+// so that the cache ranks them as the saved one did. The stream of a
+// bounded Map can be loaded too.
+func (c *LRU[K, V]) LoadWarmState(r io.Reader) error {
+	dec := gob.NewDecoder(r)
+	for {
+		var entry warmEntry[K, V]
+		if err := dec.Decode(&entry); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		c.Add(entry.Key, entry.Value)
+	}
+}
@@ -0,0 +1,80 @@
+package rhmap
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestWarmStateLFU(t *testing.T) {
+	m := must(New[string, int](WithMaxEntries(10), WithEvictionPolicy(EvictLFU)))
+	for i, k := range []string{"a", "b", "c", "d"} {
+		m.Set(k, i)
+		for j := 0; j < 3*i; j++ {
+			m.Get(k)
+		}
+	}
+	var buf bytes.Buffer
+	if err := m.SaveWarmState(&buf); err != nil {
+		t.Fatalf("Expected no error, Got %v", err)
+	}
+
+	// A smaller map keeps the hottest, with their counts
+	warm := must(New[string, int](WithMaxEntries(3), WithEvictionPolicy(EvictLFU)))
+	if err := warm.LoadWarmState(&buf); err != nil {
+		t.Fatalf("Expected no error, Got %v", err)
+	}
+	if _, ok := warm.Get("a"); ok || warm.Len() != 3 {
+		t.Errorf("The coldest element should be left out.")
+	}
+	for i := 0; i < 5; i++ {
+		warm.Set("x", 0)
+		warm.Get("x")
+	}
+	if _, ok := warm.Get("b"); ok {
+		t.Errorf("Loaded counts should rank b coldest.")
+	}
+	if _, ok := warm.Get("d"); !ok {
+		t.Errorf("Loaded counts should keep d over a newer key.")
+	}
+}
+
+func TestWarmStateLRU(t *testing.T) {
+	m := must(New[int, int](WithMaxEntries(10), WithEvictionPolicy(EvictLRU)))
+	for k := 0; k < 5; k++ {
+		m.Set(k, k)
+	}
+	m.Get(0)
+	var buf bytes.Buffer
+	if err := m.SaveWarmState(&buf); err != nil {
+		t.Fatalf("Expected no error, Got %v", err)
+	}
+	saved := buf.Bytes()
+
+	warm := must(New[int, int](WithMaxEntries(10), WithEvictionPolicy(EvictLRU)))
+	if err := warm.LoadWarmState(bytes.NewReader(saved)); err != nil {
+		t.Fatalf("Expected no error, Got %v", err)
+	}
+	if hot := warm.Hottest(1); len(hot) != 1 || hot[0].Key != 0 || warm.Coldest(1)[0].Key != 1 {
+		t.Errorf("Expected the saved recency order, Got %v", warm.Coldest(5))
+	}
+
+	c := must(NewLRU[int, int](3, nil))
+	if err := c.LoadWarmState(bytes.NewReader(saved)); err != nil {
+		t.Fatalf("Expected no error, Got %v", err)
+	}
+	if cold := c.Coldest(3); len(cold) != 3 || cold[0].Key != 3 || cold[2].Key != 0 {
+		t.Errorf("Expected the three most recently used, Got %v", cold)
+	}
+	buf.Reset()
+	if err := c.SaveWarmState(&buf); err != nil {
+		t.Fatalf("Expected no error, Got %v", err)
+	}
+	if err := warm.LoadWarmState(&buf); err != nil || warm.Len() != 5 {
+		t.Errorf("An LRU's warm state should load into a map. Got %v", err)
+	}
+
+	if err := must(New[int, int]()).SaveWarmState(&buf); !errors.Is(err, ErrNoRanking) {
+		t.Errorf("Expected ErrNoRanking, Got %v", err)
+	}
+}
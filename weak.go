@@ -0,0 +1,124 @@
+package rhmap
+
+import (
+	"runtime"
+	"sync"
+	"weak"
+)
+
+// Robin hood hashmap holding its values weakly, for canonicalization and
+// interning caches that shouldn't keep their contents alive. Once nothing
+// else references a value the garbage collector may reclaim it, and a
+// cleanup scheduled with runtime.AddCleanup then deletes its element; until
+// the cleanup runs, the key reads as absent. A WeakValueMap is safe for
+// concurrent use, since cleanups run on a goroutine of their own.
+type WeakValueMap[K comparable, V any] struct {
+	mu    sync.RWMutex
+	table *Map[K, weak.Pointer[V]]
+}
+
+// Element a cleanup deletes once its value is collected. The map is held
+// weakly too, so that pending cleanups don't keep a dropped map alive.
+type weakCleanup[K comparable, V any] struct {
+	m     weak.Pointer[WeakValueMap[K, V]]
+	key   K
+	value weak.Pointer[V]
+}
+
+// Creates an empty weak-value map. Options configure the underlying map;
+// WithZeroDeletes and WithOnEvict have no effect. It returns an error if K
+// can't be encoded.
+func NewWeakValueMap[K comparable, V any](opts ...Option) (*WeakValueMap[K, V], error) {
+	table, err := New[K, weak.Pointer[V]](opts...)
+	if err != nil {
+		return nil, err
+	}
+	table.zeroDeletes = false
+	return &WeakValueMap[K, V]{table: table}, nil
+}
+
+// Sets key to value without keeping value alive, or deletes key if value is
+// nil. Cleanups may never run for values that aren't separately heap
+// allocated, such as globals or tiny pointer-free objects sharing a block, so
+// those elements last until they are deleted or overwritten.
+func (w *WeakValueMap[K, V]) Set(key K, value *V) {
+	if value == nil {
+		w.Delete(key)
+		return
+	}
+	wp := weak.Make(value)
+	w.mu.Lock()
+	w.table.Set(key, wp)
+	w.mu.Unlock()
+	w.track(key, value, wp)
+}
+
+// Schedules key's deletion once value, which wp points to, is collected
+func (w *WeakValueMap[K, V]) track(key K, value *V, wp weak.Pointer[V]) {
+	c := weakCleanup[K, V]{m: weak.Make(w), key: key, value: wp}
+	runtime.AddCleanup(value, removeCollected[K, V], c)
+}
+
+// Deletes c's element if it still holds the collected value, rather than one
+// set under the same key since
+func removeCollected[K comparable, V any](c weakCleanup[K, V]) {
+	w := c.m.Value()
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	hash := w.table.hashKey(c.key)
+	if wp, ok, _ := w.table.getWithHash(c.key, hash); ok && wp == c.value {
+		w.table.deleteWithHash(c.key, hash)
+		w.table.maybeShrink()
+	}
+}
+
+// Returns the value under key, unless it is missing or has been collected
+func (w *WeakValueMap[K, V]) Get(key K) (*V, bool) {
+	w.mu.RLock()
+	wp, ok := w.table.Get(key)
+	w.mu.RUnlock()
+
+	if !ok {
+		return nil, false
+	}
+	v := wp.Value()
+	return v, v != nil
+}
+
+// Returns the value under key if it is still alive, and true, or sets key to
+// value and returns it, and false. Interning through GetOrSet hands every
+// caller the same canonical value for as long as any of them holds it.
+func (w *WeakValueMap[K, V]) GetOrSet(key K, value *V) (*V, bool) {
+	if v, ok := w.Get(key); ok {
+		return v, true
+	}
+	wp := weak.Make(value)
+	w.mu.Lock()
+	hash := w.table.hashKey(key)
+	if cur, ok, _ := w.table.getWithHash(key, hash); ok {
+		if v := cur.Value(); v != nil {
+			w.mu.Unlock()
+			return v, true
+		}
+	}
+	w.table.setWithHash(key, wp, hash)
+	w.mu.Unlock()
+	w.track(key, value, wp)
+	return value, false
+}
+
+func (w *WeakValueMap[K, V]) Delete(key K) {
+	w.mu.Lock()
+	w.table.Delete(key)
+	w.mu.Unlock()
+}
+
+// Returns the number of elements, including collected ones not yet cleaned up
+func (w *WeakValueMap[K, V]) Len() uint64 {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.table.Len()
+}
@@ -0,0 +1,84 @@
+package rhmap
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+// Holds a pointer, so the allocator never packs it into a shared tiny block
+type interned struct {
+	name string
+}
+
+// Collects garbage until cond holds, failing t if it doesn't within a second
+func collectUntil(t *testing.T, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("Cleanups didn't run in time.")
+		}
+		runtime.GC()
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWeakValueMap(t *testing.T) {
+	w := must(NewWeakValueMap[string, interned]())
+	kept := &interned{"kept"}
+	w.Set("kept", kept)
+	w.Set("dropped", &interned{"dropped"})
+	if w.Len() != 2 {
+		t.Errorf("Expected 2 elements, Got %d", w.Len())
+	}
+
+	collectUntil(t, func() bool { return w.Len() == 1 })
+	if _, ok := w.Get("dropped"); ok {
+		t.Errorf("A collected value should read as absent.")
+	}
+	if v, ok := w.Get("kept"); !ok || v != kept {
+		t.Errorf("Expected %p, Got %p %v", kept, v, ok)
+	}
+	runtime.KeepAlive(kept)
+
+	w.Set("kept", nil)
+	if _, ok := w.Get("kept"); ok || w.Len() != 0 {
+		t.Errorf("Setting nil should delete the key.")
+	}
+}
+
+func TestWeakValueMapOverwrite(t *testing.T) {
+	w := must(NewWeakValueMap[int, interned]())
+	replacement := &interned{"new"}
+	w.Set(1, &interned{"old"})
+	w.Set(1, replacement)
+
+	// The old value's cleanup must leave the element that replaced it alone
+	for i := 0; i < 5; i++ {
+		runtime.GC()
+		time.Sleep(time.Millisecond)
+	}
+	if v, ok := w.Get(1); !ok || v != replacement {
+		t.Errorf("Expected the replacement, Got %v %v", v, ok)
+	}
+	runtime.KeepAlive(replacement)
+}
+
+func TestWeakValueMapGetOrSet(t *testing.T) {
+	w := must(NewWeakValueMap[string, interned]())
+	first := &interned{"a"}
+	if v, ok := w.GetOrSet("a", first); ok || v != first {
+		t.Errorf("An absent key should take the value given. Got %p %v", v, ok)
+	}
+	if v, ok := w.GetOrSet("a", &interned{"a"}); !ok || v != first {
+		t.Errorf("Expected the canonical %p, Got %p %v", first, v, ok)
+	}
+	runtime.KeepAlive(first)
+
+	collectUntil(t, func() bool { return w.Len() == 0 })
+	second := &interned{"a"}
+	if v, ok := w.GetOrSet("a", second); ok || v != second {
+		t.Errorf("A collected key should take the new value. Got %p %v", v, ok)
+	}
+	runtime.KeepAlive(second)
+}
@@ -0,0 +1,92 @@
+package rhmap
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Set or delete held by a WriteBuffer, with the key's hash and shard
+type bufferedWrite[K comparable, V any] struct {
+	key     K
+	value   V
+	hash    uint64
+	shard   uint64
+	deleted bool
+}
+
+// Buffer of writes to a ConcurrentMap owned by one goroutine, for
+// telemetry-style workloads where write throughput matters more than read
+// freshness. Writes are appended to the buffer without taking any lock and
+// merged into the map on Flush, or once limit of them are buffered, taking
+// each shard's lock once per merge rather than once per write. Until then,
+// readers of the map, including the buffer's owner, don't see them. A
+// WriteBuffer must not be used concurrently; each goroutine takes its own.
+type WriteBuffer[K comparable, V any] struct {
+	c      *ConcurrentMap[K, V]
+	writes []bufferedWrite[K, V]
+	limit  int
+}
+
+// Returns an empty write buffer that merges into the map every limit
+// writes, or only on Flush if limit is 0
+func (c *ConcurrentMap[K, V]) NewWriteBuffer(limit int) *WriteBuffer[K, V] {
+	return &WriteBuffer[K, V]{c: c, limit: max(limit, 0)}
+}
+
+// Buffers setting key to value
+func (b *WriteBuffer[K, V]) Set(key K, value V) {
+	b.add(bufferedWrite[K, V]{key: key, value: value})
+}
+
+// Buffers deleting key
+func (b *WriteBuffer[K, V]) Delete(key K) {
+	b.add(bufferedWrite[K, V]{key: key, deleted: true})
+}
+
+// Returns the number of writes not yet merged
+func (b *WriteBuffer[K, V]) Len() int {
+	return len(b.writes)
+}
+
+// Merges every buffered write into the map, in the order they were made,
+// and empties the buffer. Writes to different shards become visible shard
+// by shard, not all at once.
+func (b *WriteBuffer[K, V]) Flush() {
+	if len(b.writes) == 0 {
+		return
+	}
+	// Stable, so that writes to the same key keep their order
+	slices.SortStableFunc(b.writes, func(x, y bufferedWrite[K, V]) int {
+		return cmp.Compare(x.shard, y.shard)
+	})
+	for start := 0; start < len(b.writes); {
+		s := &b.c.shards[b.writes[start].shard]
+		end := start
+		s.mu.Lock()
+		for ; end < len(b.writes) && b.writes[end].shard == b.writes[start].shard; end++ {
+			w := &b.writes[end]
+			if !w.deleted {
+				s.table.setWithHash(w.key, w.value, w.hash)
+			} else if s.table.numElements > 0 {
+				s.table.removeWithHash(w.key, w.hash)
+			}
+		}
+		s.mu.Unlock()
+		for ; start < end; start++ {
+			b.c.countOp()
+		}
+	}
+	// Clear the writes so the buffer doesn't keep their keys and values
+	// reachable
+	clear(b.writes)
+	b.writes = b.writes[:0]
+}
+
+func (b *WriteBuffer[K, V]) add(w bufferedWrite[K, V]) {
+	w.hash = b.c.shards[0].table.hashKey(w.key)
+	w.shard = b.c.shardIndex(w.hash)
+	b.writes = append(b.writes, w)
+	if b.limit > 0 && len(b.writes) >= b.limit {
+		b.Flush()
+	}
+}
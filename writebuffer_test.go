@@ -0,0 +1,71 @@
+package rhmap
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestWriteBuffer(t *testing.T) {
+	m := must(NewConcurrent[int, int](4))
+	m.Set(1, 1)
+	b := m.NewWriteBuffer(0)
+
+	for i := 0; i < 100; i++ {
+		b.Set(i, i)
+	}
+	b.Set(5, 50)
+	b.Delete(1)
+	b.Delete(7)
+	b.Set(7, 70)
+	if val, ok := m.Get(1); !ok || val != 1 || m.Len() != 1 {
+		t.Errorf("Buffered writes should stay invisible until flushed. Got %d, %t and %d elements", val, ok, m.Len())
+	}
+	if b.Len() != 104 {
+		t.Errorf("Expected 104 buffered writes. Got %d", b.Len())
+	}
+
+	b.Flush()
+	if b.Len() != 0 {
+		t.Errorf("Flush should empty the buffer. Found %d writes", b.Len())
+	}
+	if m.Len() != 99 {
+		t.Errorf("Map should contain 99 elements. Found %d", m.Len())
+	}
+	for i := 0; i < 100; i++ {
+		want, present := i, i != 1
+		switch i {
+		case 5:
+			want = 50
+		case 7:
+			want = 70
+		}
+		if val, ok := m.Get(i); ok != present || (ok && val != want) {
+			t.Errorf("Writes to key %d should apply in order. Got %d, %t", i, val, ok)
+		}
+	}
+}
+
+func TestWriteBufferLimit(t *testing.T) {
+	m := must(NewConcurrent[int, int](0))
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b := m.NewWriteBuffer(64)
+			for i := g * 1000; i < (g+1)*1000; i++ {
+				b.Set(i, i)
+				if b.Len() >= 64 {
+					t.Errorf("The buffer should merge once it holds its limit. Found %d writes", b.Len())
+				}
+			}
+			b.Flush()
+		}()
+	}
+	wg.Wait()
+
+	if m.Len() != 8000 {
+		t.Errorf("Map should contain 8000 elements. Found %d", m.Len())
+	}
+}